@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// DeviceAudit verifies the complete signature chain of a device, including its
+// timestamp tokens and that it ends with the current signature counter, and
+// writes the audit report.
+func (s *Server) DeviceAudit(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	signingService := s.signingService()
+	signDevice, err := signingService.GetDevice(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+
+	// the counter and the transactions are read together, so that a transaction
+	// being signed does not show up as missing
+	unlock := signDevice.LockChain()
+	counter := signDevice.Counter()
+	transactions := s.transactionStore.GetByDevice(deviceId)
+	unlock()
+	report := domain.AuditChain(deviceId, signDevice.ChainStart(), signDevice.VerifierForKey, func(token []byte, signature []byte) error {
+		_, err := signingService.VerifyTimestamp(token, signature)
+		return err
+	}, transactions)
+	report.CheckEnd(counter)
+
+	WriteAPIResponse(response, http.StatusOK, report)
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORContentType is the media type of CBOR request and response bodies.
+// CBOR bodies have the structure of their JSON counterparts.
+const CBORContentType = "application/cbor"
+
+var (
+	cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+	cborDecMode, _ = cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+)
+
+// isCBORRequest reports whether the request body is CBOR.
+func isCBORRequest(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return err == nil && mediaType == CBORContentType
+}
+
+// acceptsCBOR reports whether the response body is to be encoded as CBOR: if the
+// client accepts CBOR, or sent CBOR and does not ask for JSON.
+func acceptsCBOR(request *http.Request) bool {
+	accept := request.Header.Get("Accept")
+	if strings.Contains(accept, CBORContentType) {
+		return true
+	}
+	return isCBORRequest(request) && !strings.Contains(accept, "json")
+}
+
+// transcodeCBORRequest replaces a CBOR request body by its JSON equivalent, so
+// that it is decoded and validated like any JSON body. If the body is malformed,
+// the error response has been written and false is returned.
+func transcodeCBORRequest(response http.ResponseWriter, request *http.Request) bool {
+	if request.Body == nil || request.Body == http.NoBody {
+		// decodeRequest reports the missing body
+		return true
+	}
+	body, err := io.ReadAll(request.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeBodyTooLarge(response, request)
+		return false
+	}
+	if err != nil {
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body could not be read")
+		return false
+	}
+	var value interface{}
+	if err := cborDecMode.Unmarshal(body, &value); err != nil {
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must be a single CBOR data item")
+		return false
+	}
+	converted, err := cborToJSONValue(value)
+	if err != nil {
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body "+err.Error())
+		return false
+	}
+	transcoded, err := json.Marshal(converted)
+	if err != nil {
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must only contain finite numbers")
+		return false
+	}
+	request.Body = io.NopCloser(bytes.NewReader(transcoded))
+	return true
+}
+
+// cborToJSONValue converts a decoded CBOR data item to the values encoding/json
+// marshals. Maps must have text keys. Byte strings and tags have no JSON
+// equivalent and are rejected.
+func cborToJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, errors.New("must only contain maps with text keys")
+			}
+			convertedItem, err := cborToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[name] = convertedItem
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			convertedItem, err := cborToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = convertedItem
+		}
+		return converted, nil
+	case nil, bool, string, uint64, int64, float64:
+		return v, nil
+	case big.Int:
+		return json.Number(v.String()), nil
+	case *big.Int:
+		return json.Number(v.String()), nil
+	default:
+		return nil, errors.New("must only contain maps, arrays, text, numbers, booleans and null")
+	}
+}
+
+// writeCBORResponse writes a response container as CBOR, with the structure of its JSON encoding.
+func writeCBORResponse(w http.ResponseWriter, r *http.Request, code int, response Response) {
+	body, err := jsonToCBOR(response)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", CBORContentType)
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// jsonToCBOR encodes the JSON representation of value as deterministic CBOR.
+func jsonToCBOR(value interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(jsonToCBORValue(decoded))
+}
+
+// jsonToCBORValue converts JSON numbers to CBOR integers where they are integral,
+// so that large integers keep their precision.
+func jsonToCBORValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonToCBORValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonToCBORValue(item)
+		}
+		return v
+	case json.Number:
+		if integer, ok := new(big.Int).SetString(string(v), 10); ok {
+			return integer
+		}
+		float, _ := v.Float64()
+		return float
+	default:
+		return v
+	}
+}
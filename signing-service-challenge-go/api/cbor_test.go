@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/cose"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postCBORTransaction(t *testing.T, s *Server, body interface{}, accept string) *httptest.ResponseRecorder {
+	encoded, err := cbor.Marshal(body)
+	if err != nil {
+		t.Fatalf("Could not marshal CBOR: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewReader(encoded))
+	req.Header.Set("Content-Type", CBORContentType)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeCBORResponse transcodes a CBOR response to JSON, the way requests are, since
+// metadata values only unmarshal from JSON.
+func decodeCBORResponse(t *testing.T, rec *httptest.ResponseRecorder, response interface{}) {
+	var value interface{}
+	if err := cborDecMode.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("Could not unmarshal CBOR response: %v", err)
+	}
+	value, err := cborToJSONValue(value)
+	if err != nil {
+		t.Fatalf("Could not transcode CBOR response: %v", err)
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+}
+
+func Test_SignTransaction_CBORWithCOSE(t *testing.T) {
+	for _, algorithm := range []domain.SignatureAlgorithm{domain.RSA, domain.ECDSA} {
+		s, device := newServerWithDevice(t, algorithm)
+		first := signTestResponse(t, s, device.Id, "a")
+
+		rec := postCBORTransaction(t, s, map[string]interface{}{
+			"device_id":         device.Id,
+			"client_id":         testClientId,
+			"data_to_be_signed": "b",
+			"format":            "cose",
+			"metadata":          map[string]interface{}{"order_id": uint64(18446744073709551615), "paid": true},
+		}, "")
+
+		if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+			continue
+		}
+		assert.Equal(t, CBORContentType, rec.Header().Get("Content-Type"))
+		resp := struct {
+			Data domain.SignatureResponse `json:"data"`
+		}{}
+		decodeCBORResponse(t, rec, &resp)
+		transaction := resp.Data.Signature
+		assert.Equal(t, 1, transaction.Counter)
+		// integers keep their precision through both transcodings
+		assert.Equal(t, "18446744073709551615", transaction.Metadata["order_id"].String())
+
+		message, err := base64.StdEncoding.DecodeString(resp.Data.COSE)
+		assert.NoError(t, err)
+		keyId, payload, err := cose.Verify(message, s.deviceStore.GetById(device.Id).KeyPair.PublicKey())
+		if !assert.NoError(t, err, algorithm) {
+			continue
+		}
+		assert.Equal(t, device.Id, string(keyId))
+		claims := domain.COSEPayload{}
+		assert.NoError(t, cose.Unmarshal(payload, &claims))
+		expected, err := transaction.COSEPayload()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, claims)
+		assert.Equal(t, first.Signature.Signature, base64.StdEncoding.EncodeToString(claims.LastSignature))
+	}
+}
+
+func Test_SignTransaction_CBORNegotiation(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	body := map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data"}
+
+	rec := postCBORTransaction(t, s, body, "application/json")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
+
+func Test_SignTransaction_MalformedCBOR(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	for _, c := range []struct {
+		name string
+		body interface{}
+		code ErrorCode
+	}{
+		{"integer keys", map[int]string{1: device.Id}, CodeMalformedRequest},
+		{"byte string", map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": []byte("data")}, CodeMalformedRequest},
+		{"unknown field", map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "counter": 7}, CodeMalformedRequest},
+		{"invalid format", map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "xml"}, CodeValidationFailed},
+	} {
+		rec := postCBORTransaction(t, s, c.body, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, c.name)
+		// problems are always JSON
+		assert.Equal(t, c.code, decodeProblem(t, rec).Code, c.name)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewReader([]byte{0xa1, 0x61}))
+	req.Header.Set("Content-Type", CBORContentType)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeMalformedRequest, decodeProblem(t, rec).Code)
+}
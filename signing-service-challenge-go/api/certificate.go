@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// CRLContentType is the media type of a DER encoded CRL.
+const CRLContentType = "application/pkix-crl"
+
+// IssueCertificateRequest selects who certifies the current key of a device.
+type IssueCertificateRequest struct {
+	Issuer domain.CertificateIssuer `json:"issuer"`
+}
+
+// ImportCertificateRequest holds a certificate chain issued by another PKI.
+type ImportCertificateRequest struct {
+	// Certificate holds the PEM encoded certificate of the device key, followed by
+	// the certificates of its issuers.
+	Certificate string `json:"certificate"`
+}
+
+// CertificateSigningRequest holds the subject of a certificate signing request.
+// The common name defaults to the device id.
+type CertificateSigningRequest struct {
+	CommonName         string `json:"common_name,omitempty"`
+	Organization       string `json:"organization,omitempty"`
+	OrganizationalUnit string `json:"organizational_unit,omitempty"`
+	Country            string `json:"country,omitempty"`
+}
+
+// CertificateSigningResponse holds a certificate signing request for a device key.
+type CertificateSigningResponse struct {
+	// CSR is the PEM encoded PKCS #10 certificate signing request.
+	CSR string `json:"csr"`
+}
+
+// CertificateAuthorityResponse holds the certificates of the internal CA.
+type CertificateAuthorityResponse struct {
+	// Certificate is the PEM encoded X.509 root certificate, the only one verifiers need to trust.
+	Certificate string `json:"certificate"`
+	// Intermediate is the PEM encoded X.509 certificate issuing the device certificates and CRLs.
+	Intermediate string `json:"intermediate"`
+}
+
+// IssueDeviceCertificate certifies the current key of a device with a self-signed
+// certificate or one of the internal CA, and writes the device.
+func (s *Server) IssueDeviceCertificate(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	issueReq := &IssueCertificateRequest{}
+	if !decodeRequest(response, request, issueReq) {
+		return
+	}
+	signDevice, err := s.signingService().IssueDeviceCertificate(deviceId, issueReq.Issuer)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, signDevice)
+}
+
+// ImportDeviceCertificate certifies the current key of a device with a certificate
+// chain issued by another PKI, and writes the device.
+func (s *Server) ImportDeviceCertificate(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	importReq := &ImportCertificateRequest{}
+	if !decodeRequest(response, request, importReq) {
+		return
+	}
+	signDevice, err := s.signingService().ImportDeviceCertificate(deviceId, importReq.Certificate)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, signDevice)
+}
+
+// DeviceCertificateRequest writes a certificate signing request for the current key
+// of a device, so that another PKI can certify it.
+func (s *Server) DeviceCertificateRequest(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	csrReq := &CertificateSigningRequest{}
+	if !decodeRequest(response, request, csrReq) {
+		return
+	}
+	subject := pkix.Name{CommonName: csrReq.CommonName}
+	if csrReq.Organization != "" {
+		subject.Organization = []string{csrReq.Organization}
+	}
+	if csrReq.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{csrReq.OrganizationalUnit}
+	}
+	if csrReq.Country != "" {
+		subject.Country = []string{csrReq.Country}
+	}
+	csr, err := s.signingService().DeviceCertificateRequest(deviceId, subject)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, CertificateSigningResponse{CSR: string(csr)})
+}
+
+// CertificateAuthority writes the certificates of the internal CA, to verify device
+// certificates it issued with.
+func (s *Server) CertificateAuthority(response http.ResponseWriter, request *http.Request) {
+	root, intermediate, err := s.signingService().CertificateAuthority()
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, CertificateAuthorityResponse{
+		Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})),
+		Intermediate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Raw})),
+	})
+}
+
+// CertificateRevocationList writes the DER encoded CRL of the internal CA. It is
+// not wrapped in a data field, so that X.509 libraries can consume it directly.
+func (s *Server) CertificateRevocationList(response http.ResponseWriter, request *http.Request) {
+	crl, err := s.signingService().CertificateRevocationList()
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	response.Header().Set("Content-Type", CRLContentType)
+	response.WriteHeader(http.StatusOK)
+	response.Write(crl)
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/cms"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuthority(t *testing.T) *ca.Authority {
+	authority, err := ca.NewAuthority("Test Device CA")
+	if err != nil {
+		t.Fatalf("Could not create certificate authority: %v", err)
+	}
+	return authority
+}
+
+// issueExternalCertificate certifies the key of the device by a CA unknown to the
+// server, as the PKI of a customer would, and returns the PEM encoded chain.
+func issueExternalCertificate(t *testing.T, device *domain.SignatureDevice) string {
+	template, err := device.CertificateTemplate()
+	if err != nil {
+		t.Fatalf("Could not create certificate template: %v", err)
+	}
+	chain, err := newTestAuthority(t).Issue(template, device.KeyPair.PublicKey())
+	if err != nil {
+		t.Fatalf("Could not issue certificate: %v", err)
+	}
+	encoded := []byte{}
+	for _, certificate := range chain {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+	return string(encoded)
+}
+
+func postDeviceCertificate(t *testing.T, s *Server, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBuffer(encoded)))
+	return rec
+}
+
+func signCMS(t *testing.T, s *Server, deviceId string) domain.SignatureResponse {
+	rec := postTransaction(t, s, `{"device_id":"`+deviceId+`","client_id":"`+testClientId+`","data_to_be_signed":"data","format":"cms"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not sign transaction: %s", rec.Body.String())
+	}
+	resp := struct {
+		Data domain.SignatureResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return resp.Data
+}
+
+func Test_IssueDeviceCertificate_SelfSigned(t *testing.T) {
+	for _, algorithm := range []domain.SignatureAlgorithm{domain.RSA, domain.ECDSA} {
+		s, device := newServerWithDevice(t, algorithm)
+
+		rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/certificate", IssueCertificateRequest{Issuer: domain.IssuerSelf})
+
+		if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+			continue
+		}
+		certificates, err := s.deviceStore.GetById(device.Id).Certificates()
+		assert.NoError(t, err)
+		if !assert.Len(t, certificates, 1) {
+			continue
+		}
+		certificate := certificates[0]
+		assert.Equal(t, device.Id, certificate.Subject.CommonName)
+		assert.NoError(t, certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature))
+
+		response := signCMS(t, s, device.Id)
+		der, err := base64.StdEncoding.DecodeString(response.CMS)
+		assert.NoError(t, err)
+		roots := x509.NewCertPool()
+		roots.AddCert(certificate)
+		signature, err := cms.Verify(der, []byte(response.SignedData), roots)
+		if assert.NoError(t, err, algorithm) {
+			assert.Equal(t, certificate.Raw, signature.Certificates[0].Raw)
+			// the signing time is encoded with whole seconds
+			assert.Equal(t, response.Signature.SignedAt.Truncate(time.Second), signature.SigningTime.UTC())
+		}
+		// the signature is detached from the data it covers
+		_, err = cms.Verify(der, []byte(response.SignedData+"x"), roots)
+		assert.ErrorIs(t, err, cms.ErrInvalidSignedData)
+	}
+}
+
+func Test_IssueDeviceCertificate_CA(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	authority := newTestAuthority(t)
+	s.serviceOptions = []service.Option{service.WithCertificateAuthority(authority)}
+
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/certificate", IssueCertificateRequest{Issuer: domain.IssuerCA})
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	response := signCMS(t, s, device.Id)
+	der, err := base64.StdEncoding.DecodeString(response.CMS)
+	assert.NoError(t, err)
+	signature, err := cms.Verify(der, []byte(response.SignedData), authority.Roots())
+	// the chain runs through the intermediate to the root
+	if assert.NoError(t, err) && assert.Len(t, signature.Certificates, 3) {
+		assert.Equal(t, device.Id, signature.Certificates[0].Subject.CommonName)
+	}
+	// without the root of the CA the certificate is not trusted
+	_, err = cms.Verify(der, []byte(response.SignedData), x509.NewCertPool())
+	assert.ErrorIs(t, err, cms.ErrInvalidSignedData)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/certificate-authority", nil))
+	resp := struct {
+		Data CertificateAuthorityResponse `json:"data"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	block, _ := pem.Decode([]byte(resp.Data.Certificate))
+	if assert.NotNil(t, block) {
+		assert.Equal(t, authority.Certificate().Raw, block.Bytes)
+	}
+	block, _ = pem.Decode([]byte(resp.Data.Intermediate))
+	if assert.NotNil(t, block) {
+		assert.Equal(t, authority.Intermediate().Raw, block.Bytes)
+	}
+}
+
+func Test_IssueDeviceCertificate_AuthorityDisabled(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/certificate", IssueCertificateRequest{Issuer: domain.IssuerCA})
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeCertificateAuthorityDisabled, decodeProblem(t, rec).Code)
+	assert.Empty(t, s.deviceStore.GetById(device.Id).Certificate)
+}
+
+func Test_SignTransaction_CMSWithoutCertificate(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data","format":"cms"}`)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeCertificateNotFound, decodeProblem(t, rec).Code)
+	// nothing was signed
+	assert.Equal(t, 0, s.deviceStore.GetById(device.Id).Counter())
+}
+
+func Test_DeviceCertificateRequest(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.RSA)
+
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/csr", CertificateSigningRequest{Organization: "Example Retail", Country: "DE"})
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp := struct {
+		Data CertificateSigningResponse `json:"data"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	block, _ := pem.Decode([]byte(resp.Data.CSR))
+	if !assert.NotNil(t, block) {
+		return
+	}
+	assert.Equal(t, "CERTIFICATE REQUEST", block.Type)
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, request.CheckSignature())
+	assert.Equal(t, device.Id, request.Subject.CommonName)
+	assert.Equal(t, []string{"Example Retail"}, request.Subject.Organization)
+	assert.Equal(t, []string{"DE"}, request.Subject.Country)
+	assert.Equal(t, device.KeyPair.PublicKey(), request.PublicKey)
+}
+
+func Test_ImportDeviceCertificate(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	certificate := issueExternalCertificate(t, device)
+	path := "/api/v0/devices/" + device.Id + "/certificate"
+
+	rec := postDeviceCertificate(t, s, http.MethodPut, path, ImportCertificateRequest{Certificate: certificate})
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, certificate, s.deviceStore.GetById(device.Id).Certificate)
+
+	// the certificate no longer certifies the key after a rotation
+	rotate := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rotate, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+	assert.Equal(t, http.StatusOK, rotate.Code)
+	assert.Empty(t, s.deviceStore.GetById(device.Id).Certificate)
+
+	rec = postDeviceCertificate(t, s, http.MethodPut, path, ImportCertificateRequest{Certificate: certificate})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, "certificate does not certify the public key of the device", problem.Detail)
+
+	rec = postDeviceCertificate(t, s, http.MethodPut, path, ImportCertificateRequest{Certificate: "garbage"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "certificate must hold PEM encoded X.509 certificates", decodeProblem(t, rec).Detail)
+}
+
+func Test_ImportDeviceCertificate_Deactivated(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	certificate := issueExternalCertificate(t, device)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/deactivate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = postDeviceCertificate(t, s, http.MethodPut, "/api/v0/devices/"+device.Id+"/certificate", ImportCertificateRequest{Certificate: certificate})
+
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	assert.Equal(t, CodeDeviceInactive, decodeProblem(t, rec).Code)
+	assert.Empty(t, s.deviceStore.GetById(device.Id).Certificate)
+}
+
+func Test_RotateDeviceKey_CertificateFailure(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	now := time.Now()
+	authority, err := ca.NewAuthority("Test Device CA", ca.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Could not create certificate authority: %v", err)
+	}
+	s.serviceOptions = []service.Option{service.WithCertificateAuthority(authority)}
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/certificate", IssueCertificateRequest{Issuer: domain.IssuerCA})
+	if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		return
+	}
+	keyPair, certificate := device.KeyPair, device.Certificate
+	// the CA can no longer certify the new key
+	now = authority.Intermediate().NotAfter.Add(time.Hour)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	// the device keeps signing with its certified key
+	rotated := s.deviceStore.GetById(device.Id)
+	assert.Equal(t, 0, rotated.KeyVersion)
+	assert.Len(t, rotated.PublicKeys, 1)
+	assert.Equal(t, keyPair, rotated.KeyPair)
+	assert.Equal(t, certificate, rotated.Certificate)
+	assert.Empty(t, revokedSerials(getCRL(t, s, authority)))
+	response := signTestResponse(t, s, device.Id, "after")
+	assert.Equal(t, 0, response.Signature.KeyVersion)
+	verifier, err := device.Verifier()
+	if assert.NoError(t, err) {
+		assert.NoError(t, domain.VerifyTransaction(verifier, response.Signature))
+	}
+}
+
+func getCRL(t *testing.T, s *Server, authority *ca.Authority) *x509.RevocationList {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/certificate-authority/crl", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not get CRL: %s", rec.Body.String())
+	}
+	assert.Equal(t, CRLContentType, rec.Header().Get("Content-Type"))
+	crl, err := x509.ParseRevocationList(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Could not parse CRL: %v", err)
+	}
+	assert.NoError(t, crl.CheckSignatureFrom(authority.Intermediate()))
+	return crl
+}
+
+func revokedSerials(crl *x509.RevocationList) []string {
+	serials := []string{}
+	for _, revoked := range crl.RevokedCertificates {
+		serials = append(serials, revoked.SerialNumber.String())
+	}
+	return serials
+}
+
+func Test_DeviceLifecycle_CA(t *testing.T) {
+	s := NewServer(":8081")
+	authority := newTestAuthority(t)
+	s.serviceOptions = []service.Option{service.WithCertificateAuthority(authority)}
+
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices", map[string]string{"signature_algorithm": "ECC", "label": "register 1"})
+
+	if !assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		return
+	}
+	resp := struct {
+		Data domain.SignatureDevice `json:"data"`
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	deviceId := resp.Data.Id
+	// every new device is certified by the CA, binding its identity to the key
+	chain, err := s.deviceStore.GetById(deviceId).Certificates()
+	if !assert.NoError(t, err) || !assert.Len(t, chain, 2) {
+		return
+	}
+	issued := chain[0]
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(chain[1])
+	_, err = issued.Verify(x509.VerifyOptions{Roots: authority.Roots(), Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err)
+	identity, err := domain.ParseDeviceIdentity(issued)
+	if assert.NoError(t, err) {
+		assert.Equal(t, domain.DeviceIdentity{Id: deviceId, Label: "register 1", Algorithm: domain.ECDSA}, *identity)
+	}
+	assert.Empty(t, revokedSerials(getCRL(t, s, authority)))
+
+	// rotating the key revokes its certificate and certifies the new key
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+deviceId+"/rotate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	chain, err = s.deviceStore.GetById(deviceId).Certificates()
+	if !assert.NoError(t, err) || !assert.Len(t, chain, 2) {
+		return
+	}
+	rotated := chain[0]
+	assert.NotEqual(t, issued.SerialNumber, rotated.SerialNumber)
+	assert.Equal(t, s.deviceStore.GetById(deviceId).KeyPair.PublicKey(), rotated.PublicKey)
+	assert.Equal(t, []string{issued.SerialNumber.String()}, revokedSerials(getCRL(t, s, authority)))
+
+	// deactivating the device revokes the certificate of its last key
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+deviceId+"/deactivate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{issued.SerialNumber.String(), rotated.SerialNumber.String()}, revokedSerials(getCRL(t, s, authority)))
+}
+
+func Test_IssueDeviceCertificate_ConcurrentRotation(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	authority := newTestAuthority(t)
+	s.serviceOptions = []service.Option{service.WithCertificateAuthority(authority)}
+	const requests = 10
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/"+device.Id+"/certificate", IssueCertificateRequest{Issuer: domain.IssuerCA})
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		}()
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		}()
+	}
+	wg.Wait()
+
+	// every certificate but the one of the current key has been revoked
+	chain, err := device.Certificates()
+	if !assert.NoError(t, err) || !assert.Len(t, chain, 2) {
+		return
+	}
+	assert.Equal(t, device.KeyPair.PublicKey(), chain[0].PublicKey)
+	revoked := revokedSerials(getCRL(t, s, authority))
+	assert.Len(t, revoked, 2*requests-1)
+	assert.NotContains(t, revoked, chain[0].SerialNumber.String())
+}
+
+func Test_CertificateRevocationList_AuthorityDisabled(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/certificate-authority/crl", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeCertificateAuthorityDisabled, decodeProblem(t, rec).Code)
+}
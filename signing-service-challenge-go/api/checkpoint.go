@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// CheckpointPublicKeyResponse holds the key checkpoints are signed with.
+type CheckpointPublicKeyResponse struct {
+	// PublicKey is the PEM encoded PKIX public key.
+	PublicKey string `json:"public_key"`
+}
+
+// ListCheckpoints lists all checkpoints of the transparency log ordered by number.
+func (s *Server) ListCheckpoints(response http.ResponseWriter, request *http.Request) {
+	WriteAPIResponse(response, http.StatusOK, s.signingService().ListCheckpoints())
+}
+
+// LatestCheckpoint writes the checkpoint with the highest number.
+func (s *Server) LatestCheckpoint(response http.ResponseWriter, request *http.Request) {
+	checkpoint, err := s.signingService().LatestCheckpoint()
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, checkpoint)
+}
+
+// Checkpoint writes a single checkpoint of the transparency log.
+func (s *Server) Checkpoint(response http.ResponseWriter, request *http.Request) {
+	number, err := strconv.Atoi(PathParam(request, "number"))
+	if err != nil || number < 1 {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"checkpoint number must be a positive number",
+			InvalidParam{Name: "number", Reason: "must be a positive number"})
+		return
+	}
+	checkpoint, err := s.signingService().GetCheckpoint(number)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, checkpoint)
+}
+
+// CheckpointPublicKey writes the public key to verify checkpoint signatures with.
+func (s *Server) CheckpointPublicKey(response http.ResponseWriter, request *http.Request) {
+	publicKey, err := s.signingService().CheckpointPublicKey()
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, CheckpointPublicKeyResponse{PublicKey: string(publicKey)})
+}
+
+// TransactionProof writes the inclusion proof of a transaction in the checkpoint
+// selected by the optional query parameter checkpoint, by default the latest one.
+func (s *Server) TransactionProof(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	errs := fieldErrors{}
+	counter, err := strconv.Atoi(PathParam(request, "counter"))
+	if err != nil || counter < 0 {
+		errs.Add("counter", "must be a non-negative number")
+	}
+	var checkpointNumber *int
+	if value := request.URL.Query().Get("checkpoint"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			errs.Add("checkpoint", "must be a positive number")
+		} else {
+			checkpointNumber = &number
+		}
+	}
+	if err := errs.Err(); err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	proof, err := s.signingService().InclusionProof(deviceId, counter, checkpointNumber)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, proof)
+}
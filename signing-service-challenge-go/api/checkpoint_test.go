@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCheckpointKey(t *testing.T) crypto.KeyPair {
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate checkpoint key: %v", err)
+	}
+	return keyPair
+}
+
+func createTestCheckpoint(t *testing.T, s *Server) *domain.Checkpoint {
+	checkpoint, err := s.signingService().CreateCheckpoint()
+	if err != nil {
+		t.Fatalf("Could not create checkpoint: %v", err)
+	}
+	return checkpoint
+}
+
+func getTransactionProof(t *testing.T, s *Server, deviceId string, path string) (*httptest.ResponseRecorder, domain.InclusionProof) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+deviceId+"/transactions/"+path, nil))
+	resp := struct {
+		Data domain.InclusionProof `json:"data"`
+	}{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+	}
+	return rec, resp.Data
+}
+
+func Test_TransactionProof_VerifiesAcrossDevices(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	other, err := s.signingService().CreateDevice(domain.RSA, "other", "", nil)
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	if _, err := s.signingService().RegisterClientDevice(testClientId, other.Id); err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	checkpointKey := newCheckpointKey(t)
+	s.serviceOptions = []service.Option{service.WithCheckpointKey(checkpointKey)}
+	for _, data := range []string{"a", "b", "c"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+	signTestTransaction(t, s, other.Id, "d")
+
+	first := createTestCheckpoint(t, s)
+	assert.Equal(t, 1, first.Number)
+	assert.Equal(t, 4, first.TreeSize)
+	// nothing was signed since
+	assert.Nil(t, createTestCheckpoint(t, s))
+
+	signTestTransaction(t, s, device.Id, "e")
+	second := createTestCheckpoint(t, s)
+	assert.Equal(t, 2, second.Number)
+	assert.Equal(t, 5, second.TreeSize)
+
+	verifier, err := crypto.NewVerifier(checkpointKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Could not create verifier: %v", err)
+	}
+	for _, transaction := range append(s.transactionStore.GetByDevice(device.Id), s.transactionStore.GetByDevice(other.Id)...) {
+		rec, proof := getTransactionProof(t, s, transaction.DeviceId, fmt.Sprint(transaction.Counter)+"/proof")
+		if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+			continue
+		}
+		assert.Equal(t, second.Number, proof.Checkpoint.Number)
+		assert.NoError(t, proof.Verify(transaction))
+		assert.NoError(t, proof.Checkpoint.Verify(verifier))
+	}
+
+	// a tampered transaction does not match its proof
+	transaction := s.transactionStore.GetByDevice(other.Id)[0]
+	_, proof := getTransactionProof(t, s, other.Id, "0/proof?checkpoint=1")
+	assert.Equal(t, first.Number, proof.Checkpoint.Number)
+	assert.NoError(t, proof.Verify(transaction))
+	tampered := *transaction
+	tampered.Signature = base64.StdEncoding.EncodeToString([]byte("forged"))
+	assert.ErrorIs(t, proof.Verify(&tampered), domain.ErrInvalidInclusionProof)
+	// and a checkpoint cannot claim another root
+	proof.Checkpoint.RootHash = second.RootHash
+	assert.ErrorIs(t, proof.Checkpoint.Verify(verifier), crypto.ErrInvalidSignature)
+}
+
+func Test_TransactionProof_NotAnchored(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	s.serviceOptions = []service.Option{service.WithCheckpointKey(newCheckpointKey(t))}
+	signTestTransaction(t, s, device.Id, "a")
+
+	// before the first checkpoint
+	rec, _ := getTransactionProof(t, s, device.Id, "0/proof")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeTransactionNotAnchored, decodeProblem(t, rec).Code)
+
+	createTestCheckpoint(t, s)
+	signTestTransaction(t, s, device.Id, "b")
+	createTestCheckpoint(t, s)
+
+	// signed after the requested checkpoint
+	rec, _ = getTransactionProof(t, s, device.Id, "1/proof?checkpoint=1")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeTransactionNotAnchored, decodeProblem(t, rec).Code)
+
+	rec, _ = getTransactionProof(t, s, device.Id, "1/proof?checkpoint=3")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeCheckpointNotFound, decodeProblem(t, rec).Code)
+}
+
+func Test_Checkpoint_Timestamped(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	authority, err := tsa.NewLocalAuthority()
+	if err != nil {
+		t.Fatalf("Could not create timestamp authority: %v", err)
+	}
+	s.serviceOptions = []service.Option{
+		service.WithCheckpointKey(newCheckpointKey(t)),
+		service.WithTimestampAuthority(authority, authority.Roots()),
+	}
+	signTestTransaction(t, s, device.Id, "a")
+
+	checkpoint := createTestCheckpoint(t, s)
+
+	token, err := base64.StdEncoding.DecodeString(checkpoint.TimestampToken)
+	assert.NoError(t, err)
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	assert.NoError(t, err)
+	_, err = s.signingService().VerifyTimestamp(token, signature)
+	assert.NoError(t, err)
+}
+
+func Test_CheckpointPublicKey_Disabled(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/checkpoints/public-key", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeCheckpointsDisabled, decodeProblem(t, rec).Code)
+}
+
+func Test_RunCheckpoints_AnchorsNewSignatures(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	s.serviceOptions = []service.Option{service.WithCheckpointKey(newCheckpointKey(t))}
+	signTestTransaction(t, s, device.Id, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.signingService().RunCheckpoints(ctx, time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		rec, _ := getTransactionProof(t, s, device.Id, "0/proof")
+		return rec.Code == http.StatusOK
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// idle ticks do not create empty checkpoints
+	assert.Len(t, s.signingService().ListCheckpoints(), 1)
+}
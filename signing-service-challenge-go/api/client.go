@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+type RegisterClientRequest struct {
+	SerialNumber string   `json:"serial_number"`
+	DeviceIds    []string `json:"device_ids"`
+}
+
+// RegisterClient creates a client registered to the given devices.
+func (s *Server) RegisterClient(response http.ResponseWriter, request *http.Request) {
+	registerReq := &RegisterClientRequest{}
+	if !decodeRequest(response, request, registerReq) {
+		return
+	}
+	client, err := s.signingService().RegisterClient(registerReq.SerialNumber, registerReq.DeviceIds)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusCreated, client)
+}
+
+// Client writes a single client with the devices it is registered to.
+func (s *Server) Client(response http.ResponseWriter, request *http.Request) {
+	clientId, ok := clientIdParam(response, request)
+	if !ok {
+		return
+	}
+	client, err := s.signingService().GetClient(clientId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, client)
+}
+
+// ListClients lists all clients in order of registration. The device_id query
+// parameter restricts the listing to the clients registered to a device.
+func (s *Server) ListClients(response http.ResponseWriter, request *http.Request) {
+	deviceId := request.URL.Query().Get("device_id")
+	if deviceId != "" && !service.ValidId(deviceId) {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"device_id must consist of 1 to 64 letters, digits, _ or -",
+			InvalidParam{Name: "device_id", Reason: "must consist of 1 to 64 letters, digits, _ or -"})
+		return
+	}
+	clients, err := s.signingService().ListClients(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, clients)
+}
+
+// RegisterClientDevice allows a client to sign with another device.
+func (s *Server) RegisterClientDevice(response http.ResponseWriter, request *http.Request) {
+	clientId, deviceId, ok := clientDeviceParams(response, request)
+	if !ok {
+		return
+	}
+	client, err := s.signingService().RegisterClientDevice(clientId, deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, client)
+}
+
+// DeregisterClientDevice revokes the permission of a client to sign with a device.
+func (s *Server) DeregisterClientDevice(response http.ResponseWriter, request *http.Request) {
+	clientId, deviceId, ok := clientDeviceParams(response, request)
+	if !ok {
+		return
+	}
+	client, err := s.signingService().DeregisterClientDevice(clientId, deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, client)
+}
+
+func clientDeviceParams(response http.ResponseWriter, request *http.Request) (string, string, bool) {
+	clientId, ok := clientIdParam(response, request)
+	if !ok {
+		return "", "", false
+	}
+	deviceId := PathParam(request, "device_id")
+	if !service.ValidId(deviceId) {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"device id must consist of 1 to 64 letters, digits, _ or -",
+			InvalidParam{Name: "device_id", Reason: "must consist of 1 to 64 letters, digits, _ or -"})
+		return "", "", false
+	}
+	return clientId, deviceId, true
+}
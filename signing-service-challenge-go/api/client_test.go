@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendClientRequest(t *testing.T, s *Server, method string, path string, body string) (*httptest.ResponseRecorder, *domain.Client) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	if rec.Code >= http.StatusBadRequest {
+		return rec, nil
+	}
+	resp := struct {
+		Data *domain.Client `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return rec, resp.Data
+}
+
+func Test_Client_Lifecycle(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	rec, client := sendClientRequest(t, s, http.MethodPost, "/api/v0/clients",
+		`{"serial_number":"KASSE-0815","device_ids":["`+device.Id+`"]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.NotEmpty(t, client.Id)
+	assert.Equal(t, []string{device.Id}, client.DeviceIds)
+
+	sign := func() *httptest.ResponseRecorder {
+		return postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+client.Id+`","data_to_be_signed":"data"}`)
+	}
+	rec = sign()
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	stored := s.transactionStore.GetByDevice(device.Id)[0]
+	assert.Equal(t, client.Id, stored.ClientId)
+	// the client id is covered by the signature
+	assert.Contains(t, rec.Body.String(), `\"client_id\":\"`+client.Id+`\"`)
+
+	rec, listed := listClients(t, s, "?device_id="+device.Id)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, listed, 2)
+
+	rec, client = sendClientRequest(t, s, http.MethodDelete, "/api/v0/clients/"+client.Id+"/devices/"+device.Id, "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, client.DeviceIds)
+
+	rec = sign()
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, CodeClientNotRegistered, decodeProblem(t, rec).Code)
+	assert.Len(t, s.transactionStore.GetByDevice(device.Id), 1)
+
+	rec, _ = sendClientRequest(t, s, http.MethodPut, "/api/v0/clients/"+client.Id+"/devices/"+device.Id, "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusOK, sign().Code)
+}
+
+func listClients(t *testing.T, s *Server, query string) (*httptest.ResponseRecorder, []*domain.Client) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/clients"+query, nil))
+	resp := struct {
+		Data []*domain.Client `json:"data"`
+	}{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+	}
+	return rec, resp.Data
+}
+
+func Test_Client_Errors(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	cases := map[string]string{
+		`{"serial_number":"","device_ids":["` + device.Id + `"]}`:           "serial_number",
+		`{"serial_number":"KASSE 1","device_ids":["` + device.Id + `"]}`:    "serial_number",
+		`{"serial_number":"KASSE-1","device_ids":[]}`:                       "device_ids",
+		`{"serial_number":"KASSE-1","device_ids":["not.an.id"]}`:            "device_ids",
+		`{"serial_number":"REGISTER-1","device_ids":["` + device.Id + `"]}`: "serial_number",
+	}
+	for body, param := range cases {
+		rec, _ := sendClientRequest(t, s, http.MethodPost, "/api/v0/clients", body)
+		assert.GreaterOrEqual(t, rec.Code, http.StatusBadRequest, body)
+		assert.Equal(t, param, decodeProblem(t, rec).InvalidParams[0].Name, body)
+	}
+
+	rec, _ := sendClientRequest(t, s, http.MethodPost, "/api/v0/clients", `{"serial_number":"KASSE-1","device_ids":["unknown"]}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeDeviceNotFound, decodeProblem(t, rec).Code)
+
+	rec = postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"unknown","data_to_be_signed":"data"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeClientNotFound, decodeProblem(t, rec).Code)
+
+	rec = postTransaction(t, s, `{"device_id":"`+device.Id+`","data_to_be_signed":"data"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "client_id", decodeProblem(t, rec).InvalidParams[0].Name)
+}
+
+func Test_Client_FiscalTransaction(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	other, err := s.signingService().RegisterClient("KASSE-2", []string{device.Id})
+	if err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	fiscalURL := "/api/v0/devices/" + device.Id + "/fiscal-transactions"
+
+	rec, started := postFiscalTransaction(t, s, fiscalURL, `{"client_id":"`+testClientId+`"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, testClientId, started.FiscalTransaction.ClientId)
+	assert.Equal(t, testClientId, started.Signature.ClientId)
+
+	// only the starting client may change the fiscal transaction
+	rec, _ = postFiscalTransaction(t, s, fiscalURL+"/1/update", `{"client_id":"`+other.Id+`"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "client_id", decodeProblem(t, rec).InvalidParams[0].Name)
+
+	if _, err := s.signingService().DeregisterClientDevice(testClientId, device.Id); err != nil {
+		t.Fatalf("Could not deregister client: %v", err)
+	}
+	rec, _ = postFiscalTransaction(t, s, fiscalURL+"/1/finish", `{}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, CodeClientNotRegistered, decodeProblem(t, rec).Code)
+}
@@ -1,196 +1,162 @@
 package api
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/gob"
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"sort"
-	"strconv"
-	"time"
 
-	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 )
 
 type CreateDeviceRequest struct {
 	SignatureAlgorithm domain.SignatureAlgorithm `json:"signature_algorithm"`
 	Label              string                    `json:"label"`
+	EnvelopeVersion    domain.EnvelopeVersion    `json:"envelope_version,omitempty"`
+	Metadata           map[string]string         `json:"metadata,omitempty"`
 }
 
-// REST endpoints ...
-func (s *Server) SignatureDevice(response http.ResponseWriter, request *http.Request) {
-	switch request.Method {
-	case http.MethodPost:
-		if request.Body == nil {
-			WriteErrorResponse(response, http.StatusBadRequest, []string{
-				"request body must not be empty",
-			})
-			return
-		}
-		// decode body
-		createReq := &CreateDeviceRequest{}
-		if err := json.NewDecoder(request.Body).Decode(createReq); err != nil {
-			WriteErrorResponse(response, http.StatusBadRequest, []string{
-				http.StatusText(http.StatusBadRequest),
-			})
-			return
-		}
-		// generate device
-		signDevice, err := domain.NewSignatureDevice(createReq.SignatureAlgorithm, createReq.Label)
-		if err != nil {
-			WriteErrorResponse(response, http.StatusBadRequest, []string{
-				err.Error(),
-			})
-			return
-		}
+// ImportDeviceRequest creates a signature device with an existing private key.
+type ImportDeviceRequest struct {
+	SignatureAlgorithm domain.SignatureAlgorithm `json:"signature_algorithm"`
+	Label              string                    `json:"label"`
+	// PrivateKey is the PEM encoded private key, in PKCS #8 form or in PKCS #1 (RSA)
+	// or SEC 1 (ECC) form.
+	PrivateKey      string                 `json:"private_key"`
+	EnvelopeVersion domain.EnvelopeVersion `json:"envelope_version,omitempty"`
+	Metadata        map[string]string      `json:"metadata,omitempty"`
+	// SignatureCounter and LastSignature continue the signature chain the device had
+	// with its previous signing service: the first transaction carries the counter
+	// and links to the last signature.
+	SignatureCounter *int   `json:"signature_counter,omitempty"`
+	LastSignature    string `json:"last_signature,omitempty"`
+}
 
-		// persist signDevice
-		if s.deviceStore != nil {
-			s.deviceStore.Save(signDevice)
-		} else {
-			WriteInternalError(response)
-			return
-		}
-		// write response
-		WriteAPIResponse(response, http.StatusCreated, signDevice)
-	case http.MethodGet:
-		// get all devices
-		if s.deviceStore != nil {
-			devices := s.deviceStore.GetAll()
-			WriteAPIResponse(response, http.StatusOK, devices)
-		} else {
-			WriteInternalError(response)
-			return
-		}
-	default:
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
-			http.StatusText(http.StatusMethodNotAllowed),
-		})
+type SignTransactionRequest struct {
+	DeviceId string `json:"device_id"`
+	// ClientId names a client registered to the device.
+	ClientId  string `json:"client_id"`
+	Data      string `json:"data_to_be_signed"`
+	Reference string `json:"reference,omitempty"`
+	// UniqueReference rejects the request if another transaction of the device carries the reference.
+	UniqueReference bool                            `json:"unique_reference,omitempty"`
+	Metadata        map[string]domain.MetadataValue `json:"metadata,omitempty"`
+	// Format is json, the default, or one of jws, cose and cms, which additionally
+	// return the transaction as a compact JWS, as a COSE_Sign1 message or a detached
+	// CMS SignedData over the signed data, see domain.SignatureFormat.
+	Format domain.SignatureFormat `json:"format,omitempty"`
+}
+
+// CreateSignatureDevice generates a new signature device with a fresh key pair.
+func (s *Server) CreateSignatureDevice(response http.ResponseWriter, request *http.Request) {
+	// decode body
+	createReq := &CreateDeviceRequest{}
+	if !decodeRequest(response, request, createReq) {
 		return
 	}
+	if s.deviceStore == nil {
+		WriteInternalError(response, request)
+		return
+	}
+	// generate and persist device
+	signDevice, err := s.signingService().CreateDevice(createReq.SignatureAlgorithm, createReq.Label, createReq.EnvelopeVersion, createReq.Metadata)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	// write response
+	WriteAPIResponse(response, http.StatusCreated, signDevice)
 }
 
-func (s *Server) SignTransaction(response http.ResponseWriter, request *http.Request) {
-	if request.Method != http.MethodPost {
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
-			http.StatusText(http.StatusMethodNotAllowed),
-		})
+// ImportSignatureDevice creates a signature device with an existing private key,
+// e.g. to migrate it from another signing service.
+func (s *Server) ImportSignatureDevice(response http.ResponseWriter, request *http.Request) {
+	importReq := &ImportDeviceRequest{}
+	if !decodeRequest(response, request, importReq) {
 		return
 	}
-	// decode body
-	transactionToBeSigned := &domain.Transaction{}
-	if err := json.NewDecoder(request.Body).Decode(transactionToBeSigned); err != nil {
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
-			http.StatusText(http.StatusBadRequest),
-		})
+	options := service.ImportOptions{
+		EnvelopeVersion: importReq.EnvelopeVersion,
+		Metadata:        importReq.Metadata,
+	}
+	if importReq.SignatureCounter != nil || importReq.LastSignature != "" {
+		options.Chain = &domain.ChainStart{LastSignature: importReq.LastSignature}
+		if importReq.SignatureCounter != nil {
+			options.Chain.Counter = *importReq.SignatureCounter
+		}
+	}
+	signDevice, err := s.signingService().ImportDevice(importReq.SignatureAlgorithm, importReq.Label, importReq.PrivateKey, options)
+	if err != nil {
+		writeServiceError(response, request, err)
 		return
 	}
-	if transactionToBeSigned.DeviceId == "" || transactionToBeSigned.Data == "" {
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
-			"device_id and data_to_be_signed must not be empty",
-		})
+	WriteAPIResponse(response, http.StatusCreated, signDevice)
+}
+
+// SignTransaction signs data_to_be_signed with the device given by device_id on
+// behalf of the client given by client_id.
+func (s *Server) SignTransaction(response http.ResponseWriter, request *http.Request) {
+	// decode body
+	if isCBORRequest(request) && !transcodeCBORRequest(response, request) {
 		return
 	}
-	// get device
-	signDevice := s.deviceStore.GetById(transactionToBeSigned.DeviceId)
-	if signDevice == nil {
-		WriteErrorResponse(response, http.StatusNotFound, []string{
-			"device not found",
-		})
+	signReq := &SignTransactionRequest{}
+	if !decodeRequest(response, request, signReq) {
 		return
 	}
-	// get algo from device
-	algo := signDevice.SignatureAlgorithm
-
-	var resp *domain.SignatureResponse
-	var err error
 	// sign data
-	switch algo {
-	case "RSA":
-		rsaSigner := crypto.NewRSASigner()
-		resp, err = s.signData(transactionToBeSigned, signDevice, rsaSigner)
-		if err != nil {
-			WriteErrorResponse(response, http.StatusInternalServerError, []string{
-				http.StatusText(http.StatusInternalServerError),
-			})
-			return
-		}
-		// increment counter
-		s.deviceStore.IncrementCounter(transactionToBeSigned.DeviceId)
-
-	case "ECC":
-		eccSigner := crypto.NewECDSASigner()
-		resp, err = s.signData(transactionToBeSigned, signDevice, eccSigner)
-		if err != nil {
-			WriteErrorResponse(response, http.StatusInternalServerError, []string{
-				err.Error(),
-			})
-			return
-		}
-		// increment counter
-		s.deviceStore.IncrementCounter(transactionToBeSigned.DeviceId)
-
-	default:
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
-			"cannot sign if signature_algorithm not RSA or ECC",
-		})
+	resp, err := s.signingService().SignTransaction(signReq.DeviceId, signReq.ClientId, signReq.Data, service.SignOptions{
+		Reference:       signReq.Reference,
+		UniqueReference: signReq.UniqueReference,
+		Metadata:        signReq.Metadata,
+		Format:          signReq.Format,
+	})
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
 	}
+
 	// response
+	if acceptsCBOR(request) {
+		writeCBORResponse(response, request, http.StatusOK, Response{Data: resp})
+		return
+	}
 	WriteAPIResponse(response, http.StatusOK, resp)
 }
 
-func (s *Server) signData(transaction *domain.Transaction, signDevice *domain.SignatureDevice, signer crypto.Signer) (*domain.SignatureResponse, error) {
-	var lastSignatureEncoded string
-	var err error
-	// check the last signature on device if any
-	deviceTransactions := s.transactionStore.GetByDevice(transaction.DeviceId)
-	if len(deviceTransactions) == 0 {
-		// use encoded device id
-		lastSignatureEncoded = encodeString(transaction.DeviceId)
-	} else {
-		// sort by signedAt
-		// Sort by SignedAt in descending order (latest first)
-		sort.Slice(deviceTransactions, func(i, j int) bool {
-			return deviceTransactions[i].SignedAt.After(deviceTransactions[j].SignedAt)
-		})
-		latestTransaction := deviceTransactions[0]
-		lastSignatureEncoded, err = encodeStruct(latestTransaction)
-		if err != nil {
-			return nil, err
-		}
+// Device writes a single device, including its current signature counter.
+func (s *Server) Device(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
 	}
-	composedString := fmt.Sprintf("%s_%s_%s", strconv.Itoa(signDevice.Counter()), transaction.Data, lastSignatureEncoded)
-	signedData, err := signer.Sign([]byte(composedString))
+	signDevice, err := s.signingService().GetDevice(deviceId)
 	if err != nil {
-		return nil, err
-	}
-	// persist transaction
-	transaction.SignedAt = time.Now()
-	s.transactionStore.Save(transaction)
-	// response
-	resp := &domain.SignatureResponse{
-		Signature:  transaction,
-		SignedData: string(signedData),
+		writeServiceError(response, request, err)
+		return
 	}
-	return resp, nil
+	WriteAPIResponse(response, http.StatusOK, signDevice)
+}
 
+// RotateDeviceKey replaces the key pair of a device. Earlier transactions stay
+// verifiable through the key version recorded on each of them.
+func (s *Server) RotateDeviceKey(response http.ResponseWriter, request *http.Request) {
+	s.changeDevice(response, request, s.signingService().RotateDeviceKey)
 }
 
-func encodeString(s string) string {
-	return base64.StdEncoding.EncodeToString([]byte(s))
+// DeactivateDevice takes a device out of service. It can no longer sign afterwards.
+func (s *Server) DeactivateDevice(response http.ResponseWriter, request *http.Request) {
+	s.changeDevice(response, request, s.signingService().DeactivateDevice)
 }
 
-func encodeStruct(data interface{}) (string, error) {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(data)
+// changeDevice applies a state change of the signing service to a device and writes it.
+func (s *Server) changeDevice(response http.ResponseWriter, request *http.Request, change func(deviceId string) (*domain.SignatureDevice, error)) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	signDevice, err := change(deviceId)
 	if err != nil {
-		return "", err
+		writeServiceError(response, request, err)
+		return
 	}
-	encodedString := base64.StdEncoding.EncodeToString(buffer.Bytes())
-
-	return encodedString, nil
+	WriteAPIResponse(response, http.StatusOK, signDevice)
 }
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+// ExportManifest describes the content of an export archive.
+// It is signed with the current device key and stored next to the exported files.
+type ExportManifest struct {
+	DeviceId         string     `json:"device_id"`
+	KeyVersion       int        `json:"key_version"`
+	CreatedAt        time.Time  `json:"created_at"`
+	From             *time.Time `json:"from,omitempty"`
+	To               *time.Time `json:"to,omitempty"`
+	TransactionCount int        `json:"transaction_count"`
+	// ChainStart is where the exported transactions continue the signature chain:
+	// the counter and the last_signature of the first exported transaction. It is
+	// the start of the chain of the device if no transaction is exported.
+	ChainStart domain.ChainStart    `json:"chain_start"`
+	Files      []ExportManifestFile `json:"files"`
+}
+
+// ExportManifestFile holds the SHA-256 digest of a single file in the archive.
+type ExportManifestFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type exportFile struct {
+	name    string
+	content []byte
+}
+
+// DeviceExport streams a tar archive with the device metadata, its public keys,
+// its transactions and a manifest signed by the device key. The optional query
+// parameters from and to (RFC 3339) restrict the transactions by signed_at.
+func (s *Server) DeviceExport(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	from, err := parseTimeParam(request, "from")
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	to, err := parseTimeParam(request, "to")
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	if from != nil && to != nil && from.After(*to) {
+		writeServiceError(response, request, &service.ValidationError{
+			Message: "from must not be after to",
+			Fields:  []service.FieldError{{Field: "from", Reason: "must not be after to"}},
+		})
+		return
+	}
+	signDevice, err := s.signingService().GetDevice(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+
+	transactions := []*domain.Transaction{}
+	for _, transaction := range s.transactionStore.GetByDevice(deviceId) {
+		if from != nil && transaction.SignedAt.Before(*from) {
+			continue
+		}
+		if to != nil && transaction.SignedAt.After(*to) {
+			continue
+		}
+		transactions = append(transactions, transaction)
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Counter < transactions[j].Counter
+	})
+
+	files, err := s.exportFiles(signDevice, transactions, from, to)
+	if err != nil {
+		WriteInternalError(response, request)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/x-tar")
+	response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", deviceId+".tar"))
+	response.WriteHeader(http.StatusOK)
+	// the status is already sent, so a failing write can only abort the stream
+	_ = writeTar(response, deviceId, files)
+}
+
+// exportFiles assembles the archive content, ending with the manifest and its signature.
+func (s *Server) exportFiles(signDevice *domain.SignatureDevice, transactions []*domain.Transaction, from *time.Time, to *time.Time) ([]exportFile, error) {
+	deviceJSON, err := json.MarshalIndent(signDevice, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	transactionsJSON, err := json.MarshalIndent(transactions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := []exportFile{
+		{name: "device.json", content: deviceJSON},
+	}
+	for _, key := range signDevice.PublicKeys {
+		files = append(files, exportFile{
+			name:    fmt.Sprintf("public_keys/%d.pem", key.Version),
+			content: []byte(key.PublicKey),
+		})
+	}
+	files = append(files, exportFile{name: "transactions.json", content: transactionsJSON})
+
+	manifest := ExportManifest{
+		DeviceId:         signDevice.Id,
+		KeyVersion:       signDevice.KeyVersion,
+		CreatedAt:        time.Now().UTC(),
+		From:             from,
+		To:               to,
+		TransactionCount: len(transactions),
+		ChainStart:       signDevice.ChainStart(),
+		Files:            make([]ExportManifestFile, 0, len(files)),
+	}
+	if len(transactions) > 0 {
+		manifest.ChainStart = domain.ChainStart{
+			Counter:       transactions[0].Counter,
+			LastSignature: transactions[0].LastSignature,
+		}
+	}
+	for _, file := range files {
+		digest := sha256.Sum256(file.content)
+		manifest.Files = append(manifest.Files, ExportManifestFile{
+			Name:   file.name,
+			Size:   len(file.content),
+			SHA256: hex.EncodeToString(digest[:]),
+		})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := signDevice.Signer()
+	if err != nil {
+		return nil, err
+	}
+	manifestSignature, err := signer.Sign(manifestJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(files,
+		exportFile{name: "manifest.json", content: manifestJSON},
+		exportFile{name: "manifest.sig", content: manifestSignature},
+	), nil
+}
+
+func writeTar(w io.Writer, directory string, files []exportFile) error {
+	archive := tar.NewWriter(w)
+	modTime := time.Now()
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    directory + "/" + file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.content)),
+			ModTime: modTime,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func parseTimeParam(request *http.Request, name string) (*time.Time, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, &service.ValidationError{
+			Message: fmt.Sprintf("%s must be an RFC 3339 timestamp", name),
+			Fields:  []service.FieldError{{Field: name, Reason: "must be an RFC 3339 timestamp"}},
+		}
+	}
+	return &parsed, nil
+}
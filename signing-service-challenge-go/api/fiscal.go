@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+type FiscalTransactionRequest struct {
+	// ClientId names the client starting the fiscal transaction. On update and
+	// finish it may be omitted, it must name the same client otherwise.
+	ClientId    string `json:"client_id,omitempty"`
+	ProcessType string `json:"process_type,omitempty"`
+	ProcessData string `json:"process_data,omitempty"`
+}
+
+// StartFiscalTransaction starts a fiscal transaction on the device and signs the start.
+func (s *Server) StartFiscalTransaction(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	fiscalReq := &FiscalTransactionRequest{}
+	if !decodeRequest(response, request, fiscalReq) {
+		return
+	}
+	resp, err := s.signingService().StartFiscalTransaction(deviceId, fiscalReq.ClientId, fiscalReq.process())
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusCreated, resp)
+}
+
+// UpdateFiscalTransaction signs an update of the process of an active fiscal transaction.
+func (s *Server) UpdateFiscalTransaction(response http.ResponseWriter, request *http.Request) {
+	s.continueFiscalTransaction(response, request, s.signingService().UpdateFiscalTransaction)
+}
+
+// FinishFiscalTransaction signs the final process of an active fiscal transaction.
+func (s *Server) FinishFiscalTransaction(response http.ResponseWriter, request *http.Request) {
+	s.continueFiscalTransaction(response, request, s.signingService().FinishFiscalTransaction)
+}
+
+// continueFiscalTransaction applies a signed state change to the fiscal transaction
+// given by the path and writes the result.
+func (s *Server) continueFiscalTransaction(response http.ResponseWriter, request *http.Request, change func(string, int, string, service.FiscalProcess) (*domain.FiscalTransactionResponse, error)) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	number, ok := fiscalTransactionNumberParam(response, request)
+	if !ok {
+		return
+	}
+	fiscalReq := &FiscalTransactionRequest{}
+	if !decodeRequest(response, request, fiscalReq) {
+		return
+	}
+	resp, err := change(deviceId, number, fiscalReq.ClientId, fiscalReq.process())
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, resp)
+}
+
+// FiscalTransaction writes a single fiscal transaction with its log of signed state changes.
+func (s *Server) FiscalTransaction(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	number, ok := fiscalTransactionNumberParam(response, request)
+	if !ok {
+		return
+	}
+	fiscalTransaction, err := s.signingService().GetFiscalTransaction(deviceId, number)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, fiscalTransaction)
+}
+
+// ListFiscalTransactions lists all fiscal transactions of a device ordered by number.
+func (s *Server) ListFiscalTransactions(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	fiscalTransactions, err := s.signingService().ListFiscalTransactions(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, fiscalTransactions)
+}
+
+func (r *FiscalTransactionRequest) process() service.FiscalProcess {
+	return service.FiscalProcess{
+		ProcessType: r.ProcessType,
+		ProcessData: r.ProcessData,
+	}
+}
+
+// fiscalTransactionNumberParam returns the fiscal transaction number path parameter.
+// If it is malformed, the error response has been written and false is returned.
+func fiscalTransactionNumberParam(response http.ResponseWriter, request *http.Request) (int, bool) {
+	number, err := strconv.Atoi(PathParam(request, "number"))
+	if err != nil || number < 1 {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"fiscal transaction number must be a positive number",
+			InvalidParam{Name: "number", Reason: "must be a positive number"})
+		return 0, false
+	}
+	return number, true
+}
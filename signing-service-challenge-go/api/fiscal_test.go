@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func postFiscalTransaction(t *testing.T, s *Server, path string, body string) (*httptest.ResponseRecorder, *domain.FiscalTransactionResponse) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+	if rec.Code >= http.StatusBadRequest {
+		return rec, nil
+	}
+	resp := struct {
+		Data *domain.FiscalTransactionResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return rec, resp.Data
+}
+
+func Test_FiscalTransaction_Lifecycle(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	signTestTransaction(t, s, device.Id, "receipt")
+	fiscalURL := "/api/v0/devices/" + device.Id + "/fiscal-transactions"
+
+	rec, started := postFiscalTransaction(t, s, fiscalURL, `{"client_id":"`+testClientId+`","process_type":"Kassenbeleg-V1"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, 1, started.FiscalTransaction.Number)
+	assert.Equal(t, domain.FiscalActive, started.FiscalTransaction.State)
+	assert.Equal(t, 1, started.Signature.Counter)
+	assert.Equal(t, domain.FiscalStart, started.Signature.FiscalOperation)
+	assert.Equal(t, `{"transaction_number":1,"operation":"start","process_type":"Kassenbeleg-V1","process_data":""}`, started.Signature.Data)
+
+	rec, updated := postFiscalTransaction(t, s, fiscalURL+"/1/update", `{"process_data":"Beleg^5.00:Bar"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 2, updated.Signature.Counter)
+	// the state change is chained to the previous signature of the device
+	assert.Equal(t, started.Signature.Signature, updated.Signature.LastSignature)
+
+	rec, finished := postFiscalTransaction(t, s, fiscalURL+"/1/finish", `{"process_data":"Beleg^7.50:Bar"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	fiscalTransaction := finished.FiscalTransaction
+	assert.Equal(t, domain.FiscalFinished, fiscalTransaction.State)
+	assert.Equal(t, "Kassenbeleg-V1", fiscalTransaction.ProcessType)
+	assert.Equal(t, "Beleg^7.50:Bar", fiscalTransaction.ProcessData)
+	assert.Equal(t, started.Signature.SignedAt, fiscalTransaction.StartedAt)
+	if assert.NotNil(t, fiscalTransaction.FinishedAt) {
+		assert.Equal(t, finished.Signature.SignedAt, *fiscalTransaction.FinishedAt)
+	}
+	assert.Equal(t, []domain.FiscalLogEntry{
+		{Operation: domain.FiscalStart, SignatureCounter: 1, SignedAt: started.Signature.SignedAt},
+		{Operation: domain.FiscalUpdate, SignatureCounter: 2, SignedAt: updated.Signature.SignedAt},
+		{Operation: domain.FiscalFinish, SignatureCounter: 3, SignedAt: finished.Signature.SignedAt},
+	}, fiscalTransaction.Log)
+
+	rec, _ = postFiscalTransaction(t, s, fiscalURL+"/1/update", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, CodeFiscalTransactionFinished, decodeProblem(t, rec).Code)
+
+	// the state changes are part of the signature chain of the device
+	report := domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, s.transactionStore.GetByDevice(device.Id))
+	assert.True(t, report.Valid, report.Issues)
+	assert.Equal(t, 4, report.TransactionCount)
+
+	rec, second := postFiscalTransaction(t, s, fiscalURL, `{"client_id":"`+testClientId+`"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, 2, second.FiscalTransaction.Number)
+}
+
+func Test_FiscalTransaction_Errors(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	fiscalURL := "/api/v0/devices/" + device.Id + "/fiscal-transactions"
+
+	rec, _ := postFiscalTransaction(t, s, fiscalURL+"/1/finish", `{}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeFiscalTransactionNotFound, decodeProblem(t, rec).Code)
+
+	rec, _ = postFiscalTransaction(t, s, fiscalURL+"/first/finish", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "number", decodeProblem(t, rec).InvalidParams[0].Name)
+
+	rec, _ = postFiscalTransaction(t, s, fiscalURL, `{"client_id":"`+testClientId+`","process_type":"Kassenbeleg\n"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "process_type", decodeProblem(t, rec).InvalidParams[0].Name)
+
+	rec, _ = postFiscalTransaction(t, s, "/api/v0/devices/unknown/fiscal-transactions", `{"client_id":"`+testClientId+`"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeDeviceNotFound, decodeProblem(t, rec).Code)
+}
+
+func Test_FiscalTransaction_ConcurrentFinish(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	fiscalURL := "/api/v0/devices/" + device.Id + "/fiscal-transactions"
+	rec, _ := postFiscalTransaction(t, s, fiscalURL, `{"client_id":"`+testClientId+`","process_type":"Kassenbeleg-V1"}`)
+	if !assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		return
+	}
+
+	const requests = 10
+	recs := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recs[i], _ = postFiscalTransaction(t, s, fiscalURL+"/1/finish", `{"process_data":"Beleg^7.50:Bar"}`)
+		}(i)
+	}
+	wg.Wait()
+
+	finished := 0
+	for _, rec := range recs {
+		if rec.Code == http.StatusOK {
+			finished++
+			continue
+		}
+		assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+		assert.Equal(t, CodeFiscalTransactionFinished, decodeProblem(t, rec).Code)
+	}
+	assert.Equal(t, 1, finished)
+	fiscalTransaction := s.fiscalTransactionStore.Get(device.Id, 1)
+	assert.Equal(t, domain.FiscalFinished, fiscalTransaction.State)
+	assert.Len(t, fiscalTransaction.Log, 2)
+	// the rejected requests did not sign anything
+	assert.Len(t, s.transactionStore.GetByDevice(device.Id), 2)
+}
@@ -1,23 +1,34 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func Test_CreateSignatureDevice_EmptyBody(t *testing.T) {
 	// Create a new HTTP request
-	req, err := http.NewRequest(http.MethodPost, "/api/v0/device", nil)
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/devices", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -34,7 +45,7 @@ func Test_CreateSignatureDevice_EmptyBody(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignatureDevice(rec, req)
+	s.CreateSignatureDevice(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -52,7 +63,7 @@ func Test_CreateSignatureDevice_Ok(t *testing.T) {
 		t.Fatalf("Could not marshal JSON: %v", err)
 	}
 	// Create a new HTTP request
-	req, err := http.NewRequest(http.MethodPost, "/api/v0/device", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -70,7 +81,7 @@ func Test_CreateSignatureDevice_Ok(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignatureDevice(rec, req)
+	s.CreateSignatureDevice(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusCreated, rec.Code)
@@ -87,7 +98,7 @@ func Test_CreateSignatureDevice_CannotDecode(t *testing.T) {
 		t.Fatalf("Could not marshal JSON: %v", err)
 	}
 	// Create a new HTTP request
-	req, err := http.NewRequest(http.MethodPost, "/api/v0/device", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -104,7 +115,7 @@ func Test_CreateSignatureDevice_CannotDecode(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignatureDevice(rec, req)
+	s.CreateSignatureDevice(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -121,7 +132,7 @@ func Test_CreateSignatureDevice_EmptyDeviceStore(t *testing.T) {
 		t.Fatalf("Could not marshal JSON: %v", err)
 	}
 	// Create a new HTTP request
-	req, err := http.NewRequest(http.MethodPost, "/api/v0/device", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -137,7 +148,7 @@ func Test_CreateSignatureDevice_EmptyDeviceStore(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignatureDevice(rec, req)
+	s.CreateSignatureDevice(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
@@ -174,7 +185,7 @@ func Test_CreateSignatureDevice_get(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignatureDevice(rec, req)
+	s.ListAllSignatureDevices(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -199,10 +210,11 @@ func Test_SignTransaction_methodnotallowed(t *testing.T) {
 	// Record the response
 	rec := httptest.NewRecorder()
 
-	s.SignTransaction(rec, req)
+	s.Handler().ServeHTTP(rec, req)
 
 	// Validate the status code
 	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
 }
 
 func Test_SignTransaction_BadData1(t *testing.T) {
@@ -277,6 +289,7 @@ func Test_SignTransaction_BadData2(t *testing.T) {
 func Test_SignTransaction_Ok(t *testing.T) {
 	createRaw := map[string]interface{}{
 		"device_id":         "device_id",
+		"client_id":         "client_id",
 		"data_to_be_signed": "data",
 	}
 	// Marshal the JSON data into a byte slice
@@ -294,21 +307,25 @@ func Test_SignTransaction_Ok(t *testing.T) {
 	mockDeviceStoreRepo.On("GetById", "device_id").Return(&domain.SignatureDevice{
 		Id:                 "device_id",
 		SignatureAlgorithm: domain.ECDSA,
-		KeyPair: &crypto.ECCKeyPair{
-			Public:  &ecdsa.PublicKey{},
-			Private: &ecdsa.PrivateKey{},
-		},
-		Label: "device1",
+		KeyPair:            generateECCKeyPair(t),
+		Label:              "device1",
 	})
 	mockDeviceStoreRepo.On("IncrementCounter", "device_id").Return()
 	mockTransactionStoreRepo := &persistence.MockTransactionStoreRepo{}
 	mockTransactionStoreRepo.On("GetByDevice", "device_id").Return([]*domain.Transaction{})
 	mockTransactionStoreRepo.On("Save", mock.Anything).Return()
+	mockClientStoreRepo := &persistence.MockClientStoreRepo{}
+	mockClientStoreRepo.On("GetById", "client_id").Return(&domain.Client{
+		Id:           "client_id",
+		SerialNumber: "REGISTER-1",
+		DeviceIds:    []string{"device_id"},
+	})
 
 	s := &Server{
 		listenAddress:    ":8081",
 		deviceStore:      mockDeviceStoreRepo,
 		transactionStore: mockTransactionStoreRepo,
+		clientStore:      mockClientStoreRepo,
 	}
 
 	// Record the response
@@ -319,3 +336,514 @@ func Test_SignTransaction_Ok(t *testing.T) {
 	// Validate the status code
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
+
+func Test_CreateSignatureDevice_InvalidEnvelopeVersion(t *testing.T) {
+	createRaw := map[string]interface{}{
+		"signature_algorithm": "ECC",
+		"label":               "label",
+		"envelope_version":    "v0",
+	}
+	// Marshal the JSON data into a byte slice
+	jsonData, err := json.Marshal(createRaw)
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	// Create a new HTTP request
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+
+	mockDeviceStoreRepo := &persistence.MockDeviceStoreRepo{}
+	mockTransactionStoreRepo := &persistence.MockTransactionStoreRepo{}
+
+	s := &Server{
+		listenAddress:    ":8081",
+		deviceStore:      mockDeviceStoreRepo,
+		transactionStore: mockTransactionStoreRepo,
+	}
+
+	// Record the response
+	rec := httptest.NewRecorder()
+
+	s.CreateSignatureDevice(rec, req)
+
+	// Validate the status code
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "envelope_version must be legacy, v1 or v2")
+}
+
+func Test_SignTransaction_EnvelopeV1(t *testing.T) {
+	createRaw := map[string]interface{}{
+		"device_id":         "device_id",
+		"client_id":         "client_id",
+		"data_to_be_signed": "a_b",
+	}
+	// Marshal the JSON data into a byte slice
+	jsonData, err := json.Marshal(createRaw)
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	// Create a new HTTP request
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+
+	device, err := domain.NewSignatureDevice(domain.ECDSA, "device1")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	device.Id = "device_id"
+	device.EnvelopeVersion = domain.EnvelopeV1
+	// the device has signed the transaction with counter 0 below
+	device.IncrementCounter()
+
+	mockDeviceStoreRepo := &persistence.MockDeviceStoreRepo{}
+	mockDeviceStoreRepo.On("GetById", "device_id").Return(device)
+	mockDeviceStoreRepo.On("IncrementCounter", "device_id").Return()
+	mockTransactionStoreRepo := &persistence.MockTransactionStoreRepo{}
+	mockTransactionStoreRepo.On("GetByDevice", "device_id").Return([]*domain.Transaction{
+		{DeviceId: "device_id", Counter: 0, Signature: "c2lnMA=="},
+	})
+	mockTransactionStoreRepo.On("Save", mock.Anything).Return()
+	mockClientStoreRepo := &persistence.MockClientStoreRepo{}
+	mockClientStoreRepo.On("GetById", "client_id").Return(&domain.Client{
+		Id:           "client_id",
+		SerialNumber: "REGISTER-1",
+		DeviceIds:    []string{"device_id"},
+	})
+
+	s := &Server{
+		listenAddress:    ":8081",
+		deviceStore:      mockDeviceStoreRepo,
+		transactionStore: mockTransactionStoreRepo,
+		clientStore:      mockClientStoreRepo,
+	}
+
+	// Record the response
+	rec := httptest.NewRecorder()
+
+	s.SignTransaction(rec, req)
+
+	// Validate the status code
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := struct {
+		Data domain.SignatureResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	assert.Equal(t, domain.EnvelopeV1, resp.Data.Signature.EnvelopeVersion)
+	assert.Equal(t, "c2lnMA==", resp.Data.Signature.LastSignature)
+	assert.Equal(t, `{"v":"v1","signature_counter":1,"data_to_be_signed":"a_b","last_signature":"c2lnMA=="}`, resp.Data.SignedData)
+}
+
+func Test_DeviceAudit_ValidChain(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.RSA)
+	for _, data := range []string{"a", "b_c", "d"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/audit", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	resp := struct {
+		Data domain.AuditReport `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	assert.True(t, resp.Data.Valid)
+	assert.Equal(t, 3, resp.Data.TransactionCount)
+	assert.Empty(t, resp.Data.Issues)
+}
+
+func Test_DeviceAudit_TamperedChain(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	for _, data := range []string{"a", "b", "c", "d"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+	transactions := s.transactionStore.GetByDevice(device.Id)
+	for _, transaction := range transactions {
+		switch transaction.Counter {
+		case 1:
+			transaction.Data = "tampered"
+		case 3:
+			transaction.Counter = 2
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/audit", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	resp := struct {
+		Data domain.AuditReport `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	assert.False(t, resp.Data.Valid)
+	kinds := []domain.AuditIssueKind{}
+	for _, issue := range resp.Data.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	assert.Contains(t, kinds, domain.AuditInvalidSignature)
+	assert.Contains(t, kinds, domain.AuditDuplicateCounter)
+}
+
+// hidingTransactionStore hides the transactions with the given counters, as if
+// they had been deleted from the store.
+type hidingTransactionStore struct {
+	persistence.TransactionStore
+	hidden map[int]bool
+}
+
+func (s *hidingTransactionStore) GetByDevice(deviceId string) []*domain.Transaction {
+	transactions := []*domain.Transaction{}
+	for _, transaction := range s.TransactionStore.GetByDevice(deviceId) {
+		if !s.hidden[transaction.Counter] {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions
+}
+
+func Test_DeviceAudit_MissingTransactions(t *testing.T) {
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	cases := []struct {
+		name    string
+		chain   *domain.ChainStart
+		hidden  []int
+		counter int
+	}{
+		{"last", nil, []int{2}, 2},
+		{"all", nil, []int{0, 1, 2}, 0},
+		{"first of imported", &domain.ChainStart{Counter: 41, LastSignature: "cHJldmlvdXM="}, []int{41}, 42},
+		{"last of imported", &domain.ChainStart{Counter: 41, LastSignature: "cHJldmlvdXM="}, []int{43}, 43},
+	}
+	for _, c := range cases {
+		transactionStore := &hidingTransactionStore{TransactionStore: persistence.NewInMemoryTransactionStore(), hidden: map[int]bool{}}
+		s := NewServerWithStores(":8081", persistence.NewInMemoryDeviceStore(), transactionStore, persistence.NewInMemoryFiscalTransactionStore(), persistence.NewInMemoryClientStore(), persistence.NewInMemoryLogStore())
+		body := map[string]interface{}{"signature_algorithm": "ECC", "private_key": encodePKCS8PrivateKey(t, eccKey)}
+		if c.chain != nil {
+			body["signature_counter"] = c.chain.Counter
+			body["last_signature"] = c.chain.LastSignature
+		}
+		_, device := importDevice(t, s, body)
+		client := domain.NewClient("REGISTER-1", []string{device.Id})
+		client.Id = testClientId
+		if err := s.clientStore.Create(client); err != nil {
+			t.Fatalf("Could not register client: %v", err)
+		}
+		for _, data := range []string{"a", "b", "c"} {
+			signTestTransaction(t, s, device.Id, data)
+		}
+		for _, counter := range c.hidden {
+			transactionStore.hidden[counter] = true
+		}
+
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/audit", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code, c.name)
+		resp := struct {
+			Data domain.AuditReport `json:"data"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+		assert.False(t, resp.Data.Valid, c.name)
+		if assert.Len(t, resp.Data.Issues, 1, c.name) {
+			assert.Equal(t, domain.AuditCounterGap, resp.Data.Issues[0].Kind, c.name)
+			assert.Equal(t, c.counter, resp.Data.Issues[0].Counter, c.name)
+		}
+	}
+}
+
+func Test_DeviceAudit_DeviceNotFound(t *testing.T) {
+	mockDeviceStoreRepo := &persistence.MockDeviceStoreRepo{}
+	mockDeviceStoreRepo.On("GetById", "unknown").Return((*domain.SignatureDevice)(nil))
+
+	s := &Server{
+		listenAddress:    ":8081",
+		deviceStore:      mockDeviceStoreRepo,
+		transactionStore: &persistence.MockTransactionStoreRepo{},
+	}
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/unknown/audit", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func Test_DeviceTransactions_Ok(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	for _, data := range []string{"a", "b", "c"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/transactions", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	resp := struct {
+		Data []*domain.Transaction `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	if assert.Len(t, resp.Data, 3) {
+		for counter, transaction := range resp.Data {
+			assert.Equal(t, counter, transaction.Counter)
+		}
+	}
+}
+
+// readExport returns the files of an export archive by their name in the archive
+// directory, and the decoded manifest.
+func readExport(t *testing.T, rec *httptest.ResponseRecorder, deviceId string) (map[string][]byte, ExportManifest) {
+	files := map[string][]byte{}
+	archive := tar.NewReader(rec.Body)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Could not read archive: %v", err)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			t.Fatalf("Could not read archive: %v", err)
+		}
+		files[strings.TrimPrefix(header.Name, deviceId+"/")] = content
+	}
+	manifest := ExportManifest{}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("Could not unmarshal manifest: %v", err)
+	}
+	return files, manifest
+}
+
+func Test_DeviceExport_Ok(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.RSA)
+	for _, data := range []string{"a", "b"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/export", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-tar", rec.Header().Get("Content-Type"))
+
+	files, manifest := readExport(t, rec, device.Id)
+	assert.Equal(t, 2, manifest.TransactionCount)
+	assert.Equal(t, device.ChainStart(), manifest.ChainStart)
+	for _, file := range manifest.Files {
+		digest := sha256.Sum256(files[file.Name])
+		assert.Equal(t, hex.EncodeToString(digest[:]), file.SHA256, file.Name)
+	}
+	verifier, err := device.Verifier()
+	if err != nil {
+		t.Fatalf("Could not create verifier: %v", err)
+	}
+	assert.NoError(t, verifier.Verify(files["manifest.json"], files["manifest.sig"]))
+}
+
+func Test_DeviceExport_InvalidRange(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/export?from=yesterday", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "from must be an RFC 3339 timestamp")
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/export?from=2020-01-02T00:00:00Z&to=2020-01-01T00:00:00Z", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	if assert.Len(t, problem.InvalidParams, 1) {
+		assert.Equal(t, "from", problem.InvalidParams[0].Name)
+		assert.Equal(t, "must not be after to", problem.InvalidParams[0].Reason)
+	}
+}
+
+func Test_DeviceExport_Range(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day, data := range []string{"a", "b", "c", "d", "e"} {
+		signedAt := start.AddDate(0, 0, day)
+		s.serviceOptions = []service.Option{service.WithClock(service.ClockFunc(func() time.Time { return signedAt }))}
+		signTestTransaction(t, s, device.Id, data)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/export?from=2020-01-02T00:00:00Z&to=2020-01-04T00:00:00Z", nil))
+
+	if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+		return
+	}
+	files, manifest := readExport(t, rec, device.Id)
+	transactions := []*domain.Transaction{}
+	if err := json.Unmarshal(files["transactions.json"], &transactions); err != nil {
+		t.Fatalf("Could not unmarshal transactions: %v", err)
+	}
+	if !assert.Len(t, transactions, 3) {
+		return
+	}
+	preceding := s.transactionStore.GetByDevice(device.Id)
+	sort.Slice(preceding, func(i, j int) bool { return preceding[i].Counter < preceding[j].Counter })
+	assert.Equal(t, domain.ChainStart{Counter: 1, LastSignature: preceding[0].Signature}, manifest.ChainStart)
+
+	// the range is audited from the chain start of the manifest
+	report := domain.AuditChain(device.Id, manifest.ChainStart, device.VerifierForKey, nil, transactions)
+	assert.True(t, report.Valid, report.Issues)
+	assert.Equal(t, 3, report.TransactionCount)
+	// but not from the start of the chain of the device
+	report = domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, transactions)
+	assert.False(t, report.Valid)
+}
+
+func Test_Idempotency_ReplaysResponse(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	handler := s.Handler()
+
+	send := func(data string) *httptest.ResponseRecorder {
+		jsonData, err := json.Marshal(map[string]interface{}{
+			"device_id":         device.Id,
+			"client_id":         testClientId,
+			"data_to_be_signed": data,
+		})
+		if err != nil {
+			t.Fatalf("Could not marshal JSON: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatalf("Could not create request: %v", err)
+		}
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("data")
+	second := send("data")
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, device.Counter())
+
+	third := send("other data")
+	assert.Equal(t, http.StatusUnprocessableEntity, third.Code)
+}
+
+func Test_IdempotencyStore_Expiry(t *testing.T) {
+	store := newIdempotencyStore()
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	fingerprint := sha256.Sum256([]byte("request"))
+
+	_, execute := store.begin("key-0", fingerprint)
+	assert.True(t, execute)
+	_, execute = store.begin("key-0", fingerprint)
+	assert.False(t, execute)
+
+	// an expired key is used for a new request
+	now = now.Add(idempotencyKeyTTL + time.Second)
+	_, execute = store.begin("key-0", fingerprint)
+	assert.True(t, execute)
+
+	// expired responses are only swept once the sweep size is reached
+	for i := 1; i < minIdempotencySweepSize-1; i++ {
+		store.begin(fmt.Sprint("key-", i), fingerprint)
+	}
+	assert.Len(t, store.responses, minIdempotencySweepSize-1)
+	now = now.Add(idempotencyKeyTTL + time.Second)
+	store.begin("fresh", fingerprint)
+	assert.Len(t, store.responses, 1)
+	assert.Equal(t, minIdempotencySweepSize, store.sweepSize)
+}
+
+func generateECCKeyPair(t *testing.T) crypto.KeyPair {
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	return keyPair
+}
+
+// testClientId is the id of the client newServerWithDevice registers to its device.
+const testClientId = "test-client"
+
+// newServerWithDevice creates a Server backed by in-memory stores holding a single
+// device and a client registered to it.
+func newServerWithDevice(t *testing.T, algorithm domain.SignatureAlgorithm) (*Server, *domain.SignatureDevice) {
+	s := NewServer(":8081")
+	device, err := domain.NewSignatureDevice(algorithm, "device")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	s.deviceStore.Save(device)
+	client := domain.NewClient("REGISTER-1", []string{device.Id})
+	client.Id = testClientId
+	if err := s.clientStore.Create(client); err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	return s, device
+}
+
+func signTestTransaction(t *testing.T, s *Server, deviceId string, data string) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"device_id":         deviceId,
+		"client_id":         testClientId,
+		"data_to_be_signed": data,
+	})
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.SignTransaction(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not sign transaction: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a POST request without applying it twice.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response that was replayed for a known idempotency key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyTTL = 24 * time.Hour
+	// minIdempotencySweepSize is the number of recorded responses from which on
+	// expired ones are swept.
+	minIdempotencySweepSize = 1024
+)
+
+// idempotentResponse is a recorded response for an idempotency key.
+// done is closed once the first request with the key has completed.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	createdAt   time.Time
+	done        chan struct{}
+	code        int
+	header      http.Header
+	body        []byte
+}
+
+// idempotencyStore records the responses of POST requests by their idempotency key.
+// Expired responses are swept once the number of responses doubled since the last
+// sweep, so that recording a response takes amortized constant time.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	sweepSize int
+	now       func() time.Time
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{
+		responses: map[string]*idempotentResponse{},
+		sweepSize: minIdempotencySweepSize,
+		now:       time.Now,
+	}
+}
+
+// begin returns the recorded response for key, or registers a new one if the
+// key is unknown. The boolean is true if the caller has to execute the request.
+func (s *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if stored, ok := s.responses[key]; ok && !stored.expired(now) {
+		return stored, false
+	}
+	stored := &idempotentResponse{
+		fingerprint: fingerprint,
+		createdAt:   now,
+		done:        make(chan struct{}),
+	}
+	s.responses[key] = stored
+	if len(s.responses) >= s.sweepSize {
+		s.sweep(now)
+	}
+	return stored, true
+}
+
+// sweep removes the expired responses and sets the size of the next sweep.
+func (s *idempotencyStore) sweep(now time.Time) {
+	for storedKey, stored := range s.responses {
+		if stored.expired(now) {
+			delete(s.responses, storedKey)
+		}
+	}
+	s.sweepSize = 2 * len(s.responses)
+	if s.sweepSize < minIdempotencySweepSize {
+		s.sweepSize = minIdempotencySweepSize
+	}
+}
+
+// expired reports whether the key of the response may be used for a new request.
+func (r *idempotentResponse) expired(now time.Time) bool {
+	return now.Sub(r.createdAt) > idempotencyKeyTTL
+}
+
+// forget removes the response for key, so that a retry executes the request again.
+func (s *idempotencyStore) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, key)
+}
+
+// idempotent wraps a handler so that POST requests carrying an Idempotency-Key
+// header are executed at most once. Retries with the same key and body receive
+// the recorded response, retries with a different body are rejected. Server
+// errors are not recorded, so the request can be retried with the same key.
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get(IdempotencyKeyHeader)
+		if s.idempotencyKeys == nil || request.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		body := []byte{}
+		if request.Body != nil {
+			var err error
+			body, err = io.ReadAll(request.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeBodyTooLarge(response, request)
+					return
+				}
+				WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body could not be read")
+				return
+			}
+			request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		fingerprint := sha256.Sum256(append([]byte(request.URL.Path+"\n"), body...))
+
+		stored, execute := s.idempotencyKeys.begin(key, fingerprint)
+		if !execute {
+			if stored.fingerprint != fingerprint {
+				WriteProblem(response, request, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+					"idempotency key was already used for a different request")
+				return
+			}
+			select {
+			case <-stored.done:
+			case <-request.Context().Done():
+				return
+			}
+			if stored.code == 0 {
+				WriteInternalError(response, request)
+				return
+			}
+			for name, values := range stored.header {
+				// the replay is answered under the id of the current request
+				if name != RequestIdHeader {
+					response.Header()[name] = values
+				}
+			}
+			response.Header().Set(IdempotentReplayedHeader, "true")
+			response.WriteHeader(stored.code)
+			response.Write(stored.body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: response, code: http.StatusOK}
+		defer func() {
+			if stored.code == 0 || stored.code >= http.StatusInternalServerError {
+				s.idempotencyKeys.forget(key)
+			}
+			close(stored.done)
+		}()
+		next.ServeHTTP(recorder, request)
+		stored.header = response.Header().Clone()
+		stored.body = recorder.body.Bytes()
+		stored.code = recorder.code
+	})
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePKCS8PrivateKey(t *testing.T, privateKey interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Could not marshal private key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func importDevice(t *testing.T, s *Server, body map[string]interface{}) (*httptest.ResponseRecorder, *domain.SignatureDevice) {
+	rec := postDeviceCertificate(t, s, http.MethodPost, "/api/v0/devices/import", body)
+	if rec.Code != http.StatusCreated {
+		return rec, nil
+	}
+	resp := struct {
+		Data domain.SignatureDevice `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return rec, s.deviceStore.GetById(resp.Data.Id)
+}
+
+func Test_ImportSignatureDevice_KeyFormats(t *testing.T) {
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(eccKey)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, domain.MinRSAKeyBits)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	cases := []struct {
+		name       string
+		algorithm  domain.SignatureAlgorithm
+		privateKey string
+		publicKey  interface{}
+	}{
+		{"ECC SEC 1", domain.ECDSA, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})), &eccKey.PublicKey},
+		{"ECC PKCS #8", domain.ECDSA, encodePKCS8PrivateKey(t, eccKey), &eccKey.PublicKey},
+		{"RSA PKCS #1", domain.RSA, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})), &rsaKey.PublicKey},
+		{"RSA PKCS #8", domain.RSA, encodePKCS8PrivateKey(t, rsaKey), &rsaKey.PublicKey},
+	}
+	for _, c := range cases {
+		s := NewServer(":8081")
+
+		rec, device := importDevice(t, s, map[string]interface{}{"signature_algorithm": c.algorithm, "label": "migrated", "private_key": c.privateKey})
+
+		if !assert.Equal(t, http.StatusCreated, rec.Code, c.name+": "+rec.Body.String()) {
+			continue
+		}
+		assert.Equal(t, c.publicKey, device.KeyPair.PublicKey(), c.name)
+		assert.Equal(t, 0, device.Counter(), c.name)
+		assert.Nil(t, device.ImportedChain, c.name)
+		// the private key is never written back
+		assert.NotContains(t, rec.Body.String(), "PRIVATE KEY", c.name)
+	}
+}
+
+func Test_ImportSignatureDevice_ContinuesChain(t *testing.T) {
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	s := NewServer(":8081")
+	lastSignature := "cHJldmlvdXMgc2lnbmF0dXJl"
+
+	rec, device := importDevice(t, s, map[string]interface{}{
+		"signature_algorithm": "ECC", "private_key": encodePKCS8PrivateKey(t, eccKey),
+		"signature_counter": 41, "last_signature": lastSignature,
+	})
+
+	if !assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		return
+	}
+	assert.Equal(t, &domain.ChainStart{Counter: 41, LastSignature: lastSignature}, device.ImportedChain)
+	client := domain.NewClient("REGISTER-1", []string{device.Id})
+	client.Id = testClientId
+	if err := s.clientStore.Create(client); err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	signTestTransaction(t, s, device.Id, "first")
+	signTestTransaction(t, s, device.Id, "second")
+
+	transactions := s.transactionStore.GetByDevice(device.Id)
+	if !assert.Len(t, transactions, 2) {
+		return
+	}
+	first := transactions[0]
+	if first.Counter != 41 {
+		first = transactions[1]
+	}
+	assert.Equal(t, 41, first.Counter)
+	assert.Equal(t, lastSignature, first.LastSignature)
+	// the signatures verify with the public key of the previous signing service
+	assert.NoError(t, domain.VerifyTransaction(crypto.NewECDSAVerifier(&eccKey.PublicKey), first))
+
+	audit := httptest.NewRecorder()
+	s.Handler().ServeHTTP(audit, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/audit", nil))
+	resp := struct {
+		Data domain.AuditReport `json:"data"`
+	}{}
+	assert.NoError(t, json.Unmarshal(audit.Body.Bytes(), &resp))
+	assert.True(t, resp.Data.Valid, resp.Data.Issues)
+}
+
+func Test_ImportSignatureDevice_Rejected(t *testing.T) {
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	privateKey := encodePKCS8PrivateKey(t, eccKey)
+	cases := []struct {
+		name   string
+		body   map[string]interface{}
+		detail string
+	}{
+		{
+			"algorithm mismatch",
+			map[string]interface{}{"signature_algorithm": "RSA", "private_key": privateKey},
+			"private_key does not match signature_algorithm",
+		},
+		{
+			"weak RSA key",
+			map[string]interface{}{"signature_algorithm": "RSA", "private_key": encodePKCS8PrivateKey(t, weakKey)},
+			"private_key violates the key policy: RSA keys must have 2048 to 4096 bits, got 1024",
+		},
+		{
+			"unsupported curve",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": encodePKCS8PrivateKey(t, p256Key)},
+			"private_key violates the key policy: ECC keys must be on curve P-384, got P-256",
+		},
+		{
+			"not PEM",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": "garbage"},
+			"private_key must be a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key",
+		},
+		{
+			"empty key",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": " "},
+			"private_key must not be empty",
+		},
+		{
+			"counter without last signature",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": privateKey, "signature_counter": 3},
+			"last_signature must not be empty if the chain is continued",
+		},
+		{
+			"negative counter",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": privateKey, "signature_counter": -1, "last_signature": "AA=="},
+			"signature_counter must not be negative",
+		},
+		{
+			"last signature not base64",
+			map[string]interface{}{"signature_algorithm": "ECC", "private_key": privateKey, "last_signature": "not base64"},
+			"last_signature must be base64 encoded",
+		},
+	}
+	for _, c := range cases {
+		s := NewServer(":8081")
+
+		rec, _ := importDevice(t, s, c.body)
+
+		if assert.Equal(t, http.StatusBadRequest, rec.Code, c.name) {
+			problem := decodeProblem(t, rec)
+			assert.Equal(t, CodeValidationFailed, problem.Code, c.name)
+			assert.Equal(t, c.detail, problem.Detail, c.name)
+		}
+		page, err := s.deviceStore.List(persistence.DeviceQuery{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Devices, c.name)
+	}
+}
+
+func Test_ImportSignatureDevice_Anchored(t *testing.T) {
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	s := NewServer(":8081")
+	checkpointKey := newCheckpointKey(t)
+	s.serviceOptions = []service.Option{service.WithCheckpointKey(checkpointKey)}
+	rec, device := importDevice(t, s, map[string]interface{}{
+		"signature_algorithm": "ECC", "private_key": encodePKCS8PrivateKey(t, eccKey),
+		"signature_counter": 100, "last_signature": "cHJldmlvdXM=",
+	})
+	if !assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String()) {
+		return
+	}
+	client := domain.NewClient("REGISTER-1", []string{device.Id})
+	client.Id = testClientId
+	if err := s.clientStore.Create(client); err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	for _, data := range []string{"a", "b", "c"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+
+	checkpoint := createTestCheckpoint(t, s)
+
+	// the chain is anchored from the imported counter on
+	if !assert.NotNil(t, checkpoint) {
+		return
+	}
+	assert.Equal(t, 3, checkpoint.TreeSize)
+	for _, transaction := range s.transactionStore.GetByDevice(device.Id) {
+		rec, proof := getTransactionProof(t, s, device.Id, fmt.Sprint(transaction.Counter)+"/proof")
+		if assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+			assert.NoError(t, proof.Verify(transaction))
+		}
+	}
+}
+
+func Test_ImportSignatureDevice_GeneratedKeysMeetPolicy(t *testing.T) {
+	for _, algorithm := range []domain.SignatureAlgorithm{domain.RSA, domain.ECDSA} {
+		s, device := newServerWithDevice(t, algorithm)
+
+		// keys generated by the service can be migrated to another instance
+		assert.NoError(t, domain.CheckKeyPolicy(device.KeyPair), string(algorithm))
+		privateKey := encodePKCS8PrivateKey(t, device.KeyPair.PrivateKey())
+		rec, _ := importDevice(t, s, map[string]interface{}{"signature_algorithm": algorithm, "private_key": privateKey})
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
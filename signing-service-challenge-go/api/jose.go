@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// JWKContentType is the media type of a single JSON Web Key.
+	JWKContentType = "application/jwk+json"
+	// JWKSetContentType is the media type of a JSON Web Key Set.
+	JWKSetContentType = "application/jwk-set+json"
+)
+
+// JWKSet writes the current public keys of all devices as a JSON Web Key Set.
+// It is not wrapped in a data field, so that JOSE libraries can consume it directly.
+func (s *Server) JWKSet(response http.ResponseWriter, request *http.Request) {
+	set, err := s.signingService().JWKSet()
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	writeJOSE(response, request, JWKSetContentType, set)
+}
+
+// DeviceJWK writes the current public key of a device as a JSON Web Key.
+func (s *Server) DeviceJWK(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	jwk, err := s.signingService().DeviceJWK(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	writeJOSE(response, request, JWKContentType, jwk)
+}
+
+func writeJOSE(response http.ResponseWriter, request *http.Request, contentType string, value interface{}) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	response.Header().Set("Content-Type", contentType)
+	response.WriteHeader(http.StatusOK)
+	response.Write(bytes)
+}
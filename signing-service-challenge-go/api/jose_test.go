@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jose"
+	"github.com/stretchr/testify/assert"
+)
+
+func getJWKSet(t *testing.T, s *Server) *jose.JWKSet {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/jwks.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not get JWKS: %s", rec.Body.String())
+	}
+	assert.Equal(t, JWKSetContentType, rec.Header().Get("Content-Type"))
+	set := &jose.JWKSet{}
+	if err := json.Unmarshal(rec.Body.Bytes(), set); err != nil {
+		t.Fatalf("Could not unmarshal JWKS: %v", err)
+	}
+	return set
+}
+
+func Test_SignTransaction_JWS(t *testing.T) {
+	for _, c := range []struct {
+		algorithm domain.SignatureAlgorithm
+		jwsAlg    string
+	}{
+		{domain.RSA, jose.RS256},
+		{domain.ECDSA, jose.ES384},
+	} {
+		s, device := newServerWithDevice(t, c.algorithm)
+		first := signTestResponse(t, s, device.Id, "a")
+		rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"b","format":"jws"}`)
+		if !assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String()) {
+			continue
+		}
+		resp := struct {
+			Data domain.SignatureResponse `json:"data"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+		// the JWS is only returned on request
+		assert.Empty(t, first.JWS)
+
+		// a relying party picks the key by the kid of the header
+		publicKey, err := getJWKSet(t, s).Key(device.Id + "#0").PublicKey()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		header, payload, err := jose.Verify(resp.Data.JWS, publicKey)
+		if !assert.NoError(t, err, c.algorithm) {
+			continue
+		}
+		assert.Equal(t, c.jwsAlg, header.Algorithm)
+		assert.Equal(t, device.Id+"#0", header.KeyId)
+		claims := domain.JWSPayload{}
+		assert.NoError(t, json.Unmarshal(payload, &claims))
+		transaction := resp.Data.Signature
+		assert.Equal(t, transaction.JWSPayload(), claims)
+		assert.Equal(t, 1, claims.Counter)
+		assert.Equal(t, "b", claims.Data)
+		assert.Equal(t, first.Signature.Signature, claims.LastSignature)
+		assert.Equal(t, transaction.SignedAt.Unix(), claims.IssuedAt)
+	}
+}
+
+func Test_DeviceJWK_FollowsRotation(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	getJWK := func() *jose.JWK {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/jwk", nil))
+		assert.Equal(t, JWKContentType, rec.Header().Get("Content-Type"))
+		jwk := &jose.JWK{}
+		if err := json.Unmarshal(rec.Body.Bytes(), jwk); err != nil {
+			t.Fatalf("Could not unmarshal JWK: %v", err)
+		}
+		return jwk
+	}
+	before := getJWK()
+	assert.Equal(t, device.Id+"#0", before.KeyId)
+	assert.Equal(t, "EC", before.KeyType)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	after := getJWK()
+	assert.Equal(t, device.Id+"#1", after.KeyId)
+	assert.NotEqual(t, before.X, after.X)
+	// the set keeps the previous key next to the current one
+	set := getJWKSet(t, s)
+	assert.Equal(t, []jose.JWK{*before, *after}, set.Keys)
+}
+
+func Test_SignTransaction_JWSAfterRotation(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.RSA)
+	signJWS := func(data string) string {
+		rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"`+data+`","format":"jws"}`)
+		resp := struct {
+			Data domain.SignatureResponse `json:"data"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+		return resp.Data.JWS
+	}
+	before := signJWS("a")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	after := signJWS("b")
+
+	// a relying party picks the key by the kid of the header, before and after the rotation
+	set := getJWKSet(t, s)
+	for _, c := range []struct {
+		jws   string
+		keyId string
+	}{
+		{before, device.Id + "#0"},
+		{after, device.Id + "#1"},
+	} {
+		header, err := jose.ParseHeader(c.jws)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, c.keyId, header.KeyId)
+		jwk := set.Key(header.KeyId)
+		if !assert.NotNil(t, jwk, c.keyId) {
+			continue
+		}
+		publicKey, err := jwk.PublicKey()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		_, _, err = jose.Verify(c.jws, publicKey)
+		assert.NoError(t, err, c.keyId)
+	}
+	// and a JWS does not verify with the key of another version
+	publicKey, err := set.Key(device.Id + "#1").PublicKey()
+	if assert.NoError(t, err) {
+		_, _, err = jose.Verify(before, publicKey)
+		assert.Error(t, err)
+	}
+}
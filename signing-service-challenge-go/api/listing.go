@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// Pagination is sent next to the data of a paged listing. Pass NextCursor as the
+// cursor query parameter to fetch the following page. It is omitted on the last page.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parsePageParams reads the limit and cursor query parameters.
+func parsePageParams(request *http.Request, errs *fieldErrors) (int, string) {
+	query := request.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > persistence.MaxPageLimit {
+			errs.Add("limit", "must be a number between 1 and "+strconv.Itoa(persistence.MaxPageLimit))
+		}
+		limit = parsed
+	}
+	return limit, query.Get("cursor")
+}
+
+// parseDeviceQuery reads the filters, sort order and page of a device listing from
+// the query parameters label, algorithm, status, created_from, created_to,
+// metadata (repeatable, as key:value), sort, limit and cursor.
+func parseDeviceQuery(request *http.Request) (persistence.DeviceQuery, error) {
+	query := request.URL.Query()
+	errs := fieldErrors{}
+	deviceQuery := persistence.DeviceQuery{
+		Filter: persistence.DeviceFilter{
+			Label:     query.Get("label"),
+			Algorithm: domain.SignatureAlgorithm(query.Get("algorithm")),
+			Status:    domain.DeviceStatus(query.Get("status")),
+		},
+		Sort: persistence.DeviceSort(query.Get("sort")),
+	}
+	deviceQuery.Limit, deviceQuery.Cursor = parsePageParams(request, &errs)
+
+	filter := &deviceQuery.Filter
+	if filter.Algorithm != "" && filter.Algorithm != domain.RSA && filter.Algorithm != domain.ECDSA {
+		errs.Add("algorithm", "must be RSA or ECC")
+	}
+	if filter.Status != "" && filter.Status != domain.DeviceActive && filter.Status != domain.DeviceDeactivated {
+		errs.Add("status", "must be active or deactivated")
+	}
+	var err error
+	if filter.CreatedFrom, err = parseTimeParam(request, "created_from"); err != nil {
+		errs.Add("created_from", "must be an RFC 3339 timestamp")
+	}
+	if filter.CreatedTo, err = parseTimeParam(request, "created_to"); err != nil {
+		errs.Add("created_to", "must be an RFC 3339 timestamp")
+	}
+	filter.Metadata = parseMetadataParam(request, &errs)
+	return deviceQuery, errs.Err()
+}
+
+// parseMetadataParam reads the repeatable metadata query parameter given as key:value.
+func parseMetadataParam(request *http.Request, errs *fieldErrors) map[string]string {
+	var metadata map[string]string
+	for _, tag := range request.URL.Query()["metadata"] {
+		key, value, found := strings.Cut(tag, ":")
+		if !found || key == "" {
+			errs.Add("metadata", "must be given as key:value")
+			continue
+		}
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// ListSignatureDevices writes a page of the signature devices matching the query parameters.
+func (s *Server) ListSignatureDevices(response http.ResponseWriter, request *http.Request) {
+	deviceQuery, err := parseDeviceQuery(request)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	page, err := s.signingService().ListDevices(deviceQuery)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WritePageResponse(response, http.StatusOK, page.Devices, Pagination{
+		Limit:      persistence.NormalizeLimit(deviceQuery.Limit),
+		NextCursor: page.NextCursor,
+	})
+}
+
+// ListAllSignatureDevices writes all signature devices unpaged. It serves the
+// legacy route, new clients use ListSignatureDevices.
+func (s *Server) ListAllSignatureDevices(response http.ResponseWriter, request *http.Request) {
+	if s.deviceStore == nil {
+		WriteInternalError(response, request)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, s.deviceStore.GetAll())
+}
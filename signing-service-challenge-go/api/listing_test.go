@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/stretchr/testify/assert"
+)
+
+type devicePageResponse struct {
+	Data       []*domain.SignatureDevice `json:"data"`
+	Pagination Pagination                `json:"pagination"`
+}
+
+func newServerWithDevices(t *testing.T, labels ...string) *Server {
+	s := NewServer(":8081")
+	for _, label := range labels {
+		device, err := domain.NewSignatureDevice(domain.ECDSA, label)
+		if err != nil {
+			t.Fatalf("Could not create device: %v", err)
+		}
+		s.deviceStore.Save(device)
+	}
+	return s
+}
+
+func listDevices(t *testing.T, s *Server, query string) (*httptest.ResponseRecorder, devicePageResponse) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices?"+query, nil))
+	page := devicePageResponse{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+	}
+	return rec, page
+}
+
+func Test_ListSignatureDevices_Pages(t *testing.T) {
+	s := newServerWithDevices(t, "e", "c", "a", "d", "b")
+
+	labels := []string{}
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		rec, page := listDevices(t, s, "sort=label&limit=2&cursor="+url.QueryEscape(cursor))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 2, page.Pagination.Limit)
+		for _, device := range page.Data {
+			labels = append(labels, device.Label)
+		}
+		cursor = page.Pagination.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, labels)
+}
+
+func Test_ListSignatureDevices_Filters(t *testing.T) {
+	s := newServerWithDevices(t, "Kasse 1", "Kasse 2", "Backoffice")
+	tagged, err := domain.NewSignatureDevice(domain.RSA, "kasse 3")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	tagged.Metadata = map[string]string{"store": "berlin", "lane": "3"}
+	s.deviceStore.Save(tagged)
+
+	_, page := listDevices(t, s, "label=KASSE&sort=-label")
+	assert.Len(t, page.Data, 3)
+	assert.Equal(t, "kasse 3", page.Data[0].Label)
+	assert.Empty(t, page.Pagination.NextCursor)
+
+	_, page = listDevices(t, s, "algorithm=RSA")
+	assert.Len(t, page.Data, 1)
+
+	_, page = listDevices(t, s, "metadata=store:berlin&metadata=lane:3")
+	assert.Len(t, page.Data, 1)
+
+	_, page = listDevices(t, s, "metadata=store:berlin&metadata=lane:4")
+	assert.Empty(t, page.Data)
+
+	_, page = listDevices(t, s, "created_to=2000-01-01T00:00:00Z")
+	assert.Empty(t, page.Data)
+}
+
+func Test_ListSignatureDevices_InvalidParams(t *testing.T) {
+	s := newServerWithDevices(t, "a")
+
+	// a cursor issued for another sort order must not be accepted
+	createdAtCursor := persistence.EncodeCursor(string(persistence.SortCreatedAtAsc), "a", "a")
+	cases := map[string]string{
+		"limit=0":                              "limit",
+		"limit=501":                            "limit",
+		"sort=id":                              "sort",
+		"status=broken":                        "status",
+		"algorithm=DSA":                        "algorithm",
+		"created_from=yesterday":               "created_from",
+		"metadata=store":                       "metadata",
+		"cursor=garbage":                       "cursor",
+		"sort=label&cursor=" + createdAtCursor: "cursor",
+	}
+	for query, param := range cases {
+		rec, _ := listDevices(t, s, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Equal(t, param, decodeProblem(t, rec).InvalidParams[0].Name, query)
+	}
+}
+
+func Test_CreateDeviceRequest_ValidateMetadata(t *testing.T) {
+	valid := &CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Metadata: map[string]string{"store.id": "berlin-1"}}
+	assert.NoError(t, valid.Validate())
+
+	invalidKey := &CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Metadata: map[string]string{"store id": "berlin"}}
+	assert.Error(t, invalidKey.Validate())
+
+	longValue := &CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Metadata: map[string]string{"note": strings.Repeat("a", MaxMetadataValueLength+1)}}
+	assert.Error(t, longValue.Validate())
+
+	tooMany := &CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Metadata: map[string]string{}}
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany.Metadata[strings.Repeat("k", i+1)] = "v"
+	}
+	assert.Error(t, tooMany.Validate())
+}
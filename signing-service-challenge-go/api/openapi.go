@@ -0,0 +1,19 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPISpec is the OpenAPI 3 document describing all endpoints of the Server.
+// The contract tests run every handler against it, so it must be updated with them.
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+// OpenAPI writes the OpenAPI document of the service.
+func (s *Server) OpenAPI(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/yaml")
+	response.WriteHeader(http.StatusOK)
+	response.Write(OpenAPISpec)
+}
@@ -0,0 +1,395 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/stretchr/testify/assert"
+)
+
+// contractCase is a request against the Server and the status code it must produce.
+type contractCase struct {
+	name   string
+	method string
+	path   string
+	body   interface{}
+	header map[string]string
+	status int
+}
+
+func Test_OpenAPI_Contract(t *testing.T) {
+	openapi3filter.RegisterBodyDecoder("application/x-tar", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(JWKContentType, openapi3filter.RegisteredBodyDecoder("application/json"))
+	openapi3filter.RegisterBodyDecoder(JWKSetContentType, openapi3filter.RegisteredBodyDecoder("application/json"))
+	openapi3filter.RegisterBodyDecoder(CRLContentType, openapi3filter.FileBodyDecoder)
+	ctx := context.Background()
+
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	if err != nil {
+		t.Fatalf("Could not load OpenAPI document: %v", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Could not create router: %v", err)
+	}
+
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	signTestTransaction(t, s, device.Id, "data")
+	signature := s.transactionStore.GetByDevice(device.Id)[0]
+	s.serviceOptions = []service.Option{service.WithCheckpointKey(newCheckpointKey(t)), service.WithCertificateAuthority(newTestAuthority(t))}
+	if _, err := s.signingService().CreateCheckpoint(); err != nil {
+		t.Fatalf("Could not create checkpoint: %v", err)
+	}
+	deactivated, err := domain.NewSignatureDevice(domain.RSA, "deactivated")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	s.deviceStore.Save(deactivated)
+	externalCertificate := issueExternalCertificate(t, device)
+	importedKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	deviceURL := "/api/v0/devices/" + device.Id
+	deactivatedURL := "/api/v0/devices/" + deactivated.Id
+	clientURL := "/api/v0/clients/" + testClientId
+
+	cases := []contractCase{
+		{name: "health", method: http.MethodGet, path: "/api/v0/health", status: http.StatusOK},
+		{name: "openapi", method: http.MethodGet, path: "/api/v0/openapi.yaml", status: http.StatusOK},
+		{name: "list devices", method: http.MethodGet, path: "/api/v0/devices", status: http.StatusOK},
+		{
+			name: "list devices filtered", method: http.MethodGet,
+			path:   "/api/v0/devices?label=DEACT&algorithm=RSA&status=active&created_from=2020-01-01T00:00:00Z&sort=-label&limit=1",
+			status: http.StatusOK,
+		},
+		{name: "list devices invalid cursor", method: http.MethodGet, path: "/api/v0/devices?cursor=garbage", status: http.StatusBadRequest},
+		{name: "list devices legacy", method: http.MethodGet, path: "/api/v0/device", status: http.StatusOK},
+		{
+			name: "create device legacy", method: http.MethodPost, path: "/api/v0/device",
+			body:   map[string]interface{}{"signature_algorithm": "RSA"},
+			status: http.StatusCreated,
+		},
+		{
+			name: "create device", method: http.MethodPost, path: "/api/v0/devices",
+			body: map[string]interface{}{
+				"signature_algorithm": "ECC", "label": "register", "envelope_version": "legacy",
+				"metadata": map[string]string{"store": "berlin-1"},
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "create device invalid algorithm", method: http.MethodPost, path: "/api/v0/devices",
+			body:   map[string]interface{}{"signature_algorithm": "DSA"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "create device unknown field", method: http.MethodPost, path: "/api/v0/devices",
+			body:   map[string]interface{}{"signature_algorithm": "ECC", "signature_counter": 7},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "create device too large", method: http.MethodPost, path: "/api/v0/devices",
+			body:   map[string]interface{}{"signature_algorithm": "ECC", "label": strings.Repeat("a", MaxRequestBodyBytes)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "import device", method: http.MethodPost, path: "/api/v0/devices/import",
+			body: map[string]interface{}{
+				"signature_algorithm": "ECC", "label": "migrated", "private_key": encodePKCS8PrivateKey(t, importedKey),
+				"signature_counter": 41, "last_signature": "cHJldmlvdXM=",
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "import device algorithm mismatch", method: http.MethodPost, path: "/api/v0/devices/import",
+			body:   map[string]interface{}{"signature_algorithm": "RSA", "private_key": encodePKCS8PrivateKey(t, importedKey)},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "sign transaction", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "reference": "R-1"},
+			header: map[string]string{IdempotencyKeyHeader: "contract"},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction replayed", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "reference": "R-1"},
+			header: map[string]string{IdempotencyKeyHeader: "contract"},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction reused idempotency key", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "other"},
+			header: map[string]string{IdempotencyKeyHeader: "contract"},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "sign transaction with metadata", method: http.MethodPost, path: "/api/v0/transaction",
+			body: map[string]interface{}{
+				"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "reference": "R-2", "unique_reference": true,
+				"metadata": map[string]interface{}{"order_id": 4711, "paid": true, "cashier": "anna"},
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction as jws", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "jws"},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction as cms without certificate", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "cms"},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "create csr", method: http.MethodPost, path: deviceURL + "/csr",
+			body:   map[string]interface{}{"organization": "Example Retail", "country": "DE"},
+			status: http.StatusOK,
+		},
+		{
+			name: "create csr invalid country", method: http.MethodPost, path: deviceURL + "/csr",
+			body:   map[string]interface{}{"country": "Germany"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "import certificate", method: http.MethodPut, path: deviceURL + "/certificate",
+			body:   map[string]interface{}{"certificate": externalCertificate},
+			status: http.StatusOK,
+		},
+		{
+			name: "import certificate of another key", method: http.MethodPut, path: deactivatedURL + "/certificate",
+			body:   map[string]interface{}{"certificate": externalCertificate},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "issue self-signed certificate", method: http.MethodPost, path: deviceURL + "/certificate",
+			body:   map[string]interface{}{"issuer": "self"},
+			status: http.StatusOK,
+		},
+		{
+			name: "issue ca certificate", method: http.MethodPost, path: deviceURL + "/certificate",
+			body:   map[string]interface{}{"issuer": "ca"},
+			status: http.StatusOK,
+		},
+		{
+			name: "issue certificate invalid issuer", method: http.MethodPost, path: deviceURL + "/certificate",
+			body:   map[string]interface{}{"issuer": "acme"},
+			status: http.StatusBadRequest,
+		},
+		{name: "get certificate authority", method: http.MethodGet, path: "/api/v0/certificate-authority", status: http.StatusOK},
+		{name: "get crl", method: http.MethodGet, path: "/api/v0/certificate-authority/crl", status: http.StatusOK},
+		{
+			name: "sign transaction as cms", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "cms"},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction as cose", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "cose"},
+			status: http.StatusOK,
+		},
+		{
+			name: "sign transaction invalid format", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "format": "xml"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "sign transaction duplicate reference", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": device.Id, "client_id": testClientId, "data_to_be_signed": "data", "reference": "R-2", "unique_reference": true},
+			status: http.StatusConflict,
+		},
+		{
+			name: "sign transaction unknown device", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": "unknown", "client_id": testClientId, "data_to_be_signed": "data"},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "search transactions", method: http.MethodGet,
+			path:   "/api/v0/transactions?reference=R-1&label=dev&signed_from=2020-01-01T00:00:00Z&counter_from=0&counter_to=5&sort=-signed_at&limit=1",
+			status: http.StatusOK,
+		},
+		{name: "search transactions invalid counter", method: http.MethodGet, path: "/api/v0/transactions?counter_from=-1", status: http.StatusBadRequest},
+		{name: "export transactions csv", method: http.MethodGet, path: "/api/v0/transactions/export?format=csv&device_id=" + device.Id, status: http.StatusOK},
+		{name: "export transactions ndjson", method: http.MethodGet, path: "/api/v0/transactions/export?format=ndjson", status: http.StatusOK},
+		{name: "export transactions invalid format", method: http.MethodGet, path: "/api/v0/transactions/export?format=xml", status: http.StatusBadRequest},
+		{name: "get device", method: http.MethodGet, path: deviceURL, status: http.StatusOK},
+		{name: "get unknown device", method: http.MethodGet, path: "/api/v0/devices/unknown", status: http.StatusNotFound},
+		{name: "get device jwk", method: http.MethodGet, path: deviceURL + "/jwk", status: http.StatusOK},
+		{name: "get unknown device jwk", method: http.MethodGet, path: "/api/v0/devices/unknown/jwk", status: http.StatusNotFound},
+		{name: "get jwks", method: http.MethodGet, path: "/api/v0/jwks.json", status: http.StatusOK},
+		{name: "get device invalid id", method: http.MethodGet, path: "/api/v0/devices/not.an.id", status: http.StatusBadRequest},
+		{name: "list transactions", method: http.MethodGet, path: deviceURL + "/transactions", status: http.StatusOK},
+		{name: "transaction proof", method: http.MethodGet, path: deviceURL + "/transactions/0/proof?checkpoint=1", status: http.StatusOK},
+		{name: "transaction proof not anchored", method: http.MethodGet, path: deviceURL + "/transactions/1/proof", status: http.StatusNotFound},
+		{name: "transaction proof unknown transaction", method: http.MethodGet, path: deviceURL + "/transactions/99/proof", status: http.StatusNotFound},
+		{name: "transaction proof invalid checkpoint", method: http.MethodGet, path: deviceURL + "/transactions/0/proof?checkpoint=0", status: http.StatusBadRequest},
+		{name: "list checkpoints", method: http.MethodGet, path: "/api/v0/checkpoints", status: http.StatusOK},
+		{name: "latest checkpoint", method: http.MethodGet, path: "/api/v0/checkpoints/latest", status: http.StatusOK},
+		{name: "checkpoint public key", method: http.MethodGet, path: "/api/v0/checkpoints/public-key", status: http.StatusOK},
+		{name: "get checkpoint", method: http.MethodGet, path: "/api/v0/checkpoints/1", status: http.StatusOK},
+		{name: "get unknown checkpoint", method: http.MethodGet, path: "/api/v0/checkpoints/7", status: http.StatusNotFound},
+		{name: "get checkpoint invalid number", method: http.MethodGet, path: "/api/v0/checkpoints/0", status: http.StatusBadRequest},
+		{name: "audit", method: http.MethodGet, path: deviceURL + "/audit", status: http.StatusOK},
+		{name: "export", method: http.MethodGet, path: deviceURL + "/export?from=2020-01-01T00:00:00Z", status: http.StatusOK},
+		{name: "export invalid range", method: http.MethodGet, path: deviceURL + "/export?to=2020-01-01T00:00:00Z&from=now", status: http.StatusBadRequest},
+		{name: "export reversed range", method: http.MethodGet, path: deviceURL + "/export?from=2020-01-02T00:00:00Z&to=2020-01-01T00:00:00Z", status: http.StatusBadRequest},
+		{
+			name: "verify", method: http.MethodPost, path: deviceURL + "/verify",
+			body:   map[string]interface{}{"signed_data": "data", "signature": signature.Signature},
+			status: http.StatusOK,
+		},
+		{
+			name: "verify unknown key version", method: http.MethodPost, path: deviceURL + "/verify",
+			body:   map[string]interface{}{"signed_data": "data", "signature": signature.Signature, "key_version": 7},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "start fiscal transaction", method: http.MethodPost, path: deviceURL + "/fiscal-transactions",
+			body:   map[string]interface{}{"client_id": testClientId, "process_type": "Kassenbeleg-V1"},
+			status: http.StatusCreated,
+		},
+		{
+			name: "update fiscal transaction", method: http.MethodPost, path: deviceURL + "/fiscal-transactions/1/update",
+			body:   map[string]interface{}{"process_data": "Beleg^1.00:Bar"},
+			status: http.StatusOK,
+		},
+		{
+			name: "finish fiscal transaction", method: http.MethodPost, path: deviceURL + "/fiscal-transactions/1/finish",
+			body:   map[string]interface{}{},
+			status: http.StatusOK,
+		},
+		{
+			name: "finish fiscal transaction twice", method: http.MethodPost, path: deviceURL + "/fiscal-transactions/1/finish",
+			body:   map[string]interface{}{},
+			status: http.StatusConflict,
+		},
+		{name: "get fiscal transaction", method: http.MethodGet, path: deviceURL + "/fiscal-transactions/1", status: http.StatusOK},
+		{name: "get unknown fiscal transaction", method: http.MethodGet, path: deviceURL + "/fiscal-transactions/7", status: http.StatusNotFound},
+		{name: "list fiscal transactions", method: http.MethodGet, path: deviceURL + "/fiscal-transactions", status: http.StatusOK},
+		{
+			name: "register client", method: http.MethodPost, path: "/api/v0/clients",
+			body:   map[string]interface{}{"serial_number": "REGISTER-2", "device_ids": []string{device.Id}},
+			status: http.StatusCreated,
+		},
+		{
+			name: "register client duplicate serial number", method: http.MethodPost, path: "/api/v0/clients",
+			body:   map[string]interface{}{"serial_number": "REGISTER-2", "device_ids": []string{device.Id}},
+			status: http.StatusConflict,
+		},
+		{
+			name: "register client unknown device", method: http.MethodPost, path: "/api/v0/clients",
+			body:   map[string]interface{}{"serial_number": "REGISTER-3", "device_ids": []string{"unknown"}},
+			status: http.StatusNotFound,
+		},
+		{name: "list clients", method: http.MethodGet, path: "/api/v0/clients?device_id=" + device.Id, status: http.StatusOK},
+		{name: "get client", method: http.MethodGet, path: clientURL, status: http.StatusOK},
+		{name: "get unknown client", method: http.MethodGet, path: "/api/v0/clients/unknown", status: http.StatusNotFound},
+		{name: "register client device", method: http.MethodPut, path: clientURL + "/devices/" + deactivated.Id, status: http.StatusOK},
+		{name: "deregister client device", method: http.MethodDelete, path: clientURL + "/devices/" + deactivated.Id, status: http.StatusOK},
+		{name: "deregister unregistered client device", method: http.MethodDelete, path: clientURL + "/devices/" + deactivated.Id, status: http.StatusForbidden},
+		{
+			name: "sign with unregistered client", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": deactivated.Id, "client_id": testClientId, "data_to_be_signed": "data"},
+			status: http.StatusForbidden,
+		},
+		{name: "rotate", method: http.MethodPost, path: deviceURL + "/rotate", status: http.StatusOK},
+		{name: "deactivate", method: http.MethodPost, path: deactivatedURL + "/deactivate", status: http.StatusOK},
+		{name: "deactivate twice", method: http.MethodPost, path: deactivatedURL + "/deactivate", status: http.StatusConflict},
+		{name: "rotate deactivated", method: http.MethodPost, path: deactivatedURL + "/rotate", status: http.StatusConflict},
+		{name: "create csr deactivated", method: http.MethodPost, path: deactivatedURL + "/csr", body: map[string]interface{}{}, status: http.StatusConflict},
+		{
+			name: "sign with deactivated device", method: http.MethodPost, path: "/api/v0/transaction",
+			body:   map[string]interface{}{"device_id": deactivated.Id, "client_id": testClientId, "data_to_be_signed": "data"},
+			status: http.StatusConflict,
+		},
+	}
+
+	handler := s.Handler()
+	covered := map[*openapi3.Operation]bool{}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var body []byte
+			if c.body != nil {
+				body, err = json.Marshal(c.body)
+				if err != nil {
+					t.Fatalf("Could not marshal JSON: %v", err)
+				}
+			}
+			req := httptest.NewRequest(c.method, c.path, bytes.NewReader(body))
+			if c.body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for name, value := range c.header {
+				req.Header.Set(name, value)
+			}
+
+			route, pathParams, err := router.FindRoute(req)
+			if err != nil {
+				t.Fatalf("Request is not documented: %v", err)
+			}
+			covered[route.Operation] = true
+			requestInput := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+			}
+			// invalid requests are sent on purpose to check the documented error responses
+			if c.status < http.StatusBadRequest {
+				assert.NoError(t, openapi3filter.ValidateRequest(ctx, requestInput))
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, c.status, rec.Code, rec.Body.String())
+			responseInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: requestInput,
+				Status:                 rec.Code,
+				Header:                 rec.Header(),
+				Body:                   io.NopCloser(rec.Body),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			}
+			assert.NoError(t, openapi3filter.ValidateResponse(ctx, responseInput))
+		})
+	}
+
+	// every documented operation has to be exercised by at least one case
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			assert.True(t, covered[operation], "no contract case for %s %s", method, path)
+		}
+	}
+}
+
+func Test_OpenAPI_Served(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/openapi.yaml", nil)
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "openapi: 3."))
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+// ProblemContentType is the media type of error responses (RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the error code to form the type URI of a problem.
+const problemTypePrefix = "urn:signing-service:problem:"
+
+// ErrorCode is a stable, machine-readable identifier of an error.
+// Clients should branch on it instead of the human-readable detail.
+type ErrorCode string
+
+const (
+	CodeDeviceNotFound               ErrorCode = "device_not_found"
+	CodeDeviceInactive               ErrorCode = "device_inactive"
+	CodeClientNotFound               ErrorCode = "client_not_found"
+	CodeClientNotRegistered          ErrorCode = "client_not_registered"
+	CodeDuplicateSerialNumber        ErrorCode = "duplicate_serial_number"
+	CodeFiscalTransactionNotFound    ErrorCode = "fiscal_transaction_not_found"
+	CodeFiscalTransactionFinished    ErrorCode = "fiscal_transaction_finished"
+	CodeInvalidAlgorithm             ErrorCode = "invalid_algorithm"
+	CodeCounterConflict              ErrorCode = "counter_conflict"
+	CodeClockBehind                  ErrorCode = "clock_behind"
+	CodeTimestampUnavailable         ErrorCode = "timestamp_unavailable"
+	CodeDuplicateReference           ErrorCode = "duplicate_reference"
+	CodeTransactionNotFound          ErrorCode = "transaction_not_found"
+	CodeTransactionNotAnchored       ErrorCode = "transaction_not_anchored"
+	CodeCheckpointNotFound           ErrorCode = "checkpoint_not_found"
+	CodeCheckpointsDisabled          ErrorCode = "checkpoints_disabled"
+	CodeCertificateNotFound          ErrorCode = "certificate_not_found"
+	CodeCertificateAuthorityDisabled ErrorCode = "certificate_authority_disabled"
+	CodeValidationFailed             ErrorCode = "validation_failed"
+	CodeMalformedRequest             ErrorCode = "malformed_request"
+	CodeRequestTooLarge              ErrorCode = "request_too_large"
+	CodeIdempotencyKeyReused         ErrorCode = "idempotency_key_reused"
+	CodeNotFound                     ErrorCode = "not_found"
+	CodeMethodNotAllowed             ErrorCode = "method_not_allowed"
+	CodeInternal                     ErrorCode = "internal_error"
+)
+
+// InvalidParam names a request field and why its value was rejected.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Problem is the error API response container following RFC 7807.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          ErrorCode      `json:"code"`
+	RequestId     string         `json:"request_id,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// WriteProblem writes an error as a problem details HTTP response.
+// The detail is shown to clients and must not contain internals.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code ErrorCode, detail string, invalidParams ...InvalidParam) {
+	problem := Problem{
+		Type:          problemTypePrefix + string(code),
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        detail,
+		Instance:      r.URL.Path,
+		Code:          code,
+		RequestId:     RequestId(r),
+		InvalidParams: invalidParams,
+	}
+
+	bytes, err := json.Marshal(problem)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
+
+// WriteInternalError writes a generic internal error. The cause is only logged.
+func WriteInternalError(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "")
+}
+
+// writeServiceError maps an error of the signing service to a problem details response.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		invalidParams := make([]InvalidParam, 0, len(validationErr.Fields))
+		for _, field := range validationErr.Fields {
+			invalidParams = append(invalidParams, InvalidParam{Name: field.Field, Reason: field.Reason})
+		}
+		WriteProblem(w, r, http.StatusBadRequest, CodeValidationFailed, validationErr.Message, invalidParams...)
+	case errors.Is(err, domain.ErrInvalidAlgorithm):
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidAlgorithm, err.Error(), InvalidParam{
+			Name:   "signature_algorithm",
+			Reason: "must be RSA or ECC",
+		})
+	case errors.Is(err, service.ErrDeviceNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeDeviceNotFound, err.Error())
+	case errors.Is(err, domain.ErrDeviceDeactivated):
+		WriteProblem(w, r, http.StatusConflict, CodeDeviceInactive, err.Error())
+	case errors.Is(err, service.ErrClientNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeClientNotFound, err.Error())
+	case errors.Is(err, service.ErrClientNotRegistered):
+		WriteProblem(w, r, http.StatusForbidden, CodeClientNotRegistered, err.Error())
+	case errors.Is(err, service.ErrDuplicateSerialNumber):
+		WriteProblem(w, r, http.StatusConflict, CodeDuplicateSerialNumber, err.Error(), InvalidParam{
+			Name:   "serial_number",
+			Reason: "is already registered by another client",
+		})
+	case errors.Is(err, service.ErrFiscalTransactionNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeFiscalTransactionNotFound, err.Error())
+	case errors.Is(err, domain.ErrFiscalTransactionFinished):
+		WriteProblem(w, r, http.StatusConflict, CodeFiscalTransactionFinished, err.Error())
+	case errors.Is(err, service.ErrCounterConflict):
+		WriteProblem(w, r, http.StatusConflict, CodeCounterConflict, err.Error())
+	case errors.Is(err, service.ErrClockBehind):
+		WriteProblem(w, r, http.StatusServiceUnavailable, CodeClockBehind, err.Error())
+	case errors.Is(err, service.ErrTimestampUnavailable):
+		// the cause may reveal details of the authority, so it is only logged
+		log.Printf("request %s failed: %v", RequestId(r), err)
+		WriteProblem(w, r, http.StatusServiceUnavailable, CodeTimestampUnavailable, service.ErrTimestampUnavailable.Error())
+	case errors.Is(err, service.ErrDuplicateReference):
+		WriteProblem(w, r, http.StatusConflict, CodeDuplicateReference, err.Error(), InvalidParam{
+			Name:   "reference",
+			Reason: "was already used by a transaction of the device",
+		})
+	case errors.Is(err, service.ErrTransactionNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeTransactionNotFound, err.Error())
+	case errors.Is(err, service.ErrTransactionNotAnchored):
+		WriteProblem(w, r, http.StatusNotFound, CodeTransactionNotAnchored, err.Error())
+	case errors.Is(err, service.ErrCheckpointNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeCheckpointNotFound, err.Error())
+	case errors.Is(err, service.ErrCheckpointsDisabled):
+		WriteProblem(w, r, http.StatusNotFound, CodeCheckpointsDisabled, err.Error())
+	case errors.Is(err, service.ErrCertificateNotFound):
+		WriteProblem(w, r, http.StatusNotFound, CodeCertificateNotFound, err.Error())
+	case errors.Is(err, service.ErrCertificateAuthorityDisabled):
+		WriteProblem(w, r, http.StatusNotFound, CodeCertificateAuthorityDisabled, err.Error())
+	default:
+		log.Printf("request %s failed: %v", RequestId(r), err)
+		WriteInternalError(w, r)
+	}
+}
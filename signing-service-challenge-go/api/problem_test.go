@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+	problem := Problem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Could not unmarshal problem: %v", err)
+	}
+	return problem
+}
+
+func Test_Problem_DeviceNotFound(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBufferString(`{"device_id":"unknown","client_id":"register-1","data_to_be_signed":"data"}`))
+	req.Header.Set(RequestIdHeader, "req-1")
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(RequestIdHeader))
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeDeviceNotFound, problem.Code)
+	assert.Equal(t, "urn:signing-service:problem:device_not_found", problem.Type)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "device not found", problem.Detail)
+	assert.Equal(t, "/api/v0/transaction", problem.Instance)
+	assert.Equal(t, "req-1", problem.RequestId)
+}
+
+func Test_Problem_FieldErrors(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBufferString(`{"device_id":"device"}`))
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, []InvalidParam{{Name: "data_to_be_signed", Reason: "must not be empty"}}, problem.InvalidParams)
+	// a request id is generated if the client sent none
+	assert.NotEmpty(t, problem.RequestId)
+	assert.Equal(t, problem.RequestId, rec.Header().Get(RequestIdHeader))
+}
+
+func Test_Problem_InvalidAlgorithm(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBufferString(`{"signature_algorithm":"DSA"}`))
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeInvalidAlgorithm, problem.Code)
+	assert.Equal(t, "signature_algorithm", problem.InvalidParams[0].Name)
+}
+
+func Test_Problem_InternalErrorsAreNotLeaked(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	writeServiceError(rec, req, errors.New("key material is corrupt"))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeInternal, problem.Code)
+	assert.NotContains(t, rec.Body.String(), "key material")
+}
+
+func Test_Problem_ServiceErrorCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   ErrorCode
+	}{
+		{service.ErrDeviceNotFound, http.StatusNotFound, CodeDeviceNotFound},
+		{domain.ErrDeviceDeactivated, http.StatusConflict, CodeDeviceInactive},
+		{service.ErrCounterConflict, http.StatusConflict, CodeCounterConflict},
+		{service.ErrDuplicateReference, http.StatusConflict, CodeDuplicateReference},
+		{domain.ErrInvalidAlgorithm, http.StatusBadRequest, CodeInvalidAlgorithm},
+		{&service.ValidationError{Message: "invalid"}, http.StatusBadRequest, CodeValidationFailed},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		writeServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), c.err)
+
+		assert.Equal(t, c.status, rec.Code, c.err.Error())
+		assert.Equal(t, c.code, decodeProblem(t, rec).Code, c.err.Error())
+	}
+}
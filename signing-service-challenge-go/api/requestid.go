@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIdHeader carries the id of a request. A valid id sent by the client is
+// kept, otherwise a new one is generated. Responses always echo the id.
+const RequestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength bounds client provided request ids.
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// withRequestId assigns an id to every request and echoes it in the response.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if !validRequestId(requestId) {
+			requestId = uuid.New().String()
+		}
+		w.Header().Set(RequestIdHeader, requestId)
+		ctx := context.WithValue(r.Context(), requestIdKey{}, requestId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestId returns the id assigned to the request, or "" outside of the Server handler.
+func RequestId(r *http.Request) string {
+	requestId, _ := r.Context().Value(requestIdKey{}).(string)
+	return requestId
+}
+
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range requestId {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Middleware wraps a handler to run code around every request it serves.
+type Middleware func(http.Handler) http.Handler
+
+// Router dispatches requests by path and method. Path patterns consist of literal
+// segments and parameters written as {name}, e.g. /api/v0/devices/{id}/audit.
+// Requests for unknown paths are answered with 404, requests with a method a path
+// is not registered for with 405 and an Allow header.
+type Router struct {
+	routes      []*route
+	middlewares []Middleware
+}
+
+type route struct {
+	segments []string
+	handlers map[string]http.Handler
+}
+
+type pathParamsKey struct{}
+
+// NewRouter is a factory to instantiate a new Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use appends middlewares to the chain. The first middleware is the outermost one.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handle registers a handler for a method on a path pattern.
+func (r *Router) Handle(method string, pattern string, handler http.HandlerFunc) {
+	segments := splitPath(pattern)
+	for _, existing := range r.routes {
+		if equalSegments(existing.segments, segments) {
+			existing.handlers[method] = handler
+			return
+		}
+	}
+	r.routes = append(r.routes, &route{
+		segments: segments,
+		handlers: map[string]http.Handler{method: handler},
+	})
+}
+
+// ServeHTTP runs the middleware chain around the dispatch of the request.
+func (r *Router) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	var handler http.Handler = http.HandlerFunc(r.dispatch)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	handler.ServeHTTP(response, request)
+}
+
+func (r *Router) dispatch(response http.ResponseWriter, request *http.Request) {
+	segments := splitPath(request.URL.Path)
+	// a path may match several routes, e.g. a literal one and a parameterized one
+	allowedSet := map[string]bool{}
+	for _, route := range r.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if handler, ok := route.handlers[request.Method]; ok {
+			ctx := context.WithValue(request.Context(), pathParamsKey{}, params)
+			handler.ServeHTTP(response, request.WithContext(ctx))
+			return
+		}
+		for method := range route.handlers {
+			allowedSet[method] = true
+		}
+	}
+
+	if len(allowedSet) > 0 {
+		allowed := make([]string, 0, len(allowedSet))
+		for method := range allowedSet {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		response.Header().Set("Allow", strings.Join(allowed, ", "))
+		WriteProblem(response, request, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			request.Method+" is not supported by "+request.URL.Path)
+		return
+	}
+	WriteProblem(response, request, http.StatusNotFound, CodeNotFound, "no resource at "+request.URL.Path)
+}
+
+// match reports whether the path segments match the route and returns the path parameters.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// PathParam returns the value of a path parameter of the route that matched the request.
+func PathParam(request *http.Request, name string) string {
+	params, _ := request.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func equalSegments(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter() *Router {
+	router := NewRouter()
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		WriteAPIResponse(w, http.StatusOK, PathParam(r, "id"))
+	})
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		WriteAPIResponse(w, http.StatusOK, "rotated "+PathParam(r, "id"))
+	})
+	router.Handle(http.MethodDelete, "/api/v0/devices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return router
+}
+
+func Test_Router_PathParams(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/abc/rotate", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	resp := Response{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "rotated abc", resp.Data)
+}
+
+func Test_Router_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v0/devices/abc", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "DELETE, GET", rec.Header().Get("Allow"))
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+}
+
+func Test_Router_MethodNotAllowed_OverlappingRoutes(t *testing.T) {
+	router := NewRouter()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router.Handle(http.MethodGet, "/api/v0/checkpoints/latest", handler)
+	router.Handle(http.MethodGet, "/api/v0/checkpoints/{number}", handler)
+	router.Handle(http.MethodDelete, "/api/v0/checkpoints/{number}", handler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/checkpoints/latest", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "DELETE, GET", rec.Header().Get("Allow"))
+}
+
+func Test_Router_NotFound(t *testing.T) {
+	for _, path := range []string{"/api/v0/devices", "/api/v0/devices/", "/api/v0/devices/abc/unknown", "/unknown"} {
+		rec := httptest.NewRecorder()
+		newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		problem := Problem{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, CodeNotFound, problem.Code)
+	}
+}
+
+func Test_Router_MiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	router := newTestRouter()
+	router.Use(trace("outer"), trace("inner"))
+
+	// middlewares also run for requests that match no route
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v0/devices/abc", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	assert.Equal(t, []string{"outer", "inner", "outer", "inner"}, calls)
+}
+
+func Test_Router_RecoversPanics(t *testing.T) {
+	router := NewRouter()
+	router.Use(recoverPanics)
+	router.Handle(http.MethodGet, "/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
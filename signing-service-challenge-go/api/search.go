@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+// transactionCSVHeader names the columns of a CSV transaction export.
+var transactionCSVHeader = []string{
+	"device_id", "signature_counter", "signed_at", "reference", "data_to_be_signed",
+	"last_signature", "signature", "envelope_version", "key_version", "metadata", "client_id",
+}
+
+// parseTransactionQuery reads a transaction search from the query parameters
+// device_id (repeatable), signed_from, signed_to, counter_from, counter_to,
+// reference, sort, limit and cursor. The device filter built from label and
+// metadata (repeatable, as key:value) is nil if neither is given.
+func parseTransactionQuery(request *http.Request) (persistence.TransactionQuery, *persistence.DeviceFilter, error) {
+	query := request.URL.Query()
+	errs := fieldErrors{}
+	transactionQuery := persistence.TransactionQuery{
+		Filter: persistence.TransactionFilter{
+			Reference: query.Get("reference"),
+		},
+		Sort: persistence.TransactionSort(query.Get("sort")),
+	}
+	transactionQuery.Limit, transactionQuery.Cursor = parsePageParams(request, &errs)
+
+	filter := &transactionQuery.Filter
+	for _, deviceId := range query["device_id"] {
+		if !service.ValidId(deviceId) {
+			errs.Add("device_id", "must consist of 1 to 64 letters, digits, _ or -")
+			continue
+		}
+		filter.DeviceIds = append(filter.DeviceIds, deviceId)
+	}
+	var err error
+	if filter.SignedFrom, err = parseTimeParam(request, "signed_from"); err != nil {
+		errs.Add("signed_from", "must be an RFC 3339 timestamp")
+	}
+	if filter.SignedTo, err = parseTimeParam(request, "signed_to"); err != nil {
+		errs.Add("signed_to", "must be an RFC 3339 timestamp")
+	}
+	filter.CounterFrom = parseCounterParam(request, "counter_from", &errs)
+	filter.CounterTo = parseCounterParam(request, "counter_to", &errs)
+	service.ValidateReference("reference", filter.Reference, &errs)
+
+	var devices *persistence.DeviceFilter
+	label := query.Get("label")
+	metadata := parseMetadataParam(request, &errs)
+	if label != "" || metadata != nil {
+		devices = &persistence.DeviceFilter{Label: label, Metadata: metadata}
+	}
+	return transactionQuery, devices, errs.Err()
+}
+
+func parseCounterParam(request *http.Request, name string, errs *fieldErrors) *int {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return nil
+	}
+	counter, err := strconv.Atoi(value)
+	if err != nil || counter < 0 {
+		errs.Add(name, "must be a non-negative number")
+		return nil
+	}
+	return &counter
+}
+
+// SearchTransactions writes a page of the transactions of all devices matching
+// the query parameters, by default in the order they were signed.
+func (s *Server) SearchTransactions(response http.ResponseWriter, request *http.Request) {
+	transactionQuery, devices, err := parseTransactionQuery(request)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	page, err := s.signingService().SearchTransactions(transactionQuery, devices)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	WritePageResponse(response, http.StatusOK, page.Transactions, Pagination{
+		Limit:      persistence.NormalizeLimit(transactionQuery.Limit),
+		NextCursor: page.NextCursor,
+	})
+}
+
+// ExportTransactions streams all transactions matching the search query parameters
+// as CSV or NDJSON, selected by the format parameter. The limit parameter sets
+// how many transactions are fetched from the store at once.
+func (s *Server) ExportTransactions(response http.ResponseWriter, request *http.Request) {
+	format := request.URL.Query().Get("format")
+	if format != "csv" && format != "ndjson" {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"format must be csv or ndjson", InvalidParam{Name: "format", Reason: "must be csv or ndjson"})
+		return
+	}
+	transactionQuery, devices, err := parseTransactionQuery(request)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+	if transactionQuery.Limit == 0 {
+		transactionQuery.Limit = persistence.MaxPageLimit
+	}
+	// the first page is fetched before the response is started, so that invalid
+	// queries are still answered with a problem
+	page, err := s.signingService().SearchTransactions(transactionQuery, devices)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+
+	var write func(*domain.Transaction) error
+	var flush func() error
+	if format == "csv" {
+		response.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		response.Header().Set("Content-Disposition", `attachment; filename="transactions.csv"`)
+		writer := csv.NewWriter(response)
+		writer.Write(transactionCSVHeader)
+		write = func(transaction *domain.Transaction) error {
+			return writer.Write(transactionCSVRecord(transaction))
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		response.Header().Set("Content-Type", "application/x-ndjson")
+		response.Header().Set("Content-Disposition", `attachment; filename="transactions.ndjson"`)
+		encoder := json.NewEncoder(response)
+		write = func(transaction *domain.Transaction) error {
+			return encoder.Encode(transaction)
+		}
+		flush = func() error { return nil }
+	}
+	response.WriteHeader(http.StatusOK)
+
+	for {
+		for _, transaction := range page.Transactions {
+			if err := write(transaction); err != nil {
+				log.Printf("request %s: transaction export aborted: %v", RequestId(request), err)
+				return
+			}
+		}
+		if err := flush(); err != nil {
+			log.Printf("request %s: transaction export aborted: %v", RequestId(request), err)
+			return
+		}
+		if page.NextCursor == "" {
+			return
+		}
+		transactionQuery.Cursor = page.NextCursor
+		page, err = s.signingService().SearchTransactions(transactionQuery, devices)
+		if err != nil {
+			log.Printf("request %s: transaction export aborted: %v", RequestId(request), err)
+			return
+		}
+	}
+}
+
+func transactionCSVRecord(transaction *domain.Transaction) []string {
+	return []string{
+		transaction.DeviceId,
+		strconv.Itoa(transaction.Counter),
+		transaction.SignedAt.UTC().Format(time.RFC3339Nano),
+		transaction.Reference,
+		transaction.Data,
+		transaction.LastSignature,
+		transaction.Signature,
+		string(transaction.EnvelopeVersion),
+		strconv.Itoa(transaction.KeyVersion),
+		transactionCSVMetadata(transaction),
+		transaction.ClientId,
+	}
+}
+
+// transactionCSVMetadata encodes the metadata of a transaction as a JSON object,
+// or as an empty string if there is none.
+func transactionCSVMetadata(transaction *domain.Transaction) string {
+	if len(transaction.Metadata) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(transaction.Metadata)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type transactionPageResponse struct {
+	Data       []*domain.Transaction `json:"data"`
+	Pagination Pagination            `json:"pagination"`
+}
+
+// newServerWithRegisters creates two tagged devices with a transaction every minute
+// starting at 14:00 and a reference naming the register and the minute.
+func newServerWithRegisters(t *testing.T) (*Server, time.Time) {
+	s := NewServer(":8081")
+	start := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	for _, register := range []string{"7", "8"} {
+		device, err := domain.NewSignatureDevice(domain.ECDSA, "register "+register)
+		if err != nil {
+			t.Fatalf("Could not create device: %v", err)
+		}
+		device.Metadata = map[string]string{"register": register}
+		s.deviceStore.Save(device)
+		for minute := 0; minute < 5; minute++ {
+			s.transactionStore.Save(&domain.Transaction{
+				DeviceId:  device.Id,
+				Data:      "receipt",
+				Counter:   minute,
+				SignedAt:  start.Add(time.Duration(minute) * time.Minute),
+				Reference: register + "-" + string(rune('0'+minute)),
+			})
+		}
+	}
+	return s, start
+}
+
+func searchTransactions(t *testing.T, s *Server, query string) (*httptest.ResponseRecorder, transactionPageResponse) {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/transactions?"+query, nil))
+	page := transactionPageResponse{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+	}
+	return rec, page
+}
+
+func Test_SearchTransactions_ByDeviceMetadataAndTime(t *testing.T) {
+	s, start := newServerWithRegisters(t)
+
+	query := url.Values{}
+	query.Set("metadata", "register:7")
+	query.Set("signed_from", start.Add(2*time.Minute).Format(time.RFC3339))
+	query.Set("signed_to", start.Add(2*time.Minute).Format(time.RFC3339))
+	rec, page := searchTransactions(t, s, query.Encode())
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "7-2", page.Data[0].Reference)
+	}
+}
+
+func Test_SearchTransactions_Filters(t *testing.T) {
+	s, _ := newServerWithRegisters(t)
+
+	cases := map[string][]string{
+		"reference=8-3":                          {"8-3"},
+		"label=REGISTER 8&counter_from=3":        {"8-3", "8-4"},
+		"label=register&counter_to=0":            {"7-0", "8-0"},
+		"label=register 9":                       {},
+		"metadata=register:7&sort=-signed_at":    {"7-4", "7-3", "7-2", "7-1", "7-0"},
+		"metadata=register:7&reference=8-1":      {},
+		"signed_to=2024-03-01T14:00:59Z&label=7": {"7-0"},
+	}
+	for query, references := range cases {
+		rec, page := searchTransactions(t, s, url.PathEscape(query))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		found := []string{}
+		for _, transaction := range page.Data {
+			found = append(found, transaction.Reference)
+		}
+		assert.ElementsMatch(t, references, found, query)
+	}
+
+	_, page := searchTransactions(t, s, "metadata=register:7&sort=-signed_at")
+	assert.Equal(t, "7-4", page.Data[0].Reference)
+	assert.Equal(t, "7-0", page.Data[4].Reference)
+}
+
+func Test_SearchTransactions_Pages(t *testing.T) {
+	s, _ := newServerWithRegisters(t)
+
+	count := 0
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		rec, page := searchTransactions(t, s, "limit=3&cursor="+url.QueryEscape(cursor))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		count += len(page.Data)
+		cursor = page.Pagination.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, 10, count)
+}
+
+func Test_SearchTransactions_InvalidParams(t *testing.T) {
+	s, _ := newServerWithRegisters(t)
+
+	cases := map[string]string{
+		"counter_from=-1":             "counter_from",
+		"counter_from=3&counter_to=2": "counter_from",
+		"signed_from=yesterday":       "signed_from",
+		"signed_from=2024-03-02T00:00:00Z&signed_to=2024-03-01T00:00:00Z": "signed_from",
+		"device_id=not.an.id": "device_id",
+		"sort=counter":        "sort",
+		"cursor=garbage":      "cursor",
+	}
+	for query, param := range cases {
+		rec, _ := searchTransactions(t, s, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Equal(t, param, decodeProblem(t, rec).InvalidParams[0].Name, query)
+	}
+}
+
+func Test_ExportTransactions_CSV(t *testing.T) {
+	s, _ := newServerWithRegisters(t)
+
+	rec := httptest.NewRecorder()
+	// a small limit makes the export walk several pages
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/transactions/export?format=csv&limit=2&label=register", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 11)
+	assert.Equal(t, transactionCSVHeader, records[0])
+	assert.Equal(t, "2024-03-01T14:00:00Z", records[1][2])
+}
+
+func Test_ExportTransactions_NDJSON(t *testing.T) {
+	s, _ := newServerWithRegisters(t)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/transactions/export?format=ndjson&metadata=register:8", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	scanner := bufio.NewScanner(bytes.NewReader(rec.Body.Bytes()))
+	references := []string{}
+	for scanner.Scan() {
+		transaction := domain.Transaction{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &transaction))
+		references = append(references, transaction.Reference)
+	}
+	assert.Equal(t, []string{"8-0", "8-1", "8-2", "8-3", "8-4"}, references)
+}
+
+func Test_SignTransaction_Reference(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	send := func(reference string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(SignTransactionRequest{DeviceId: device.Id, ClientId: testClientId, Data: "data", Reference: reference})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, send("receipt 4711").Code)
+	invalid := send("receipt\n4711")
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.Equal(t, "reference", decodeProblem(t, invalid).InvalidParams[0].Name)
+	// non-ASCII references are rejected as such, so the length is counted in characters
+	assert.Equal(t, "must only contain printable ASCII characters", decodeProblem(t, send(strings.Repeat("ä", 100))).InvalidParams[0].Reason)
+	assert.Equal(t, fmt.Sprintf("must not be longer than %d characters", service.MaxReferenceLength),
+		decodeProblem(t, send(strings.Repeat("a", service.MaxReferenceLength+1))).InvalidParams[0].Reason)
+	assert.Equal(t, http.StatusOK, send(strings.Repeat("a", service.MaxReferenceLength)).Code)
+
+	_, page := searchTransactions(t, s, "reference="+url.QueryEscape("receipt 4711"))
+	assert.Len(t, page.Data, 1)
+}
@@ -2,89 +2,157 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 )
 
 // Response is the generic API response container.
 type Response struct {
-	Data interface{} `json:"data"`
-}
-
-// ErrorResponse is the generic error API response container.
-type ErrorResponse struct {
-	Errors []string `json:"errors"`
+	Data       interface{} `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
 // Server manages HTTP requests and dispatches them to the appropriate services.
 type Server struct {
-	listenAddress    string
-	deviceStore      persistence.DeviceStore
-	transactionStore persistence.TransactionStore
+	listenAddress          string
+	deviceStore            persistence.DeviceStore
+	transactionStore       persistence.TransactionStore
+	fiscalTransactionStore persistence.FiscalTransactionStore
+	clientStore            persistence.ClientStore
+	logStore               persistence.LogStore
+	serviceOptions         []service.Option
+	idempotencyKeys        *idempotencyStore
 }
 
-// NewServer is a factory to instantiate a new Server.
+// NewServer is a factory to instantiate a new Server backed by in-memory stores.
 func NewServer(listenAddress string) *Server {
 	devicePersistence := persistence.NewInMemoryDeviceStore()
 	transactionPersistence := persistence.NewInMemoryTransactionStore()
+	fiscalTransactionPersistence := persistence.NewInMemoryFiscalTransactionStore()
+	clientPersistence := persistence.NewInMemoryClientStore()
+	logPersistence := persistence.NewInMemoryLogStore()
+	return NewServerWithStores(listenAddress, devicePersistence, transactionPersistence, fiscalTransactionPersistence, clientPersistence, logPersistence)
+}
+
+// NewServerWithStores is a factory to instantiate a new Server on the given stores,
+// e.g. to share them with the gRPC API. The options configure the signing service,
+// e.g. its clock.
+func NewServerWithStores(listenAddress string, deviceStore persistence.DeviceStore, transactionStore persistence.TransactionStore, fiscalTransactionStore persistence.FiscalTransactionStore, clientStore persistence.ClientStore, logStore persistence.LogStore, options ...service.Option) *Server {
 	return &Server{
-		listenAddress:    listenAddress,
-		deviceStore:      devicePersistence,
-		transactionStore: transactionPersistence,
+		listenAddress:          listenAddress,
+		deviceStore:            deviceStore,
+		transactionStore:       transactionStore,
+		fiscalTransactionStore: fiscalTransactionStore,
+		clientStore:            clientStore,
+		logStore:               logStore,
+		serviceOptions:         options,
+		idempotencyKeys:        newIdempotencyStore(),
 	}
 }
 
-// Run registers all HandlerFuncs for the existing HTTP routes and starts the Server.
+// signingService returns the service implementing the device operations on the stores of the Server.
+func (s *Server) signingService() *service.SigningService {
+	return service.NewSigningService(s.deviceStore, s.transactionStore, s.fiscalTransactionStore, s.clientStore, s.logStore, s.serviceOptions...)
+}
+
+// Run starts the Server on its listen address.
 func (s *Server) Run() error {
-	mux := http.NewServeMux()
+	return http.ListenAndServe(s.listenAddress, s.Handler())
+}
 
-	mux.Handle("/api/v0/health", http.HandlerFunc(s.Health))
+// Handler registers all HandlerFuncs for the existing HTTP routes.
+func (s *Server) Handler() http.Handler {
+	router := NewRouter()
+	router.Use(withRequestId, recoverPanics, limitRequestBody, s.idempotent)
 
-	// register further HandlerFuncs here ...
-	mux.Handle("/api/v0/device", http.HandlerFunc(s.SignatureDevice))
+	router.Handle(http.MethodGet, "/api/v0/health", s.Health)
 
-	mux.Handle("/api/v0/transaction", http.HandlerFunc(s.SignTransaction))
+	router.Handle(http.MethodGet, "/api/v0/openapi.yaml", s.OpenAPI)
 
-	return http.ListenAndServe(s.listenAddress, mux)
-}
+	router.Handle(http.MethodGet, "/api/v0/jwks.json", s.JWKSet)
+	router.Handle(http.MethodGet, "/api/v0/certificate-authority", s.CertificateAuthority)
+	router.Handle(http.MethodGet, "/api/v0/certificate-authority/crl", s.CertificateRevocationList)
 
-// WriteInternalError writes a default internal error message as an HTTP response.
-func WriteInternalError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+	// register further HandlerFuncs here ...
+	router.Handle(http.MethodGet, "/api/v0/devices", s.ListSignatureDevices)
+	router.Handle(http.MethodPost, "/api/v0/devices", s.CreateSignatureDevice)
+	router.Handle(http.MethodPost, "/api/v0/devices/import", s.ImportSignatureDevice)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}", s.Device)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/jwk", s.DeviceJWK)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/certificate", s.IssueDeviceCertificate)
+	router.Handle(http.MethodPut, "/api/v0/devices/{id}/certificate", s.ImportDeviceCertificate)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/csr", s.DeviceCertificateRequest)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/transactions", s.DeviceTransactions)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/transactions/{counter}/proof", s.TransactionProof)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/audit", s.DeviceAudit)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/export", s.DeviceExport)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/rotate", s.RotateDeviceKey)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/deactivate", s.DeactivateDevice)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/verify", s.VerifySignature)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/fiscal-transactions", s.ListFiscalTransactions)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/fiscal-transactions", s.StartFiscalTransaction)
+	router.Handle(http.MethodGet, "/api/v0/devices/{id}/fiscal-transactions/{number}", s.FiscalTransaction)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/fiscal-transactions/{number}/update", s.UpdateFiscalTransaction)
+	router.Handle(http.MethodPost, "/api/v0/devices/{id}/fiscal-transactions/{number}/finish", s.FinishFiscalTransaction)
+
+	router.Handle(http.MethodGet, "/api/v0/clients", s.ListClients)
+	router.Handle(http.MethodPost, "/api/v0/clients", s.RegisterClient)
+	router.Handle(http.MethodGet, "/api/v0/clients/{id}", s.Client)
+	router.Handle(http.MethodPut, "/api/v0/clients/{id}/devices/{device_id}", s.RegisterClientDevice)
+	router.Handle(http.MethodDelete, "/api/v0/clients/{id}/devices/{device_id}", s.DeregisterClientDevice)
+
+	// the literal checkpoint routes are registered before the number
+	router.Handle(http.MethodGet, "/api/v0/checkpoints", s.ListCheckpoints)
+	router.Handle(http.MethodGet, "/api/v0/checkpoints/latest", s.LatestCheckpoint)
+	router.Handle(http.MethodGet, "/api/v0/checkpoints/public-key", s.CheckpointPublicKey)
+	router.Handle(http.MethodGet, "/api/v0/checkpoints/{number}", s.Checkpoint)
+
+	router.Handle(http.MethodPost, "/api/v0/transaction", s.SignTransaction)
+	router.Handle(http.MethodGet, "/api/v0/transactions", s.SearchTransactions)
+	router.Handle(http.MethodGet, "/api/v0/transactions/export", s.ExportTransactions)
+
+	// legacy routes of the first API version
+	router.Handle(http.MethodGet, "/api/v0/device", s.ListAllSignatureDevices)
+	router.Handle(http.MethodPost, "/api/v0/device", s.CreateSignatureDevice)
+
+	return router
 }
 
-// WriteErrorResponse takes an HTTP status code and a slice of errors
-// and writes those as an HTTP error response in a structured format.
-func WriteErrorResponse(w http.ResponseWriter, code int, errors []string) {
-	w.WriteHeader(code)
-
-	errorResponse := ErrorResponse{
-		Errors: errors,
-	}
-
-	bytes, err := json.Marshal(errorResponse)
-	if err != nil {
-		WriteInternalError(w)
-	}
-
-	w.Write(bytes)
+// recoverPanics turns a panicking handler into an internal error response.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("request %s: panic serving %s %s: %v", RequestId(r), r.Method, r.URL.Path, err)
+				WriteInternalError(w, r)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
 }
 
 // WriteAPIResponse takes an HTTP status code and a generic data struct
 // and writes those as an HTTP response in a structured format.
 func WriteAPIResponse(w http.ResponseWriter, code int, data interface{}) {
-	w.WriteHeader(code)
+	writeResponse(w, code, Response{Data: data})
+}
 
-	response := Response{
-		Data: data,
-	}
+// WritePageResponse writes a page of a listing with its pagination as an HTTP response.
+func WritePageResponse(w http.ResponseWriter, code int, data interface{}, pagination Pagination) {
+	writeResponse(w, code, Response{Data: data, Pagination: &pagination})
+}
 
+func writeResponse(w http.ResponseWriter, code int, response Response) {
 	bytes, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
-		WriteInternalError(w)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	w.Write(bytes)
 }
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServerWithTimestampAuthority(t *testing.T) (*Server, *domain.SignatureDevice) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	authority, err := tsa.NewLocalAuthority()
+	if err != nil {
+		t.Fatalf("Could not create timestamp authority: %v", err)
+	}
+	s.serviceOptions = []service.Option{service.WithTimestampAuthority(authority, authority.Roots())}
+	return s, device
+}
+
+func signTestResponse(t *testing.T, s *Server, deviceId string, data string) domain.SignatureResponse {
+	rec := postTransaction(t, s, `{"device_id":"`+deviceId+`","client_id":"`+testClientId+`","data_to_be_signed":"`+data+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not sign transaction: %s", rec.Body.String())
+	}
+	resp := struct {
+		Data domain.SignatureResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return resp.Data
+}
+
+func verifyTimestamped(t *testing.T, s *Server, deviceId string, signature domain.SignatureResponse, token string) VerifyResponse {
+	body, err := json.Marshal(VerifyRequest{
+		SignedData:     signature.SignedData,
+		Signature:      signature.Signature.Signature,
+		TimestampToken: token,
+	})
+	if err != nil {
+		t.Fatalf("Could not marshal JSON: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+deviceId+"/verify", bytes.NewBuffer(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Could not verify signature: %s", rec.Body.String())
+	}
+	resp := struct {
+		Data VerifyResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	return resp.Data
+}
+
+func Test_SignTransaction_Timestamped(t *testing.T) {
+	s, device := newServerWithTimestampAuthority(t)
+	first := signTestResponse(t, s, device.Id, "a")
+	second := signTestResponse(t, s, device.Id, "b")
+	assert.NotEmpty(t, first.Signature.TimestampToken)
+
+	valid := verifyTimestamped(t, s, device.Id, first, first.Signature.TimestampToken)
+	assert.True(t, valid.Valid)
+	if assert.NotNil(t, valid.TimestampedAt) {
+		// the token carries whole seconds
+		assert.WithinDuration(t, first.Signature.SignedAt, *valid.TimestampedAt, time.Second)
+	}
+	// the token of another signature does not cover this one
+	invalid := verifyTimestamped(t, s, device.Id, first, second.Signature.TimestampToken)
+	assert.False(t, invalid.Valid)
+	assert.Nil(t, invalid.TimestampedAt)
+}
+
+func Test_DeviceAudit_InvalidTimestamp(t *testing.T) {
+	s, device := newServerWithTimestampAuthority(t)
+	for _, data := range []string{"a", "b"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+	audit := func() domain.AuditReport {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id+"/audit", nil))
+		resp := struct {
+			Data domain.AuditReport `json:"data"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+		return resp.Data
+	}
+	assert.True(t, audit().Valid)
+
+	// swap the tokens, so that neither covers the signature of its transaction
+	transactions := s.transactionStore.GetByDevice(device.Id)
+	transactions[0].TimestampToken, transactions[1].TimestampToken = transactions[1].TimestampToken, transactions[0].TimestampToken
+
+	report := audit()
+	assert.False(t, report.Valid)
+	if assert.Len(t, report.Issues, 2) {
+		assert.Equal(t, domain.AuditInvalidTimestamp, report.Issues[0].Kind)
+		assert.Equal(t, domain.AuditInvalidTimestamp, report.Issues[1].Kind)
+	}
+}
+
+func Test_SignTransaction_TimestampUnavailable(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	authority := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer authority.Close()
+	s.serviceOptions = []service.Option{service.WithTimestampAuthority(tsa.NewHTTPAuthority(authority.URL), nil)}
+
+	rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	problem := decodeProblem(t, rec)
+	assert.Equal(t, CodeTimestampUnavailable, problem.Code)
+	assert.Equal(t, "timestamp authority is unavailable", problem.Detail)
+	// nothing was signed
+	assert.Empty(t, s.transactionStore.GetByDevice(device.Id))
+	assert.Equal(t, 0, s.deviceStore.GetById(device.Id).Counter())
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+)
+
+// DeviceTransactions lists all transactions signed by a device in counter order.
+// The response body doubles as the transaction export consumed by cmd/verify.
+func (s *Server) DeviceTransactions(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	transactions, err := s.signingService().ListTransactions(deviceId)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+
+	WriteAPIResponse(response, http.StatusOK, transactions)
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func postTransaction(t *testing.T, s *Server, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/transaction", bytes.NewBufferString(body)))
+	return rec
+}
+
+func Test_SignTransaction_Metadata(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data",
+		"metadata":{"order_id":9007199254740993,"paid":true,"cashier":"anna","total":12.50}}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	stored := s.transactionStore.GetByDevice(device.Id)[0]
+	assert.Equal(t, domain.MetadataNumber, stored.Metadata["order_id"].Kind())
+	assert.Equal(t, json.Number("9007199254740993"), stored.Metadata["order_id"].Interface())
+	assert.Equal(t, true, stored.Metadata["paid"].Interface())
+	assert.Equal(t, "anna", stored.Metadata["cashier"].Interface())
+	assert.Contains(t, rec.Body.String(), `"order_id": 9007199254740993`)
+	assert.Contains(t, rec.Body.String(), `"total": 12.50`)
+}
+
+func Test_SignTransaction_InvalidMetadata(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+
+	cases := map[string]string{
+		`{"nested":{"a":1}}`: "metadata.nested",
+		`{"list":[1]}`:       "metadata.list",
+		`{"empty":null}`:     "metadata.empty",
+		`{"bad key":"a"}`:    "metadata.bad key",
+	}
+	for metadata, param := range cases {
+		rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data","metadata":`+metadata+`}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, metadata)
+		assert.Equal(t, param, decodeProblem(t, rec).InvalidParams[0].Name, metadata)
+	}
+	assert.Empty(t, s.transactionStore.GetByDevice(device.Id))
+}
+
+func Test_SignTransaction_UniqueReference(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	other, err := domain.NewSignatureDevice(domain.ECDSA, "other")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	s.deviceStore.Save(other)
+	if _, err := s.signingService().RegisterClientDevice(testClientId, other.Id); err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	sign := func(deviceId string, unique bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(SignTransactionRequest{DeviceId: deviceId, ClientId: testClientId, Data: "data", Reference: "R-1", UniqueReference: unique})
+		return postTransaction(t, s, string(body))
+	}
+
+	assert.Equal(t, http.StatusOK, sign(device.Id, true).Code)
+	// the reference is only unique per device
+	assert.Equal(t, http.StatusOK, sign(other.Id, true).Code)
+	// uniqueness is only enforced when requested
+	assert.Equal(t, http.StatusOK, sign(device.Id, false).Code)
+
+	duplicate := sign(device.Id, true)
+	assert.Equal(t, http.StatusConflict, duplicate.Code)
+	assert.Equal(t, CodeDuplicateReference, decodeProblem(t, duplicate).Code)
+
+	// the rejected transaction did not use up a signature counter
+	assert.Equal(t, 2, s.deviceStore.GetById(device.Id).Counter())
+	assert.Equal(t, http.StatusOK, postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`).Code)
+
+	missing := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data","unique_reference":true}`)
+	assert.Equal(t, http.StatusBadRequest, missing.Code)
+	assert.Equal(t, "reference", decodeProblem(t, missing).InvalidParams[0].Name)
+}
+
+func Test_SignTransaction_Concurrent(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	const requests = 200
+
+	codes := make(chan int, requests)
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	transactions := s.transactionStore.GetByDevice(device.Id)
+	counters := make([]int, 0, len(transactions))
+	for _, transaction := range transactions {
+		counters = append(counters, transaction.Counter)
+	}
+	sort.Ints(counters)
+	expected := make([]int, requests)
+	for i := range expected {
+		expected[i] = i
+	}
+	// every transaction took the next counter and links to its predecessor
+	assert.Equal(t, expected, counters)
+	assert.Equal(t, requests, s.deviceStore.GetById(device.Id).Counter())
+	audit := domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, transactions)
+	assert.True(t, audit.Valid, audit.Issues)
+}
+
+func Test_SignTransaction_ConcurrentRotateAndDeactivate(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	const requests = 20
+
+	signed := make(chan int, requests)
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			signed <- postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`).Code
+		}()
+		go func() {
+			defer wg.Done()
+			s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/rotate", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+device.Id, nil))
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+device.Id+"/deactivate", nil))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}()
+	wg.Wait()
+	close(signed)
+
+	succeeded := 0
+	for code := range signed {
+		if code == http.StatusOK {
+			succeeded++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	// every transaction was signed before the deactivation, with the key version it records
+	transactions := s.transactionStore.GetByDevice(device.Id)
+	assert.Len(t, transactions, succeeded)
+	assert.Equal(t, succeeded, device.Counter())
+	for _, transaction := range transactions {
+		assert.False(t, transaction.SignedAt.After(*device.DeactivatedAt))
+	}
+	audit := domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, transactions)
+	assert.True(t, audit.Valid, audit.Issues)
+}
+
+func Test_SignTransaction_EnvelopeV2SignsTimestamp(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	signedAt := time.Date(2026, 3, 1, 9, 30, 0, 500, time.FixedZone("CET", 3600))
+	s.serviceOptions = []service.Option{service.WithClock(service.ClockFunc(func() time.Time { return signedAt }))}
+
+	rec := postTransaction(t, s, `{"device_id":"`+device.Id+`","client_id":"`+testClientId+`","data_to_be_signed":"data"}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp := struct {
+		Data domain.SignatureResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+	assert.Equal(t, domain.EnvelopeV2, resp.Data.Signature.EnvelopeVersion)
+	assert.True(t, resp.Data.Signature.SignedAt.Equal(signedAt))
+	assert.Contains(t, resp.Data.SignedData, `"signed_at":"2026-03-01T08:30:00.0000005Z"`)
+	audit := domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, s.transactionStore.GetByDevice(device.Id))
+	assert.True(t, audit.Valid)
+}
+
+func Test_SignTransaction_ClockBehind(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	s.serviceOptions = []service.Option{service.WithClock(service.ClockFunc(func() time.Time { return now }))}
+	body := `{"device_id":"` + device.Id + `","client_id":"` + testClientId + `","data_to_be_signed":"data"}`
+	assert.Equal(t, http.StatusOK, postTransaction(t, s, body).Code)
+
+	now = now.Add(-time.Second)
+	rec := postTransaction(t, s, body)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, CodeClockBehind, decodeProblem(t, rec).Code)
+	// the rejected transaction did not use up a signature counter
+	assert.Equal(t, 1, s.deviceStore.GetById(device.Id).Counter())
+}
+
+func Test_DeviceAudit_TimestampRegression(t *testing.T) {
+	s, device := newServerWithDevice(t, domain.ECDSA)
+	device.EnvelopeVersion = domain.EnvelopeV1
+	for _, data := range []string{"a", "b"} {
+		signTestTransaction(t, s, device.Id, data)
+	}
+	for _, transaction := range s.transactionStore.GetByDevice(device.Id) {
+		if transaction.Counter == 1 {
+			transaction.SignedAt = transaction.SignedAt.Add(-time.Hour)
+		}
+	}
+
+	audit := domain.AuditChain(device.Id, device.ChainStart(), device.VerifierForKey, nil, s.transactionStore.GetByDevice(device.Id))
+
+	assert.False(t, audit.Valid)
+	if assert.Len(t, audit.Issues, 1) {
+		assert.Equal(t, domain.AuditTimestampRegression, audit.Issues[0].Kind)
+		assert.Equal(t, 1, audit.Issues[0].Counter)
+	}
+}
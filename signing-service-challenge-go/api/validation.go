@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+const (
+	// MaxRequestBodyBytes bounds every request body read by the Server.
+	MaxRequestBodyBytes = 1 << 20
+	// MaxSubjectAttributeLength bounds an attribute of a certificate subject, as X.520 does for names.
+	MaxSubjectAttributeLength = 64
+
+	// the limits of the field values are shared with the gRPC API, see service.ValidateDevice
+
+	MaxDataToBeSignedBytes = service.MaxDataToBeSignedBytes
+	MaxLabelLength         = service.MaxLabelLength
+	MaxMetadataEntries     = service.MaxMetadataEntries
+	MaxMetadataValueLength = service.MaxMetadataValueLength
+	MaxReferenceLength     = service.MaxReferenceLength
+	MaxProcessTypeLength   = service.MaxProcessTypeLength
+	MaxClientDevices       = service.MaxClientDevices
+	MaxLastSignatureLength = service.MaxLastSignatureLength
+)
+
+// countries of certificate subjects are ISO 3166 alpha-2 codes
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// requestValidator is implemented by request bodies to check their field values
+// after decoding. Validate returns a *service.ValidationError or a domain error.
+type requestValidator interface {
+	Validate() error
+}
+
+// Validate checks the algorithm, label and envelope version of the new device.
+func (r *CreateDeviceRequest) Validate() error {
+	if r.SignatureAlgorithm != domain.RSA && r.SignatureAlgorithm != domain.ECDSA {
+		return domain.ErrInvalidAlgorithm
+	}
+	errs := fieldErrors{}
+	service.ValidateDevice(r.Label, r.EnvelopeVersion, r.Metadata, &errs)
+	return errs.Err()
+}
+
+// Validate checks the algorithm, label and envelope version of the imported device,
+// that a private key is given and the length of the last signature.
+// The key and the chain are checked by the signing service.
+func (r *ImportDeviceRequest) Validate() error {
+	if r.SignatureAlgorithm != domain.RSA && r.SignatureAlgorithm != domain.ECDSA {
+		return domain.ErrInvalidAlgorithm
+	}
+	errs := fieldErrors{}
+	service.ValidateDevice(r.Label, r.EnvelopeVersion, r.Metadata, &errs)
+	if strings.TrimSpace(r.PrivateKey) == "" {
+		errs.Add("private_key", "must not be empty")
+	}
+	if len(r.LastSignature) > MaxLastSignatureLength {
+		errs.Add("last_signature", fmt.Sprintf("must not be longer than %d characters", MaxLastSignatureLength))
+	}
+	return errs.Err()
+}
+
+// Validate checks the format of the device id, the size of the data, the reference
+// and the metadata.
+// Missing fields are reported by the signing service.
+func (r *SignTransactionRequest) Validate() error {
+	errs := fieldErrors{}
+	service.ValidateTransaction(r.DeviceId, r.ClientId, r.Data, service.SignOptions{
+		Reference:       r.Reference,
+		UniqueReference: r.UniqueReference,
+		Metadata:        r.Metadata,
+	}, &errs)
+	return errs.Err()
+}
+
+// Validate checks the client id, the process type and the size of the process data.
+func (r *FiscalTransactionRequest) Validate() error {
+	errs := fieldErrors{}
+	service.ValidateFiscalProcess(r.ClientId, service.FiscalProcess{ProcessType: r.ProcessType, ProcessData: r.ProcessData}, &errs)
+	return errs.Err()
+}
+
+// Validate checks the serial number and the device ids of the new client.
+// Missing fields are reported by the signing service.
+func (r *RegisterClientRequest) Validate() error {
+	errs := fieldErrors{}
+	service.ValidateClient(r.SerialNumber, r.DeviceIds, &errs)
+	return errs.Err()
+}
+
+// Validate checks the key version. Missing fields are reported by the signing service.
+func (r *VerifyRequest) Validate() error {
+	errs := fieldErrors{}
+	if r.KeyVersion != nil && *r.KeyVersion < 0 {
+		errs.Add("key_version", "must not be negative")
+	}
+	return errs.Err()
+}
+
+// Validate checks the length of the subject attributes and the country code.
+func (r *CertificateSigningRequest) Validate() error {
+	errs := fieldErrors{}
+	for _, attribute := range []struct {
+		field string
+		value string
+	}{
+		{"common_name", r.CommonName},
+		{"organization", r.Organization},
+		{"organizational_unit", r.OrganizationalUnit},
+	} {
+		if utf8.RuneCountInString(attribute.value) > MaxSubjectAttributeLength {
+			errs.Add(attribute.field, fmt.Sprintf("must not be longer than %d characters", MaxSubjectAttributeLength))
+		}
+	}
+	if r.Country != "" && !countryPattern.MatchString(r.Country) {
+		errs.Add("country", "must be an ISO 3166 alpha-2 code")
+	}
+	return errs.Err()
+}
+
+// fieldErrors collects the invalid fields of a request.
+type fieldErrors = service.FieldErrors
+
+// decodeRequest strictly decodes a JSON request body into v and validates it.
+// The body must hold exactly one JSON value without unknown fields and must not
+// exceed MaxRequestBodyBytes. If decoding fails, the error response has been
+// written and false is returned.
+func decodeRequest(response http.ResponseWriter, request *http.Request, v interface{}) bool {
+	if request.Body == nil || request.Body == http.NoBody {
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must not be empty")
+		return false
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(response, request.Body, MaxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeDecodeError(response, request, err)
+		return false
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		if err != nil {
+			writeDecodeError(response, request, err)
+			return false
+		}
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must contain a single JSON object")
+		return false
+	}
+	if validator, ok := v.(requestValidator); ok {
+		if err := validator.Validate(); err != nil {
+			writeServiceError(response, request, err)
+			return false
+		}
+	}
+	return true
+}
+
+func writeDecodeError(response http.ResponseWriter, request *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeBodyTooLarge(response, request)
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must be a JSON object")
+	case errors.As(err, &typeErr):
+		reason := "must be a JSON " + jsonTypeName(typeErr.Type)
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			typeErr.Field+" "+reason, InvalidParam{Name: typeErr.Field, Reason: reason})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest,
+			"request body contains unknown field "+field,
+			InvalidParam{Name: field, Reason: "is not supported"})
+	case errors.Is(err, io.EOF):
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must not be empty")
+	default:
+		WriteProblem(response, request, http.StatusBadRequest, CodeMalformedRequest, "request body must be valid JSON")
+	}
+}
+
+func writeBodyTooLarge(response http.ResponseWriter, request *http.Request) {
+	WriteProblem(response, request, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+		fmt.Sprintf("request body must not be larger than %d bytes", MaxRequestBodyBytes))
+}
+
+func jsonTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "number"
+	}
+}
+
+// deviceIdParam returns the device id path parameter. If it is malformed, the
+// error response has been written and false is returned.
+func deviceIdParam(response http.ResponseWriter, request *http.Request) (string, bool) {
+	deviceId := PathParam(request, "id")
+	if !service.ValidId(deviceId) {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"device id must consist of 1 to 64 letters, digits, _ or -",
+			InvalidParam{Name: "id", Reason: "must consist of 1 to 64 letters, digits, _ or -"})
+		return "", false
+	}
+	return deviceId, true
+}
+
+// clientIdParam returns the client id path parameter. If it is malformed, the
+// error response has been written and false is returned.
+func clientIdParam(response http.ResponseWriter, request *http.Request) (string, bool) {
+	clientId := PathParam(request, "id")
+	if !service.ValidId(clientId) {
+		WriteProblem(response, request, http.StatusBadRequest, CodeValidationFailed,
+			"client id must consist of 1 to 64 letters, digits, _ or -",
+			InvalidParam{Name: "id", Reason: "must consist of 1 to 64 letters, digits, _ or -"})
+		return "", false
+	}
+	return clientId, true
+}
+
+// limitRequestBody bounds the request body before any middleware reads it.
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DecodeRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		code   ErrorCode
+		param  string
+	}{
+		{name: "empty", body: "", status: http.StatusBadRequest, code: CodeMalformedRequest},
+		{name: "syntax error", body: `{"signature_algorithm":`, status: http.StatusBadRequest, code: CodeMalformedRequest},
+		{name: "unknown field", body: `{"signature_algorithm":"ECC","id":"mine"}`, status: http.StatusBadRequest, code: CodeMalformedRequest, param: "id"},
+		{name: "trailing data", body: `{"signature_algorithm":"ECC"} {}`, status: http.StatusBadRequest, code: CodeMalformedRequest},
+		{name: "not an object", body: `["ECC"]`, status: http.StatusBadRequest, code: CodeMalformedRequest},
+		{name: "wrong type", body: `{"signature_algorithm":"ECC","label":7}`, status: http.StatusBadRequest, code: CodeValidationFailed, param: "label"},
+		{name: "invalid algorithm", body: `{"signature_algorithm":"ecc"}`, status: http.StatusBadRequest, code: CodeInvalidAlgorithm, param: "signature_algorithm"},
+		{name: "label too long", body: `{"signature_algorithm":"ECC","label":"` + strings.Repeat("a", MaxLabelLength+1) + `"}`, status: http.StatusBadRequest, code: CodeValidationFailed, param: "label"},
+		{name: "label charset", body: `{"signature_algorithm":"ECC","label":"<script>"}`, status: http.StatusBadRequest, code: CodeValidationFailed, param: "label"},
+		{name: "too large", body: `{"signature_algorithm":"ECC","label":"` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`, status: http.StatusRequestEntityTooLarge, code: CodeRequestTooLarge},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBufferString(c.body))
+
+			ok := decodeRequest(rec, req, &CreateDeviceRequest{})
+
+			assert.False(t, ok)
+			assert.Equal(t, c.status, rec.Code)
+			problem := decodeProblem(t, rec)
+			assert.Equal(t, c.code, problem.Code)
+			if c.param != "" && assert.Len(t, problem.InvalidParams, 1) {
+				assert.Equal(t, c.param, problem.InvalidParams[0].Name)
+			}
+		})
+	}
+}
+
+func Test_DecodeRequest_Ok(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBufferString(`{"signature_algorithm":"RSA","label":"Kasse 1 (Filiale #3)"}`+"\n"))
+	createReq := &CreateDeviceRequest{}
+
+	assert.True(t, decodeRequest(rec, req, createReq))
+	assert.Equal(t, "Kasse 1 (Filiale #3)", createReq.Label)
+}
+
+func Test_SignTransactionRequest_Validate(t *testing.T) {
+	assert.NoError(t, (&SignTransactionRequest{DeviceId: "4b9c6a0e-0b5e-4f1e-9d6c-2f1d2a3b4c5d", Data: "data"}).Validate())
+	assert.Error(t, (&SignTransactionRequest{DeviceId: "../devices", Data: "data"}).Validate())
+	assert.Error(t, (&SignTransactionRequest{DeviceId: "device", Data: strings.Repeat("a", MaxDataToBeSignedBytes+1)}).Validate())
+}
+
+func Test_DeviceIdParam_Invalid(t *testing.T) {
+	s := NewServer(":8081")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+strings.Repeat("a", 65), nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "id", decodeProblem(t, rec).InvalidParams[0].Name)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+type VerifyRequest struct {
+	SignedData string `json:"signed_data"`
+	Signature  string `json:"signature"`
+	// KeyVersion selects the device key, the current key is used if omitted.
+	KeyVersion *int `json:"key_version,omitempty"`
+	// TimestampToken is checked to cover the signature if given.
+	TimestampToken string `json:"timestamp_token,omitempty"`
+}
+
+type VerifyResponse struct {
+	Valid      bool `json:"valid"`
+	KeyVersion int  `json:"key_version"`
+	// TimestampedAt is the time of the timestamp token, set if a valid token was given.
+	TimestampedAt *time.Time `json:"timestamped_at,omitempty"`
+}
+
+// VerifySignature checks a signature over signed data with a key of the device,
+// and the timestamp token over the signature if given.
+func (s *Server) VerifySignature(response http.ResponseWriter, request *http.Request) {
+	deviceId, ok := deviceIdParam(response, request)
+	if !ok {
+		return
+	}
+	verifyReq := &VerifyRequest{}
+	if !decodeRequest(response, request, verifyReq) {
+		return
+	}
+	result, err := s.signingService().VerifySignature(deviceId, verifyReq.SignedData, verifyReq.Signature, verifyReq.TimestampToken, verifyReq.KeyVersion)
+	if err != nil {
+		writeServiceError(response, request, err)
+		return
+	}
+
+	WriteAPIResponse(response, http.StatusOK, VerifyResponse{
+		Valid:         result.Valid,
+		KeyVersion:    result.KeyVersion,
+		TimestampedAt: result.TimestampedAt,
+	})
+}
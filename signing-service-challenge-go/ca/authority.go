@@ -0,0 +1,278 @@
+// Package ca is the certificate authority of the signing service. It certifies
+// the keys of signature devices with X.509 certificates and publishes the
+// revocations of these certificates in CRLs.
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultValidity is how long the certificates issued by an Authority are valid,
+	// bounded by the validity of the Authority itself.
+	DefaultValidity = 5 * 365 * 24 * time.Hour
+	// CRLValidity is the time until the next update announced by a CRL. Verifiers
+	// fetch a new CRL once it has passed.
+	CRLValidity = 24 * time.Hour
+)
+
+// Revocation reasons of section 5.3.1 of RFC 5280 used by the signing service.
+const (
+	// ReasonSuperseded revokes the certificate of a key that has been replaced.
+	ReasonSuperseded = 4
+	// ReasonCessationOfOperation revokes the certificate of a device taken out of service.
+	ReasonCessationOfOperation = 5
+)
+
+// ErrAuthorityExpired is returned if a certificate is requested after the
+// intermediate certificate of the Authority has expired.
+var ErrAuthorityExpired = errors.New("certificate authority has expired")
+
+var (
+	oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+	// serialNumberLimit bounds the random serial numbers of certificates.
+	serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 127)
+)
+
+// Authority issues certificates with an intermediate key certified by a self-signed
+// root. The root key only signs the intermediate certificate and is discarded then,
+// as it would be kept offline. Keys and revocations live in memory only.
+type Authority struct {
+	root        *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certificate *x509.Certificate
+	now         func() time.Time
+
+	mu        sync.Mutex
+	revoked   []pkix.RevokedCertificate
+	crlNumber int64
+}
+
+// Option configures an Authority.
+type Option func(*Authority)
+
+// WithClock makes the Authority take the time of its certificates, revocations and
+// CRLs from now instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(a *Authority) {
+		a.now = now
+	}
+}
+
+// NewAuthority is a factory to instantiate an Authority with fresh P-384 keys, a
+// self-signed root certificate with the given common name and an intermediate
+// certificate issuing the device certificates and CRLs.
+func NewAuthority(commonName string, options ...Option) (*Authority, error) {
+	a := &Authority{now: time.Now}
+	for _, option := range options {
+		option(a)
+	}
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	notBefore := a.now().UTC().Truncate(time.Second)
+	root, err := createCA(&x509.Certificate{
+		Subject:    pkix.Name{CommonName: commonName},
+		NotBefore:  notBefore,
+		NotAfter:   notBefore.AddDate(20, 0, 0),
+		MaxPathLen: 1,
+	}, nil, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := createCA(&x509.Certificate{
+		Subject:        pkix.Name{CommonName: commonName + " Intermediate"},
+		NotBefore:      notBefore,
+		NotAfter:       notBefore.AddDate(10, 0, 0),
+		MaxPathLenZero: true,
+	}, root, &key.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	a.root, a.key, a.certificate = root, key, intermediate
+	return a, nil
+}
+
+// createCA creates a CA certificate from template, signed by the parent or self-signed if parent is nil.
+func createCA(template *x509.Certificate, parent *x509.Certificate, publicKey *ecdsa.PublicKey, signer *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	serialNumber, err := SerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	subjectKeyId, err := SubjectKeyId(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serialNumber
+	template.SubjectKeyId = subjectKeyId
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	template.BasicConstraintsValid = true
+	template.IsCA = true
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Certificate returns the root certificate of the authority.
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.root
+}
+
+// Intermediate returns the certificate of the intermediate key, which signs the
+// issued certificates and the CRLs.
+func (a *Authority) Intermediate() *x509.Certificate {
+	return a.certificate
+}
+
+// Roots returns a pool holding the root certificate of the authority, to verify
+// the certificates it issued with.
+func (a *Authority) Roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(a.root)
+	return roots
+}
+
+// Issue certifies publicKey with the subject and extensions of template. The serial
+// number, validity and key identifiers of the template are set by the authority.
+// It returns the certificate followed by the intermediate certificate.
+func (a *Authority) Issue(template *x509.Certificate, publicKey interface{}) ([]*x509.Certificate, error) {
+	if template.IsCA {
+		return nil, errors.New("authority does not issue CA certificates")
+	}
+	serialNumber, err := SerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	subjectKeyId, err := SubjectKeyId(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	issued := *template
+	issued.SerialNumber = serialNumber
+	issued.SubjectKeyId = subjectKeyId
+	issued.NotBefore = a.now().UTC().Truncate(time.Second)
+	if issued.NotBefore.After(a.certificate.NotAfter) {
+		return nil, ErrAuthorityExpired
+	}
+	issued.NotAfter = issued.NotBefore.Add(DefaultValidity)
+	if issued.NotAfter.After(a.certificate.NotAfter) {
+		issued.NotAfter = a.certificate.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &issued, a.certificate, publicKey, a.key)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{certificate, a.certificate}, nil
+}
+
+// Issued reports whether the certificate was issued by the authority.
+func (a *Authority) Issued(certificate *x509.Certificate) bool {
+	return certificate.CheckSignatureFrom(a.certificate) == nil
+}
+
+// Revoke adds a certificate issued by the authority to its CRLs. Revoking a
+// certificate again keeps the time and reason of the first revocation.
+func (a *Authority) Revoke(certificate *x509.Certificate, reason int) error {
+	if !a.Issued(certificate) {
+		return errors.New("certificate was not issued by the authority")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.revokedLocked(certificate.SerialNumber) {
+		return nil
+	}
+	reasonCode, err := asn1.Marshal(asn1.Enumerated(reason))
+	if err != nil {
+		return err
+	}
+	a.revoked = append(a.revoked, pkix.RevokedCertificate{
+		SerialNumber:   certificate.SerialNumber,
+		RevocationTime: a.now().UTC().Truncate(time.Second),
+		Extensions:     []pkix.Extension{{Id: oidExtensionReasonCode, Value: reasonCode}},
+	})
+	return nil
+}
+
+// Revoked reports whether the certificate with the serial number has been revoked.
+func (a *Authority) Revoked(serialNumber *big.Int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.revokedLocked(serialNumber)
+}
+
+func (a *Authority) revokedLocked(serialNumber *big.Int) bool {
+	for _, revoked := range a.revoked {
+		if revoked.SerialNumber.Cmp(serialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CRL returns a DER encoded CRL of all revoked certificates, signed by the
+// intermediate key. Every CRL carries a higher number than the previous one.
+func (a *Authority) CRL() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.crlNumber++
+	thisUpdate := a.now().UTC().Truncate(time.Second)
+	return x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(a.crlNumber),
+		ThisUpdate:          thisUpdate,
+		NextUpdate:          thisUpdate.Add(CRLValidity),
+		RevokedCertificates: a.revoked,
+	}, a.certificate, a.key)
+}
+
+// SerialNumber returns a random positive serial number for a certificate.
+func SerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, err
+	}
+	// a zero serial number is not allowed by RFC 5280
+	return serialNumber.Add(serialNumber, big.NewInt(1)), nil
+}
+
+// SubjectKeyId derives the key identifier of a public key as in method 1 of
+// section 4.2.1.2 of RFC 5280, the SHA-1 hash of the encoded key.
+func SubjectKeyId(publicKey interface{}) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	// the hash is over the subjectPublicKey bit string, without the algorithm
+	info := struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{}
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	digest := sha1.Sum(info.PublicKey.Bytes)
+	return digest[:], nil
+}
@@ -0,0 +1,114 @@
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Issue_ChainsToRoot(t *testing.T) {
+	authority, err := NewAuthority("Test CA")
+	if err != nil {
+		t.Fatalf("Could not create authority: %v", err)
+	}
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+
+	chain, err := authority.Issue(&x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, keyPair.PublicKey())
+
+	if !assert.NoError(t, err) || !assert.Len(t, chain, 2) {
+		return
+	}
+	certificate := chain[0]
+	assert.Equal(t, authority.Intermediate(), chain[1])
+	assert.Equal(t, keyPair.PublicKey(), certificate.PublicKey)
+	assert.Equal(t, authority.Intermediate().SubjectKeyId, certificate.AuthorityKeyId)
+	assert.NotEmpty(t, certificate.SubjectKeyId)
+	assert.WithinDuration(t, certificate.NotBefore.Add(DefaultValidity), certificate.NotAfter, time.Second)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(chain[1])
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: authority.Roots(), Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err)
+}
+
+func Test_Issue_RejectsCA(t *testing.T) {
+	authority, err := NewAuthority("Test CA")
+	if err != nil {
+		t.Fatalf("Could not create authority: %v", err)
+	}
+
+	_, err = authority.Issue(&x509.Certificate{IsCA: true, BasicConstraintsValid: true}, authority.Certificate().PublicKey)
+
+	assert.Error(t, err)
+}
+
+func Test_Issue_RejectsExpiredAuthority(t *testing.T) {
+	now := time.Now()
+	authority, err := NewAuthority("Test CA", WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Could not create authority: %v", err)
+	}
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	now = authority.Intermediate().NotAfter.Add(time.Second)
+
+	_, err = authority.Issue(&x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, keyPair.PublicKey())
+
+	assert.ErrorIs(t, err, ErrAuthorityExpired)
+}
+
+func Test_Revoke_CRL(t *testing.T) {
+	authority, err := NewAuthority("Test CA")
+	if err != nil {
+		t.Fatalf("Could not create authority: %v", err)
+	}
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	chain, err := authority.Issue(&x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, keyPair.PublicKey())
+	if err != nil {
+		t.Fatalf("Could not issue certificate: %v", err)
+	}
+	certificate := chain[0]
+
+	assert.NoError(t, authority.Revoke(certificate, ReasonSuperseded))
+	// the first revocation is kept
+	assert.NoError(t, authority.Revoke(certificate, ReasonCessationOfOperation))
+	// certificates of other authorities cannot be revoked
+	assert.Error(t, authority.Revoke(authority.Certificate(), ReasonSuperseded))
+
+	assert.True(t, authority.Revoked(certificate.SerialNumber))
+	first, err := authority.CRL()
+	assert.NoError(t, err)
+	der, err := authority.CRL()
+	assert.NoError(t, err)
+	crl, err := x509.ParseRevocationList(der)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, crl.CheckSignatureFrom(authority.Intermediate()))
+	previous, err := x509.ParseRevocationList(first)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, crl.Number.Cmp(previous.Number))
+	}
+	assert.Equal(t, crl.ThisUpdate.Add(CRLValidity), crl.NextUpdate)
+	if assert.Len(t, crl.RevokedCertificates, 1) {
+		revoked := crl.RevokedCertificates[0]
+		assert.Equal(t, 0, certificate.SerialNumber.Cmp(revoked.SerialNumber))
+		if assert.Len(t, revoked.Extensions, 1) {
+			var reason asn1.Enumerated
+			_, err := asn1.Unmarshal(revoked.Extensions[0].Value, &reason)
+			assert.NoError(t, err)
+			assert.Equal(t, asn1.Enumerated(ReasonSuperseded), reason)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// IssueDeviceCertificate certifies the current key of a device, either with a
+// self-signed certificate or with one of the internal CA of the service.
+func (c *Client) IssueDeviceCertificate(ctx context.Context, deviceId string, issuer domain.CertificateIssuer) (*domain.SignatureDevice, error) {
+	device := &domain.SignatureDevice{}
+	if err := c.call(ctx, http.MethodPost, devicePath(deviceId)+"/certificate", api.IssueCertificateRequest{Issuer: issuer}, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// ImportDeviceCertificate certifies the current key of a device with a PEM encoded
+// certificate chain issued by another PKI, e.g. for a DeviceCertificateRequest.
+func (c *Client) ImportDeviceCertificate(ctx context.Context, deviceId string, certificate string) (*domain.SignatureDevice, error) {
+	device := &domain.SignatureDevice{}
+	if err := c.call(ctx, http.MethodPut, devicePath(deviceId)+"/certificate", api.ImportCertificateRequest{Certificate: certificate}, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// DeviceCertificateRequest returns a PEM encoded certificate signing request for
+// the current key of a device, to be certified by another PKI.
+func (c *Client) DeviceCertificateRequest(ctx context.Context, deviceId string, csrReq api.CertificateSigningRequest) (*api.CertificateSigningResponse, error) {
+	csr := &api.CertificateSigningResponse{}
+	if err := c.call(ctx, http.MethodPost, devicePath(deviceId)+"/csr", csrReq, csr); err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
+
+// CertificateAuthority returns the root and the intermediate certificate of the
+// internal CA of the service.
+func (c *Client) CertificateAuthority(ctx context.Context) (*api.CertificateAuthorityResponse, error) {
+	authority := &api.CertificateAuthorityResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/v0/certificate-authority", nil, authority); err != nil {
+		return nil, err
+	}
+	return authority, nil
+}
+
+// CertificateRevocationList returns the CRL of the device certificates revoked by
+// the internal CA of the service. Its signature is checked by the caller against
+// the intermediate certificate returned by CertificateAuthority.
+func (c *Client) CertificateRevocationList(ctx context.Context) (*x509.RevocationList, error) {
+	response, err := c.send(ctx, http.MethodGet, "/api/v0/certificate-authority/crl", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	der, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode response: %w", err)
+	}
+	return crl, nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ListCheckpoints returns all checkpoints of the transparency log ordered by number.
+func (c *Client) ListCheckpoints(ctx context.Context) ([]*domain.Checkpoint, error) {
+	checkpoints := []*domain.Checkpoint{}
+	if err := c.call(ctx, http.MethodGet, "/api/v0/checkpoints", nil, &checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// LatestCheckpoint returns the checkpoint with the highest number.
+func (c *Client) LatestCheckpoint(ctx context.Context) (*domain.Checkpoint, error) {
+	return c.checkpointCall(ctx, "/api/v0/checkpoints/latest")
+}
+
+// GetCheckpoint returns a single checkpoint of the transparency log.
+func (c *Client) GetCheckpoint(ctx context.Context, number int) (*domain.Checkpoint, error) {
+	return c.checkpointCall(ctx, "/api/v0/checkpoints/"+strconv.Itoa(number))
+}
+
+// CheckpointVerifier fetches the public key of the checkpoints and returns a verifier for it.
+func (c *Client) CheckpointVerifier(ctx context.Context) (crypto.Verifier, error) {
+	publicKey := &api.CheckpointPublicKeyResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/v0/checkpoints/public-key", nil, publicKey); err != nil {
+		return nil, err
+	}
+	key, err := crypto.DecodePublicKey([]byte(publicKey.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewVerifier(key)
+}
+
+// TransactionProof returns the inclusion proof of a transaction in a checkpoint.
+// A nil checkpoint number selects the latest checkpoint.
+func (c *Client) TransactionProof(ctx context.Context, deviceId string, counter int, checkpointNumber *int) (*domain.InclusionProof, error) {
+	path := devicePath(deviceId) + "/transactions/" + strconv.Itoa(counter) + "/proof"
+	if checkpointNumber != nil {
+		path += "?" + url.Values{"checkpoint": {strconv.Itoa(*checkpointNumber)}}.Encode()
+	}
+	proof := &domain.InclusionProof{}
+	if err := c.call(ctx, http.MethodGet, path, nil, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (c *Client) checkpointCall(ctx context.Context, path string) (*domain.Checkpoint, error) {
+	checkpoint := &domain.Checkpoint{}
+	if err := c.call(ctx, http.MethodGet, path, nil, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,220 @@
+// Package client is a typed Go client for the REST API of the signing service.
+//
+// Requests that are safe to repeat are retried on network errors, 429 and 5xx
+// responses. POST requests carry a generated Idempotency-Key, so a retried
+// request is applied by the server at most once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 200 * time.Millisecond
+)
+
+// Client calls the endpoints exposed by api.Server.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used to send requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithMaxRetries sets how often a failed request is retried. Zero disables retries.
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry. It doubles with every further retry.
+func WithRetryBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retryBackoff = backoff
+	}
+}
+
+// New is a factory to instantiate a new Client for the service at baseURL, e.g. http://localhost:8080.
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Error is returned for responses with a 4xx or 5xx status code.
+// Branch on Code, e.g. api.CodeDeviceNotFound, rather than on Detail.
+type Error struct {
+	StatusCode int
+	api.Problem
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("signing service responded with status %d", e.StatusCode)
+	}
+	if e.Detail == "" {
+		return fmt.Sprintf("signing service responded with status %d (%s)", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("signing service responded with status %d (%s): %s", e.StatusCode, e.Code, e.Detail)
+}
+
+// call sends body as JSON and decodes the data field of the response container into out.
+func (c *Client) call(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	response, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	_, err = decodeResponse(response, out)
+	return err
+}
+
+// callPage sends a GET request for a page of a listing. It decodes the data field
+// of the response container into out and returns the pagination of the page.
+func (c *Client) callPage(ctx context.Context, path string, out interface{}) (*api.Pagination, error) {
+	response, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	container, err := decodeResponse(response, out)
+	if err != nil {
+		return nil, err
+	}
+	if container.Pagination == nil {
+		return &api.Pagination{}, nil
+	}
+	return container.Pagination, nil
+}
+
+// callDocument sends a GET request for a JSON document that is not wrapped in a
+// response container, e.g. a JSON Web Key Set, and decodes it into out.
+func (c *Client) callDocument(ctx context.Context, path string, out interface{}) error {
+	response, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("cannot decode response: %w", err)
+	}
+	return nil
+}
+
+func decodeResponse(response *http.Response, out interface{}) (*api.Response, error) {
+	container := &api.Response{Data: out}
+	if err := json.NewDecoder(response.Body).Decode(container); err != nil {
+		return nil, fmt.Errorf("cannot decode response: %w", err)
+	}
+	return container, nil
+}
+
+// send performs a request with retries and returns the first successful response.
+// The caller has to close its body.
+func (c *Client) send(ctx context.Context, method string, path string, body interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	idempotencyKey := ""
+	if method == http.MethodPost {
+		idempotencyKey = uuid.New().String()
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.sendOnce(ctx, method, path, payload, idempotencyKey)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return response, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, method string, path string, payload []byte, idempotencyKey string) (*http.Response, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		request.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+		apiErr := &Error{StatusCode: response.StatusCode}
+		// error bodies are not always structured, the status code is reported regardless
+		_ = json.NewDecoder(response.Body).Decode(&apiErr.Problem)
+		return nil, apiErr
+	}
+	return response, nil
+}
+
+// retryable reports whether a request may succeed if it is sent again.
+// Transport errors are retried, a cancelled context ends the retries in send.
+func retryable(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+}
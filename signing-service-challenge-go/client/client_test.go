@@ -0,0 +1,547 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jose"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Client_SignAndVerify(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Label: "register"})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	clientId := registerTestClient(t, c, device.Id)
+	signature, err := c.Sign(ctx, device.Id, clientId, "payload")
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	verification, err := c.Verify(ctx, device.Id, api.VerifyRequest{
+		SignedData: signature.SignedData,
+		Signature:  signature.Signature.Signature,
+	})
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	verification, err = c.Verify(ctx, device.Id, api.VerifyRequest{
+		SignedData: signature.SignedData + "x",
+		Signature:  signature.Signature.Signature,
+	})
+	assert.NoError(t, err)
+	assert.False(t, verification.Valid)
+
+	fetched, err := c.GetDevice(ctx, device.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetched.Counter())
+}
+
+func Test_Client_RetriesWithIdempotencyKey(t *testing.T) {
+	handler := api.NewServer(":8081").Handler()
+	var failures int32 = 1
+	// the first signing request is applied by the server, but its response gets lost
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v0/transaction" && atomic.AddInt32(&failures, -1) == 0 {
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	c := New(server.URL, WithRetryBackoff(time.Millisecond))
+	ctx := context.Background()
+
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.RSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	clientId := registerTestClient(t, c, device.Id)
+	signature, err := c.Sign(ctx, device.Id, clientId, "payload")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, signature.Signature.Counter)
+
+	fetched, err := c.GetDevice(ctx, device.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetched.Counter())
+}
+
+func Test_Client_DecodesErrors(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+
+	_, err := c.GetDevice(context.Background(), "unknown")
+
+	apiErr := &Error{}
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, api.CodeDeviceNotFound, apiErr.Code)
+		assert.Equal(t, "device not found", apiErr.Detail)
+		assert.NotEmpty(t, apiErr.RequestId)
+	}
+}
+
+func Test_Client_ContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	c := New(server.URL, WithRetryBackoff(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ListDevices(ctx, ListDevicesOptions{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_Client_ListDevicesPages(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+
+	for _, label := range []string{"kasse 1", "kasse 2", "kasse 3", "backoffice"} {
+		_, err := c.CreateDevice(ctx, api.CreateDeviceRequest{
+			SignatureAlgorithm: domain.ECDSA,
+			Label:              label,
+			Metadata:           map[string]string{"store": "berlin"},
+		})
+		if err != nil {
+			t.Fatalf("Could not create device: %v", err)
+		}
+	}
+	options := ListDevicesOptions{Label: "kasse", Metadata: map[string]string{"store": "berlin"}, Sort: "-label", Limit: 2}
+
+	page, err := c.ListDevices(ctx, options)
+	assert.NoError(t, err)
+	assert.Len(t, page.Devices, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	devices, err := c.ListAllDevices(ctx, options)
+	assert.NoError(t, err)
+	labels := []string{}
+	for _, device := range devices {
+		labels = append(labels, device.Label)
+	}
+	assert.Equal(t, []string{"kasse 3", "kasse 2", "kasse 1"}, labels)
+}
+
+func Test_Client_SearchAndExportTransactions(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{
+		SignatureAlgorithm: domain.ECDSA,
+		Label:              "register 7",
+		Metadata:           map[string]string{"register": "7"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	clientId := registerTestClient(t, c, device.Id)
+	for _, reference := range []string{"R-1", "R-2", "R-3"} {
+		_, err := c.SignTransaction(ctx, api.SignTransactionRequest{DeviceId: device.Id, ClientId: clientId, Data: "receipt", Reference: reference})
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+	}
+
+	page, err := c.SearchTransactions(ctx, TransactionSearchOptions{DeviceMetadata: map[string]string{"register": "7"}, Reference: "R-2"})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Transactions, 1) {
+		assert.Equal(t, 1, page.Transactions[0].Counter)
+	}
+
+	counterFrom := 1
+	page, err = c.SearchTransactions(ctx, TransactionSearchOptions{CounterFrom: &counterFrom, Sort: "-signed_at", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "R-3", page.Transactions[0].Reference)
+	assert.NotEmpty(t, page.NextCursor)
+
+	export := &bytes.Buffer{}
+	assert.NoError(t, c.ExportTransactions(ctx, TransactionSearchOptions{DeviceIds: []string{device.Id}}, "csv", export))
+	assert.Equal(t, 4, strings.Count(export.String(), "\n"))
+}
+
+func Test_Client_FiscalTransaction(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+
+	clientId := registerTestClient(t, c, device.Id)
+
+	started, err := c.StartFiscalTransaction(ctx, device.Id, api.FiscalTransactionRequest{ClientId: clientId, ProcessType: "Kassenbeleg-V1"})
+	if err != nil {
+		t.Fatalf("Could not start fiscal transaction: %v", err)
+	}
+	number := started.FiscalTransaction.Number
+	_, err = c.UpdateFiscalTransaction(ctx, device.Id, number, api.FiscalTransactionRequest{ProcessData: "Beleg^5.00:Bar"})
+	assert.NoError(t, err)
+	finished, err := c.FinishFiscalTransaction(ctx, device.Id, number, api.FiscalTransactionRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.FiscalFinished, finished.FiscalTransaction.State)
+	assert.Equal(t, "Beleg^5.00:Bar", finished.FiscalTransaction.ProcessData)
+
+	fetched, err := c.GetFiscalTransaction(ctx, device.Id, number)
+	assert.NoError(t, err)
+	assert.Len(t, fetched.Log, 3)
+
+	_, err = c.FinishFiscalTransaction(ctx, device.Id, number, api.FiscalTransactionRequest{})
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeFiscalTransactionFinished, apiErr.Code)
+	}
+
+	fiscalTransactions, err := c.ListFiscalTransactions(ctx, device.Id)
+	assert.NoError(t, err)
+	assert.Len(t, fiscalTransactions, 1)
+}
+
+func Test_Client_Clients(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	first, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	second, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	clientId := registerTestClient(t, c, first.Id)
+
+	_, err = c.Sign(ctx, second.Id, clientId, "payload")
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeClientNotRegistered, apiErr.Code)
+	}
+
+	client, err := c.RegisterClientDevice(ctx, clientId, second.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first.Id, second.Id}, client.DeviceIds)
+	_, err = c.Sign(ctx, second.Id, clientId, "payload")
+	assert.NoError(t, err)
+
+	client, err = c.DeregisterClientDevice(ctx, clientId, first.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{second.Id}, client.DeviceIds)
+	clients, err := c.ListClients(ctx, first.Id)
+	assert.NoError(t, err)
+	assert.Empty(t, clients)
+
+	fetched, err := c.GetClient(ctx, clientId)
+	assert.NoError(t, err)
+	assert.Equal(t, "KASSE-"+first.Id, fetched.SerialNumber)
+}
+
+func registerTestClient(t *testing.T, c *Client, deviceId string) string {
+	client, err := c.RegisterClient(context.Background(), api.RegisterClientRequest{
+		SerialNumber: "KASSE-" + deviceId,
+		DeviceIds:    []string{deviceId},
+	})
+	if err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	return client.Id
+}
+
+func Test_Client_TransactionProof(t *testing.T) {
+	deviceStore := persistence.NewInMemoryDeviceStore()
+	transactionStore := persistence.NewInMemoryTransactionStore()
+	fiscalTransactionStore := persistence.NewInMemoryFiscalTransactionStore()
+	clientStore := persistence.NewInMemoryClientStore()
+	logStore := persistence.NewInMemoryLogStore()
+	checkpointKey, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate checkpoint key: %v", err)
+	}
+	option := service.WithCheckpointKey(checkpointKey)
+	server := httptest.NewServer(api.NewServerWithStores(":8081", deviceStore, transactionStore, fiscalTransactionStore, clientStore, logStore, option).Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	signature, err := c.Sign(ctx, device.Id, registerTestClient(t, c, device.Id), "payload")
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	_, err = c.TransactionProof(ctx, device.Id, 0, nil)
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeTransactionNotAnchored, apiErr.Code)
+	}
+
+	signingService := service.NewSigningService(deviceStore, transactionStore, fiscalTransactionStore, clientStore, logStore, option)
+	if _, err := signingService.CreateCheckpoint(); err != nil {
+		t.Fatalf("Could not create checkpoint: %v", err)
+	}
+	number := 1
+	proof, err := c.TransactionProof(ctx, device.Id, 0, &number)
+	if !assert.NoError(t, err) {
+		return
+	}
+	verifier, err := c.CheckpointVerifier(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, proof.Verify(signature.Signature))
+	assert.NoError(t, proof.Checkpoint.Verify(verifier))
+
+	latest, err := c.LatestCheckpoint(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, proof.Checkpoint, *latest)
+	checkpoints, err := c.ListCheckpoints(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+	_, err = c.GetCheckpoint(ctx, 2)
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeCheckpointNotFound, apiErr.Code)
+	}
+}
+
+func Test_Client_JWKs(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	signature, err := c.SignTransaction(ctx, api.SignTransactionRequest{DeviceId: device.Id, ClientId: registerTestClient(t, c, device.Id), Data: "payload", Format: domain.FormatJWS})
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	jwk, err := c.DeviceJWK(ctx, device.Id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	set, err := c.JWKSet(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the JWS names the key it is signed with in the set
+	header, err := jose.ParseHeader(signature.JWS)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, jwk, set.Key(header.KeyId))
+	publicKey, err := jwk.PublicKey()
+	if assert.NoError(t, err) {
+		_, _, err = jose.Verify(signature.JWS, publicKey)
+		assert.NoError(t, err)
+	}
+
+	_, err = c.DeviceJWK(ctx, "unknown")
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeDeviceNotFound, apiErr.Code)
+	}
+}
+
+func Test_Client_Certificates(t *testing.T) {
+	authority, err := ca.NewAuthority("Test Device CA")
+	if err != nil {
+		t.Fatalf("Could not create certificate authority: %v", err)
+	}
+	server := httptest.NewServer(api.NewServerWithStores(":8081", persistence.NewInMemoryDeviceStore(), persistence.NewInMemoryTransactionStore(),
+		persistence.NewInMemoryFiscalTransactionStore(), persistence.NewInMemoryClientStore(), persistence.NewInMemoryLogStore(),
+		service.WithCertificateAuthority(authority)).Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Label: "register"})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+
+	// the certificate of the device is issued by the published CA
+	certificates, err := c.CertificateAuthority(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM([]byte(certificates.Certificate)))
+	self, err := c.IssueDeviceCertificate(ctx, device.Id, domain.IssuerSelf)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, device.Certificate, self.Certificate)
+	}
+	issued, err := c.IssueDeviceCertificate(ctx, device.Id, domain.IssuerCA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	chain, err := domain.ParseCertificates([]byte(issued.Certificate))
+	if assert.NoError(t, err) && assert.Len(t, chain, 2) {
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(chain[1])
+		_, err = chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+		assert.NoError(t, err)
+	}
+
+	// a certificate signing request is answered by another PKI
+	csr, err := c.DeviceCertificateRequest(ctx, device.Id, api.CertificateSigningRequest{Organization: "Example GmbH", Country: "DE"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	block, _ := pem.Decode([]byte(csr.CSR))
+	if !assert.NotNil(t, block) {
+		return
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if !assert.NoError(t, err) || !assert.NoError(t, request.CheckSignature()) {
+		return
+	}
+	assert.Equal(t, device.Id, request.Subject.CommonName)
+	external, err := ca.NewAuthority("External CA")
+	if err != nil {
+		t.Fatalf("Could not create certificate authority: %v", err)
+	}
+	template, err := device.CertificateTemplate()
+	if err != nil {
+		t.Fatalf("Could not create certificate template: %v", err)
+	}
+	externalChain, err := external.Issue(template, request.PublicKey)
+	if err != nil {
+		t.Fatalf("Could not issue certificate: %v", err)
+	}
+	encoded := ""
+	for _, certificate := range externalChain {
+		encoded += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+	}
+	imported, err := c.ImportDeviceCertificate(ctx, device.Id, encoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, encoded, imported.Certificate)
+	}
+
+	_, err = c.ImportDeviceCertificate(ctx, device.Id, "not a certificate")
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeValidationFailed, apiErr.Code)
+	}
+}
+
+func Test_Client_CertificateRevocationList(t *testing.T) {
+	authority, err := ca.NewAuthority("Test Device CA")
+	if err != nil {
+		t.Fatalf("Could not create certificate authority: %v", err)
+	}
+	server := httptest.NewServer(api.NewServerWithStores(":8081", persistence.NewInMemoryDeviceStore(), persistence.NewInMemoryTransactionStore(),
+		persistence.NewInMemoryFiscalTransactionStore(), persistence.NewInMemoryClientStore(), persistence.NewInMemoryLogStore(),
+		service.WithCertificateAuthority(authority)).Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	device, err := c.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Label: "register"})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	certificates, err := domain.ParseCertificates([]byte(device.Certificate))
+	if err != nil || len(certificates) == 0 {
+		t.Fatalf("Could not parse device certificate: %v", err)
+	}
+
+	crl, err := c.CertificateRevocationList(ctx)
+	if assert.NoError(t, err) {
+		assert.NoError(t, crl.CheckSignatureFrom(authority.Intermediate()))
+		assert.Empty(t, crl.RevokedCertificates)
+	}
+
+	// the certificate of the rotated key is revoked as superseded
+	_, err = c.RotateDeviceKey(ctx, device.Id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	crl, err = c.CertificateRevocationList(ctx)
+	if assert.NoError(t, err) && assert.Len(t, crl.RevokedCertificates, 1) {
+		assert.Equal(t, certificates[0].SerialNumber, crl.RevokedCertificates[0].SerialNumber)
+	}
+
+	disabled := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer disabled.Close()
+	_, err = New(disabled.URL).CertificateRevocationList(ctx)
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, api.CodeCertificateAuthorityDisabled, apiErr.Code)
+	}
+}
+
+func Test_Client_ImportDevice(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+	c := New(server.URL)
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %v", err)
+	}
+	counter := 41
+	device, err := c.ImportDevice(ctx, api.ImportDeviceRequest{
+		SignatureAlgorithm: domain.ECDSA,
+		Label:              "migrated",
+		PrivateKey:         string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		SignatureCounter:   &counter,
+		LastSignature:      "cHJldmlvdXM=",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &domain.ChainStart{Counter: 41, LastSignature: "cHJldmlvdXM="}, device.ImportedChain)
+
+	// the first transaction continues the imported chain
+	response, err := c.Sign(ctx, device.Id, registerTestClient(t, c, device.Id), "data")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 41, response.Signature.Counter)
+		assert.Equal(t, "cHJldmlvdXM=", response.Signature.LastSignature)
+	}
+
+	_, err = c.ImportDevice(ctx, api.ImportDeviceRequest{SignatureAlgorithm: domain.RSA, PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))})
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// RegisterClient registers a cash register with the devices it may sign with.
+func (c *Client) RegisterClient(ctx context.Context, registerReq api.RegisterClientRequest) (*domain.Client, error) {
+	client := &domain.Client{}
+	if err := c.call(ctx, http.MethodPost, "/api/v0/clients", registerReq, client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// GetClient returns a single client with the devices it is registered to.
+func (c *Client) GetClient(ctx context.Context, clientId string) (*domain.Client, error) {
+	return c.clientCall(ctx, http.MethodGet, clientPath(clientId))
+}
+
+// ListClients returns all clients in order of registration. If deviceId is not
+// empty, only the clients registered to that device are returned.
+func (c *Client) ListClients(ctx context.Context, deviceId string) ([]*domain.Client, error) {
+	path := "/api/v0/clients"
+	if deviceId != "" {
+		path += "?" + url.Values{"device_id": {deviceId}}.Encode()
+	}
+	clients := []*domain.Client{}
+	if err := c.call(ctx, http.MethodGet, path, nil, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// RegisterClientDevice allows a client to sign with another device.
+func (c *Client) RegisterClientDevice(ctx context.Context, clientId string, deviceId string) (*domain.Client, error) {
+	return c.clientCall(ctx, http.MethodPut, clientDevicePath(clientId, deviceId))
+}
+
+// DeregisterClientDevice revokes the permission of a client to sign with a device.
+func (c *Client) DeregisterClientDevice(ctx context.Context, clientId string, deviceId string) (*domain.Client, error) {
+	return c.clientCall(ctx, http.MethodDelete, clientDevicePath(clientId, deviceId))
+}
+
+func (c *Client) clientCall(ctx context.Context, method string, path string) (*domain.Client, error) {
+	client := &domain.Client{}
+	if err := c.call(ctx, method, path, nil, client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func clientPath(clientId string) string {
+	return "/api/v0/clients/" + url.PathEscape(clientId)
+}
+
+func clientDevicePath(clientId string, deviceId string) string {
+	return clientPath(clientId) + "/devices/" + url.PathEscape(deviceId)
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// Health returns the health status of the service.
+func (c *Client) Health(ctx context.Context) (*api.HealthResponse, error) {
+	health := &api.HealthResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/v0/health", nil, health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// CreateDevice creates a new signature device.
+func (c *Client) CreateDevice(ctx context.Context, createReq api.CreateDeviceRequest) (*domain.SignatureDevice, error) {
+	device := &domain.SignatureDevice{}
+	if err := c.call(ctx, http.MethodPost, "/api/v0/devices", createReq, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// ImportDevice creates a signature device with a key pair and, optionally, the
+// signature chain it had with another signing service.
+func (c *Client) ImportDevice(ctx context.Context, importReq api.ImportDeviceRequest) (*domain.SignatureDevice, error) {
+	device := &domain.SignatureDevice{}
+	if err := c.call(ctx, http.MethodPost, "/api/v0/devices/import", importReq, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// ListDevicesOptions selects the devices of a listing. Zero values match every device.
+type ListDevicesOptions struct {
+	// Label matches devices whose label contains it, ignoring case.
+	Label     string
+	Algorithm domain.SignatureAlgorithm
+	Status    domain.DeviceStatus
+	// CreatedFrom and CreatedTo bound the creation time, both inclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Metadata matches devices carrying all of the given tags.
+	Metadata map[string]string
+	// Sort is one of created_at, -created_at, label or -label.
+	Sort string
+	// Limit is the size of a page, the server applies its default if it is zero.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// DevicePage is a page of a device listing. NextCursor is empty on the last page.
+type DevicePage struct {
+	Devices    []*domain.SignatureDevice
+	NextCursor string
+}
+
+// ListDevices returns a page of the signature devices matching the options.
+func (c *Client) ListDevices(ctx context.Context, options ListDevicesOptions) (*DevicePage, error) {
+	devices := []*domain.SignatureDevice{}
+	pagination, err := c.callPage(ctx, "/api/v0/devices"+options.query(), &devices)
+	if err != nil {
+		return nil, err
+	}
+	return &DevicePage{Devices: devices, NextCursor: pagination.NextCursor}, nil
+}
+
+// ListAllDevices returns the signature devices matching the options from all pages,
+// starting at options.Cursor.
+func (c *Client) ListAllDevices(ctx context.Context, options ListDevicesOptions) ([]*domain.SignatureDevice, error) {
+	devices := []*domain.SignatureDevice{}
+	for {
+		page, err := c.ListDevices(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, page.Devices...)
+		if page.NextCursor == "" {
+			return devices, nil
+		}
+		options.Cursor = page.NextCursor
+	}
+}
+
+func (o ListDevicesOptions) query() string {
+	query := url.Values{}
+	if o.Label != "" {
+		query.Set("label", o.Label)
+	}
+	if o.Algorithm != "" {
+		query.Set("algorithm", string(o.Algorithm))
+	}
+	if o.Status != "" {
+		query.Set("status", string(o.Status))
+	}
+	if o.CreatedFrom != nil {
+		query.Set("created_from", o.CreatedFrom.Format(time.RFC3339Nano))
+	}
+	if o.CreatedTo != nil {
+		query.Set("created_to", o.CreatedTo.Format(time.RFC3339Nano))
+	}
+	for key, value := range o.Metadata {
+		query.Add("metadata", key+":"+value)
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		query.Set("cursor", o.Cursor)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// GetDevice returns a single signature device including its signature counter.
+func (c *Client) GetDevice(ctx context.Context, deviceId string) (*domain.SignatureDevice, error) {
+	return c.deviceCall(ctx, http.MethodGet, deviceId, "")
+}
+
+// RotateDeviceKey replaces the key pair of a device.
+func (c *Client) RotateDeviceKey(ctx context.Context, deviceId string) (*domain.SignatureDevice, error) {
+	return c.deviceCall(ctx, http.MethodPost, deviceId, "/rotate")
+}
+
+// DeactivateDevice takes a device out of service.
+func (c *Client) DeactivateDevice(ctx context.Context, deviceId string) (*domain.SignatureDevice, error) {
+	return c.deviceCall(ctx, http.MethodPost, deviceId, "/deactivate")
+}
+
+func (c *Client) deviceCall(ctx context.Context, method string, deviceId string, suffix string) (*domain.SignatureDevice, error) {
+	device := &domain.SignatureDevice{}
+	if err := c.call(ctx, method, devicePath(deviceId)+suffix, nil, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// Sign signs data with a device on behalf of a client registered to it.
+func (c *Client) Sign(ctx context.Context, deviceId string, clientId string, data string) (*domain.SignatureResponse, error) {
+	return c.SignTransaction(ctx, api.SignTransactionRequest{
+		DeviceId: deviceId,
+		ClientId: clientId,
+		Data:     data,
+	})
+}
+
+// SignTransaction signs the data of a request with its device, storing the optional reference.
+func (c *Client) SignTransaction(ctx context.Context, signReq api.SignTransactionRequest) (*domain.SignatureResponse, error) {
+	signature := &domain.SignatureResponse{}
+	if err := c.call(ctx, http.MethodPost, "/api/v0/transaction", signReq, signature); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// Verify checks a signature over signed data with a key of the device.
+func (c *Client) Verify(ctx context.Context, deviceId string, verifyReq api.VerifyRequest) (*api.VerifyResponse, error) {
+	verification := &api.VerifyResponse{}
+	if err := c.call(ctx, http.MethodPost, devicePath(deviceId)+"/verify", verifyReq, verification); err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// ListTransactions returns all transactions of a device in counter order.
+func (c *Client) ListTransactions(ctx context.Context, deviceId string) ([]*domain.Transaction, error) {
+	transactions := []*domain.Transaction{}
+	if err := c.call(ctx, http.MethodGet, devicePath(deviceId)+"/transactions", nil, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Audit verifies the signature chain of a device on the server.
+func (c *Client) Audit(ctx context.Context, deviceId string) (*domain.AuditReport, error) {
+	report := &domain.AuditReport{}
+	if err := c.call(ctx, http.MethodGet, devicePath(deviceId)+"/audit", nil, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Export writes the signed tar export archive of a device to w.
+// from and to optionally restrict the transactions by their signing time.
+func (c *Client) Export(ctx context.Context, deviceId string, from *time.Time, to *time.Time, w io.Writer) error {
+	query := url.Values{}
+	if from != nil {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if to != nil {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+	path := devicePath(deviceId) + "/export"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	response, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+func devicePath(deviceId string) string {
+	return "/api/v0/devices/" + url.PathEscape(deviceId)
+}
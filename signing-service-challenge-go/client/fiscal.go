@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// StartFiscalTransaction starts a fiscal transaction on a device and signs the start.
+func (c *Client) StartFiscalTransaction(ctx context.Context, deviceId string, fiscalReq api.FiscalTransactionRequest) (*domain.FiscalTransactionResponse, error) {
+	resp := &domain.FiscalTransactionResponse{}
+	if err := c.call(ctx, http.MethodPost, fiscalTransactionsPath(deviceId), fiscalReq, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// UpdateFiscalTransaction signs an update of the process of an active fiscal transaction.
+// Empty fields of the request keep the values of the previous state.
+func (c *Client) UpdateFiscalTransaction(ctx context.Context, deviceId string, number int, fiscalReq api.FiscalTransactionRequest) (*domain.FiscalTransactionResponse, error) {
+	return c.changeFiscalTransaction(ctx, deviceId, number, "/update", fiscalReq)
+}
+
+// FinishFiscalTransaction signs the final process of an active fiscal transaction.
+// Empty fields of the request keep the values of the previous state.
+func (c *Client) FinishFiscalTransaction(ctx context.Context, deviceId string, number int, fiscalReq api.FiscalTransactionRequest) (*domain.FiscalTransactionResponse, error) {
+	return c.changeFiscalTransaction(ctx, deviceId, number, "/finish", fiscalReq)
+}
+
+func (c *Client) changeFiscalTransaction(ctx context.Context, deviceId string, number int, suffix string, fiscalReq api.FiscalTransactionRequest) (*domain.FiscalTransactionResponse, error) {
+	resp := &domain.FiscalTransactionResponse{}
+	if err := c.call(ctx, http.MethodPost, fiscalTransactionPath(deviceId, number)+suffix, fiscalReq, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetFiscalTransaction returns a fiscal transaction with its log of signed state changes.
+func (c *Client) GetFiscalTransaction(ctx context.Context, deviceId string, number int) (*domain.FiscalTransaction, error) {
+	fiscalTransaction := &domain.FiscalTransaction{}
+	if err := c.call(ctx, http.MethodGet, fiscalTransactionPath(deviceId, number), nil, fiscalTransaction); err != nil {
+		return nil, err
+	}
+	return fiscalTransaction, nil
+}
+
+// ListFiscalTransactions returns all fiscal transactions of a device ordered by number.
+func (c *Client) ListFiscalTransactions(ctx context.Context, deviceId string) ([]*domain.FiscalTransaction, error) {
+	fiscalTransactions := []*domain.FiscalTransaction{}
+	if err := c.call(ctx, http.MethodGet, fiscalTransactionsPath(deviceId), nil, &fiscalTransactions); err != nil {
+		return nil, err
+	}
+	return fiscalTransactions, nil
+}
+
+func fiscalTransactionsPath(deviceId string) string {
+	return devicePath(deviceId) + "/fiscal-transactions"
+}
+
+func fiscalTransactionPath(deviceId string, number int) string {
+	return fiscalTransactionsPath(deviceId) + "/" + strconv.Itoa(number)
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jose"
+)
+
+// JWKSet returns the public keys of all devices as a JSON Web Key Set, to verify
+// the JWS signatures of their transactions by key id.
+func (c *Client) JWKSet(ctx context.Context) (*jose.JWKSet, error) {
+	set := &jose.JWKSet{}
+	if err := c.callDocument(ctx, "/api/v0/jwks.json", set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// DeviceJWK returns the current public key of a device as a JSON Web Key.
+func (c *Client) DeviceJWK(ctx context.Context, deviceId string) (*jose.JWK, error) {
+	jwk := &jose.JWK{}
+	if err := c.callDocument(ctx, devicePath(deviceId)+"/jwk", jwk); err != nil {
+		return nil, err
+	}
+	return jwk, nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// TransactionSearchOptions selects the transactions of a search across devices.
+// Zero values match every transaction.
+type TransactionSearchOptions struct {
+	DeviceIds []string
+	// SignedFrom and SignedTo bound the signing time, both inclusive.
+	SignedFrom *time.Time
+	SignedTo   *time.Time
+	// CounterFrom and CounterTo bound the signature counter, both inclusive.
+	CounterFrom *int
+	CounterTo   *int
+	// Reference matches transactions with exactly this reference.
+	Reference string
+	// DeviceLabel and DeviceMetadata select the devices whose transactions are searched.
+	DeviceLabel    string
+	DeviceMetadata map[string]string
+	// Sort is signed_at or -signed_at.
+	Sort string
+	// Limit is the size of a page, the server applies its default if it is zero.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// TransactionPage is a page of a transaction search. NextCursor is empty on the last page.
+type TransactionPage struct {
+	Transactions []*domain.Transaction
+	NextCursor   string
+}
+
+// SearchTransactions returns a page of the transactions of all devices matching the options.
+func (c *Client) SearchTransactions(ctx context.Context, options TransactionSearchOptions) (*TransactionPage, error) {
+	transactions := []*domain.Transaction{}
+	pagination, err := c.callPage(ctx, "/api/v0/transactions?"+options.query().Encode(), &transactions)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionPage{Transactions: transactions, NextCursor: pagination.NextCursor}, nil
+}
+
+// ExportTransactions writes all transactions matching the options to w, formatted
+// as "csv" or "ndjson". options.Limit sets how many transactions the server
+// fetches from its store at once.
+func (c *Client) ExportTransactions(ctx context.Context, options TransactionSearchOptions, format string, w io.Writer) error {
+	query := options.query()
+	query.Set("format", format)
+	response, err := c.send(ctx, http.MethodGet, "/api/v0/transactions/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+func (o TransactionSearchOptions) query() url.Values {
+	query := url.Values{}
+	for _, deviceId := range o.DeviceIds {
+		query.Add("device_id", deviceId)
+	}
+	if o.SignedFrom != nil {
+		query.Set("signed_from", o.SignedFrom.Format(time.RFC3339Nano))
+	}
+	if o.SignedTo != nil {
+		query.Set("signed_to", o.SignedTo.Format(time.RFC3339Nano))
+	}
+	if o.CounterFrom != nil {
+		query.Set("counter_from", strconv.Itoa(*o.CounterFrom))
+	}
+	if o.CounterTo != nil {
+		query.Set("counter_to", strconv.Itoa(*o.CounterTo))
+	}
+	if o.Reference != "" {
+		query.Set("reference", o.Reference)
+	}
+	if o.DeviceLabel != "" {
+		query.Set("label", o.DeviceLabel)
+	}
+	for key, value := range o.DeviceMetadata {
+		query.Add("metadata", key+":"+value)
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		query.Set("cursor", o.Cursor)
+	}
+	return query
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+type cli struct {
+	client *client.Client
+	json   bool
+	stdout io.Writer
+}
+
+func (c *cli) create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	algorithm := flags.String("algorithm", "", "signature algorithm: RSA or ECC")
+	label := flags.String("label", "", "label of the device")
+	envelope := flags.String("envelope", "", "envelope version: legacy, v1 or v2")
+	tags := tagFlag{}
+	flags.Var(tags, "tag", "tag of the device, as key:value (repeatable)")
+	if err := flags.Parse(args); err != nil || *algorithm == "" || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	device, err := c.client.CreateDevice(context.Background(), api.CreateDeviceRequest{
+		SignatureAlgorithm: domain.SignatureAlgorithm(*algorithm),
+		Label:              *label,
+		EnvelopeVersion:    domain.EnvelopeVersion(*envelope),
+		Metadata:           tags,
+	})
+	if err != nil {
+		return err
+	}
+	return c.writeDevice(device)
+}
+
+func (c *cli) list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	options := client.ListDevicesOptions{}
+	flags.StringVar(&options.Label, "label", "", "only list devices whose label contains this text")
+	algorithm := flags.String("algorithm", "", "only list devices with this algorithm: RSA or ECC")
+	status := flags.String("status", "", "only list devices with this status: active or deactivated")
+	createdFrom := flags.String("created-from", "", "only list devices created at or after this time (RFC 3339)")
+	createdTo := flags.String("created-to", "", "only list devices created at or before this time (RFC 3339)")
+	tags := tagFlag{}
+	flags.Var(tags, "tag", "only list devices carrying this tag, as key:value (repeatable)")
+	flags.StringVar(&options.Sort, "sort", "", "sort order: created_at, -created_at, label or -label")
+	flags.IntVar(&options.Limit, "page-size", 0, "number of devices fetched per request")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	options.Algorithm = domain.SignatureAlgorithm(*algorithm)
+	options.Status = domain.DeviceStatus(*status)
+	options.Metadata = tags
+	var err error
+	if options.CreatedFrom, err = parseTimeFlag(*createdFrom); err != nil {
+		return err
+	}
+	if options.CreatedTo, err = parseTimeFlag(*createdTo); err != nil {
+		return err
+	}
+
+	devices, err := c.client.ListAllDevices(context.Background(), options)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(devices)
+	}
+	return writeDeviceTable(c.stdout, devices)
+}
+
+// tagFlag collects repeated key:value flags.
+type tagFlag map[string]string
+
+func (f tagFlag) String() string {
+	tags := make([]string, 0, len(f))
+	for key, value := range f {
+		tags = append(tags, key+":"+value)
+	}
+	return strings.Join(tags, ",")
+}
+
+func (f tagFlag) Set(tag string) error {
+	key, value, found := strings.Cut(tag, ":")
+	if !found || key == "" {
+		return fmt.Errorf("%q is not a key:value tag", tag)
+	}
+	f[key] = value
+	return nil
+}
+
+func (c *cli) show(args []string) error {
+	return c.deviceCommand(args, c.client.GetDevice)
+}
+
+func (c *cli) rotate(args []string) error {
+	return c.deviceCommand(args, c.client.RotateDeviceKey)
+}
+
+func (c *cli) deactivate(args []string) error {
+	return c.deviceCommand(args, c.client.DeactivateDevice)
+}
+
+// deviceCommand calls an endpoint of a single device that responds with the device.
+func (c *cli) deviceCommand(args []string, call func(context.Context, string) (*domain.SignatureDevice, error)) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	device, err := call(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	return c.writeDevice(device)
+}
+
+func (c *cli) sign(args []string) error {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	clientId := flags.String("client", "", "id of a client registered to the device")
+	reference := flags.String("reference", "", "reference of the transaction, e.g. a receipt number")
+	if err := flags.Parse(args); err != nil || *clientId == "" || flags.NArg() != 2 {
+		return errUsage
+	}
+	resp, err := c.client.SignTransaction(context.Background(), api.SignTransactionRequest{
+		DeviceId:  flags.Arg(0),
+		ClientId:  *clientId,
+		Data:      flags.Arg(1),
+		Reference: *reference,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "DEVICE\t%s\n", resp.Signature.DeviceId)
+	fmt.Fprintf(table, "CLIENT\t%s\n", resp.Signature.ClientId)
+	fmt.Fprintf(table, "COUNTER\t%d\n", resp.Signature.Counter)
+	fmt.Fprintf(table, "KEY VERSION\t%d\n", resp.Signature.KeyVersion)
+	fmt.Fprintf(table, "SIGNED DATA\t%s\n", resp.SignedData)
+	fmt.Fprintf(table, "SIGNATURE\t%s\n", resp.Signature.Signature)
+	return table.Flush()
+}
+
+// fiscal runs the subcommands of a fiscal transaction: start, update, finish, show and list.
+func (c *cli) fiscal(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	subcommand := args[0]
+	flags := flag.NewFlagSet("fiscal "+subcommand, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	fiscalReq := api.FiscalTransactionRequest{}
+	flags.StringVar(&fiscalReq.ClientId, "client", "", "id of the client changing the fiscal transaction")
+	flags.StringVar(&fiscalReq.ProcessType, "type", "", "process type, e.g. Kassenbeleg-V1")
+	flags.StringVar(&fiscalReq.ProcessData, "data", "", "process data")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	ctx := context.Background()
+	deviceId := flags.Arg(0)
+
+	switch subcommand {
+	case "start":
+		if flags.NArg() != 1 {
+			return errUsage
+		}
+		resp, err := c.client.StartFiscalTransaction(ctx, deviceId, fiscalReq)
+		if err != nil {
+			return err
+		}
+		return c.writeFiscalTransactionResponse(resp)
+	case "list":
+		if flags.NArg() != 1 {
+			return errUsage
+		}
+		fiscalTransactions, err := c.client.ListFiscalTransactions(ctx, deviceId)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.writeJSON(fiscalTransactions)
+		}
+		return writeFiscalTransactionTable(c.stdout, fiscalTransactions)
+	}
+
+	if flags.NArg() != 2 {
+		return errUsage
+	}
+	number, err := strconv.Atoi(flags.Arg(1))
+	if err != nil {
+		return errUsage
+	}
+	switch subcommand {
+	case "update", "finish":
+		change := c.client.UpdateFiscalTransaction
+		if subcommand == "finish" {
+			change = c.client.FinishFiscalTransaction
+		}
+		resp, err := change(ctx, deviceId, number, fiscalReq)
+		if err != nil {
+			return err
+		}
+		return c.writeFiscalTransactionResponse(resp)
+	case "show":
+		fiscalTransaction, err := c.client.GetFiscalTransaction(ctx, deviceId, number)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.writeJSON(fiscalTransaction)
+		}
+		return writeFiscalTransactionTable(c.stdout, []*domain.FiscalTransaction{fiscalTransaction})
+	default:
+		return errUsage
+	}
+}
+
+func (c *cli) writeFiscalTransactionResponse(resp *domain.FiscalTransactionResponse) error {
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "DEVICE\t%s\n", resp.FiscalTransaction.DeviceId)
+	fmt.Fprintf(table, "NUMBER\t%d\n", resp.FiscalTransaction.Number)
+	fmt.Fprintf(table, "CLIENT\t%s\n", resp.FiscalTransaction.ClientId)
+	fmt.Fprintf(table, "STATE\t%s\n", resp.FiscalTransaction.State)
+	fmt.Fprintf(table, "OPERATION\t%s\n", resp.Signature.FiscalOperation)
+	fmt.Fprintf(table, "COUNTER\t%d\n", resp.Signature.Counter)
+	fmt.Fprintf(table, "SIGNED DATA\t%s\n", resp.SignedData)
+	fmt.Fprintf(table, "SIGNATURE\t%s\n", resp.Signature.Signature)
+	return table.Flush()
+}
+
+// clients runs the subcommands of the clients: register, show, list, add and remove.
+func (c *cli) clients(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	subcommand := args[0]
+	flags := flag.NewFlagSet("client "+subcommand, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	serialNumber := flags.String("serial", "", "serial number of the cash register")
+	deviceId := flags.String("device", "", "only list clients registered to this device")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	ctx := context.Background()
+
+	switch subcommand {
+	case "register":
+		if *serialNumber == "" || flags.NArg() == 0 {
+			return errUsage
+		}
+		client, err := c.client.RegisterClient(ctx, api.RegisterClientRequest{
+			SerialNumber: *serialNumber,
+			DeviceIds:    flags.Args(),
+		})
+		if err != nil {
+			return err
+		}
+		return c.writeClient(client)
+	case "show":
+		if flags.NArg() != 1 {
+			return errUsage
+		}
+		client, err := c.client.GetClient(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return c.writeClient(client)
+	case "list":
+		if flags.NArg() != 0 {
+			return errUsage
+		}
+		clients, err := c.client.ListClients(ctx, *deviceId)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.writeJSON(clients)
+		}
+		return writeClientTable(c.stdout, clients)
+	case "add", "remove":
+		if flags.NArg() != 2 {
+			return errUsage
+		}
+		change := c.client.RegisterClientDevice
+		if subcommand == "remove" {
+			change = c.client.DeregisterClientDevice
+		}
+		client, err := change(ctx, flags.Arg(0), flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		return c.writeClient(client)
+	default:
+		return errUsage
+	}
+}
+
+func (c *cli) audit(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	report, err := c.client.Audit(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		err = c.writeJSON(report)
+	} else {
+		err = writeAuditTable(c.stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Valid {
+		return fmt.Errorf("audit of device %s found %d issue(s)", report.DeviceId, len(report.Issues))
+	}
+	return nil
+}
+
+func (c *cli) search(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	options := client.TransactionSearchOptions{}
+	devices := deviceIdsFlag{}
+	flags.Var(&devices, "device", "only include transactions of this device (repeatable)")
+	signedFrom := flags.String("signed-from", "", "only include transactions signed at or after this time (RFC 3339)")
+	signedTo := flags.String("signed-to", "", "only include transactions signed at or before this time (RFC 3339)")
+	counterFrom := flags.Int("counter-from", -1, "only include transactions with at least this signature counter")
+	counterTo := flags.Int("counter-to", -1, "only include transactions with at most this signature counter")
+	flags.StringVar(&options.Reference, "reference", "", "only include transactions with exactly this reference")
+	flags.StringVar(&options.DeviceLabel, "label", "", "only include transactions of devices whose label contains this text")
+	tags := tagFlag{}
+	flags.Var(tags, "tag", "only include transactions of devices carrying this tag, as key:value (repeatable)")
+	flags.StringVar(&options.Sort, "sort", "", "sort order: signed_at or -signed_at")
+	format := flags.String("format", "", "write an export instead of the output format: csv or ndjson")
+	flags.IntVar(&options.Limit, "page-size", 0, "number of transactions fetched per request")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	if *format != "" && *format != "csv" && *format != "ndjson" {
+		return errUsage
+	}
+	options.DeviceIds = devices
+	options.DeviceMetadata = tags
+	if *counterFrom >= 0 {
+		options.CounterFrom = counterFrom
+	}
+	if *counterTo >= 0 {
+		options.CounterTo = counterTo
+	}
+	var err error
+	if options.SignedFrom, err = parseTimeFlag(*signedFrom); err != nil {
+		return err
+	}
+	if options.SignedTo, err = parseTimeFlag(*signedTo); err != nil {
+		return err
+	}
+
+	if *format != "" {
+		return c.client.ExportTransactions(context.Background(), options, *format, c.stdout)
+	}
+	transactions := []*domain.Transaction{}
+	for {
+		page, err := c.client.SearchTransactions(context.Background(), options)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, page.Transactions...)
+		if page.NextCursor == "" {
+			break
+		}
+		options.Cursor = page.NextCursor
+	}
+	if c.json {
+		return c.writeJSON(transactions)
+	}
+	return writeTransactionTable(c.stdout, transactions)
+}
+
+// deviceIdsFlag collects repeated device id flags.
+type deviceIdsFlag []string
+
+func (f *deviceIdsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *deviceIdsFlag) Set(deviceId string) error {
+	*f = append(*f, deviceId)
+	return nil
+}
+
+func (c *cli) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	from := flags.String("from", "", "only export transactions signed at or after this time (RFC 3339)")
+	to := flags.String("to", "", "only export transactions signed at or before this time (RFC 3339)")
+	file := flags.String("file", "", "path of the archive, defaults to DEVICE_ID.tar")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	deviceId := flags.Arg(0)
+	if *file == "" {
+		*file = deviceId + ".tar"
+	}
+	fromTime, err := parseTimeFlag(*from)
+	if err != nil {
+		return err
+	}
+	toTime, err := parseTimeFlag(*to)
+	if err != nil {
+		return err
+	}
+
+	archive := &bytes.Buffer{}
+	if err := c.client.Export(context.Background(), deviceId, fromTime, toTime, archive); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*file, archive.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "wrote %s (%d bytes)\n", *file, archive.Len())
+	return nil
+}
+
+func parseTimeFlag(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an RFC 3339 timestamp", value)
+	}
+	return &parsed, nil
+}
+
+func (c *cli) writeJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) writeDevice(device *domain.SignatureDevice) error {
+	if c.json {
+		return c.writeJSON(device)
+	}
+	return writeDeviceTable(c.stdout, []*domain.SignatureDevice{device})
+}
+
+func writeDeviceTable(w io.Writer, devices []*domain.SignatureDevice) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tLABEL\tALGORITHM\tSTATUS\tCOUNTER\tKEY VERSION\tENVELOPE")
+	for _, d := range devices {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			d.Id, d.Label, d.SignatureAlgorithm, d.Status, d.Counter(), d.KeyVersion, d.EnvelopeVersion)
+	}
+	return table.Flush()
+}
+
+func (c *cli) writeClient(client *domain.Client) error {
+	if c.json {
+		return c.writeJSON(client)
+	}
+	return writeClientTable(c.stdout, []*domain.Client{client})
+}
+
+func writeClientTable(w io.Writer, clients []*domain.Client) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSERIAL NUMBER\tDEVICES\tCREATED AT")
+	for _, client := range clients {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+			client.Id, client.SerialNumber, strings.Join(client.DeviceIds, ","), client.CreatedAt.Format(time.RFC3339))
+	}
+	return table.Flush()
+}
+
+func writeTransactionTable(w io.Writer, transactions []*domain.Transaction) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "DEVICE\tCOUNTER\tSIGNED AT\tREFERENCE\tKEY VERSION")
+	for _, t := range transactions {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%d\n",
+			t.DeviceId, t.Counter, t.SignedAt.Format(time.RFC3339), t.Reference, t.KeyVersion)
+	}
+	return table.Flush()
+}
+
+func writeFiscalTransactionTable(w io.Writer, fiscalTransactions []*domain.FiscalTransaction) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "DEVICE\tNUMBER\tSTATE\tPROCESS TYPE\tSTARTED AT\tSIGNATURES")
+	for _, t := range fiscalTransactions {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%d\n",
+			t.DeviceId, t.Number, t.State, t.ProcessType, t.StartedAt.Format(time.RFC3339), len(t.Log))
+	}
+	return table.Flush()
+}
+
+func writeAuditTable(w io.Writer, report *domain.AuditReport) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "DEVICE\t%s\n", report.DeviceId)
+	fmt.Fprintf(table, "TRANSACTIONS\t%d\n", report.TransactionCount)
+	fmt.Fprintf(table, "VALID\t%t\n", report.Valid)
+	if len(report.Issues) > 0 {
+		fmt.Fprintln(table, "\nCOUNTER\tKIND\tDETAIL")
+		for _, issue := range report.Issues {
+			fmt.Fprintf(table, "%d\t%s\t%s\n", issue.Counter, issue.Kind, issue.Detail)
+		}
+	}
+	return table.Flush()
+}
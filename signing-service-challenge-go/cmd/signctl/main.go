@@ -0,0 +1,134 @@
+// Command signctl manages signature devices through the REST API of the signing service.
+//
+// Usage:
+//
+//	signctl [-server URL] [-output table|json] <command> [arguments]
+//
+// Commands:
+//
+//	create -algorithm RSA|ECC [-label LABEL] [-envelope legacy|v1|v2] [-tag KEY:VALUE]...
+//	list [-label TEXT] [-algorithm RSA|ECC] [-status active|deactivated] [-tag KEY:VALUE]...
+//	     [-created-from RFC3339] [-created-to RFC3339] [-sort ORDER] [-page-size N]
+//	show DEVICE_ID
+//	client register -serial SERIAL DEVICE_ID...
+//	client show CLIENT_ID
+//	client list [-device DEVICE_ID]
+//	client add|remove CLIENT_ID DEVICE_ID
+//	sign -client CLIENT_ID [-reference REF] DEVICE_ID DATA
+//	fiscal start -client CLIENT_ID [-type TYPE] [-data DATA] DEVICE_ID
+//	fiscal update|finish [-client CLIENT_ID] [-type TYPE] [-data DATA] DEVICE_ID NUMBER
+//	fiscal show DEVICE_ID NUMBER
+//	fiscal list DEVICE_ID
+//	search [-device DEVICE_ID]... [-signed-from RFC3339] [-signed-to RFC3339] [-counter-from N]
+//	       [-counter-to N] [-reference REF] [-label TEXT] [-tag KEY:VALUE]... [-sort ORDER]
+//	       [-format csv|ndjson] [-page-size N]
+//	rotate DEVICE_ID
+//	deactivate DEVICE_ID
+//	audit DEVICE_ID
+//	export [-from RFC3339] [-to RFC3339] [-file PATH] DEVICE_ID
+//
+// The server defaults to $SIGNCTL_SERVER or http://localhost:8080.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+)
+
+const defaultServer = "http://localhost:8080"
+
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage marks errors caused by invalid command line arguments.
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"create":     {usage: "create -algorithm RSA|ECC [-label LABEL] [-envelope legacy|v1|v2] [-tag KEY:VALUE]...", run: (*cli).create},
+	"list":       {usage: "list [-label TEXT] [-algorithm RSA|ECC] [-status active|deactivated] [-tag KEY:VALUE]... [-created-from RFC3339] [-created-to RFC3339] [-sort ORDER] [-page-size N]", run: (*cli).list},
+	"show":       {usage: "show DEVICE_ID", run: (*cli).show},
+	"client":     {usage: "client register|show|list|add|remove [-serial SERIAL] [-device DEVICE_ID] [CLIENT_ID] [DEVICE_ID]...", run: (*cli).clients},
+	"sign":       {usage: "sign -client CLIENT_ID [-reference REF] DEVICE_ID DATA", run: (*cli).sign},
+	"fiscal":     {usage: "fiscal start|update|finish|show|list [-client CLIENT_ID] [-type TYPE] [-data DATA] DEVICE_ID [NUMBER]", run: (*cli).fiscal},
+	"search":     {usage: "search [-device DEVICE_ID]... [-signed-from RFC3339] [-signed-to RFC3339] [-counter-from N] [-counter-to N] [-reference REF] [-label TEXT] [-tag KEY:VALUE]... [-sort ORDER] [-format csv|ndjson] [-page-size N]", run: (*cli).search},
+	"rotate":     {usage: "rotate DEVICE_ID", run: (*cli).rotate},
+	"deactivate": {usage: "deactivate DEVICE_ID", run: (*cli).deactivate},
+	"audit":      {usage: "audit DEVICE_ID", run: (*cli).audit},
+	"export":     {usage: "export [-from RFC3339] [-to RFC3339] [-file PATH] DEVICE_ID", run: (*cli).export},
+}
+
+var commandOrder = []string{"create", "list", "show", "client", "sign", "fiscal", "search", "rotate", "deactivate", "audit", "export"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("signctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { writeUsage(stderr, flags) }
+	server := flags.String("server", serverFromEnv(), "base URL of the signing service")
+	output := flags.String("output", "table", "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintln(stderr, "-output must be table or json")
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return exitUsage
+	}
+
+	c := &cli{
+		client: client.New(*server),
+		json:   *output == "json",
+		stdout: stdout,
+	}
+	if err := cmd.run(c, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: signctl %s\n", cmd.usage)
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "signctl: %v\n", err)
+		return exitError
+	}
+	return exitOk
+}
+
+func serverFromEnv() string {
+	if server := os.Getenv("SIGNCTL_SERVER"); server != "" {
+		return server
+	}
+	return defaultServer
+}
+
+func writeUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: signctl [-server URL] [-output table|json] <command> [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	flags.PrintDefaults()
+}
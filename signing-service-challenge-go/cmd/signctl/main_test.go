@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Run_DeviceLifecycle(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(":8081").Handler())
+	defer server.Close()
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-server", server.URL, "-output", "json", "create", "-algorithm", "ECC", "-label", "register", "-tag", "store:berlin"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	created := domain.SignatureDevice{}
+	if err := json.Unmarshal(stdout.Bytes(), &created); err != nil {
+		t.Fatalf("Could not unmarshal device: %v", err)
+	}
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "-output", "json", "client", "register", "-serial", "KASSE-1", created.Id}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	client := domain.Client{}
+	if err := json.Unmarshal(stdout.Bytes(), &client); err != nil {
+		t.Fatalf("Could not unmarshal client: %v", err)
+	}
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "client", "list", "-device", created.Id}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Regexp(t, client.Id+`\s+KASSE-1\s+`+created.Id, stdout.String())
+
+	assert.Equal(t, exitOk, run([]string{"-server", server.URL, "sign", "-client", client.Id, "-reference", "R-1", created.Id, "payload"}, &bytes.Buffer{}, &bytes.Buffer{}))
+	assert.Equal(t, exitOk, run([]string{"-server", server.URL, "rotate", created.Id}, &bytes.Buffer{}, &bytes.Buffer{}))
+	assert.Equal(t, exitOk, run([]string{"-server", server.URL, "sign", "-client", client.Id, created.Id, "payload"}, &bytes.Buffer{}, &bytes.Buffer{}))
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "show", created.Id}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Contains(t, stdout.String(), "register")
+	assert.Regexp(t, `ECC\s+active\s+2\s+1\s+v2`, stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "list", "-tag", "store:berlin", "-label", "REG", "-page-size", "1"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Contains(t, stdout.String(), created.Id)
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "list", "-tag", "store:hamburg"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.NotContains(t, stdout.String(), created.Id)
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "search", "-tag", "store:berlin", "-reference", "R-1"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Regexp(t, created.Id+`\s+0\s+\S+\s+R-1`, stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "search", "-device", created.Id, "-counter-from", "1", "-format", "csv"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Equal(t, 2, strings.Count(stdout.String(), "\n"))
+
+	assert.Equal(t, exitOk, run([]string{"-server", server.URL, "fiscal", "start", "-client", client.Id, "-type", "Kassenbeleg-V1", created.Id}, &bytes.Buffer{}, &bytes.Buffer{}))
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "fiscal", "finish", "-data", "Beleg^7.50:Bar", created.Id, "1"}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Regexp(t, `STATE\s+finished`, stdout.String())
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "fiscal", "list", created.Id}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Regexp(t, `1\s+finished\s+Kassenbeleg-V1`, stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-server", server.URL, "audit", created.Id}, stdout, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	assert.Regexp(t, `VALID\s+true`, stdout.String())
+
+	archive := filepath.Join(t.TempDir(), "export.tar")
+	code = run([]string{"-server", server.URL, "export", "-file", archive, created.Id}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.Equal(t, exitOk, code)
+	_, err := os.Stat(archive)
+	assert.NoError(t, err)
+
+	assert.Equal(t, exitOk, run([]string{"-server", server.URL, "deactivate", created.Id}, &bytes.Buffer{}, &bytes.Buffer{}))
+	stderr := &bytes.Buffer{}
+	code = run([]string{"-server", server.URL, "sign", "-client", client.Id, created.Id, "payload"}, &bytes.Buffer{}, stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "device is deactivated")
+}
+
+func Test_Run_Usage(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	code := run([]string{"sign", "-client", "client-id", "only-device-id"}, &bytes.Buffer{}, stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "usage: signctl sign -client CLIENT_ID [-reference REF] DEVICE_ID DATA")
+}
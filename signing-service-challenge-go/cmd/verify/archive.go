@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+const (
+	manifestFile          = "manifest.json"
+	manifestSignatureFile = "manifest.sig"
+	transactionsFile      = "transactions.json"
+)
+
+// loadArchive returns the files of an export archive by their path inside the
+// device directory of the archive, e.g. public_keys/0.pem. path is either the tar
+// file or the directory holding manifest.json after extraction.
+func loadArchive(path string) (map[string][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadArchiveDirectory(path)
+	}
+	return loadArchiveFile(path)
+}
+
+func loadArchiveFile(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	files := map[string][]byte{}
+	archive := tar.NewReader(file)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// every file is stored below a directory named after the device
+		_, name, found := strings.Cut(header.Name, "/")
+		if !found {
+			return nil, fmt.Errorf("%s is not inside the device directory", header.Name)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+}
+
+func loadArchiveDirectory(path string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func decodeManifest(files map[string][]byte) (*api.ExportManifest, error) {
+	content, ok := files[manifestFile]
+	if !ok {
+		return nil, errors.New("archive contains no " + manifestFile)
+	}
+	manifest := &api.ExportManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", manifestFile, err)
+	}
+	return manifest, nil
+}
+
+// checkManifest verifies manifest.sig with the public key the manifest was signed
+// with and compares every file of the archive with its entry in the manifest. It
+// returns a description of every mismatch found.
+func checkManifest(manifest *api.ExportManifest, files map[string][]byte) []string {
+	issues := []string{}
+	verifier, err := archiveVerifier(files, manifest.KeyVersion)
+	if err != nil {
+		issues = append(issues, fmt.Sprintf("%s: %v", manifestSignatureFile, err))
+	} else if err := verifier.Verify(files[manifestFile], files[manifestSignatureFile]); err != nil {
+		issues = append(issues, fmt.Sprintf("%s: does not verify with the public key of key_version %d", manifestSignatureFile, manifest.KeyVersion))
+	}
+
+	listed := map[string]bool{manifestFile: true, manifestSignatureFile: true}
+	for _, file := range manifest.Files {
+		listed[file.Name] = true
+		content, ok := files[file.Name]
+		if !ok {
+			issues = append(issues, fmt.Sprintf("%s: is missing", file.Name))
+			continue
+		}
+		digest := sha256.Sum256(content)
+		if len(content) != file.Size || hex.EncodeToString(digest[:]) != file.SHA256 {
+			issues = append(issues, fmt.Sprintf("%s: does not match its size and digest in the manifest", file.Name))
+		}
+	}
+	unlisted := []string{}
+	for name := range files {
+		if !listed[name] {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+	for _, name := range unlisted {
+		issues = append(issues, fmt.Sprintf("%s: is not listed in the manifest", name))
+	}
+	return issues
+}
+
+// archiveChain returns the exported transactions with the public keys of the
+// archive, audited from the chain start recorded in the manifest.
+func archiveChain(manifest *api.ExportManifest, files map[string][]byte) (*chain, error) {
+	transactions, err := decodeTransactions(files[transactionsFile])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", transactionsFile, err)
+	}
+	for _, transaction := range transactions {
+		if transaction.DeviceId != manifest.DeviceId {
+			return nil, fmt.Errorf("export contains transactions of device %s", transaction.DeviceId)
+		}
+	}
+	verifiers := map[int]crypto.Verifier{}
+	return &chain{
+		deviceId: manifest.DeviceId,
+		start:    manifest.ChainStart,
+		verifiers: func(keyVersion int) (crypto.Verifier, error) {
+			if verifier, ok := verifiers[keyVersion]; ok {
+				return verifier, nil
+			}
+			verifier, err := archiveVerifier(files, keyVersion)
+			if err != nil {
+				return nil, err
+			}
+			verifiers[keyVersion] = verifier
+			return verifier, nil
+		},
+		transactions: transactions,
+	}, nil
+}
+
+func archiveVerifier(files map[string][]byte, keyVersion int) (crypto.Verifier, error) {
+	name := fmt.Sprintf("public_keys/%d.pem", keyVersion)
+	publicKeyBytes, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("archive contains no %s", name)
+	}
+	return decodeVerifier(publicKeyBytes)
+}
@@ -0,0 +1,273 @@
+// Command verify checks an exported transaction history of a signature device
+// without contacting the signing service.
+//
+// Usage:
+//
+//	verify -archive DEVICE_ID.tar [-tsa-certificate tsa.pem]
+//	verify -public-key device.pem -transactions transactions.json [-tsa-certificate tsa.pem]
+//
+// -archive takes an archive of GET /api/v0/devices/{id}/export, or the directory
+// holding its manifest.json after extraction. Before the transactions are audited,
+// manifest.sig is verified with the public key of the manifest key_version and every
+// file is compared with the size and SHA-256 digest listed in manifest.json. The
+// chain is audited from the chain_start of the manifest, so archives of a time range
+// verify as well.
+//
+// Without an archive, the public keys and transactions are given separately.
+// If the device keys have been rotated, -public-key is repeated once per key
+// version in ascending order, matching public_keys/<version>.pem of an export
+// archive.
+//
+// Timestamp tokens of the transactions are verified against the PEM encoded root
+// certificates given with -tsa-certificate, or the system roots if it is omitted.
+//
+// The chain of a device imported from another signing service continues where it
+// left off. Pass the imported_chain of the device with -initial-counter and
+// -initial-last-signature, otherwise the chain is expected to start at counter 0
+// with a link to the encoded device id.
+//
+// The transactions file holds either a JSON array of transactions or the response
+// body of GET /api/v0/devices/{id}/transactions. verify exits with status 1 if the
+// archive does not match its manifest, the chain is broken or a signature is
+// invalid, and with status 2 on invalid input.
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	archivePath := flags.String("archive", "", "path to an export archive or the directory it was extracted to, replaces all other inputs but -tsa-certificate")
+	publicKeyPaths := &pathList{}
+	flags.Var(publicKeyPaths, "public-key", "path to the PEM encoded public key of the device, repeated per key version")
+	transactionsPath := flags.String("transactions", "", "path to the JSON export of the device transactions")
+	initialCounter := flags.Int("initial-counter", 0, "signature counter of the first transaction of an imported device")
+	initialLastSignature := flags.String("initial-last-signature", "", "last signature the first transaction of an imported device links to")
+	tsaCertificatePath := flags.String("tsa-certificate", "", "path to the PEM encoded root certificates of the timestamp authority, defaults to the system roots")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *archivePath != "" && (len(*publicKeyPaths) > 0 || *transactionsPath != "" || *initialCounter != 0 || *initialLastSignature != "") {
+		fmt.Fprintln(stderr, "-archive cannot be combined with -public-key, -transactions, -initial-counter or -initial-last-signature")
+		flags.Usage()
+		return exitUsage
+	}
+	if *archivePath == "" && (len(*publicKeyPaths) == 0 || *transactionsPath == "") {
+		fmt.Fprintln(stderr, "both -public-key and -transactions are required")
+		flags.Usage()
+		return exitUsage
+	}
+
+	var tsaRoots *x509.CertPool
+	if *tsaCertificatePath != "" {
+		var err error
+		tsaRoots, err = loadCertificates(*tsaCertificatePath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot load timestamp authority certificates %s: %v\n", *tsaCertificatePath, err)
+			return exitUsage
+		}
+	}
+
+	var input *chain
+	if *archivePath != "" {
+		files, err := loadArchive(*archivePath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot load archive %s: %v\n", *archivePath, err)
+			return exitUsage
+		}
+		manifest, err := decodeManifest(files)
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot load archive %s: %v\n", *archivePath, err)
+			return exitUsage
+		}
+		// nothing in the archive can be trusted unless it matches the signed manifest
+		if issues := checkManifest(manifest, files); len(issues) > 0 {
+			for _, issue := range issues {
+				fmt.Fprintf(stdout, "FAIL %s\n", issue)
+			}
+			fmt.Fprintf(stdout, "FAILED: %d archive issue(s) found\n", len(issues))
+			return exitInvalid
+		}
+		fmt.Fprintf(stdout, "manifest:     signature and %d file digest(s) verified\n", len(manifest.Files))
+		if input, err = archiveChain(manifest, files); err != nil {
+			fmt.Fprintf(stderr, "cannot verify transactions: %v\n", err)
+			return exitUsage
+		}
+	} else {
+		var err error
+		if input, err = flagChain(*publicKeyPaths, *transactionsPath, *initialCounter, *initialLastSignature); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+
+	report := domain.AuditChain(input.deviceId, input.start, input.verifiers, func(token []byte, signature []byte) error {
+		parsed, err := tsa.ParseToken(token)
+		if err != nil {
+			return err
+		}
+		return parsed.Verify(signature, tsaRoots)
+	}, input.transactions)
+	writeReport(stdout, report)
+	if !report.Valid {
+		return exitInvalid
+	}
+	return exitValid
+}
+
+// chain holds the transactions to audit and what the audit needs to know about their device.
+type chain struct {
+	deviceId     string
+	start        domain.ChainStart
+	verifiers    domain.VerifierResolver
+	transactions []*domain.Transaction
+}
+
+// flagChain loads the chain given by -public-key, -transactions and the -initial flags.
+func flagChain(publicKeyPaths []string, transactionsPath string, initialCounter int, initialLastSignature string) (*chain, error) {
+	verifiers := make([]crypto.Verifier, 0, len(publicKeyPaths))
+	for _, path := range publicKeyPaths {
+		verifier, err := loadVerifier(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load public key %s: %w", path, err)
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	raw, err := os.ReadFile(transactionsPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load transactions: %w", err)
+	}
+	transactions, err := decodeTransactions(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load transactions: %w", err)
+	}
+	deviceId, err := singleDeviceId(transactions)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify transactions: %w", err)
+	}
+
+	start := domain.DefaultChainStart(deviceId)
+	start.Counter = initialCounter
+	if initialLastSignature != "" {
+		start.LastSignature = initialLastSignature
+	}
+	return &chain{
+		deviceId: deviceId,
+		start:    start,
+		verifiers: func(keyVersion int) (crypto.Verifier, error) {
+			if keyVersion < 0 || keyVersion >= len(verifiers) {
+				return nil, fmt.Errorf("no public key given for key_version %d", keyVersion)
+			}
+			return verifiers[keyVersion], nil
+		},
+		transactions: transactions,
+	}, nil
+}
+
+// pathList collects the values of a repeated flag.
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+func loadVerifier(path string) (crypto.Verifier, error) {
+	publicKeyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeVerifier(publicKeyBytes)
+}
+
+func decodeVerifier(publicKeyBytes []byte) (crypto.Verifier, error) {
+	publicKey, err := crypto.DecodePublicKey(publicKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewVerifier(publicKey)
+}
+
+func loadCertificates(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return roots, nil
+}
+
+// decodeTransactions accepts a plain JSON array as well as the API response container.
+func decodeTransactions(raw []byte) ([]*domain.Transaction, error) {
+	raw = bytes.TrimSpace(raw)
+
+	transactions := []*domain.Transaction{}
+	if len(raw) > 0 && raw[0] == '{' {
+		response := struct {
+			Data *[]*domain.Transaction `json:"data"`
+		}{Data: &transactions}
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(raw, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func singleDeviceId(transactions []*domain.Transaction) (string, error) {
+	if len(transactions) == 0 {
+		return "", errors.New("export contains no transactions")
+	}
+	deviceId := transactions[0].DeviceId
+	for _, transaction := range transactions {
+		if transaction.DeviceId != deviceId {
+			return "", errors.New("export contains transactions of more than one device")
+		}
+	}
+	return deviceId, nil
+}
+
+func writeReport(w io.Writer, report *domain.AuditReport) {
+	fmt.Fprintf(w, "device:       %s\n", report.DeviceId)
+	fmt.Fprintf(w, "transactions: %d\n", report.TransactionCount)
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "FAIL counter %d: %s: %s\n", issue.Counter, issue.Kind, issue.Detail)
+	}
+	if report.Valid {
+		fmt.Fprintln(w, "OK: signature chain is intact")
+	} else {
+		fmt.Fprintf(w, "FAILED: %d issue(s) found\n", len(report.Issues))
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/client"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Run_ValidExport(t *testing.T) {
+	device, transactions := signedChain(t)
+	keyPath, exportPath := writeExport(t, device, transactions)
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-public-key", keyPath, "-transactions", exportPath}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitValid, code)
+	assert.Contains(t, stdout.String(), "OK: signature chain is intact")
+}
+
+func Test_Run_TamperedExport(t *testing.T) {
+	device, transactions := signedChain(t)
+	transactions[1].Data = "tampered"
+	keyPath, exportPath := writeExport(t, device, transactions)
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-public-key", keyPath, "-transactions", exportPath}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "counter 1: invalid_signature")
+}
+
+func Test_Run_TimestampedExport(t *testing.T) {
+	device, transactions := signedChain(t)
+	authority, err := tsa.NewLocalAuthority()
+	if err != nil {
+		t.Fatalf("Could not create timestamp authority: %v", err)
+	}
+	for _, transaction := range transactions {
+		signature, _ := base64.StdEncoding.DecodeString(transaction.Signature)
+		token, err := authority.Timestamp(context.Background(), signature)
+		if err != nil {
+			t.Fatalf("Could not timestamp: %v", err)
+		}
+		transaction.TimestampToken = base64.StdEncoding.EncodeToString(token)
+	}
+	transactions[2].TimestampToken = transactions[0].TimestampToken
+	keyPath, exportPath := writeExport(t, device, transactions)
+	certificatePath := filepath.Join(filepath.Dir(keyPath), "tsa.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.Certificate().Raw})
+	if err := os.WriteFile(certificatePath, certificate, 0o600); err != nil {
+		t.Fatalf("Could not write certificate: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-public-key", keyPath, "-transactions", exportPath, "-tsa-certificate", certificatePath}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "counter 2: invalid_timestamp")
+	assert.Contains(t, stdout.String(), "FAILED: 1 issue(s) found")
+
+	// the system roots do not trust the local authority
+	stdout.Reset()
+	code = run([]string{"-public-key", keyPath, "-transactions", exportPath}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "FAILED: 3 issue(s) found")
+}
+
+func Test_Run_ImportedChain(t *testing.T) {
+	device, transactions := signedChain(t)
+	// the first transaction was signed by the previous signing service
+	previous := transactions[0]
+	keyPath, exportPath := writeExport(t, device, transactions[1:])
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-public-key", keyPath, "-transactions", exportPath}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "counter 1: counter_gap")
+
+	stdout.Reset()
+	code = run([]string{"-public-key", keyPath, "-transactions", exportPath, "-initial-counter", "1", "-initial-last-signature", previous.Signature}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitValid, code)
+	assert.Contains(t, stdout.String(), "OK: signature chain is intact")
+}
+
+func Test_Run_MissingFlags(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	code := run([]string{}, &bytes.Buffer{}, stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "both -public-key and -transactions are required")
+}
+
+func Test_Run_Archive(t *testing.T) {
+	archive, _ := exportArchive(t, false)
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-archive", archive}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitValid, code, stdout.String())
+	assert.Contains(t, stdout.String(), "manifest:     signature and 4 file digest(s) verified")
+	assert.Contains(t, stdout.String(), "transactions: 4")
+	assert.Contains(t, stdout.String(), "OK: signature chain is intact")
+
+	// extracted archives verify the same way
+	dir := extractArchive(t, archive)
+	stdout.Reset()
+	code = run([]string{"-archive", dir}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitValid, code, stdout.String())
+	assert.Contains(t, stdout.String(), "OK: signature chain is intact")
+}
+
+func Test_Run_ArchiveRange(t *testing.T) {
+	archive, _ := exportArchive(t, true)
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-archive", archive}, stdout, &bytes.Buffer{})
+
+	// the range starts at counter 1, chained to the signature of counter 0
+	assert.Equal(t, exitValid, code, stdout.String())
+	assert.Contains(t, stdout.String(), "transactions: 2")
+	assert.Contains(t, stdout.String(), "OK: signature chain is intact")
+}
+
+func Test_Run_TamperedArchive(t *testing.T) {
+	archive, deviceId := exportArchive(t, false)
+	dir := extractArchive(t, archive)
+	transactionsPath := filepath.Join(dir, "transactions.json")
+	transactions, err := os.ReadFile(transactionsPath)
+	if err != nil {
+		t.Fatalf("Could not read transactions: %v", err)
+	}
+	// the transactions are checked against the manifest before they are audited
+	if err := os.WriteFile(transactionsPath, bytes.Replace(transactions, []byte(`"a"`), []byte(`"A"`), 1), 0o600); err != nil {
+		t.Fatalf("Could not write transactions: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	code := run([]string{"-archive", dir}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "FAIL transactions.json: does not match its size and digest in the manifest")
+	assert.NotContains(t, stdout.String(), "transactions:")
+
+	// a manifest matching the tampered file is not signed by the device
+	manifestPath := filepath.Join(dir, "manifest.json")
+	manifest := api.ExportManifest{}
+	manifestJSON, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Could not read manifest: %v", err)
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		t.Fatalf("Could not unmarshal manifest: %v", err)
+	}
+	files, err := loadArchiveDirectory(dir)
+	if err != nil {
+		t.Fatalf("Could not read archive: %v", err)
+	}
+	for i, file := range manifest.Files {
+		if file.Name == "transactions.json" {
+			digest := sha256.Sum256(files[file.Name])
+			manifest.Files[i].Size, manifest.Files[i].SHA256 = len(files[file.Name]), hex.EncodeToString(digest[:])
+		}
+	}
+	if manifestJSON, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		t.Fatalf("Could not marshal manifest: %v", err)
+	}
+	if err := os.WriteFile(manifestPath, manifestJSON, 0o600); err != nil {
+		t.Fatalf("Could not write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(deviceId), 0o600); err != nil {
+		t.Fatalf("Could not write file: %v", err)
+	}
+
+	stdout.Reset()
+	code = run([]string{"-archive", dir}, stdout, &bytes.Buffer{})
+
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stdout.String(), "FAIL manifest.sig: does not verify with the public key of key_version 1")
+	assert.Contains(t, stdout.String(), "FAIL notes.txt: is not listed in the manifest")
+	assert.Contains(t, stdout.String(), "FAILED: 2 archive issue(s) found")
+}
+
+func Test_Run_ArchiveWithOtherInputs(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	code := run([]string{"-archive", "export.tar", "-initial-counter", "1"}, &bytes.Buffer{}, stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "-archive cannot be combined with")
+}
+
+// exportArchive signs four transactions with a key rotation after the second and
+// writes the export archive of the device. If inRange is set, only the second and
+// third transaction are exported.
+func exportArchive(t *testing.T, inRange bool) (string, string) {
+	// every signature is a day after the previous one, so a range can select them
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := service.ClockFunc(func() time.Time {
+		now = now.AddDate(0, 0, 1)
+		return now
+	})
+	server := httptest.NewServer(api.NewServerWithStores(":8081",
+		persistence.NewInMemoryDeviceStore(),
+		persistence.NewInMemoryTransactionStore(),
+		persistence.NewInMemoryFiscalTransactionStore(),
+		persistence.NewInMemoryClientStore(),
+		persistence.NewInMemoryLogStore(),
+		service.WithClock(clock),
+	).Handler())
+	defer server.Close()
+	ctx := context.Background()
+	signingClient := client.New(server.URL)
+
+	device, err := signingClient.CreateDevice(ctx, api.CreateDeviceRequest{SignatureAlgorithm: domain.ECDSA, Label: "register"})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	registered, err := signingClient.RegisterClient(ctx, api.RegisterClientRequest{SerialNumber: "REGISTER-1", DeviceIds: []string{device.Id}})
+	if err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	signedAt := []time.Time{}
+	for i, data := range []string{"a", "b", "c", "d"} {
+		if i == 2 {
+			if _, err := signingClient.RotateDeviceKey(ctx, device.Id); err != nil {
+				t.Fatalf("Could not rotate key: %v", err)
+			}
+		}
+		resp, err := signingClient.Sign(ctx, device.Id, registered.Id, data)
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+		signedAt = append(signedAt, resp.Signature.SignedAt)
+	}
+
+	var from, to *time.Time
+	if inRange {
+		from, to = &signedAt[1], &signedAt[2]
+	}
+	archive := &bytes.Buffer{}
+	if err := signingClient.Export(ctx, device.Id, from, to, archive); err != nil {
+		t.Fatalf("Could not export: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), device.Id+".tar")
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatalf("Could not write archive: %v", err)
+	}
+	return path, device.Id
+}
+
+// extractArchive extracts an export archive and returns the directory holding its manifest.
+func extractArchive(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Could not open archive: %v", err)
+	}
+	defer file.Close()
+	dir := t.TempDir()
+	archive := tar.NewReader(file)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Could not read archive: %v", err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			t.Fatalf("Could not create directory: %v", err)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			t.Fatalf("Could not read archive: %v", err)
+		}
+		if err := os.WriteFile(target, content, 0o600); err != nil {
+			t.Fatalf("Could not write file: %v", err)
+		}
+	}
+	// the files are stored below a directory named after the device, like the archive
+	return filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".tar"))
+}
+
+// signedChain signs three transactions the same way the API does.
+func signedChain(t *testing.T) (*domain.SignatureDevice, []*domain.Transaction) {
+	device, err := domain.NewSignatureDevice(domain.ECDSA, "device")
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	device.Id = "device_id"
+	signer, err := device.Signer()
+	if err != nil {
+		t.Fatalf("Could not create signer: %v", err)
+	}
+
+	transactions := []*domain.Transaction{}
+	lastSignature := base64.StdEncoding.EncodeToString([]byte(device.Id))
+	for counter, data := range []string{"a", "b", "c"} {
+		transaction := &domain.Transaction{
+			DeviceId:        device.Id,
+			Data:            data,
+			Counter:         counter,
+			LastSignature:   lastSignature,
+			EnvelopeVersion: device.EnvelopeVersion,
+		}
+		securedData, err := transaction.SecuredData().Encode(transaction.EnvelopeVersion)
+		if err != nil {
+			t.Fatalf("Could not encode secured data: %v", err)
+		}
+		signature, err := signer.Sign(securedData)
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+		transaction.Signature = base64.StdEncoding.EncodeToString(signature)
+		lastSignature = transaction.Signature
+		transactions = append(transactions, transaction)
+	}
+	return device, transactions
+}
+
+func writeExport(t *testing.T, device *domain.SignatureDevice, transactions []*domain.Transaction) (string, string) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "device.pem")
+	if err := os.WriteFile(keyPath, []byte(device.PublicKey), 0o600); err != nil {
+		t.Fatalf("Could not write public key: %v", err)
+	}
+	export, err := json.Marshal(map[string]interface{}{"data": transactions})
+	if err != nil {
+		t.Fatalf("Could not marshal export: %v", err)
+	}
+	exportPath := filepath.Join(dir, "transactions.json")
+	if err := os.WriteFile(exportPath, export, 0o600); err != nil {
+		t.Fatalf("Could not write export: %v", err)
+	}
+	return keyPath, exportPath
+}
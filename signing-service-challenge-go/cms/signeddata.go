@@ -0,0 +1,315 @@
+// Package cms creates and verifies detached CMS SignedData (RFC 5652), the PKCS #7
+// format document-signing tools validate signatures in.
+package cms
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+
+	// register the hash functions of the supported signature algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// ContentType is the media type of a DER encoded SignedData.
+const ContentType = "application/pkcs7-signature"
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+)
+
+// ErrInvalidSignedData is returned if a SignedData is malformed or does not cover
+// the data it is verified against.
+var ErrInvalidSignedData = errors.New("invalid CMS signed data")
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// encapsulatedContentInfo omits the content, since the signatures are detached.
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"optional,explicit,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	Sid                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// algorithm is a signature algorithm of the signers of the crypto package.
+type algorithm struct {
+	hash      stdcrypto.Hash
+	digest    asn1.ObjectIdentifier
+	signature asn1.ObjectIdentifier
+	x509      x509.SignatureAlgorithm
+}
+
+var (
+	sha256WithRSA   = algorithm{stdcrypto.SHA256, oidSHA256, oidSHA256WithRSA, x509.SHA256WithRSA}
+	ecdsaWithSHA384 = algorithm{stdcrypto.SHA384, oidSHA384, oidECDSAWithSHA384, x509.ECDSAWithSHA384}
+)
+
+// signerAlgorithm returns the algorithm a crypto.Signer uses for the key of the certificate.
+func signerAlgorithm(certificate *x509.Certificate) (algorithm, error) {
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return sha256WithRSA, nil
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P384() {
+			return ecdsaWithSHA384, nil
+		}
+	}
+	return algorithm{}, fmt.Errorf("unsupported public key type %T", certificate.PublicKey)
+}
+
+// Sign creates a DER encoded SignedData over data without embedding it. The signer
+// must hold the key certified by the first certificate, the RSA and ECDSA signers of
+// the crypto package use SHA-256 and SHA-384 respectively. All certificates are
+// embedded, so verifiers can build the chain to their trusted roots.
+func Sign(signer crypto.Signer, certificates []*x509.Certificate, data []byte, signingTime time.Time) ([]byte, error) {
+	if len(certificates) == 0 {
+		return nil, errors.New("certificate of the signer is missing")
+	}
+	certificate := certificates[0]
+	alg, err := signerAlgorithm(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := alg.hash.New()
+	digest.Write(data)
+	attributes := []attribute{}
+	for _, attr := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidContentType, oidData},
+		{oidSigningTime, signingTime.UTC()},
+		{oidMessageDigest, digest.Sum(nil)},
+	} {
+		value, err := asn1.Marshal(attr.value)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute{Type: attr.oid, Values: []asn1.RawValue{{FullBytes: value}}})
+	}
+	signedAttrs, err := asn1.MarshalWithParams(attributes, "set")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(signedAttrs)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := []byte{}
+	for _, certificate := range certificates {
+		raw = append(raw, certificate.Raw...)
+	}
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: alg.digest}
+	signatureAlgorithm := pkix.AlgorithmIdentifier{Algorithm: alg.signature}
+	if alg.x509 == x509.SHA256WithRSA {
+		// RSA algorithm identifiers carry NULL parameters
+		digestAlgorithm.Parameters = asn1.NullRawValue
+		signatureAlgorithm.Parameters = asn1.NullRawValue
+	}
+	signedDER, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			Sid: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: certificate.RawIssuer},
+				SerialNumber: certificate.SerialNumber,
+			},
+			DigestAlgorithm: digestAlgorithm,
+			// the signed attributes are embedded with an implicit [0] instead of the SET OF tag
+			SignedAttrs:        asn1.RawValue{FullBytes: append([]byte{0xa0}, signedAttrs[1:]...)},
+			SignatureAlgorithm: signatureAlgorithm,
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedDER},
+	})
+}
+
+// Signature is a verified SignedData.
+type Signature struct {
+	// Certificates holds the chain from the certificate of the signer to a trusted root.
+	Certificates []*x509.Certificate
+	// SigningTime is the time the signer claims to have signed at.
+	SigningTime time.Time
+}
+
+// Verify checks that a DER encoded detached SignedData covers data, that it is signed
+// by one of its embedded certificates and that this certificate chains up to roots
+// at the signing time. A nil roots pool uses the system roots.
+func Verify(der []byte, data []byte, roots *x509.CertPool) (*Signature, error) {
+	info := contentInfo{}
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: not a CMS content info", ErrInvalidSignedData)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content is not signed data", ErrInvalidSignedData)
+	}
+	signed := signedData{}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return nil, fmt.Errorf("%w: malformed signed data: %v", ErrInvalidSignedData, err)
+	}
+	if len(signed.SignerInfos) != 1 {
+		return nil, fmt.Errorf("%w: expected one signer, got %d", ErrInvalidSignedData, len(signed.SignerInfos))
+	}
+	if !signed.EncapContentInfo.EContentType.Equal(oidData) || len(signed.EncapContentInfo.EContent) > 0 {
+		return nil, fmt.Errorf("%w: content is not detached data", ErrInvalidSignedData)
+	}
+	if len(signed.Certificates.Bytes) == 0 {
+		return nil, fmt.Errorf("%w: certificate of the signer is missing", ErrInvalidSignedData)
+	}
+	certificates, err := x509.ParseCertificates(signed.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed certificate: %v", ErrInvalidSignedData, err)
+	}
+	signer := signed.SignerInfos[0]
+	var certificate *x509.Certificate
+	intermediates := x509.NewCertPool()
+	for _, candidate := range certificates {
+		if bytes.Equal(candidate.RawIssuer, signer.Sid.Issuer.FullBytes) && candidate.SerialNumber.Cmp(signer.Sid.SerialNumber) == 0 {
+			certificate = candidate
+		} else {
+			intermediates.AddCert(candidate)
+		}
+	}
+	if certificate == nil {
+		return nil, fmt.Errorf("%w: certificate of the signer is missing", ErrInvalidSignedData)
+	}
+
+	signingTime, err := verifySigner(signer, certificate, data)
+	if err != nil {
+		return nil, err
+	}
+	chains, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signingTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignedData, err)
+	}
+	return &Signature{Certificates: chains[0], SigningTime: signingTime}, nil
+}
+
+// verifySigner checks the signed attributes of the signer against data and their
+// signature with the key of the certificate. It returns the signing time.
+func verifySigner(signer signerInfo, certificate *x509.Certificate, data []byte) (time.Time, error) {
+	if len(signer.SignedAttrs.Bytes) == 0 {
+		return time.Time{}, fmt.Errorf("%w: signed attributes are missing", ErrInvalidSignedData)
+	}
+	var alg algorithm
+	switch {
+	case signer.DigestAlgorithm.Algorithm.Equal(oidSHA256) && signer.SignatureAlgorithm.Algorithm.Equal(oidSHA256WithRSA):
+		alg = sha256WithRSA
+	case signer.DigestAlgorithm.Algorithm.Equal(oidSHA384) && signer.SignatureAlgorithm.Algorithm.Equal(oidECDSAWithSHA384):
+		alg = ecdsaWithSHA384
+	default:
+		return time.Time{}, fmt.Errorf("%w: unsupported signature algorithm %s with %s", ErrInvalidSignedData,
+			signer.SignatureAlgorithm.Algorithm, signer.DigestAlgorithm.Algorithm)
+	}
+
+	// the signature covers the DER encoding of the attributes as an explicit SET OF
+	signedAttrs := append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+	attributes := []attribute{}
+	if _, err := asn1.UnmarshalWithParams(signedAttrs, &attributes, "set"); err != nil {
+		return time.Time{}, fmt.Errorf("%w: malformed signed attributes", ErrInvalidSignedData)
+	}
+	var contentType asn1.ObjectIdentifier
+	var messageDigest []byte
+	var signingTime time.Time
+	for _, attribute := range attributes {
+		if len(attribute.Values) != 1 {
+			return time.Time{}, fmt.Errorf("%w: attribute %s must have a single value", ErrInvalidSignedData, attribute.Type)
+		}
+		value := attribute.Values[0].FullBytes
+		var err error
+		switch {
+		case attribute.Type.Equal(oidContentType):
+			_, err = asn1.Unmarshal(value, &contentType)
+		case attribute.Type.Equal(oidMessageDigest):
+			_, err = asn1.Unmarshal(value, &messageDigest)
+		case attribute.Type.Equal(oidSigningTime):
+			_, err = asn1.Unmarshal(value, &signingTime)
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: malformed attribute %s", ErrInvalidSignedData, attribute.Type)
+		}
+	}
+	if !contentType.Equal(oidData) {
+		return time.Time{}, fmt.Errorf("%w: content type attribute does not match the content", ErrInvalidSignedData)
+	}
+	if signingTime.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: signing time attribute is missing", ErrInvalidSignedData)
+	}
+	digest := alg.hash.New()
+	digest.Write(data)
+	if !bytes.Equal(digest.Sum(nil), messageDigest) {
+		return time.Time{}, fmt.Errorf("%w: message digest attribute does not match the data", ErrInvalidSignedData)
+	}
+	if err := certificate.CheckSignature(alg.x509, signedAttrs, signer.Signature); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidSignedData, err)
+	}
+	return signingTime, nil
+}
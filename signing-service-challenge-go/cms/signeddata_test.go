@@ -0,0 +1,102 @@
+package cms
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSelfSigned returns a signer and a self-signed certificate of a fresh key.
+func newSelfSigned(t *testing.T, generator crypto.Generator) (crypto.Signer, *x509.Certificate) {
+	keyPair, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	signer, err := crypto.NewSigner(keyPair.PrivateKey())
+	if err != nil {
+		t.Fatalf("Could not create signer: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, keyPair.PublicKey(), keyPair.PrivateKey().(stdcrypto.Signer))
+	if err != nil {
+		t.Fatalf("Could not create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Could not parse certificate: %v", err)
+	}
+	return signer, certificate
+}
+
+func Test_Sign_Verifies(t *testing.T) {
+	for _, generator := range []crypto.Generator{crypto.NewRSAGenerator(), crypto.NewECCGenerator()} {
+		signer, certificate := newSelfSigned(t, generator)
+		roots := x509.NewCertPool()
+		roots.AddCert(certificate)
+		signingTime := time.Now().UTC().Truncate(time.Second)
+
+		der, err := Sign(signer, []*x509.Certificate{certificate}, []byte("data"), signingTime)
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+
+		signature, err := Verify(der, []byte("data"), roots)
+		if assert.NoError(t, err) {
+			assert.Equal(t, certificate.Raw, signature.Certificates[0].Raw)
+			assert.True(t, signingTime.Equal(signature.SigningTime))
+		}
+		_, err = Verify(der, []byte("other data"), roots)
+		assert.ErrorIs(t, err, ErrInvalidSignedData)
+		// the certificate must chain up to the roots
+		_, err = Verify(der, []byte("data"), x509.NewCertPool())
+		assert.ErrorIs(t, err, ErrInvalidSignedData)
+	}
+}
+
+func Test_Verify_RejectsSigningTimeOutsideValidity(t *testing.T) {
+	signer, certificate := newSelfSigned(t, crypto.NewECCGenerator())
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+
+	der, err := Sign(signer, []*x509.Certificate{certificate}, []byte("data"), certificate.NotAfter.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	_, err = Verify(der, []byte("data"), roots)
+	assert.ErrorIs(t, err, ErrInvalidSignedData)
+}
+
+func Test_Verify_RejectsOtherSigner(t *testing.T) {
+	signer, _ := newSelfSigned(t, crypto.NewECCGenerator())
+	_, certificate := newSelfSigned(t, crypto.NewECCGenerator())
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+
+	der, err := Sign(signer, []*x509.Certificate{certificate}, []byte("data"), time.Now())
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	_, err = Verify(der, []byte("data"), roots)
+	assert.ErrorIs(t, err, ErrInvalidSignedData)
+}
+
+func Test_Verify_RejectsMalformed(t *testing.T) {
+	for _, der := range [][]byte{nil, []byte("garbage"), {0x30, 0x03, 0x06, 0x01, 0x00}} {
+		_, err := Verify(der, []byte("data"), nil)
+		assert.ErrorIs(t, err, ErrInvalidSignedData)
+	}
+}
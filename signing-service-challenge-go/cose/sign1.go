@@ -0,0 +1,162 @@
+// Package cose encodes device signatures as COSE_Sign1 messages (RFC 9052) in
+// CBOR, a compact alternative to JWS for constrained clients.
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Algorithms of the IANA COSE registry matching the signers of the crypto package.
+const (
+	// ES384 is ECDSA over SHA-384, as signed by crypto.ECDSASigner on P-384.
+	ES384 = -35
+	// RS256 is RSASSA-PKCS1-v1_5 over SHA-256, as signed by crypto.RSASigner (RFC 8812).
+	RS256 = -257
+)
+
+// Header labels of RFC 9052.
+const (
+	headerAlgorithm = 1
+	headerKeyId     = 4
+)
+
+// ContentType is the media type of a COSE_Sign1 message.
+const ContentType = `application/cose; cose-type="cose-sign1"`
+
+// tagSign1 is the CBOR tag of a COSE_Sign1 message.
+const tagSign1 = 18
+
+// ErrInvalidMessage is returned if a COSE_Sign1 message is malformed or its signature is invalid.
+var ErrInvalidMessage = errors.New("invalid COSE_Sign1 message")
+
+var (
+	// encMode encodes deterministically as required by section 9 of RFC 9052.
+	encMode, _ = cbor.CoreDetEncOptions().EncMode()
+	decMode, _ = cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+)
+
+// Marshal encodes v as deterministic CBOR, e.g. the payload of a message.
+func Marshal(v interface{}) ([]byte, error) {
+	return encMode.Marshal(v)
+}
+
+// Unmarshal decodes CBOR data into v, rejecting duplicate map keys.
+func Unmarshal(data []byte, v interface{}) error {
+	return decMode.Unmarshal(data, v)
+}
+
+// sign1 is the array of a COSE_Sign1 message.
+type sign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// sigStructure is the data signed for a COSE_Sign1 message.
+type sigStructure struct {
+	_           struct{} `cbor:",toarray"`
+	Context     string
+	Protected   []byte
+	ExternalAAD []byte
+	Payload     []byte
+}
+
+// Algorithm returns the COSE algorithm to sign with the private key of publicKey
+// through the crypto package.
+func Algorithm(publicKey interface{}) (int, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return RS256, nil
+	case *ecdsa.PublicKey:
+		// crypto.ECDSASigner hashes with SHA-384 on every curve, which only ES384 specifies
+		if key.Curve != elliptic.P384() {
+			return 0, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+		return ES384, nil
+	default:
+		return 0, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// Sign encodes the payload as a tagged COSE_Sign1 message signed by signer, which
+// must sign with algorithm. The algorithm is a protected header, the key id an
+// unprotected one.
+func Sign(signer crypto.Signer, algorithm int, keyId []byte, payload []byte) ([]byte, error) {
+	protected, err := encMode.Marshal(map[int]interface{}{headerAlgorithm: algorithm})
+	if err != nil {
+		return nil, err
+	}
+	toBeSigned, err := encMode.Marshal(sigStructure{Context: "Signature1", Protected: protected, ExternalAAD: []byte{}, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(toBeSigned)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm == ES384 {
+		if signature, err = crypto.ECDSARawSignature(signature, crypto.ECDSASignatureSize); err != nil {
+			return nil, err
+		}
+	}
+	unprotected := map[int]interface{}{}
+	if len(keyId) > 0 {
+		unprotected[headerKeyId] = keyId
+	}
+	return encMode.Marshal(cbor.Tag{
+		Number:  tagSign1,
+		Content: sign1{Protected: protected, Unprotected: unprotected, Payload: payload, Signature: signature},
+	})
+}
+
+// Verify checks the signature of a tagged COSE_Sign1 message with publicKey and
+// returns its key id and payload. The algorithm of the message must match the key.
+func Verify(message []byte, publicKey interface{}) ([]byte, []byte, error) {
+	tag := cbor.RawTag{}
+	if err := decMode.Unmarshal(message, &tag); err != nil || tag.Number != tagSign1 {
+		return nil, nil, ErrInvalidMessage
+	}
+	decoded := sign1{}
+	if err := decMode.Unmarshal(tag.Content, &decoded); err != nil || decoded.Payload == nil {
+		return nil, nil, ErrInvalidMessage
+	}
+	protected := map[int]interface{}{}
+	if err := decMode.Unmarshal(decoded.Protected, &protected); err != nil {
+		return nil, nil, ErrInvalidMessage
+	}
+	algorithm, err := Algorithm(publicKey)
+	if err != nil {
+		return nil, nil, ErrInvalidMessage
+	}
+	if value, ok := protected[headerAlgorithm].(int64); !ok || value != int64(algorithm) {
+		return nil, nil, ErrInvalidMessage
+	}
+	signature := decoded.Signature
+	if algorithm == ES384 {
+		if signature, err = crypto.ECDSAASN1Signature(signature, crypto.ECDSASignatureSize); err != nil {
+			return nil, nil, ErrInvalidMessage
+		}
+	}
+	toBeSigned, err := encMode.Marshal(sigStructure{Context: "Signature1", Protected: decoded.Protected, ExternalAAD: []byte{}, Payload: decoded.Payload})
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier, err := crypto.NewVerifier(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifier.Verify(toBeSigned, signature); err != nil {
+		return nil, nil, ErrInvalidMessage
+	}
+	keyId, _ := decoded.Unprotected[headerKeyId].([]byte)
+	return keyId, decoded.Payload, nil
+}
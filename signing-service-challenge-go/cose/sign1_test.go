@@ -0,0 +1,71 @@
+package cose
+
+import (
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sign_Verifies(t *testing.T) {
+	for _, generator := range []crypto.Generator{crypto.NewRSAGenerator(), crypto.NewECCGenerator()} {
+		keyPair, err := generator.Generate()
+		if err != nil {
+			t.Fatalf("Could not generate key pair: %v", err)
+		}
+		signer, err := crypto.NewSigner(keyPair.PrivateKey())
+		if err != nil {
+			t.Fatalf("Could not create signer: %v", err)
+		}
+		algorithm, err := Algorithm(keyPair.PublicKey())
+		if err != nil {
+			t.Fatalf("Could not select algorithm: %v", err)
+		}
+
+		message, err := Sign(signer, algorithm, []byte("device"), []byte("payload"))
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+
+		keyId, payload, err := Verify(message, keyPair.PublicKey())
+		if assert.NoError(t, err, algorithm) {
+			assert.Equal(t, "device", string(keyId))
+			assert.Equal(t, "payload", string(payload))
+		}
+		// the tagged array starts with tag 18
+		assert.Equal(t, byte(0xd2), message[0])
+
+		tampered := append([]byte{}, message...)
+		tampered[len(tampered)-1] ^= 0x01
+		_, _, err = Verify(tampered, keyPair.PublicKey())
+		assert.ErrorIs(t, err, ErrInvalidMessage)
+	}
+}
+
+func Test_Verify_RejectsOtherAlgorithm(t *testing.T) {
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	signer, err := crypto.NewSigner(keyPair.PrivateKey())
+	if err != nil {
+		t.Fatalf("Could not create signer: %v", err)
+	}
+	rsaKeyPair, err := crypto.NewRSAGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+
+	message, err := Sign(signer, ES384, nil, []byte("payload"))
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	_, _, err = Verify(message, rsaKeyPair.PublicKey())
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	untagged, err := cbor.Marshal([]interface{}{[]byte{}, map[int]interface{}{}, []byte("payload"), []byte{}})
+	assert.NoError(t, err)
+	_, _, err = Verify(untagged, keyPair.PublicKey())
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
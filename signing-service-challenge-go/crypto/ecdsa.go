@@ -3,7 +3,7 @@ package crypto
 import (
 	"crypto/ecdsa"
 	"crypto/x509"
-	"encoding/pem"
+	"fmt"
 )
 
 // ECCKeyPair is a DTO that holds ECC private and public keys.
@@ -29,39 +29,44 @@ func NewECCMarshaler() ECCMarshaler {
 	return ECCMarshaler{}
 }
 
-// Encode takes an ECCKeyPair and encodes it to be written on disk.
-// It returns the public and the private key as a byte slice.
-func (m ECCMarshaler) Encode(keyPair ECCKeyPair) ([]byte, []byte, error) {
-	privateKeyBytes, err := x509.MarshalECPrivateKey(keyPair.Private)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	publicKeyBytes, err := x509.MarshalPKIXPublicKey(keyPair.Public)
-	if err != nil {
-		return nil, nil, err
+// Marshal takes an ECC key pair and encodes it to be persisted or exported.
+// It returns the PKIX public and the PKCS #8 private key as PEM.
+func (m ECCMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	if _, ok := keyPair.PrivateKey().(*ecdsa.PrivateKey); !ok {
+		return nil, nil, ErrKeyTypeMismatch
 	}
-
-	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "PRIVATE_KEY",
-		Bytes: privateKeyBytes,
-	})
-
-	encodedPublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC_KEY",
-		Bytes: publicKeyBytes,
-	})
-
-	return encodedPublic, encodedPrivate, nil
+	return marshalKeyPair(keyPair)
 }
 
-// Decode assembles an ECCKeyPair from an encoded private key.
-func (m ECCMarshaler) Decode(privateKeyBytes []byte) (*ECCKeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+// Unmarshal assembles an ECCKeyPair from a PEM encoded private key. Besides PKCS #8
+// "PRIVATE KEY" blocks it accepts SEC 1 "EC PRIVATE KEY" blocks, as written by
+// OpenSSL, and the SEC 1 "PRIVATE_KEY" blocks of earlier versions.
+func (m ECCMarshaler) Unmarshal(privateKeyBytes []byte) (KeyPair, error) {
+	block, err := decodePrivateKeyBlock(privateKeyBytes)
 	if err != nil {
 		return nil, err
 	}
+	var privateKey *ecdsa.PrivateKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := parsePKCS8PrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if privateKey, ok = parsed.(*ecdsa.PrivateKey); !ok {
+			return nil, ErrKeyTypeMismatch
+		}
+	case "EC PRIVATE KEY", "PRIVATE_KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedKey, err)
+		}
+	case "RSA PRIVATE KEY", "RSA_PRIVATE_KEY":
+		return nil, ErrKeyTypeMismatch
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block type %q", ErrMalformedKey, block.Type)
+	}
 
 	return &ECCKeyPair{
 		Private: privateKey,
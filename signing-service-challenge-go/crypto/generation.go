@@ -11,6 +11,10 @@ type Generator interface {
 	Generate() (KeyPair, error)
 }
 
+// RSAKeyBits is the size of generated RSA keys, the minimum size accepted for
+// imported keys as well.
+const RSAKeyBits = 2048
+
 // RSAGenerator generates a RSA key pair.
 type RSAGenerator struct{}
 
@@ -20,8 +24,7 @@ func NewRSAGenerator() Generator {
 
 // Generate generates a new RSAKeyPair.
 func (g *RSAGenerator) Generate() (KeyPair, error) {
-	// Security has been ignored for the sake of simplicity.
-	key, err := rsa.GenerateKey(rand.Reader, 512)
+	key, err := rsa.GenerateKey(rand.Reader, RSAKeyBits)
 	if err != nil {
 		return nil, err
 	}
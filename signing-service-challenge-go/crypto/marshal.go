@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMalformedKey is returned by a Marshaler if the encoded key cannot be parsed.
+	ErrMalformedKey = errors.New("malformed private key")
+	// ErrKeyTypeMismatch is returned by a Marshaler if a key is of another algorithm
+	// than the one it encodes and decodes.
+	ErrKeyTypeMismatch = errors.New("private key is of another algorithm")
+)
+
+// Marshaler encodes the key pairs of one algorithm to be persisted or exported, and
+// decodes them again. All algorithms encode keys in the same form: the public key as
+// a PKIX "PUBLIC KEY" PEM block and the private key as a PKCS #8 "PRIVATE KEY" PEM block.
+type Marshaler interface {
+	// Marshal returns the PEM encoded public and private key of a key pair.
+	Marshal(keyPair KeyPair) ([]byte, []byte, error)
+	// Unmarshal assembles a key pair from a PEM encoded private key.
+	Unmarshal(privateKeyBytes []byte) (KeyPair, error)
+}
+
+// marshalKeyPair encodes the public key as PKIX and the private key as PKCS #8.
+func marshalKeyPair(keyPair KeyPair) ([]byte, []byte, error) {
+	encodedPublic, err := EncodePublicKey(keyPair.PublicKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(keyPair.PrivateKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	encodedPrivate := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateKeyBytes,
+	})
+	return encodedPublic, encodedPrivate, nil
+}
+
+// decodePrivateKeyBlock returns the first PEM block of an encoded private key.
+func decodePrivateKeyBlock(privateKeyBytes []byte) (*pem.Block, error) {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrMalformedKey)
+	}
+	return block, nil
+}
+
+// parsePKCS8PrivateKey parses a PKCS #8 "PRIVATE KEY" block, which may hold a key of any algorithm.
+func parsePKCS8PrivateKey(block *pem.Block) (interface{}, error) {
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedKey, err)
+	}
+	return privateKey, nil
+}
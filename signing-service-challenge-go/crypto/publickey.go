@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// EncodePublicKey encodes an RSA or ECDSA public key as a PKIX "PUBLIC KEY" PEM block,
+// the format used to hand device keys to external verifiers.
+func EncodePublicKey(publicKey interface{}) ([]byte, error) {
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	}), nil
+}
+
+// DecodePublicKey parses a PEM encoded public key. Besides PKIX "PUBLIC KEY" blocks it
+// accepts the block types written by ECCMarshaler and RSAMarshaler.
+func DecodePublicKey(publicKeyBytes []byte) (interface{}, error) {
+	block, _ := pem.Decode(publicKeyBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY", "PUBLIC_KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY", "RSA_PUBLIC_KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// NewVerifier returns the Verifier matching the type of the given public key.
+func NewVerifier(publicKey interface{}) (Verifier, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return NewRSAVerifier(key), nil
+	case *ecdsa.PublicKey:
+		return NewECDSAVerifier(key), nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// NewSigner returns the Signer matching the type of the given private key.
+func NewSigner(privateKey interface{}) (Signer, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return NewRSASigner(key), nil
+	case *ecdsa.PrivateKey:
+		return NewECDSASigner(key), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
@@ -3,7 +3,7 @@ package crypto
 import (
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/pem"
+	"fmt"
 )
 
 // RSAKeyPair is a DTO that holds RSA private and public keys.
@@ -29,32 +29,44 @@ func NewRSAMarshaler() RSAMarshaler {
 	return RSAMarshaler{}
 }
 
-// Marshal takes an RSAKeyPair and encodes it to be written on disk.
-// It returns the public and the private key as a byte slice.
-func (m *RSAMarshaler) Marshal(keyPair RSAKeyPair) ([]byte, []byte, error) {
-	privateKeyBytes := x509.MarshalPKCS1PrivateKey(keyPair.Private)
-	publicKeyBytes := x509.MarshalPKCS1PublicKey(keyPair.Public)
-
-	encodedPrivate := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PRIVATE_KEY",
-		Bytes: privateKeyBytes,
-	})
-
-	encodePublic := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA_PUBLIC_KEY",
-		Bytes: publicKeyBytes,
-	})
-
-	return encodePublic, encodedPrivate, nil
+// Marshal takes an RSA key pair and encodes it to be persisted or exported.
+// It returns the PKIX public and the PKCS #8 private key as PEM.
+func (m RSAMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	if _, ok := keyPair.PrivateKey().(*rsa.PrivateKey); !ok {
+		return nil, nil, ErrKeyTypeMismatch
+	}
+	return marshalKeyPair(keyPair)
 }
 
-// Unmarshal takes an encoded RSA private key and transforms it into a rsa.PrivateKey.
-func (m *RSAMarshaler) Unmarshal(privateKeyBytes []byte) (*RSAKeyPair, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+// Unmarshal assembles an RSAKeyPair from a PEM encoded private key. Besides PKCS #8
+// "PRIVATE KEY" blocks it accepts PKCS #1 "RSA PRIVATE KEY" blocks, as written by
+// OpenSSL, and the PKCS #1 "RSA_PRIVATE_KEY" blocks of earlier versions.
+func (m RSAMarshaler) Unmarshal(privateKeyBytes []byte) (KeyPair, error) {
+	block, err := decodePrivateKeyBlock(privateKeyBytes)
 	if err != nil {
 		return nil, err
 	}
+	var privateKey *rsa.PrivateKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := parsePKCS8PrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if privateKey, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, ErrKeyTypeMismatch
+		}
+	case "RSA PRIVATE KEY", "RSA_PRIVATE_KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedKey, err)
+		}
+	case "EC PRIVATE KEY", "PRIVATE_KEY":
+		return nil, ErrKeyTypeMismatch
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block type %q", ErrMalformedKey, block.Type)
+	}
 
 	return &RSAKeyPair{
 		Private: privateKey,
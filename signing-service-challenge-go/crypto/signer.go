@@ -1,28 +1,124 @@
 package crypto
 
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// ErrInvalidSignature is returned by a Verifier if the signature does not match the data.
+var ErrInvalidSignature = errors.New("invalid signature")
+
 // Signer defines a contract for different types of signing implementations.
 type Signer interface {
 	Sign(dataToBeSigned []byte) ([]byte, error)
 }
 
-type RSASigner struct{}
+// Verifier defines a contract for checking signatures created by a Signer.
+type Verifier interface {
+	Verify(signedData []byte, signature []byte) error
+}
+
+// RSASigner signs data with RSASSA-PKCS1-v1_5 over SHA-256.
+type RSASigner struct {
+	key *rsa.PrivateKey
+}
 
-func NewRSASigner() Signer {
-	return &RSASigner{}
+func NewRSASigner(key *rsa.PrivateKey) Signer {
+	return &RSASigner{key: key}
 }
 
 func (s *RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	digest := sha256.Sum256(dataToBeSigned)
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+}
 
-	return dataToBeSigned, nil
+// RSAVerifier verifies signatures created by an RSASigner.
+type RSAVerifier struct {
+	key *rsa.PublicKey
 }
 
-type ECDSASigner struct{}
+func NewRSAVerifier(key *rsa.PublicKey) Verifier {
+	return &RSAVerifier{key: key}
+}
+
+func (v *RSAVerifier) Verify(signedData []byte, signature []byte) error {
+	digest := sha256.Sum256(signedData)
+	if err := rsa.VerifyPKCS1v15(v.key, crypto.SHA256, digest[:], signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
 
-func NewECDSASigner() Signer {
-	return &ECDSASigner{}
+// ECDSASigner signs data with ECDSA over SHA-384 and returns an ASN.1 encoded signature.
+type ECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func NewECDSASigner(key *ecdsa.PrivateKey) Signer {
+	return &ECDSASigner{key: key}
 }
 
 func (s *ECDSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	digest := sha512.Sum384(dataToBeSigned)
+	return ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+}
+
+// ECDSAVerifier verifies signatures created by an ECDSASigner.
+type ECDSAVerifier struct {
+	key *ecdsa.PublicKey
+}
+
+func NewECDSAVerifier(key *ecdsa.PublicKey) Verifier {
+	return &ECDSAVerifier{key: key}
+}
+
+func (v *ECDSAVerifier) Verify(signedData []byte, signature []byte) error {
+	digest := sha512.Sum384(signedData)
+	if !ecdsa.VerifyASN1(v.key, digest[:], signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ECDSASignatureSize is the size in bytes of r and s of an ECDSASigner signature
+// on P-384, as padded by ECDSARawSignature.
+const ECDSASignatureSize = 48
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ECDSARawSignature converts an ASN.1 encoded ECDSA signature to the concatenation
+// of r and s padded to size bytes each, the format used by JOSE and COSE.
+func ECDSARawSignature(signature []byte, size int) ([]byte, error) {
+	parsed := ecdsaSignature{}
+	if rest, err := asn1.Unmarshal(signature, &parsed); err != nil || len(rest) > 0 {
+		return nil, errors.New("malformed ECDSA signature")
+	}
+	if parsed.R.Sign() < 0 || parsed.S.Sign() < 0 || parsed.R.BitLen() > size*8 || parsed.S.BitLen() > size*8 {
+		return nil, errors.New("ECDSA signature does not fit the size")
+	}
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+	return raw, nil
+}
 
-	return dataToBeSigned, nil
+// ECDSAASN1Signature converts the concatenation of r and s of size bytes each to
+// an ASN.1 encoded ECDSA signature, as checked by ECDSAVerifier.
+func ECDSAASN1Signature(raw []byte, size int) ([]byte, error) {
+	if len(raw) != 2*size {
+		return nil, errors.New("malformed ECDSA signature")
+	}
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(raw[:size]),
+		S: new(big.Int).SetBytes(raw[size:]),
+	})
 }
@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+type AuditIssueKind string
+
+const (
+	AuditCounterGap       AuditIssueKind = "counter_gap"
+	AuditDuplicateCounter AuditIssueKind = "duplicate_counter"
+	AuditBrokenLink       AuditIssueKind = "broken_link"
+	AuditInvalidSignature AuditIssueKind = "invalid_signature"
+	// AuditTimestampRegression flags a transaction signed before its predecessor,
+	// e.g. because the clock of the signing service went backwards.
+	AuditTimestampRegression AuditIssueKind = "timestamp_regression"
+	// AuditInvalidTimestamp flags a timestamp token that does not cover the signature
+	// of its transaction or was not issued by a trusted authority.
+	AuditInvalidTimestamp AuditIssueKind = "invalid_timestamp"
+)
+
+// AuditIssue describes a single problem found in the signature chain of a device.
+type AuditIssue struct {
+	Counter int            `json:"signature_counter"`
+	Kind    AuditIssueKind `json:"kind"`
+	Detail  string         `json:"detail"`
+}
+
+// AuditReport is the result of verifying the full signature chain of a device.
+type AuditReport struct {
+	DeviceId         string       `json:"device_id"`
+	TransactionCount int          `json:"transaction_count"`
+	Valid            bool         `json:"valid"`
+	Issues           []AuditIssue `json:"issues"`
+	// nextCounter is the counter following the last audited transaction, see CheckEnd.
+	nextCounter int
+}
+
+// VerifierResolver returns the verifier for a key version recorded on a transaction.
+type VerifierResolver func(keyVersion int) (crypto.Verifier, error)
+
+// TimestampVerifier checks that a DER encoded timestamp token covers a signature.
+type TimestampVerifier func(token []byte, signature []byte) error
+
+// AuditChain walks the transactions of a device in counter order and checks that
+// the counters have no gaps or duplicates starting from the counter of start, that
+// every transaction links to the signature of its predecessor, or the first one to
+// the last signature of start, that no transaction was signed before its
+// predecessor and that every signature is valid. Timestamp tokens are checked
+// with timestamps unless it is nil.
+func AuditChain(deviceId string, start ChainStart, verifiers VerifierResolver, timestamps TimestampVerifier, transactions []*Transaction) *AuditReport {
+	report := &AuditReport{
+		DeviceId:         deviceId,
+		TransactionCount: len(transactions),
+		Issues:           []AuditIssue{},
+	}
+
+	ordered := make([]*Transaction, len(transactions))
+	copy(ordered, transactions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Counter < ordered[j].Counter
+	})
+
+	expectedCounter := start.Counter
+	previousSignature := start.LastSignature
+	linkKnown := true
+	for i, transaction := range ordered {
+		switch {
+		case i > 0 && transaction.Counter == ordered[i-1].Counter:
+			report.Issues = append(report.Issues, AuditIssue{
+				Counter: transaction.Counter,
+				Kind:    AuditDuplicateCounter,
+				Detail:  fmt.Sprintf("signature_counter %d is used more than once", transaction.Counter),
+			})
+		case transaction.Counter != expectedCounter:
+			report.Issues = append(report.Issues, AuditIssue{
+				Counter: transaction.Counter,
+				Kind:    AuditCounterGap,
+				Detail:  fmt.Sprintf("expected signature_counter %d, got %d", expectedCounter, transaction.Counter),
+			})
+			// the predecessor is missing, so the link cannot be checked
+			linkKnown = false
+			fallthrough
+		default:
+			if i > 0 {
+				previousSignature = ordered[i-1].Signature
+			}
+			expectedCounter = transaction.Counter + 1
+		}
+
+		if linkKnown && transaction.LastSignature != previousSignature {
+			report.Issues = append(report.Issues, AuditIssue{
+				Counter: transaction.Counter,
+				Kind:    AuditBrokenLink,
+				Detail:  "last_signature does not match the signature of the previous transaction",
+			})
+		}
+		linkKnown = true
+
+		if i > 0 && transaction.SignedAt.Before(ordered[i-1].SignedAt) {
+			report.Issues = append(report.Issues, AuditIssue{
+				Counter: transaction.Counter,
+				Kind:    AuditTimestampRegression,
+				Detail:  "signed_at is before the signed_at of the previous transaction",
+			})
+		}
+
+		verifier, err := verifiers(transaction.KeyVersion)
+		if err == nil {
+			err = VerifyTransaction(verifier, transaction)
+		}
+		if err != nil {
+			report.Issues = append(report.Issues, AuditIssue{
+				Counter: transaction.Counter,
+				Kind:    AuditInvalidSignature,
+				Detail:  err.Error(),
+			})
+		}
+
+		if timestamps != nil && transaction.TimestampToken != "" {
+			if err := VerifyTimestamp(timestamps, transaction); err != nil {
+				report.Issues = append(report.Issues, AuditIssue{
+					Counter: transaction.Counter,
+					Kind:    AuditInvalidTimestamp,
+					Detail:  err.Error(),
+				})
+			}
+		}
+	}
+
+	report.nextCounter = expectedCounter
+	report.Valid = len(report.Issues) == 0
+	return report
+}
+
+// CheckEnd reports a counter gap if the audited chain does not end right before
+// next, the counter the device assigns to its next transaction, i.e. if the last
+// transactions of the device are missing. It is skipped by audits of a part of
+// the chain, e.g. of an export restricted to a time range.
+func (r *AuditReport) CheckEnd(next int) {
+	if r.nextCounter == next {
+		return
+	}
+	r.Issues = append(r.Issues, AuditIssue{
+		Counter: r.nextCounter,
+		Kind:    AuditCounterGap,
+		Detail:  fmt.Sprintf("expected the chain to end at signature_counter %d, got %d", next-1, r.nextCounter-1),
+	})
+	r.Valid = false
+}
+
+// VerifyTransaction recomputes the secured data of a transaction and checks its signature.
+func VerifyTransaction(verifier crypto.Verifier, transaction *Transaction) error {
+	securedData, err := transaction.SecuredData().Encode(transaction.EnvelopeVersion)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(transaction.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64 encoded")
+	}
+	return verifier.Verify(securedData, signature)
+}
+
+// VerifyTimestamp checks the timestamp token of a transaction against its signature.
+func VerifyTimestamp(timestamps TimestampVerifier, transaction *Transaction) error {
+	token, err := base64.StdEncoding.DecodeString(transaction.TimestampToken)
+	if err != nil {
+		return fmt.Errorf("timestamp_token is not base64 encoded")
+	}
+	signature, err := base64.StdEncoding.DecodeString(transaction.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64 encoded")
+	}
+	return timestamps(token, signature)
+}
@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrCertificateKeyMismatch is returned if a certificate does not certify the current key of a device.
+var ErrCertificateKeyMismatch = errors.New("certificate does not certify the public key of the device")
+
+// OIDDeviceIdentity identifies the certificate extension binding a key to the
+// DeviceIdentity of its device. It lies below the enterprise number RFC 5612
+// reserves for documentation.
+var OIDDeviceIdentity = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 1, 1}
+
+// DeviceIdentity is the content of the device identity extension of a device certificate.
+type DeviceIdentity struct {
+	Id        string             `asn1:"utf8"`
+	Label     string             `asn1:"utf8"`
+	Algorithm SignatureAlgorithm `asn1:"utf8"`
+}
+
+// ParseDeviceIdentity returns the device identity extension of a certificate.
+func ParseDeviceIdentity(certificate *x509.Certificate) (*DeviceIdentity, error) {
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(OIDDeviceIdentity) {
+			continue
+		}
+		identity := &DeviceIdentity{}
+		if rest, err := asn1.Unmarshal(extension.Value, identity); err != nil || len(rest) > 0 {
+			return nil, errors.New("malformed device identity extension")
+		}
+		return identity, nil
+	}
+	return nil, errors.New("certificate has no device identity extension")
+}
+
+// CertificateIssuer selects who certifies the key of a device.
+type CertificateIssuer string
+
+const (
+	// IssuerSelf certifies the key with a certificate signed by the key itself.
+	IssuerSelf CertificateIssuer = "self"
+	// IssuerCA certifies the key with a certificate of the service-internal CA.
+	IssuerCA CertificateIssuer = "ca"
+)
+
+// Valid reports whether the issuer is supported.
+func (i CertificateIssuer) Valid() bool {
+	return i == IssuerSelf || i == IssuerCA
+}
+
+// CertificateTemplate returns the subject, key usage and extensions of a certificate
+// for the current key of the device. Its common name is the device id, the device
+// identity extension binds the id, label and algorithm of the device.
+func (d *SignatureDevice) CertificateTemplate() (*x509.Certificate, error) {
+	identity, err := asn1.Marshal(DeviceIdentity{Id: d.Id, Label: d.Label, Algorithm: d.SignatureAlgorithm})
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: d.Id},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: OIDDeviceIdentity, Value: identity}},
+	}, nil
+}
+
+// CertificateRequest returns a DER encoded PKCS #10 certificate signing request
+// for the current key of the device, signed by the key.
+func (d *SignatureDevice) CertificateRequest(subject pkix.Name) ([]byte, error) {
+	signer, err := d.keySigner()
+	if err != nil {
+		return nil, err
+	}
+	return x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, signer)
+}
+
+// SelfSignCertificate returns a certificate of the current key signed by the key itself.
+// The template sets the serial number and validity.
+func (d *SignatureDevice) SelfSignCertificate(template *x509.Certificate) (*x509.Certificate, error) {
+	signer, err := d.keySigner()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, d.KeyPair.PublicKey(), signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// SetCertificate certifies the current key of the device with a certificate chain,
+// starting with the certificate of the key.
+func (d *SignatureDevice) SetCertificate(chain []*x509.Certificate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.Active() {
+		return ErrDeviceDeactivated
+	}
+	encoded, err := encodeCertificates(d.KeyPair.PublicKey(), chain)
+	if err != nil {
+		return err
+	}
+	d.Certificate = encoded
+	return nil
+}
+
+// encodeCertificates PEM encodes a certificate chain, starting with the certificate of publicKey.
+func encodeCertificates(publicKey interface{}, chain []*x509.Certificate) (string, error) {
+	if len(chain) == 0 {
+		return "", errors.New("certificate chain is empty")
+	}
+	encodedKey, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	certifiedKey, err := x509.MarshalPKIXPublicKey(chain[0].PublicKey)
+	if err != nil || !bytes.Equal(encodedKey, certifiedKey) {
+		return "", ErrCertificateKeyMismatch
+	}
+	encoded := []byte{}
+	for _, certificate := range chain {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+	return string(encoded), nil
+}
+
+// Certificates returns the certificate chain of the current key, nil if it has not been certified.
+func (d *SignatureDevice) Certificates() ([]*x509.Certificate, error) {
+	d.mu.Lock()
+	certificate := d.Certificate
+	d.mu.Unlock()
+	return ParseCertificates([]byte(certificate))
+}
+
+// ParseCertificates decodes a sequence of PEM encoded certificates. Blocks of other types are skipped.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certificates, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+}
+
+// keySigner returns the private key of the device as a standard library signer, as
+// needed to sign certificates and requests.
+func (d *SignatureDevice) keySigner() (stdcrypto.Signer, error) {
+	if d.KeyPair == nil {
+		return nil, fmt.Errorf("device has no key pair")
+	}
+	signer, ok := d.KeyPair.PrivateKey().(stdcrypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", d.KeyPair.PrivateKey())
+	}
+	return signer, nil
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/merkle"
+)
+
+// CheckpointVersion identifies the format of the data a checkpoint signature covers.
+const CheckpointVersion = "checkpoint-v1"
+
+// logLeaf fixes the fields and their order in the leaf of a transaction.
+type logLeaf struct {
+	DeviceId  string `json:"device_id"`
+	Counter   int    `json:"signature_counter"`
+	Signature string `json:"signature"`
+}
+
+// LogLeaf encodes the transaction as a leaf of the transparency log. The leaf
+// covers the device signature, which in turn covers the signed data, the link
+// to the previous signature and, depending on the envelope, the signing time.
+func (t *Transaction) LogLeaf() ([]byte, error) {
+	return encodeCanonical(logLeaf{DeviceId: t.DeviceId, Counter: t.Counter, Signature: t.Signature})
+}
+
+// LogEntry is a transaction anchored in the transparency log.
+type LogEntry struct {
+	// Index is the position of the entry in the log, starting at 0.
+	Index    int    `json:"index"`
+	DeviceId string `json:"device_id"`
+	Counter  int    `json:"signature_counter"`
+	// LeafHash is the base64 encoded Merkle leaf hash of the LogLeaf of the transaction.
+	LeafHash string `json:"leaf_hash"`
+}
+
+// NewLogEntry is a factory to instantiate the LogEntry of a transaction at index.
+func NewLogEntry(index int, transaction *Transaction) (*LogEntry, error) {
+	leaf, err := transaction.LogLeaf()
+	if err != nil {
+		return nil, err
+	}
+	return &LogEntry{
+		Index:    index,
+		DeviceId: transaction.DeviceId,
+		Counter:  transaction.Counter,
+		LeafHash: base64.StdEncoding.EncodeToString(merkle.LeafHash(leaf)),
+	}, nil
+}
+
+// Checkpoint commits to the first TreeSize entries of the transparency log with
+// the root hash of their Merkle tree, signed by the checkpoint key of the service.
+type Checkpoint struct {
+	// Number counts the checkpoints, starting at 1.
+	Number    int       `json:"number"`
+	TreeSize  int       `json:"tree_size"`
+	RootHash  string    `json:"root_hash"`
+	CreatedAt time.Time `json:"created_at"`
+	// Signature is the base64 encoded signature over SignedData.
+	Signature string `json:"signature"`
+	// TimestampToken is the base64 encoded RFC 3161 timestamp token over the decoded
+	// signature, set if the signing service countersigns with a timestamp authority.
+	TimestampToken string `json:"timestamp_token,omitempty"`
+}
+
+// signedCheckpoint fixes the fields and their order of the signed data of a checkpoint.
+type signedCheckpoint struct {
+	Version   string `json:"v"`
+	Number    int    `json:"number"`
+	TreeSize  int    `json:"tree_size"`
+	RootHash  string `json:"root_hash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SignedData encodes the fields of the checkpoint covered by its signature as canonical JSON.
+func (c *Checkpoint) SignedData() ([]byte, error) {
+	return encodeCanonical(signedCheckpoint{
+		Version:   CheckpointVersion,
+		Number:    c.Number,
+		TreeSize:  c.TreeSize,
+		RootHash:  c.RootHash,
+		CreatedAt: FormatSignedAt(c.CreatedAt),
+	})
+}
+
+// Verify checks the signature of the checkpoint with the checkpoint key of the service.
+func (c *Checkpoint) Verify(verifier crypto.Verifier) error {
+	signedData, err := c.SignedData()
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return crypto.ErrInvalidSignature
+	}
+	return verifier.Verify(signedData, signature)
+}
+
+// InclusionProof proves that a transaction is included in the tree a checkpoint commits to.
+type InclusionProof struct {
+	Entry      LogEntry   `json:"entry"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+	// AuditPath holds the base64 encoded hashes leading from the leaf to the root, see RFC 9162.
+	AuditPath []string `json:"audit_path"`
+}
+
+// ErrInvalidInclusionProof is returned if an inclusion proof does not match its transaction or checkpoint.
+var ErrInvalidInclusionProof = errors.New("invalid inclusion proof")
+
+// Verify checks that the proof holds for the transaction and leads to the root
+// hash of its checkpoint. The checkpoint signature is not checked.
+func (p *InclusionProof) Verify(transaction *Transaction) error {
+	if transaction.DeviceId != p.Entry.DeviceId || transaction.Counter != p.Entry.Counter {
+		return ErrInvalidInclusionProof
+	}
+	leaf, err := transaction.LogLeaf()
+	if err != nil {
+		return err
+	}
+	rootHash, err := base64.StdEncoding.DecodeString(p.Checkpoint.RootHash)
+	if err != nil {
+		return ErrInvalidInclusionProof
+	}
+	path := make([][]byte, len(p.AuditPath))
+	for i, hash := range p.AuditPath {
+		if path[i], err = base64.StdEncoding.DecodeString(hash); err != nil {
+			return ErrInvalidInclusionProof
+		}
+	}
+	if err := merkle.VerifyInclusion(p.Entry.Index, p.Checkpoint.TreeSize, merkle.LeafHash(leaf), path, rootHash); err != nil {
+		return ErrInvalidInclusionProof
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// Client is a cash register or another terminal that signs transactions. It may
+// only sign with the devices it is registered to.
+type Client struct {
+	Id string `json:"id"`
+	// SerialNumber identifies the hardware or installation of the client. It is unique.
+	SerialNumber string    `json:"serial_number"`
+	DeviceIds    []string  `json:"device_ids"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NewClient returns a client registered to the given devices.
+func NewClient(serialNumber string, deviceIds []string) *Client {
+	client := &Client{
+		SerialNumber: serialNumber,
+		DeviceIds:    []string{},
+		CreatedAt:    time.Now().UTC(),
+	}
+	for _, deviceId := range deviceIds {
+		client.Register(deviceId)
+	}
+	return client
+}
+
+// RegisteredTo reports whether the client may sign with the device.
+func (c *Client) RegisteredTo(deviceId string) bool {
+	for _, registered := range c.DeviceIds {
+		if registered == deviceId {
+			return true
+		}
+	}
+	return false
+}
+
+// Register allows the client to sign with the device. Registering twice has no effect.
+func (c *Client) Register(deviceId string) {
+	if !c.RegisteredTo(deviceId) {
+		c.DeviceIds = append(c.DeviceIds, deviceId)
+	}
+}
+
+// Deregister revokes the permission of the client to sign with the device.
+func (c *Client) Deregister(deviceId string) {
+	deviceIds := make([]string, 0, len(c.DeviceIds))
+	for _, registered := range c.DeviceIds {
+		if registered != deviceId {
+			deviceIds = append(deviceIds, registered)
+		}
+	}
+	c.DeviceIds = deviceIds
+}
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -15,6 +18,20 @@ const (
 	ECDSA SignatureAlgorithm = "ECC"
 )
 
+type DeviceStatus string
+
+const (
+	DeviceActive      DeviceStatus = "active"
+	DeviceDeactivated DeviceStatus = "deactivated"
+)
+
+var (
+	// ErrDeviceDeactivated is returned when a deactivated device is asked to sign or change its keys.
+	ErrDeviceDeactivated = errors.New("device is deactivated")
+	// ErrInvalidAlgorithm is returned when a device is created with an unsupported signature algorithm.
+	ErrInvalidAlgorithm = errors.New("signature_algorithm must be RSA or ECC")
+)
+
 type DeviceInterface interface {
 	GenerateKeyPair() error
 	IncrementCounter()
@@ -25,47 +42,192 @@ type DeviceInterface interface {
 type SignatureDevice struct {
 	Id                 string             `json:"id"`
 	SignatureAlgorithm SignatureAlgorithm `json:"signature_algorithm"`
-	KeyPair            crypto.KeyPair     `json:"key_pair"`
+	KeyPair            crypto.KeyPair     `json:"-"`
+	PublicKey          string             `json:"public_key"`
+	KeyVersion         int                `json:"key_version"`
+	PublicKeys         []DevicePublicKey  `json:"public_keys"`
 	Label              string             `json:"label"`
-	signatureCounter   int
-	mu                 *sync.Mutex
+	EnvelopeVersion    EnvelopeVersion    `json:"envelope_version"`
+	Status             DeviceStatus       `json:"status"`
+	CreatedAt          time.Time          `json:"created_at"`
+	DeactivatedAt      *time.Time         `json:"deactivated_at,omitempty"`
+	// Certificate holds the PEM encoded X.509 certificate of the current key, followed
+	// by the certificates of its issuers, if the key has been certified.
+	Certificate string `json:"certificate,omitempty"`
+	// ImportedChain is where the signature chain of a device migrated from another
+	// signing service continues. It is nil for devices created by this service.
+	ImportedChain *ChainStart `json:"imported_chain,omitempty"`
+	// Metadata holds tags of the device, e.g. the store or register it is used in.
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	signatureCounter int
+	mu               sync.Mutex
+	// chainMu serializes the transactions of the device, see LockChain.
+	chainMu sync.Mutex
+}
+
+// DevicePublicKey is a public key that has been used by a device.
+// Transactions reference it by Version, so keys are kept after a rotation.
+type DevicePublicKey struct {
+	Version   int       `json:"version"`
+	PublicKey string    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 func NewSignatureDevice(algorithm SignatureAlgorithm, label string) (*SignatureDevice, error) {
 	dev := &SignatureDevice{
 		SignatureAlgorithm: algorithm,
 		Label:              label,
+		EnvelopeVersion:    DefaultEnvelopeVersion,
+		Status:             DeviceActive,
+		CreatedAt:          time.Now().UTC(),
 	}
 	err := dev.GenerateKeyPair()
 	if err != nil {
 		return nil, err
 	}
-	dev.mu = &sync.Mutex{}
 	return dev, nil
 }
 
 func (d *SignatureDevice) GenerateKeyPair() error {
+	keyPair, err := d.NewKeyPair()
+	if err != nil {
+		return err
+	}
+	d.KeyPair = keyPair
+	return d.addKeyPair()
+}
+
+// NewKeyPair generates a key pair for the signature algorithm of the device without
+// using it, e.g. to certify it before it replaces the current key, see RotateKeyPair.
+func (d *SignatureDevice) NewKeyPair() (crypto.KeyPair, error) {
 	switch d.SignatureAlgorithm {
 	case RSA:
-		gen := crypto.NewRSAGenerator()
-		key, err := gen.Generate()
-		if err != nil {
-			return err
-		}
-		d.KeyPair = key
+		return crypto.NewRSAGenerator().Generate()
 	case ECDSA:
-		gen := crypto.NewECCGenerator()
-		key, err := gen.Generate()
-		if err != nil {
+		return crypto.NewECCGenerator().Generate()
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+// addKeyPair makes the key pair of the device its current key, recording the public
+// key under a new key version.
+func (d *SignatureDevice) addKeyPair() error {
+	// export the public key for external verifiers
+	publicKey, err := crypto.EncodePublicKey(d.KeyPair.PublicKey())
+	if err != nil {
+		return err
+	}
+	if len(d.PublicKeys) > 0 {
+		d.KeyVersion = d.PublicKeys[len(d.PublicKeys)-1].Version + 1
+	}
+	d.PublicKey = string(publicKey)
+	// a certificate only certifies the key it was issued for
+	d.Certificate = ""
+	d.PublicKeys = append(d.PublicKeys, DevicePublicKey{
+		Version:   d.KeyVersion,
+		PublicKey: d.PublicKey,
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+// RotateKeyPair replaces the key pair of the device with keyPair, certified by the
+// certificate chain unless it is empty. The previous public key stays available so
+// that earlier transactions can still be verified. The device is left unchanged if
+// the chain does not certify keyPair.
+func (d *SignatureDevice) RotateKeyPair(keyPair crypto.KeyPair, chain []*x509.Certificate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.Active() {
+		return ErrDeviceDeactivated
+	}
+	certificate := ""
+	if len(chain) > 0 {
+		var err error
+		if certificate, err = encodeCertificates(keyPair.PublicKey(), chain); err != nil {
 			return err
 		}
-		d.KeyPair = key
-	default:
-		return fmt.Errorf("signature_algorithm must be RSA or ECC")
 	}
+	previous := d.KeyPair
+	d.KeyPair = keyPair
+	if err := d.addKeyPair(); err != nil {
+		d.KeyPair = previous
+		return err
+	}
+	d.Certificate = certificate
+	return nil
+}
+
+// Active reports whether the device may still sign transactions. It reads the
+// status without locking, for methods already holding the lock of the device;
+// everyone else uses IsActive.
+func (d *SignatureDevice) Active() bool {
+	return d.Status != DeviceDeactivated
+}
+
+// IsActive reports whether the device may still sign transactions, reading the
+// status under the lock of the device.
+func (d *SignatureDevice) IsActive() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Active()
+}
+
+// Deactivate permanently takes the device out of service.
+func (d *SignatureDevice) Deactivate() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.Active() {
+		return ErrDeviceDeactivated
+	}
+	now := time.Now().UTC()
+	d.Status = DeviceDeactivated
+	d.DeactivatedAt = &now
 	return nil
 }
 
+// Signer returns a crypto.Signer backed by the private key of the device.
+func (d *SignatureDevice) Signer() (crypto.Signer, error) {
+	switch keyPair := d.KeyPair.(type) {
+	case *crypto.RSAKeyPair:
+		return crypto.NewRSASigner(keyPair.Private), nil
+	case *crypto.ECCKeyPair:
+		return crypto.NewECDSASigner(keyPair.Private), nil
+	default:
+		return nil, fmt.Errorf("cannot sign if signature_algorithm not RSA or ECC")
+	}
+}
+
+// Verifier returns a crypto.Verifier backed by the public key of the device.
+func (d *SignatureDevice) Verifier() (crypto.Verifier, error) {
+	switch keyPair := d.KeyPair.(type) {
+	case *crypto.RSAKeyPair:
+		return crypto.NewRSAVerifier(keyPair.Public), nil
+	case *crypto.ECCKeyPair:
+		return crypto.NewECDSAVerifier(keyPair.Public), nil
+	default:
+		return nil, fmt.Errorf("cannot verify if signature_algorithm not RSA or ECC")
+	}
+}
+
+// VerifierForKey returns a crypto.Verifier for the given key version of the device.
+func (d *SignatureDevice) VerifierForKey(version int) (crypto.Verifier, error) {
+	if version == d.KeyVersion {
+		return d.Verifier()
+	}
+	for _, key := range d.PublicKeys {
+		if key.Version == version {
+			publicKey, err := crypto.DecodePublicKey([]byte(key.PublicKey))
+			if err != nil {
+				return nil, err
+			}
+			return crypto.NewVerifier(publicKey)
+		}
+	}
+	return nil, fmt.Errorf("unknown key_version %d", version)
+}
+
 func (d *SignatureDevice) IncrementCounter() {
 	d.mu.Lock()
 	d.signatureCounter++
@@ -73,16 +235,121 @@ func (d *SignatureDevice) IncrementCounter() {
 }
 
 func (d *SignatureDevice) Counter() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.signatureCounter
 }
 
+// LockChain serializes the transactions of the device and the changes of its key,
+// so that every transaction takes the next counter, links to the signature of its
+// predecessor and is signed with the current key. The lock must be held from
+// reading the counter until the transaction is saved and the counter incremented.
+// It returns the function releasing the lock.
+func (d *SignatureDevice) LockChain() func() {
+	d.chainMu.Lock()
+	return d.chainMu.Unlock
+}
+
+// Snapshot returns a copy of the device taken under its lock, so that it can be
+// read while the key of the device is rotated or the device is deactivated.
+func (d *SignatureDevice) Snapshot() *SignatureDevice {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &SignatureDevice{
+		Id:                 d.Id,
+		SignatureAlgorithm: d.SignatureAlgorithm,
+		KeyPair:            d.KeyPair,
+		PublicKey:          d.PublicKey,
+		KeyVersion:         d.KeyVersion,
+		PublicKeys:         append([]DevicePublicKey{}, d.PublicKeys...),
+		Label:              d.Label,
+		EnvelopeVersion:    d.EnvelopeVersion,
+		Status:             d.Status,
+		CreatedAt:          d.CreatedAt,
+		DeactivatedAt:      d.DeactivatedAt,
+		Certificate:        d.Certificate,
+		ImportedChain:      d.ImportedChain,
+		Metadata:           d.Metadata,
+		signatureCounter:   d.signatureCounter,
+	}
+}
+
+// MarshalJSON adds the current signature counter to the JSON representation of the device.
+// It marshals a Snapshot of the device.
+func (d *SignatureDevice) MarshalJSON() ([]byte, error) {
+	type device SignatureDevice
+	snapshot := d.Snapshot()
+	return json.Marshal(struct {
+		*device
+		SignatureCounter int `json:"signature_counter"`
+	}{
+		device:           (*device)(snapshot),
+		SignatureCounter: snapshot.signatureCounter,
+	})
+}
+
+// UnmarshalJSON restores a device from its JSON representation, e.g. in API clients.
+// The key pair is not part of it, so the device can only be used to read its state.
+func (d *SignatureDevice) UnmarshalJSON(data []byte) error {
+	type device SignatureDevice
+	decoded := struct {
+		*device
+		SignatureCounter int `json:"signature_counter"`
+	}{
+		device: (*device)(d),
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	d.signatureCounter = decoded.SignatureCounter
+	return nil
+}
+
 type Transaction struct {
-	DeviceId string    `json:"device_id"`
-	Data     string    `json:"data_to_be_signed"`
+	DeviceId        string          `json:"device_id"`
+	Data            string          `json:"data_to_be_signed"`
+	Counter         int             `json:"signature_counter"`
+	LastSignature   string          `json:"last_signature"`
+	Signature       string          `json:"signature"`
+	EnvelopeVersion EnvelopeVersion `json:"envelope_version"`
+	KeyVersion      int             `json:"key_version"`
+	// SignedAt is taken before signing. It is covered by the signature if the v2 envelope is used.
 	SignedAt time.Time `json:"signed_at"`
+	// TimestampToken is the base64 encoded RFC 3161 timestamp token over the decoded
+	// signature, set if the signing service countersigns with a timestamp authority.
+	TimestampToken string `json:"timestamp_token,omitempty"`
+	// ClientId names the client that requested the signature. It is covered by
+	// the signature if the v2 envelope is used.
+	ClientId string `json:"client_id,omitempty"`
+	// Reference is an optional identifier assigned by the client, e.g. a receipt
+	// number. It is not covered by the signature.
+	Reference string `json:"reference,omitempty"`
+	// Metadata holds typed values assigned by the client. It is not covered by the signature.
+	Metadata map[string]MetadataValue `json:"metadata,omitempty"`
+	// FiscalTransactionNumber and FiscalOperation are set if the transaction signed
+	// a state change of a fiscal transaction. Both are part of the signed data.
+	FiscalTransactionNumber int             `json:"fiscal_transaction_number,omitempty"`
+	FiscalOperation         FiscalOperation `json:"fiscal_operation,omitempty"`
+}
+
+// SecuredData returns the fields of the transaction that are covered by its signature.
+func (t *Transaction) SecuredData() SecuredData {
+	return SecuredData{
+		Counter:       t.Counter,
+		Data:          t.Data,
+		LastSignature: t.LastSignature,
+		ClientId:      t.ClientId,
+		SignedAt:      t.SignedAt,
+	}
 }
 
 type SignatureResponse struct {
 	Signature  *Transaction `json:"transaction"`
 	SignedData string       `json:"signed_data"`
+	// JWS is the transaction as a compact JWS, set if FormatJWS was requested.
+	JWS string `json:"jws,omitempty"`
+	// COSE is the base64 encoded COSE_Sign1 message of the transaction, set if FormatCOSE was requested.
+	COSE string `json:"cose,omitempty"`
+	// CMS is the base64 encoded detached CMS SignedData over SignedData, set if FormatCMS was requested.
+	CMS string `json:"cms,omitempty"`
 }
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// EnvelopeVersion identifies the format used to assemble the secured_data_to_be_signed.
+type EnvelopeVersion string
+
+const (
+	// EnvelopeLegacy joins counter, data and last signature with underscores.
+	// Data containing underscores makes the result ambiguous, so it is only
+	// kept for devices that depend on the original format.
+	EnvelopeLegacy EnvelopeVersion = "legacy"
+	// EnvelopeV1 encodes the secured data as canonical JSON.
+	EnvelopeV1 EnvelopeVersion = "v1"
+	// EnvelopeV2 extends EnvelopeV1 by the client id and the signing timestamp,
+	// so they cannot be altered after signing.
+	EnvelopeV2 EnvelopeVersion = "v2"
+)
+
+// DefaultEnvelopeVersion is used for devices that do not request a specific format.
+const DefaultEnvelopeVersion = EnvelopeV2
+
+// Valid reports whether v is a known envelope version.
+func (v EnvelopeVersion) Valid() bool {
+	switch v {
+	case EnvelopeLegacy, EnvelopeV1, EnvelopeV2:
+		return true
+	}
+	return false
+}
+
+// SecuredData holds the fields that are covered by a device signature.
+type SecuredData struct {
+	Counter       int    `json:"signature_counter"`
+	Data          string `json:"data_to_be_signed"`
+	LastSignature string `json:"last_signature"`
+	// ClientId is only part of the v2 envelope, the v1 envelope predates clients.
+	// It is omitted if empty, so v2 transactions signed before clients were
+	// introduced still verify.
+	ClientId string `json:"client_id,omitempty"`
+	// SignedAt is only part of the v2 envelope.
+	SignedAt time.Time `json:"-"`
+}
+
+// securedDataV1 fixes the field order of the v1 envelope.
+type securedDataV1 struct {
+	Version EnvelopeVersion `json:"v"`
+	SecuredData
+}
+
+// securedDataV2 adds the client id and the signing timestamp to the fields of the v1 envelope.
+type securedDataV2 struct {
+	securedDataV1
+	SignedAt string `json:"signed_at"`
+}
+
+// Encode assembles the secured data in the given envelope format.
+// An empty version is treated as EnvelopeLegacy, the format used before
+// envelopes were versioned.
+func (d SecuredData) Encode(version EnvelopeVersion) ([]byte, error) {
+	switch version {
+	case "", EnvelopeLegacy:
+		return []byte(strconv.Itoa(d.Counter) + "_" + d.Data + "_" + d.LastSignature), nil
+	case EnvelopeV1:
+		d.ClientId = ""
+		return encodeCanonical(securedDataV1{Version: version, SecuredData: d})
+	case EnvelopeV2:
+		return encodeCanonical(securedDataV2{
+			securedDataV1: securedDataV1{Version: version, SecuredData: d},
+			SignedAt:      FormatSignedAt(d.SignedAt),
+		})
+	default:
+		return nil, fmt.Errorf("unknown envelope version %q", version)
+	}
+}
+
+// FormatSignedAt formats a signing timestamp as it is covered by the v2 envelope:
+// RFC 3339 in UTC with nanoseconds, without trailing zeros.
+func FormatSignedAt(signedAt time.Time) string {
+	return signedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// encodeCanonical encodes v as compact JSON in field order, without HTML escaping
+// and without the trailing newline of json.Encoder.
+func encodeCanonical(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
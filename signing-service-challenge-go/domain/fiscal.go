@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// FiscalOperation is a state change of a fiscal transaction.
+type FiscalOperation string
+
+const (
+	FiscalStart  FiscalOperation = "start"
+	FiscalUpdate FiscalOperation = "update"
+	FiscalFinish FiscalOperation = "finish"
+)
+
+type FiscalState string
+
+const (
+	FiscalActive   FiscalState = "active"
+	FiscalFinished FiscalState = "finished"
+)
+
+// ErrFiscalTransactionFinished is returned when a finished fiscal transaction is asked to change.
+var ErrFiscalTransactionFinished = errors.New("fiscal transaction is already finished")
+
+// FiscalTransaction is a long-running transaction of a cash register, e.g. a
+// receipt from the first item scanned to the payment. It is signed when it is
+// started, on every update and when it is finished. Each of these state changes
+// is a Transaction of the device, so it is chained to all other signatures of
+// the device through the signature counter.
+type FiscalTransaction struct {
+	DeviceId string `json:"device_id"`
+	// Number is assigned in order of start per device, starting at 1.
+	Number int `json:"number"`
+	// ClientId names the client that started the fiscal transaction. Only it may change it.
+	ClientId    string      `json:"client_id"`
+	State       FiscalState `json:"state"`
+	ProcessType string      `json:"process_type"`
+	ProcessData string      `json:"process_data"`
+	StartedAt   time.Time   `json:"started_at"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+	// Log lists the signed state changes in the order they were made.
+	Log []FiscalLogEntry `json:"log"`
+}
+
+// FiscalLogEntry refers to the Transaction that signed a state change of a fiscal transaction.
+type FiscalLogEntry struct {
+	Operation        FiscalOperation `json:"operation"`
+	SignatureCounter int             `json:"signature_counter"`
+	SignedAt         time.Time       `json:"signed_at"`
+}
+
+// FiscalLogData is the data signed for a state change of a fiscal transaction.
+// It is encoded as data_to_be_signed of the Transaction, so verifiers check it
+// like any other signed data.
+type FiscalLogData struct {
+	TransactionNumber int             `json:"transaction_number"`
+	Operation         FiscalOperation `json:"operation"`
+	ProcessType       string          `json:"process_type"`
+	ProcessData       string          `json:"process_data"`
+}
+
+// Encode returns the data as compact JSON with a fixed field order.
+func (d FiscalLogData) Encode() (string, error) {
+	encoded, err := encodeCanonical(d)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// Active reports whether the fiscal transaction may still be updated or finished.
+func (t *FiscalTransaction) Active() bool {
+	return t.State != FiscalFinished
+}
+
+// Record applies a state change that has been signed by transaction.
+func (t *FiscalTransaction) Record(data FiscalLogData, transaction *Transaction) {
+	t.ProcessType = data.ProcessType
+	t.ProcessData = data.ProcessData
+	t.Log = append(t.Log, FiscalLogEntry{
+		Operation:        data.Operation,
+		SignatureCounter: transaction.Counter,
+		SignedAt:         transaction.SignedAt,
+	})
+	switch data.Operation {
+	case FiscalStart:
+		t.State = FiscalActive
+		t.StartedAt = transaction.SignedAt
+	case FiscalFinish:
+		finishedAt := transaction.SignedAt
+		t.State = FiscalFinished
+		t.FinishedAt = &finishedAt
+	}
+}
+
+// FiscalTransactionResponse is the result of a state change of a fiscal transaction.
+type FiscalTransactionResponse struct {
+	FiscalTransaction *FiscalTransaction `json:"fiscal_transaction"`
+	Signature         *Transaction       `json:"transaction"`
+	SignedData        string             `json:"signed_data"`
+}
@@ -0,0 +1,87 @@
+package domain
+
+import "encoding/base64"
+
+// SignatureFormat selects how a signature is returned to the client.
+type SignatureFormat string
+
+const (
+	// FormatJSON returns the transaction and the signed data.
+	FormatJSON SignatureFormat = "json"
+	// FormatJWS additionally returns the transaction as a compact JWS signed by the device key.
+	FormatJWS SignatureFormat = "jws"
+	// FormatCOSE additionally returns the transaction as a COSE_Sign1 message signed by the device key.
+	FormatCOSE SignatureFormat = "cose"
+	// FormatCMS additionally returns a detached CMS SignedData over the signed data,
+	// signed by the device key and carrying its certificate.
+	FormatCMS SignatureFormat = "cms"
+)
+
+// Valid reports whether f is a known signature format.
+func (f SignatureFormat) Valid() bool {
+	return f == FormatJSON || f == FormatJWS || f == FormatCOSE || f == FormatCMS
+}
+
+// JWSPayload holds the claims of the JWS of a transaction. The JWS is signed in
+// addition to the transaction, its signature is not part of the signature chain.
+type JWSPayload struct {
+	DeviceId      string `json:"device_id"`
+	Counter       int    `json:"signature_counter"`
+	Data          string `json:"data_to_be_signed"`
+	LastSignature string `json:"last_signature"`
+	// Signature is the chained signature of the transaction.
+	Signature  string `json:"signature"`
+	KeyVersion int    `json:"key_version"`
+	SignedAt   string `json:"signed_at"`
+	// IssuedAt is SignedAt as the JWT NumericDate in whole seconds.
+	IssuedAt int64 `json:"iat"`
+}
+
+// JWSPayload returns the claims of the JWS of the transaction.
+func (t *Transaction) JWSPayload() JWSPayload {
+	return JWSPayload{
+		DeviceId:      t.DeviceId,
+		Counter:       t.Counter,
+		Data:          t.Data,
+		LastSignature: t.LastSignature,
+		Signature:     t.Signature,
+		KeyVersion:    t.KeyVersion,
+		SignedAt:      FormatSignedAt(t.SignedAt),
+		IssuedAt:      t.SignedAt.Unix(),
+	}
+}
+
+// COSEPayload holds the claims of the COSE_Sign1 message of a transaction. They
+// match the JWSPayload, but are keyed by small integers and carry the signatures
+// as byte strings to keep the message small.
+type COSEPayload struct {
+	DeviceId string `cbor:"1,keyasint"`
+	Counter  int    `cbor:"2,keyasint"`
+	Data     string `cbor:"3,keyasint"`
+	// LastSignature is the decoded last_signature, the device id for the first transaction.
+	LastSignature []byte `cbor:"4,keyasint"`
+	Signature     []byte `cbor:"5,keyasint"`
+	KeyVersion    int    `cbor:"6,keyasint"`
+	SignedAt      string `cbor:"7,keyasint"`
+}
+
+// COSEPayload returns the claims of the COSE_Sign1 message of the transaction.
+func (t *Transaction) COSEPayload() (COSEPayload, error) {
+	lastSignature, err := base64.StdEncoding.DecodeString(t.LastSignature)
+	if err != nil {
+		return COSEPayload{}, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(t.Signature)
+	if err != nil {
+		return COSEPayload{}, err
+	}
+	return COSEPayload{
+		DeviceId:      t.DeviceId,
+		Counter:       t.Counter,
+		Data:          t.Data,
+		LastSignature: lastSignature,
+		Signature:     signature,
+		KeyVersion:    t.KeyVersion,
+		SignedAt:      FormatSignedAt(t.SignedAt),
+	}, nil
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+const (
+	// MinRSAKeyBits and MaxRSAKeyBits bound the size of imported RSA keys. The
+	// minimum is the size of the keys generated by the service itself.
+	MinRSAKeyBits = crypto.RSAKeyBits
+	MaxRSAKeyBits = 4096
+)
+
+// ChainStart is where the signature chain of a device begins: the counter of its
+// first transaction and the signature that transaction links to.
+type ChainStart struct {
+	Counter       int    `json:"signature_counter"`
+	LastSignature string `json:"last_signature"`
+}
+
+// DefaultChainStart returns the start of the chain of a device created by this
+// service: its first transaction has counter 0 and links to the encoded device id.
+func DefaultChainStart(deviceId string) ChainStart {
+	return ChainStart{LastSignature: base64.StdEncoding.EncodeToString([]byte(deviceId))}
+}
+
+// ChainStart returns where the signature chain of the device begins.
+func (d *SignatureDevice) ChainStart() ChainStart {
+	if d.ImportedChain != nil {
+		return *d.ImportedChain
+	}
+	return DefaultChainStart(d.Id)
+}
+
+// DecodePrivateKey parses a PEM encoded private key of the given algorithm, in
+// PKCS #8 form or in the algorithm specific PKCS #1 or SEC 1 form. A key of another
+// algorithm is rejected with crypto.ErrKeyTypeMismatch.
+func DecodePrivateKey(algorithm SignatureAlgorithm, privateKey []byte) (crypto.KeyPair, error) {
+	var marshaler crypto.Marshaler
+	switch algorithm {
+	case RSA:
+		marshaler = crypto.NewRSAMarshaler()
+	case ECDSA:
+		marshaler = crypto.NewECCMarshaler()
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+	return marshaler.Unmarshal(privateKey)
+}
+
+// CheckKeyPolicy returns an error describing why a key pair may not be imported:
+// RSA keys must have MinRSAKeyBits to MaxRSAKeyBits, ECC keys must be on P-384,
+// the only curve all signature formats support with SHA-384.
+func CheckKeyPolicy(keyPair crypto.KeyPair) error {
+	switch key := keyPair.PublicKey().(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < MinRSAKeyBits || bits > MaxRSAKeyBits {
+			return fmt.Errorf("RSA keys must have %d to %d bits, got %d", MinRSAKeyBits, MaxRSAKeyBits, bits)
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P384() {
+			return fmt.Errorf("ECC keys must be on curve P-384, got %s", key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	return nil
+}
+
+// ImportSignatureDevice creates a device with an existing key pair, e.g. one
+// migrated from another signing service. A non-nil chain continues the signature
+// chain of the device, so its first transaction carries chain.Counter and links
+// to chain.LastSignature.
+func ImportSignatureDevice(algorithm SignatureAlgorithm, label string, keyPair crypto.KeyPair, chain *ChainStart) (*SignatureDevice, error) {
+	if algorithm != RSA && algorithm != ECDSA {
+		return nil, ErrInvalidAlgorithm
+	}
+	dev := &SignatureDevice{
+		SignatureAlgorithm: algorithm,
+		KeyPair:            keyPair,
+		Label:              label,
+		EnvelopeVersion:    DefaultEnvelopeVersion,
+		Status:             DeviceActive,
+		CreatedAt:          time.Now().UTC(),
+		ImportedChain:      chain,
+	}
+	if err := dev.addKeyPair(); err != nil {
+		return nil, err
+	}
+	if chain != nil {
+		dev.signatureCounter = chain.Counter
+	}
+	return dev, nil
+}
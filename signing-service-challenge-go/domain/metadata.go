@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// MetadataKind is the JSON type of a MetadataValue.
+type MetadataKind string
+
+const (
+	MetadataString  MetadataKind = "string"
+	MetadataNumber  MetadataKind = "number"
+	MetadataBoolean MetadataKind = "boolean"
+)
+
+// MetadataValue is a typed value attached to a transaction: a string, a number or
+// a boolean. Numbers keep their JSON representation, so large integers such as
+// order ids are stored without loss of precision.
+type MetadataValue struct {
+	kind  MetadataKind
+	value string
+}
+
+// StringValue returns a string metadata value.
+func StringValue(value string) MetadataValue {
+	return MetadataValue{kind: MetadataString, value: value}
+}
+
+// NumberValue returns a number metadata value. It panics if the value is not a valid JSON number.
+func NumberValue(value json.Number) MetadataValue {
+	if !validNumber(string(value)) {
+		panic("domain: invalid metadata number " + strconv.Quote(string(value)))
+	}
+	return MetadataValue{kind: MetadataNumber, value: string(value)}
+}
+
+// BooleanValue returns a boolean metadata value.
+func BooleanValue(value bool) MetadataValue {
+	return MetadataValue{kind: MetadataBoolean, value: strconv.FormatBool(value)}
+}
+
+// Valid reports whether the value is a string, a number or a boolean.
+func (v MetadataValue) Valid() bool {
+	return v.kind != ""
+}
+
+// Kind returns the type of the value.
+func (v MetadataValue) Kind() MetadataKind {
+	return v.kind
+}
+
+// String returns a string value, or the JSON representation of other kinds.
+func (v MetadataValue) String() string {
+	return v.value
+}
+
+// Interface returns the value as a string, a json.Number or a bool, or nil if it is invalid.
+func (v MetadataValue) Interface() interface{} {
+	switch v.kind {
+	case "":
+		return nil
+	case MetadataNumber:
+		return json.Number(v.value)
+	case MetadataBoolean:
+		return v.value == "true"
+	default:
+		return v.value
+	}
+}
+
+func (v MetadataValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Interface())
+}
+
+// UnmarshalJSON accepts JSON strings, numbers and booleans. Other JSON values
+// decode to an invalid value, so that callers can report them with their key.
+func (v *MetadataValue) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+	switch value := decoded.(type) {
+	case string:
+		*v = StringValue(value)
+	case json.Number:
+		*v = MetadataValue{kind: MetadataNumber, value: value.String()}
+	case bool:
+		*v = BooleanValue(value)
+	default:
+		*v = MetadataValue{}
+	}
+	return nil
+}
+
+// validNumber reports whether value is a JSON number. NaN and infinities are not.
+func validNumber(value string) bool {
+	if value == "" || (value[0] != '-' && (value[0] < '0' || value[0] > '9')) {
+		return false
+	}
+	return json.Valid([]byte(value))
+}
@@ -0,0 +1,294 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/grpcapi/signingpb"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var signatureAlgorithms = map[signingpb.SignatureAlgorithm]domain.SignatureAlgorithm{
+	signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_RSA: domain.RSA,
+	signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC: domain.ECDSA,
+}
+
+// envelopeVersions maps the unspecified version to "", which selects the default of the service.
+var envelopeVersions = map[signingpb.EnvelopeVersion]domain.EnvelopeVersion{
+	signingpb.EnvelopeVersion_ENVELOPE_VERSION_UNSPECIFIED: "",
+	signingpb.EnvelopeVersion_ENVELOPE_VERSION_LEGACY:      domain.EnvelopeLegacy,
+	signingpb.EnvelopeVersion_ENVELOPE_VERSION_V1:          domain.EnvelopeV1,
+	signingpb.EnvelopeVersion_ENVELOPE_VERSION_V2:          domain.EnvelopeV2,
+}
+
+var deviceStatuses = map[signingpb.DeviceStatus]domain.DeviceStatus{
+	signingpb.DeviceStatus_DEVICE_STATUS_UNSPECIFIED: "",
+	signingpb.DeviceStatus_DEVICE_STATUS_ACTIVE:      domain.DeviceActive,
+	signingpb.DeviceStatus_DEVICE_STATUS_DEACTIVATED: domain.DeviceDeactivated,
+}
+
+var deviceSorts = map[signingpb.DeviceSort]persistence.DeviceSort{
+	signingpb.DeviceSort_DEVICE_SORT_UNSPECIFIED:     "",
+	signingpb.DeviceSort_DEVICE_SORT_CREATED_AT_ASC:  persistence.SortCreatedAtAsc,
+	signingpb.DeviceSort_DEVICE_SORT_CREATED_AT_DESC: persistence.SortCreatedAtDesc,
+	signingpb.DeviceSort_DEVICE_SORT_LABEL_ASC:       persistence.SortLabelAsc,
+	signingpb.DeviceSort_DEVICE_SORT_LABEL_DESC:      persistence.SortLabelDesc,
+}
+
+// toDeviceQuery converts the filters and paging of a listing request.
+func toDeviceQuery(req *signingpb.ListSignatureDevicesRequest) (persistence.DeviceQuery, error) {
+	query := persistence.DeviceQuery{
+		Filter: persistence.DeviceFilter{
+			Label:    req.GetLabel(),
+			Metadata: req.GetMetadata(),
+		},
+		Cursor: req.GetPageToken(),
+		Limit:  int(req.GetPageSize()),
+	}
+	sortOrder, ok := deviceSorts[req.GetSort()]
+	if !ok {
+		return query, &service.ValidationError{
+			Message: "sort is not supported",
+			Fields:  []service.FieldError{{Field: "sort", Reason: "is not supported"}},
+		}
+	}
+	query.Sort = sortOrder
+	if req.GetSignatureAlgorithm() != signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_UNSPECIFIED {
+		algorithm, ok := signatureAlgorithms[req.GetSignatureAlgorithm()]
+		if !ok {
+			return query, domain.ErrInvalidAlgorithm
+		}
+		query.Filter.Algorithm = algorithm
+	}
+	query.Filter.Status = deviceStatuses[req.GetStatus()]
+	if req.GetCreatedFrom() != nil {
+		createdFrom := req.GetCreatedFrom().AsTime()
+		query.Filter.CreatedFrom = &createdFrom
+	}
+	if req.GetCreatedTo() != nil {
+		createdTo := req.GetCreatedTo().AsTime()
+		query.Filter.CreatedTo = &createdTo
+	}
+	return query, nil
+}
+
+var transactionSorts = map[signingpb.TransactionSort]persistence.TransactionSort{
+	signingpb.TransactionSort_TRANSACTION_SORT_UNSPECIFIED:    "",
+	signingpb.TransactionSort_TRANSACTION_SORT_SIGNED_AT_ASC:  persistence.SortSignedAtAsc,
+	signingpb.TransactionSort_TRANSACTION_SORT_SIGNED_AT_DESC: persistence.SortSignedAtDesc,
+}
+
+// toTransactionQuery converts the filters and paging of a search request. The
+// device filter is nil unless the request selects devices by label or metadata.
+func toTransactionQuery(req *signingpb.SearchTransactionsRequest) (persistence.TransactionQuery, *persistence.DeviceFilter, error) {
+	query := persistence.TransactionQuery{
+		Filter: persistence.TransactionFilter{
+			DeviceIds: req.GetDeviceIds(),
+			Reference: req.GetReference(),
+		},
+		Cursor: req.GetPageToken(),
+		Limit:  int(req.GetPageSize()),
+	}
+	sortOrder, ok := transactionSorts[req.GetSort()]
+	if !ok {
+		return query, nil, &service.ValidationError{
+			Message: "sort is not supported",
+			Fields:  []service.FieldError{{Field: "sort", Reason: "is not supported"}},
+		}
+	}
+	query.Sort = sortOrder
+	if req.GetSignedFrom() != nil {
+		signedFrom := req.GetSignedFrom().AsTime()
+		query.Filter.SignedFrom = &signedFrom
+	}
+	if req.GetSignedTo() != nil {
+		signedTo := req.GetSignedTo().AsTime()
+		query.Filter.SignedTo = &signedTo
+	}
+	if req.CounterFrom != nil {
+		counterFrom := int(req.GetCounterFrom())
+		query.Filter.CounterFrom = &counterFrom
+	}
+	if req.CounterTo != nil {
+		counterTo := int(req.GetCounterTo())
+		query.Filter.CounterTo = &counterTo
+	}
+
+	var devices *persistence.DeviceFilter
+	if req.GetDeviceLabel() != "" || len(req.GetDeviceMetadata()) > 0 {
+		devices = &persistence.DeviceFilter{
+			Label:    req.GetDeviceLabel(),
+			Metadata: req.GetDeviceMetadata(),
+		}
+	}
+	return query, devices, nil
+}
+
+func toSignatureAlgorithm(algorithm domain.SignatureAlgorithm) signingpb.SignatureAlgorithm {
+	switch algorithm {
+	case domain.RSA:
+		return signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_RSA
+	case domain.ECDSA:
+		return signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC
+	default:
+		return signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_UNSPECIFIED
+	}
+}
+
+func toEnvelopeVersion(version domain.EnvelopeVersion) signingpb.EnvelopeVersion {
+	switch version {
+	case domain.EnvelopeLegacy:
+		return signingpb.EnvelopeVersion_ENVELOPE_VERSION_LEGACY
+	case domain.EnvelopeV1:
+		return signingpb.EnvelopeVersion_ENVELOPE_VERSION_V1
+	case domain.EnvelopeV2:
+		return signingpb.EnvelopeVersion_ENVELOPE_VERSION_V2
+	default:
+		return signingpb.EnvelopeVersion_ENVELOPE_VERSION_UNSPECIFIED
+	}
+}
+
+func toDevice(signDevice *domain.SignatureDevice) *signingpb.SignatureDevice {
+	signDevice = signDevice.Snapshot()
+	device := &signingpb.SignatureDevice{
+		Id:                 signDevice.Id,
+		SignatureAlgorithm: toSignatureAlgorithm(signDevice.SignatureAlgorithm),
+		Label:              signDevice.Label,
+		PublicKey:          signDevice.PublicKey,
+		KeyVersion:         int32(signDevice.KeyVersion),
+		EnvelopeVersion:    toEnvelopeVersion(signDevice.EnvelopeVersion),
+		Status:             signingpb.DeviceStatus_DEVICE_STATUS_ACTIVE,
+		SignatureCounter:   int64(signDevice.Counter()),
+		CreatedAt:          timestamppb.New(signDevice.CreatedAt),
+		Metadata:           signDevice.Metadata,
+	}
+	if !signDevice.Active() {
+		device.Status = signingpb.DeviceStatus_DEVICE_STATUS_DEACTIVATED
+	}
+	if signDevice.DeactivatedAt != nil {
+		device.DeactivatedAt = timestamppb.New(*signDevice.DeactivatedAt)
+	}
+	for _, publicKey := range signDevice.PublicKeys {
+		device.PublicKeys = append(device.PublicKeys, &signingpb.DevicePublicKey{
+			Version:   int32(publicKey.Version),
+			PublicKey: publicKey.PublicKey,
+			CreatedAt: timestamppb.New(publicKey.CreatedAt),
+		})
+	}
+	return device
+}
+
+// toMetadata converts transaction metadata. Only string, number and bool values are accepted.
+func toMetadata(values map[string]*structpb.Value) (map[string]domain.MetadataValue, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	metadata := make(map[string]domain.MetadataValue, len(values))
+	for key, value := range values {
+		switch kind := value.GetKind().(type) {
+		case *structpb.Value_StringValue:
+			metadata[key] = domain.StringValue(kind.StringValue)
+		case *structpb.Value_NumberValue:
+			if math.IsNaN(kind.NumberValue) || math.IsInf(kind.NumberValue, 0) {
+				return nil, &service.ValidationError{
+					Message: "metadata." + key + " must be a finite number",
+					Fields:  []service.FieldError{{Field: "metadata." + key, Reason: "must be a finite number"}},
+				}
+			}
+			metadata[key] = domain.NumberValue(json.Number(strconv.FormatFloat(kind.NumberValue, 'g', -1, 64)))
+		case *structpb.Value_BoolValue:
+			metadata[key] = domain.BooleanValue(kind.BoolValue)
+		default:
+			return nil, &service.ValidationError{
+				Message: "metadata." + key + " must be a string, number or boolean",
+				Fields:  []service.FieldError{{Field: "metadata." + key, Reason: "must be a string, number or boolean"}},
+			}
+		}
+	}
+	return metadata, nil
+}
+
+// fromMetadata converts transaction metadata. Numbers beyond the precision of a double are rounded.
+func fromMetadata(metadata map[string]domain.MetadataValue) map[string]*structpb.Value {
+	if len(metadata) == 0 {
+		return nil
+	}
+	values := make(map[string]*structpb.Value, len(metadata))
+	for key, value := range metadata {
+		switch value.Kind() {
+		case domain.MetadataNumber:
+			number, _ := strconv.ParseFloat(value.String(), 64)
+			values[key] = structpb.NewNumberValue(number)
+		case domain.MetadataBoolean:
+			values[key] = structpb.NewBoolValue(value.Interface().(bool))
+		default:
+			values[key] = structpb.NewStringValue(value.String())
+		}
+	}
+	return values
+}
+
+func toTransaction(transaction *domain.Transaction) *signingpb.Transaction {
+	return &signingpb.Transaction{
+		DeviceId:                transaction.DeviceId,
+		DataToBeSigned:          transaction.Data,
+		SignatureCounter:        int64(transaction.Counter),
+		LastSignature:           transaction.LastSignature,
+		Signature:               transaction.Signature,
+		EnvelopeVersion:         toEnvelopeVersion(transaction.EnvelopeVersion),
+		KeyVersion:              int32(transaction.KeyVersion),
+		SignedAt:                timestamppb.New(transaction.SignedAt),
+		Reference:               transaction.Reference,
+		Metadata:                fromMetadata(transaction.Metadata),
+		FiscalTransactionNumber: int64(transaction.FiscalTransactionNumber),
+		FiscalOperation:         fiscalOperations[transaction.FiscalOperation],
+		ClientId:                transaction.ClientId,
+		TimestampToken:          transaction.TimestampToken,
+	}
+}
+
+var fiscalOperations = map[domain.FiscalOperation]signingpb.FiscalOperation{
+	domain.FiscalStart:  signingpb.FiscalOperation_FISCAL_OPERATION_START,
+	domain.FiscalUpdate: signingpb.FiscalOperation_FISCAL_OPERATION_UPDATE,
+	domain.FiscalFinish: signingpb.FiscalOperation_FISCAL_OPERATION_FINISH,
+}
+
+func toFiscalTransaction(fiscalTransaction *domain.FiscalTransaction) *signingpb.FiscalTransaction {
+	converted := &signingpb.FiscalTransaction{
+		DeviceId:    fiscalTransaction.DeviceId,
+		Number:      int64(fiscalTransaction.Number),
+		State:       signingpb.FiscalState_FISCAL_STATE_ACTIVE,
+		ProcessType: fiscalTransaction.ProcessType,
+		ProcessData: fiscalTransaction.ProcessData,
+		StartedAt:   timestamppb.New(fiscalTransaction.StartedAt),
+		ClientId:    fiscalTransaction.ClientId,
+	}
+	if !fiscalTransaction.Active() {
+		converted.State = signingpb.FiscalState_FISCAL_STATE_FINISHED
+	}
+	if fiscalTransaction.FinishedAt != nil {
+		converted.FinishedAt = timestamppb.New(*fiscalTransaction.FinishedAt)
+	}
+	for _, entry := range fiscalTransaction.Log {
+		converted.Log = append(converted.Log, &signingpb.FiscalLogEntry{
+			Operation:        fiscalOperations[entry.Operation],
+			SignatureCounter: int64(entry.SignatureCounter),
+			SignedAt:         timestamppb.New(entry.SignedAt),
+		})
+	}
+	return converted
+}
+
+func toClient(client *domain.Client) *signingpb.Client {
+	return &signingpb.Client{
+		Id:           client.Id,
+		SerialNumber: client.SerialNumber,
+		DeviceIds:    client.DeviceIds,
+		CreatedAt:    timestamppb.New(client.CreatedAt),
+	}
+}
@@ -0,0 +1,360 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/grpcapi/signingpb"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server exposes the signing service over gRPC.
+type Server struct {
+	signingpb.UnimplementedSigningServiceServer
+
+	listenAddress  string
+	signingService *service.SigningService
+}
+
+// NewServer is a factory to instantiate a new Server.
+func NewServer(listenAddress string, signingService *service.SigningService) *Server {
+	return &Server{
+		listenAddress:  listenAddress,
+		signingService: signingService,
+	}
+}
+
+// Run starts the Server on its listen address.
+func (s *Server) Run() error {
+	listener, err := net.Listen("tcp", s.listenAddress)
+	if err != nil {
+		return err
+	}
+	return s.GRPCServer().Serve(listener)
+}
+
+// GRPCServer returns a gRPC server with the signing service registered.
+func (s *Server) GRPCServer() *grpc.Server {
+	grpcServer := grpc.NewServer()
+	signingpb.RegisterSigningServiceServer(grpcServer, s)
+	return grpcServer
+}
+
+func (s *Server) CreateSignatureDevice(ctx context.Context, req *signingpb.CreateSignatureDeviceRequest) (*signingpb.CreateSignatureDeviceResponse, error) {
+	algorithm, ok := signatureAlgorithms[req.GetSignatureAlgorithm()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "signature_algorithm must be RSA or ECC")
+	}
+	signDevice, err := s.signingService.CreateDevice(algorithm, req.GetLabel(), envelopeVersions[req.GetEnvelopeVersion()], req.GetMetadata())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.CreateSignatureDeviceResponse{Device: toDevice(signDevice)}, nil
+}
+
+func (s *Server) GetSignatureDevice(ctx context.Context, req *signingpb.GetSignatureDeviceRequest) (*signingpb.GetSignatureDeviceResponse, error) {
+	signDevice, err := s.signingService.GetDevice(req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.GetSignatureDeviceResponse{Device: toDevice(signDevice)}, nil
+}
+
+func (s *Server) ListSignatureDevices(ctx context.Context, req *signingpb.ListSignatureDevicesRequest) (*signingpb.ListSignatureDevicesResponse, error) {
+	query, err := toDeviceQuery(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	page, err := s.signingService.ListDevices(query)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.ListSignatureDevicesResponse{
+		Devices:       make([]*signingpb.SignatureDevice, 0, len(page.Devices)),
+		NextPageToken: page.NextCursor,
+	}
+	for _, signDevice := range page.Devices {
+		resp.Devices = append(resp.Devices, toDevice(signDevice))
+	}
+	return resp, nil
+}
+
+func (s *Server) SignTransaction(ctx context.Context, req *signingpb.SignTransactionRequest) (*signingpb.SignTransactionResponse, error) {
+	metadata, err := toMetadata(req.GetMetadata())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	signature, err := s.signingService.SignTransaction(req.GetDeviceId(), req.GetClientId(), req.GetDataToBeSigned(), service.SignOptions{
+		Reference:       req.GetReference(),
+		UniqueReference: req.GetUniqueReference(),
+		Metadata:        metadata,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.SignTransactionResponse{
+		Transaction: toTransaction(signature.Signature),
+		SignedData:  signature.SignedData,
+	}, nil
+}
+
+func (s *Server) SignTransactionBatch(req *signingpb.SignTransactionBatchRequest, stream signingpb.SigningService_SignTransactionBatchServer) error {
+	if len(req.GetDataToBeSigned()) == 0 {
+		return status.Error(codes.InvalidArgument, "data_to_be_signed must not be empty")
+	}
+	for i, data := range req.GetDataToBeSigned() {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		signature, err := s.signingService.SignTransaction(req.GetDeviceId(), req.GetClientId(), data, service.SignOptions{})
+		if err != nil {
+			return statusError(err)
+		}
+		err = stream.Send(&signingpb.SignTransactionBatchResponse{
+			Index:       int32(i),
+			Transaction: toTransaction(signature.Signature),
+			SignedData:  signature.SignedData,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *signingpb.ListTransactionsRequest) (*signingpb.ListTransactionsResponse, error) {
+	transactions, err := s.signingService.ListTransactions(req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.ListTransactionsResponse{
+		Transactions: make([]*signingpb.Transaction, 0, len(transactions)),
+	}
+	for _, transaction := range transactions {
+		resp.Transactions = append(resp.Transactions, toTransaction(transaction))
+	}
+	return resp, nil
+}
+
+func (s *Server) SearchTransactions(ctx context.Context, req *signingpb.SearchTransactionsRequest) (*signingpb.SearchTransactionsResponse, error) {
+	query, devices, err := toTransactionQuery(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	page, err := s.signingService.SearchTransactions(query, devices)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.SearchTransactionsResponse{
+		Transactions:  make([]*signingpb.Transaction, 0, len(page.Transactions)),
+		NextPageToken: page.NextCursor,
+	}
+	for _, transaction := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, toTransaction(transaction))
+	}
+	return resp, nil
+}
+
+func (s *Server) VerifySignature(ctx context.Context, req *signingpb.VerifySignatureRequest) (*signingpb.VerifySignatureResponse, error) {
+	var keyVersion *int
+	if req.KeyVersion != nil {
+		version := int(req.GetKeyVersion())
+		keyVersion = &version
+	}
+	result, err := s.signingService.VerifySignature(req.GetDeviceId(), req.GetSignedData(), req.GetSignature(), req.GetTimestampToken(), keyVersion)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.VerifySignatureResponse{
+		Valid:      result.Valid,
+		KeyVersion: int32(result.KeyVersion),
+	}
+	if result.TimestampedAt != nil {
+		resp.TimestampedAt = timestamppb.New(*result.TimestampedAt)
+	}
+	return resp, nil
+}
+
+func (s *Server) StartFiscalTransaction(ctx context.Context, req *signingpb.StartFiscalTransactionRequest) (*signingpb.StartFiscalTransactionResponse, error) {
+	resp, err := s.signingService.StartFiscalTransaction(req.GetDeviceId(), req.GetClientId(), service.FiscalProcess{
+		ProcessType: req.GetProcessType(),
+		ProcessData: req.GetProcessData(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.StartFiscalTransactionResponse{
+		FiscalTransaction: toFiscalTransaction(resp.FiscalTransaction),
+		Transaction:       toTransaction(resp.Signature),
+		SignedData:        resp.SignedData,
+	}, nil
+}
+
+func (s *Server) UpdateFiscalTransaction(ctx context.Context, req *signingpb.UpdateFiscalTransactionRequest) (*signingpb.UpdateFiscalTransactionResponse, error) {
+	resp, err := s.signingService.UpdateFiscalTransaction(req.GetDeviceId(), int(req.GetNumber()), req.GetClientId(), service.FiscalProcess{
+		ProcessType: req.GetProcessType(),
+		ProcessData: req.GetProcessData(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.UpdateFiscalTransactionResponse{
+		FiscalTransaction: toFiscalTransaction(resp.FiscalTransaction),
+		Transaction:       toTransaction(resp.Signature),
+		SignedData:        resp.SignedData,
+	}, nil
+}
+
+func (s *Server) FinishFiscalTransaction(ctx context.Context, req *signingpb.FinishFiscalTransactionRequest) (*signingpb.FinishFiscalTransactionResponse, error) {
+	resp, err := s.signingService.FinishFiscalTransaction(req.GetDeviceId(), int(req.GetNumber()), req.GetClientId(), service.FiscalProcess{
+		ProcessType: req.GetProcessType(),
+		ProcessData: req.GetProcessData(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.FinishFiscalTransactionResponse{
+		FiscalTransaction: toFiscalTransaction(resp.FiscalTransaction),
+		Transaction:       toTransaction(resp.Signature),
+		SignedData:        resp.SignedData,
+	}, nil
+}
+
+func (s *Server) GetFiscalTransaction(ctx context.Context, req *signingpb.GetFiscalTransactionRequest) (*signingpb.GetFiscalTransactionResponse, error) {
+	fiscalTransaction, err := s.signingService.GetFiscalTransaction(req.GetDeviceId(), int(req.GetNumber()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.GetFiscalTransactionResponse{FiscalTransaction: toFiscalTransaction(fiscalTransaction)}, nil
+}
+
+func (s *Server) ListFiscalTransactions(ctx context.Context, req *signingpb.ListFiscalTransactionsRequest) (*signingpb.ListFiscalTransactionsResponse, error) {
+	fiscalTransactions, err := s.signingService.ListFiscalTransactions(req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.ListFiscalTransactionsResponse{
+		FiscalTransactions: make([]*signingpb.FiscalTransaction, 0, len(fiscalTransactions)),
+	}
+	for _, fiscalTransaction := range fiscalTransactions {
+		resp.FiscalTransactions = append(resp.FiscalTransactions, toFiscalTransaction(fiscalTransaction))
+	}
+	return resp, nil
+}
+
+func (s *Server) RegisterClient(ctx context.Context, req *signingpb.RegisterClientRequest) (*signingpb.RegisterClientResponse, error) {
+	client, err := s.signingService.RegisterClient(req.GetSerialNumber(), req.GetDeviceIds())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.RegisterClientResponse{Client: toClient(client)}, nil
+}
+
+func (s *Server) GetClient(ctx context.Context, req *signingpb.GetClientRequest) (*signingpb.GetClientResponse, error) {
+	client, err := s.signingService.GetClient(req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.GetClientResponse{Client: toClient(client)}, nil
+}
+
+func (s *Server) ListClients(ctx context.Context, req *signingpb.ListClientsRequest) (*signingpb.ListClientsResponse, error) {
+	clients, err := s.signingService.ListClients(req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &signingpb.ListClientsResponse{
+		Clients: make([]*signingpb.Client, 0, len(clients)),
+	}
+	for _, client := range clients {
+		resp.Clients = append(resp.Clients, toClient(client))
+	}
+	return resp, nil
+}
+
+func (s *Server) RegisterClientDevice(ctx context.Context, req *signingpb.RegisterClientDeviceRequest) (*signingpb.RegisterClientDeviceResponse, error) {
+	client, err := s.signingService.RegisterClientDevice(req.GetClientId(), req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.RegisterClientDeviceResponse{Client: toClient(client)}, nil
+}
+
+func (s *Server) DeregisterClientDevice(ctx context.Context, req *signingpb.DeregisterClientDeviceRequest) (*signingpb.DeregisterClientDeviceResponse, error) {
+	client, err := s.signingService.DeregisterClientDevice(req.GetClientId(), req.GetDeviceId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &signingpb.DeregisterClientDeviceResponse{Client: toClient(client)}, nil
+}
+
+// statusError maps an error of the signing service to a gRPC status.
+// Field errors are attached as google.rpc.BadRequest details.
+func statusError(err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		st := status.New(codes.InvalidArgument, validationErr.Message)
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range validationErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Reason,
+			})
+		}
+		if detailed, detailsErr := st.WithDetails(badRequest); detailsErr == nil {
+			st = detailed
+		}
+		return st.Err()
+	case errors.Is(err, domain.ErrInvalidAlgorithm):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrDeviceNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrDeviceDeactivated):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrCounterConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, service.ErrClockBehind):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, service.ErrTimestampUnavailable):
+		return status.Error(codes.Unavailable, service.ErrTimestampUnavailable.Error())
+	case errors.Is(err, service.ErrDuplicateReference):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrClientNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrClientNotRegistered):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrDuplicateSerialNumber):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrFiscalTransactionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrFiscalTransactionFinished):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrTransactionNotFound),
+		errors.Is(err, service.ErrCheckpointNotFound),
+		errors.Is(err, service.ErrCertificateNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrTransactionNotAnchored),
+		errors.Is(err, service.ErrCheckpointsDisabled),
+		errors.Is(err, service.ErrCertificateAuthorityDisabled),
+		errors.Is(err, ca.ErrAuthorityExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrCertificateKeyMismatch),
+		errors.Is(err, crypto.ErrMalformedKey),
+		errors.Is(err, crypto.ErrKeyTypeMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		log.Printf("gRPC request failed: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,554 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/grpcapi/signingpb"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestClient serves a Server on an in-memory listener and returns a client connected to it.
+func newTestClient(t *testing.T, options ...service.Option) signingpb.SigningServiceClient {
+	signingService := service.NewSigningService(persistence.NewInMemoryDeviceStore(), persistence.NewInMemoryTransactionStore(), persistence.NewInMemoryFiscalTransactionStore(), persistence.NewInMemoryClientStore(), persistence.NewInMemoryLogStore(), options...)
+	grpcServer := NewServer("", signingService).GRPCServer()
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return signingpb.NewSigningServiceClient(conn)
+}
+
+func createTestDevice(t *testing.T, client signingpb.SigningServiceClient) *signingpb.SignatureDevice {
+	resp, err := client.CreateSignatureDevice(context.Background(), &signingpb.CreateSignatureDeviceRequest{
+		SignatureAlgorithm: signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC,
+		Label:              "register",
+	})
+	if err != nil {
+		t.Fatalf("Could not create device: %v", err)
+	}
+	return resp.GetDevice()
+}
+
+func registerTestClient(t *testing.T, client signingpb.SigningServiceClient, deviceId string) string {
+	resp, err := client.RegisterClient(context.Background(), &signingpb.RegisterClientRequest{
+		SerialNumber: "KASSE-" + deviceId,
+		DeviceIds:    []string{deviceId},
+	})
+	if err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	return resp.GetClient().GetId()
+}
+
+func Test_GRPC_CreateAndGetDevice(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	device := createTestDevice(t, client)
+	assert.NotEmpty(t, device.GetId())
+	assert.Equal(t, signingpb.EnvelopeVersion_ENVELOPE_VERSION_V2, device.GetEnvelopeVersion())
+	assert.Equal(t, signingpb.DeviceStatus_DEVICE_STATUS_ACTIVE, device.GetStatus())
+	assert.Len(t, device.GetPublicKeys(), 1)
+
+	fetched, err := client.GetSignatureDevice(ctx, &signingpb.GetSignatureDeviceRequest{DeviceId: device.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "register", fetched.GetDevice().GetLabel())
+
+	list, err := client.ListSignatureDevices(ctx, &signingpb.ListSignatureDevicesRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.GetDevices(), 1)
+}
+
+func Test_GRPC_ListSignatureDevices_Pages(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	for _, label := range []string{"kasse 2", "kasse 1", "backoffice"} {
+		_, err := client.CreateSignatureDevice(ctx, &signingpb.CreateSignatureDeviceRequest{
+			SignatureAlgorithm: signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC,
+			Label:              label,
+			Metadata:           map[string]string{"store": "berlin"},
+		})
+		if err != nil {
+			t.Fatalf("Could not create device: %v", err)
+		}
+	}
+
+	req := &signingpb.ListSignatureDevicesRequest{
+		PageSize: 1,
+		Sort:     signingpb.DeviceSort_DEVICE_SORT_LABEL_ASC,
+		Label:    "KASSE",
+		Metadata: map[string]string{"store": "berlin"},
+	}
+	first, err := client.ListSignatureDevices(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "kasse 1", first.GetDevices()[0].GetLabel())
+	assert.Equal(t, map[string]string{"store": "berlin"}, first.GetDevices()[0].GetMetadata())
+	assert.NotNil(t, first.GetDevices()[0].GetCreatedAt())
+
+	req.PageToken = first.GetNextPageToken()
+	second, err := client.ListSignatureDevices(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "kasse 2", second.GetDevices()[0].GetLabel())
+	assert.Empty(t, second.GetNextPageToken())
+
+	_, err = client.ListSignatureDevices(ctx, &signingpb.ListSignatureDevicesRequest{PageToken: "garbage"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_GRPC_CreateDevice_InvalidAlgorithm(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.CreateSignatureDevice(context.Background(), &signingpb.CreateSignatureDeviceRequest{})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_GRPC_SignAndVerify(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+	clientId := registerTestClient(t, client, device.GetId())
+
+	signed, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{
+		DeviceId:       device.GetId(),
+		ClientId:       clientId,
+		DataToBeSigned: "payload",
+	})
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+	assert.Equal(t, int64(0), signed.GetTransaction().GetSignatureCounter())
+	assert.Equal(t, clientId, signed.GetTransaction().GetClientId())
+
+	verified, err := client.VerifySignature(ctx, &signingpb.VerifySignatureRequest{
+		DeviceId:   device.GetId(),
+		SignedData: signed.GetSignedData(),
+		Signature:  signed.GetTransaction().GetSignature(),
+	})
+	assert.NoError(t, err)
+	assert.True(t, verified.GetValid())
+	assert.Equal(t, int32(0), verified.GetKeyVersion())
+
+	verified, err = client.VerifySignature(ctx, &signingpb.VerifySignatureRequest{
+		DeviceId:   device.GetId(),
+		SignedData: signed.GetSignedData() + "x",
+		Signature:  signed.GetTransaction().GetSignature(),
+	})
+	assert.NoError(t, err)
+	assert.False(t, verified.GetValid())
+}
+
+func Test_GRPC_TimestampedSignature(t *testing.T) {
+	authority, err := tsa.NewLocalAuthority()
+	if err != nil {
+		t.Fatalf("Could not create timestamp authority: %v", err)
+	}
+	client := newTestClient(t, service.WithTimestampAuthority(authority, authority.Roots()))
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+	clientId := registerTestClient(t, client, device.GetId())
+
+	signed, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{
+		DeviceId:       device.GetId(),
+		ClientId:       clientId,
+		DataToBeSigned: "payload",
+	})
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+	assert.NotEmpty(t, signed.GetTransaction().GetTimestampToken())
+
+	verified, err := client.VerifySignature(ctx, &signingpb.VerifySignatureRequest{
+		DeviceId:       device.GetId(),
+		SignedData:     signed.GetSignedData(),
+		Signature:      signed.GetTransaction().GetSignature(),
+		TimestampToken: signed.GetTransaction().GetTimestampToken(),
+	})
+	assert.NoError(t, err)
+	assert.True(t, verified.GetValid())
+	assert.NotNil(t, verified.GetTimestampedAt())
+
+	_, err = client.VerifySignature(ctx, &signingpb.VerifySignatureRequest{
+		DeviceId:       device.GetId(),
+		SignedData:     signed.GetSignedData(),
+		Signature:      signed.GetTransaction().GetSignature(),
+		TimestampToken: "not base64",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+func Test_GRPC_SignTransaction_Errors(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: "unknown", ClientId: "client", DataToBeSigned: "payload"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: "unknown"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	device := createTestDevice(t, client)
+	_, err = client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: device.GetId(), ClientId: "unknown", DataToBeSigned: "payload"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	other := createTestDevice(t, client)
+	clientId := registerTestClient(t, client, other.GetId())
+	_, err = client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: device.GetId(), ClientId: clientId, DataToBeSigned: "payload"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func Test_GRPC_Clients(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	first := createTestDevice(t, client)
+	second := createTestDevice(t, client)
+
+	registered, err := client.RegisterClient(ctx, &signingpb.RegisterClientRequest{SerialNumber: "KASSE-1", DeviceIds: []string{first.GetId()}})
+	if err != nil {
+		t.Fatalf("Could not register client: %v", err)
+	}
+	clientId := registered.GetClient().GetId()
+	assert.NotNil(t, registered.GetClient().GetCreatedAt())
+
+	_, err = client.RegisterClient(ctx, &signingpb.RegisterClientRequest{SerialNumber: "KASSE-1", DeviceIds: []string{second.GetId()}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	changed, err := client.RegisterClientDevice(ctx, &signingpb.RegisterClientDeviceRequest{ClientId: clientId, DeviceId: second.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first.GetId(), second.GetId()}, changed.GetClient().GetDeviceIds())
+
+	_, err = client.DeregisterClientDevice(ctx, &signingpb.DeregisterClientDeviceRequest{ClientId: clientId, DeviceId: first.GetId()})
+	assert.NoError(t, err)
+	_, err = client.DeregisterClientDevice(ctx, &signingpb.DeregisterClientDeviceRequest{ClientId: clientId, DeviceId: first.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	fetched, err := client.GetClient(ctx, &signingpb.GetClientRequest{Id: clientId})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{second.GetId()}, fetched.GetClient().GetDeviceIds())
+	_, err = client.GetClient(ctx, &signingpb.GetClientRequest{Id: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.ListClients(ctx, &signingpb.ListClientsRequest{DeviceId: first.GetId()})
+	assert.NoError(t, err)
+	assert.Empty(t, list.GetClients())
+	list, err = client.ListClients(ctx, &signingpb.ListClientsRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.GetClients(), 1)
+}
+
+func Test_GRPC_SignTransaction_ReferenceAndMetadata(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+
+	clientId := registerTestClient(t, client, device.GetId())
+
+	req := &signingpb.SignTransactionRequest{
+		DeviceId:        device.GetId(),
+		ClientId:        clientId,
+		DataToBeSigned:  "receipt",
+		Reference:       "order-1",
+		UniqueReference: true,
+		Metadata: map[string]*structpb.Value{
+			"table": structpb.NewNumberValue(12),
+			"paid":  structpb.NewBoolValue(true),
+			"clerk": structpb.NewStringValue("anna"),
+		},
+	}
+	signed, err := client.SignTransaction(ctx, req)
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+	transaction := signed.GetTransaction()
+	assert.Equal(t, "order-1", transaction.GetReference())
+	assert.Equal(t, float64(12), transaction.GetMetadata()["table"].GetNumberValue())
+	assert.True(t, transaction.GetMetadata()["paid"].GetBoolValue())
+	assert.Equal(t, "anna", transaction.GetMetadata()["clerk"].GetStringValue())
+
+	_, err = client.SignTransaction(ctx, req)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.SignTransaction(ctx, &signingpb.SignTransactionRequest{
+		DeviceId:       device.GetId(),
+		ClientId:       clientId,
+		DataToBeSigned: "receipt",
+		Metadata:       map[string]*structpb.Value{"items": structpb.NewListValue(&structpb.ListValue{})},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_GRPC_FiscalTransaction(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+
+	clientId := registerTestClient(t, client, device.GetId())
+
+	started, err := client.StartFiscalTransaction(ctx, &signingpb.StartFiscalTransactionRequest{
+		DeviceId:    device.GetId(),
+		ClientId:    clientId,
+		ProcessType: "Kassenbeleg-V1",
+	})
+	if err != nil {
+		t.Fatalf("Could not start: %v", err)
+	}
+	number := started.GetFiscalTransaction().GetNumber()
+	assert.Equal(t, int64(1), number)
+	assert.Equal(t, signingpb.FiscalOperation_FISCAL_OPERATION_START, started.GetTransaction().GetFiscalOperation())
+	assert.Equal(t, clientId, started.GetFiscalTransaction().GetClientId())
+
+	finished, err := client.FinishFiscalTransaction(ctx, &signingpb.FinishFiscalTransactionRequest{
+		DeviceId:    device.GetId(),
+		Number:      number,
+		ProcessData: "Beleg^7.50:Bar",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, signingpb.FiscalState_FISCAL_STATE_FINISHED, finished.GetFiscalTransaction().GetState())
+	assert.Equal(t, "Kassenbeleg-V1", finished.GetFiscalTransaction().GetProcessType())
+	assert.Len(t, finished.GetFiscalTransaction().GetLog(), 2)
+	assert.Equal(t, started.GetTransaction().GetSignature(), finished.GetTransaction().GetLastSignature())
+
+	_, err = client.UpdateFiscalTransaction(ctx, &signingpb.UpdateFiscalTransactionRequest{DeviceId: device.GetId(), Number: number})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.GetFiscalTransaction(ctx, &signingpb.GetFiscalTransactionRequest{DeviceId: device.GetId(), Number: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.ListFiscalTransactions(ctx, &signingpb.ListFiscalTransactionsRequest{DeviceId: device.GetId()})
+	assert.NoError(t, err)
+	assert.Len(t, list.GetFiscalTransactions(), 1)
+}
+
+func Test_GRPC_SearchTransactions(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+	clientId := registerTestClient(t, client, device.GetId())
+	for _, reference := range []string{"R-1", "R-2", "R-3"} {
+		_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{
+			DeviceId:       device.GetId(),
+			ClientId:       clientId,
+			DataToBeSigned: "receipt",
+			Reference:      reference,
+		})
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+	}
+
+	counterFrom := int64(1)
+	resp, err := client.SearchTransactions(ctx, &signingpb.SearchTransactionsRequest{
+		DeviceLabel: "REGISTER",
+		CounterFrom: &counterFrom,
+		Sort:        signingpb.TransactionSort_TRANSACTION_SORT_SIGNED_AT_DESC,
+		PageSize:    1,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetTransactions(), 1) {
+		assert.Equal(t, "R-3", resp.GetTransactions()[0].GetReference())
+	}
+	assert.NotEmpty(t, resp.GetNextPageToken())
+
+	resp, err = client.SearchTransactions(ctx, &signingpb.SearchTransactionsRequest{Reference: "R-2"})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetTransactions(), 1)
+
+	resp, err = client.SearchTransactions(ctx, &signingpb.SearchTransactionsRequest{DeviceLabel: "backoffice"})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.GetTransactions())
+
+	counterTo := int64(0)
+	_, err = client.SearchTransactions(ctx, &signingpb.SearchTransactionsRequest{CounterFrom: &counterFrom, CounterTo: &counterTo})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_GRPC_SignTransactionBatch(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+
+	stream, err := client.SignTransactionBatch(ctx, &signingpb.SignTransactionBatchRequest{
+		DeviceId:       device.GetId(),
+		ClientId:       registerTestClient(t, client, device.GetId()),
+		DataToBeSigned: []string{"first", "second", "third"},
+	})
+	if err != nil {
+		t.Fatalf("Could not start batch: %v", err)
+	}
+	var signed []*signingpb.SignTransactionBatchResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Could not receive: %v", err)
+		}
+		signed = append(signed, resp)
+	}
+
+	if assert.Len(t, signed, 3) {
+		for i, resp := range signed {
+			assert.Equal(t, int32(i), resp.GetIndex())
+			assert.Equal(t, int64(i), resp.GetTransaction().GetSignatureCounter())
+		}
+		// every transaction is chained to its predecessor
+		assert.Equal(t, signed[0].GetTransaction().GetSignature(), signed[1].GetTransaction().GetLastSignature())
+		assert.Equal(t, signed[1].GetTransaction().GetSignature(), signed[2].GetTransaction().GetLastSignature())
+	}
+
+	transactions, err := client.ListTransactions(ctx, &signingpb.ListTransactionsRequest{DeviceId: device.GetId()})
+	assert.NoError(t, err)
+	assert.Len(t, transactions.GetTransactions(), 3)
+
+	fetched, err := client.GetSignatureDevice(ctx, &signingpb.GetSignatureDeviceRequest{DeviceId: device.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), fetched.GetDevice().GetSignatureCounter())
+}
+
+func Test_GRPC_SignTransactionBatch_UnknownDevice(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.SignTransactionBatch(context.Background(), &signingpb.SignTransactionBatchRequest{
+		DeviceId:       "unknown",
+		ClientId:       "client",
+		DataToBeSigned: []string{"first"},
+	})
+	if err != nil {
+		t.Fatalf("Could not start batch: %v", err)
+	}
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func Test_GRPC_InvalidInput(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	device := createTestDevice(t, client)
+	clientId := registerTestClient(t, client, device.GetId())
+	cases := []struct {
+		name  string
+		call  func() error
+		field string
+	}{
+		{"label too long", func() error {
+			_, err := client.CreateSignatureDevice(ctx, &signingpb.CreateSignatureDeviceRequest{SignatureAlgorithm: signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC, Label: strings.Repeat("a", service.MaxLabelLength+1)})
+			return err
+		}, "label"},
+		{"label charset", func() error {
+			_, err := client.CreateSignatureDevice(ctx, &signingpb.CreateSignatureDeviceRequest{SignatureAlgorithm: signingpb.SignatureAlgorithm_SIGNATURE_ALGORITHM_ECC, Label: "<script>"})
+			return err
+		}, "label"},
+		{"malformed device id", func() error {
+			_, err := client.GetSignatureDevice(ctx, &signingpb.GetSignatureDeviceRequest{DeviceId: "../devices"})
+			return err
+		}, "device_id"},
+		{"malformed signing device id", func() error {
+			_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: "../devices", ClientId: clientId, DataToBeSigned: "payload"})
+			return err
+		}, "device_id"},
+		{"data too large", func() error {
+			_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: device.GetId(), ClientId: clientId, DataToBeSigned: strings.Repeat("a", service.MaxDataToBeSignedBytes+1)})
+			return err
+		}, "data_to_be_signed"},
+		{"reference too long", func() error {
+			_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: device.GetId(), ClientId: clientId, DataToBeSigned: "payload", Reference: strings.Repeat("a", service.MaxReferenceLength+1)})
+			return err
+		}, "reference"},
+		{"reference charset", func() error {
+			_, err := client.SignTransaction(ctx, &signingpb.SignTransactionRequest{DeviceId: device.GetId(), ClientId: clientId, DataToBeSigned: "payload", Reference: "Beleg\n1"})
+			return err
+		}, "reference"},
+		{"batch data too large", func() error {
+			stream, err := client.SignTransactionBatch(ctx, &signingpb.SignTransactionBatchRequest{DeviceId: device.GetId(), ClientId: clientId, DataToBeSigned: []string{strings.Repeat("a", service.MaxDataToBeSignedBytes+1)}})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, "data_to_be_signed"},
+		{"process type too long", func() error {
+			_, err := client.StartFiscalTransaction(ctx, &signingpb.StartFiscalTransactionRequest{DeviceId: device.GetId(), ClientId: clientId, ProcessType: strings.Repeat("a", service.MaxProcessTypeLength+1)})
+			return err
+		}, "process_type"},
+		{"malformed client device id", func() error {
+			_, err := client.RegisterClient(ctx, &signingpb.RegisterClientRequest{SerialNumber: "KASSE-2", DeviceIds: []string{"../devices"}})
+			return err
+		}, "device_ids"},
+		{"malformed client id", func() error {
+			_, err := client.GetClient(ctx, &signingpb.GetClientRequest{Id: "../clients"})
+			return err
+		}, "client_id"},
+	}
+	for _, c := range cases {
+		err := c.call()
+
+		st := status.Convert(err)
+		if !assert.Equal(t, codes.InvalidArgument, st.Code(), c.name) {
+			continue
+		}
+		if assert.Len(t, st.Details(), 1, c.name) {
+			badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+			if assert.True(t, ok, c.name) && assert.Len(t, badRequest.GetFieldViolations(), 1, c.name) {
+				assert.Equal(t, c.field, badRequest.GetFieldViolations()[0].GetField(), c.name)
+			}
+		}
+	}
+	// nothing was signed or registered
+	transactions, err := client.ListTransactions(ctx, &signingpb.ListTransactionsRequest{DeviceId: device.GetId()})
+	assert.NoError(t, err)
+	assert.Empty(t, transactions.GetTransactions())
+	clients, err := client.ListClients(ctx, &signingpb.ListClientsRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, clients.GetClients(), 1)
+}
+
+func Test_StatusError(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{service.ErrTransactionNotFound, codes.NotFound},
+		{service.ErrCheckpointNotFound, codes.NotFound},
+		{service.ErrCertificateNotFound, codes.NotFound},
+		{service.ErrTransactionNotAnchored, codes.FailedPrecondition},
+		{service.ErrCheckpointsDisabled, codes.FailedPrecondition},
+		{service.ErrCertificateAuthorityDisabled, codes.FailedPrecondition},
+		{fmt.Errorf("issuing certificate: %w", ca.ErrAuthorityExpired), codes.FailedPrecondition},
+		{domain.ErrCertificateKeyMismatch, codes.InvalidArgument},
+		{crypto.ErrMalformedKey, codes.InvalidArgument},
+		{crypto.ErrKeyTypeMismatch, codes.InvalidArgument},
+		{errors.New("disk full"), codes.Internal},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.code, status.Code(statusError(c.err)), c.err.Error())
+	}
+}
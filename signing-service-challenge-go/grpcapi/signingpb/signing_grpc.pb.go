@@ -0,0 +1,782 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: signing/v0/signing.proto
+
+package signingpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SigningService_CreateSignatureDevice_FullMethodName   = "/signing.v0.SigningService/CreateSignatureDevice"
+	SigningService_GetSignatureDevice_FullMethodName      = "/signing.v0.SigningService/GetSignatureDevice"
+	SigningService_ListSignatureDevices_FullMethodName    = "/signing.v0.SigningService/ListSignatureDevices"
+	SigningService_SignTransaction_FullMethodName         = "/signing.v0.SigningService/SignTransaction"
+	SigningService_SignTransactionBatch_FullMethodName    = "/signing.v0.SigningService/SignTransactionBatch"
+	SigningService_ListTransactions_FullMethodName        = "/signing.v0.SigningService/ListTransactions"
+	SigningService_SearchTransactions_FullMethodName      = "/signing.v0.SigningService/SearchTransactions"
+	SigningService_VerifySignature_FullMethodName         = "/signing.v0.SigningService/VerifySignature"
+	SigningService_StartFiscalTransaction_FullMethodName  = "/signing.v0.SigningService/StartFiscalTransaction"
+	SigningService_UpdateFiscalTransaction_FullMethodName = "/signing.v0.SigningService/UpdateFiscalTransaction"
+	SigningService_FinishFiscalTransaction_FullMethodName = "/signing.v0.SigningService/FinishFiscalTransaction"
+	SigningService_GetFiscalTransaction_FullMethodName    = "/signing.v0.SigningService/GetFiscalTransaction"
+	SigningService_ListFiscalTransactions_FullMethodName  = "/signing.v0.SigningService/ListFiscalTransactions"
+	SigningService_RegisterClient_FullMethodName          = "/signing.v0.SigningService/RegisterClient"
+	SigningService_GetClient_FullMethodName               = "/signing.v0.SigningService/GetClient"
+	SigningService_ListClients_FullMethodName             = "/signing.v0.SigningService/ListClients"
+	SigningService_RegisterClientDevice_FullMethodName    = "/signing.v0.SigningService/RegisterClientDevice"
+	SigningService_DeregisterClientDevice_FullMethodName  = "/signing.v0.SigningService/DeregisterClientDevice"
+)
+
+// SigningServiceClient is the client API for SigningService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SigningServiceClient interface {
+	CreateSignatureDevice(ctx context.Context, in *CreateSignatureDeviceRequest, opts ...grpc.CallOption) (*CreateSignatureDeviceResponse, error)
+	GetSignatureDevice(ctx context.Context, in *GetSignatureDeviceRequest, opts ...grpc.CallOption) (*GetSignatureDeviceResponse, error)
+	ListSignatureDevices(ctx context.Context, in *ListSignatureDevicesRequest, opts ...grpc.CallOption) (*ListSignatureDevicesResponse, error)
+	SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error)
+	// SignTransactionBatch signs every entry of data in order and streams one
+	// response per signed entry. The stream fails at the first entry that cannot be signed.
+	SignTransactionBatch(ctx context.Context, in *SignTransactionBatchRequest, opts ...grpc.CallOption) (SigningService_SignTransactionBatchClient, error)
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// SearchTransactions returns a page of the transactions of all devices matching the filters.
+	SearchTransactions(ctx context.Context, in *SearchTransactionsRequest, opts ...grpc.CallOption) (*SearchTransactionsResponse, error)
+	VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error)
+	// StartFiscalTransaction starts a fiscal transaction with the next transaction
+	// number of the device. The start, every update and the finish are signed as
+	// transactions of the device.
+	StartFiscalTransaction(ctx context.Context, in *StartFiscalTransactionRequest, opts ...grpc.CallOption) (*StartFiscalTransactionResponse, error)
+	UpdateFiscalTransaction(ctx context.Context, in *UpdateFiscalTransactionRequest, opts ...grpc.CallOption) (*UpdateFiscalTransactionResponse, error)
+	FinishFiscalTransaction(ctx context.Context, in *FinishFiscalTransactionRequest, opts ...grpc.CallOption) (*FinishFiscalTransactionResponse, error)
+	GetFiscalTransaction(ctx context.Context, in *GetFiscalTransactionRequest, opts ...grpc.CallOption) (*GetFiscalTransactionResponse, error)
+	ListFiscalTransactions(ctx context.Context, in *ListFiscalTransactionsRequest, opts ...grpc.CallOption) (*ListFiscalTransactionsResponse, error)
+	// RegisterClient registers a cash register with the devices it may sign with.
+	// Every signature names a client registered to the signing device.
+	RegisterClient(ctx context.Context, in *RegisterClientRequest, opts ...grpc.CallOption) (*RegisterClientResponse, error)
+	GetClient(ctx context.Context, in *GetClientRequest, opts ...grpc.CallOption) (*GetClientResponse, error)
+	ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error)
+	RegisterClientDevice(ctx context.Context, in *RegisterClientDeviceRequest, opts ...grpc.CallOption) (*RegisterClientDeviceResponse, error)
+	DeregisterClientDevice(ctx context.Context, in *DeregisterClientDeviceRequest, opts ...grpc.CallOption) (*DeregisterClientDeviceResponse, error)
+}
+
+type signingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSigningServiceClient(cc grpc.ClientConnInterface) SigningServiceClient {
+	return &signingServiceClient{cc}
+}
+
+func (c *signingServiceClient) CreateSignatureDevice(ctx context.Context, in *CreateSignatureDeviceRequest, opts ...grpc.CallOption) (*CreateSignatureDeviceResponse, error) {
+	out := new(CreateSignatureDeviceResponse)
+	err := c.cc.Invoke(ctx, SigningService_CreateSignatureDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) GetSignatureDevice(ctx context.Context, in *GetSignatureDeviceRequest, opts ...grpc.CallOption) (*GetSignatureDeviceResponse, error) {
+	out := new(GetSignatureDeviceResponse)
+	err := c.cc.Invoke(ctx, SigningService_GetSignatureDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) ListSignatureDevices(ctx context.Context, in *ListSignatureDevicesRequest, opts ...grpc.CallOption) (*ListSignatureDevicesResponse, error) {
+	out := new(ListSignatureDevicesResponse)
+	err := c.cc.Invoke(ctx, SigningService_ListSignatureDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error) {
+	out := new(SignTransactionResponse)
+	err := c.cc.Invoke(ctx, SigningService_SignTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) SignTransactionBatch(ctx context.Context, in *SignTransactionBatchRequest, opts ...grpc.CallOption) (SigningService_SignTransactionBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SigningService_ServiceDesc.Streams[0], SigningService_SignTransactionBatch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &signingServiceSignTransactionBatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SigningService_SignTransactionBatchClient interface {
+	Recv() (*SignTransactionBatchResponse, error)
+	grpc.ClientStream
+}
+
+type signingServiceSignTransactionBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *signingServiceSignTransactionBatchClient) Recv() (*SignTransactionBatchResponse, error) {
+	m := new(SignTransactionBatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *signingServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, SigningService_ListTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) SearchTransactions(ctx context.Context, in *SearchTransactionsRequest, opts ...grpc.CallOption) (*SearchTransactionsResponse, error) {
+	out := new(SearchTransactionsResponse)
+	err := c.cc.Invoke(ctx, SigningService_SearchTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) VerifySignature(ctx context.Context, in *VerifySignatureRequest, opts ...grpc.CallOption) (*VerifySignatureResponse, error) {
+	out := new(VerifySignatureResponse)
+	err := c.cc.Invoke(ctx, SigningService_VerifySignature_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) StartFiscalTransaction(ctx context.Context, in *StartFiscalTransactionRequest, opts ...grpc.CallOption) (*StartFiscalTransactionResponse, error) {
+	out := new(StartFiscalTransactionResponse)
+	err := c.cc.Invoke(ctx, SigningService_StartFiscalTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) UpdateFiscalTransaction(ctx context.Context, in *UpdateFiscalTransactionRequest, opts ...grpc.CallOption) (*UpdateFiscalTransactionResponse, error) {
+	out := new(UpdateFiscalTransactionResponse)
+	err := c.cc.Invoke(ctx, SigningService_UpdateFiscalTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) FinishFiscalTransaction(ctx context.Context, in *FinishFiscalTransactionRequest, opts ...grpc.CallOption) (*FinishFiscalTransactionResponse, error) {
+	out := new(FinishFiscalTransactionResponse)
+	err := c.cc.Invoke(ctx, SigningService_FinishFiscalTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) GetFiscalTransaction(ctx context.Context, in *GetFiscalTransactionRequest, opts ...grpc.CallOption) (*GetFiscalTransactionResponse, error) {
+	out := new(GetFiscalTransactionResponse)
+	err := c.cc.Invoke(ctx, SigningService_GetFiscalTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) ListFiscalTransactions(ctx context.Context, in *ListFiscalTransactionsRequest, opts ...grpc.CallOption) (*ListFiscalTransactionsResponse, error) {
+	out := new(ListFiscalTransactionsResponse)
+	err := c.cc.Invoke(ctx, SigningService_ListFiscalTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) RegisterClient(ctx context.Context, in *RegisterClientRequest, opts ...grpc.CallOption) (*RegisterClientResponse, error) {
+	out := new(RegisterClientResponse)
+	err := c.cc.Invoke(ctx, SigningService_RegisterClient_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) GetClient(ctx context.Context, in *GetClientRequest, opts ...grpc.CallOption) (*GetClientResponse, error) {
+	out := new(GetClientResponse)
+	err := c.cc.Invoke(ctx, SigningService_GetClient_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error) {
+	out := new(ListClientsResponse)
+	err := c.cc.Invoke(ctx, SigningService_ListClients_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) RegisterClientDevice(ctx context.Context, in *RegisterClientDeviceRequest, opts ...grpc.CallOption) (*RegisterClientDeviceResponse, error) {
+	out := new(RegisterClientDeviceResponse)
+	err := c.cc.Invoke(ctx, SigningService_RegisterClientDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) DeregisterClientDevice(ctx context.Context, in *DeregisterClientDeviceRequest, opts ...grpc.CallOption) (*DeregisterClientDeviceResponse, error) {
+	out := new(DeregisterClientDeviceResponse)
+	err := c.cc.Invoke(ctx, SigningService_DeregisterClientDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SigningServiceServer is the server API for SigningService service.
+// All implementations must embed UnimplementedSigningServiceServer
+// for forward compatibility
+type SigningServiceServer interface {
+	CreateSignatureDevice(context.Context, *CreateSignatureDeviceRequest) (*CreateSignatureDeviceResponse, error)
+	GetSignatureDevice(context.Context, *GetSignatureDeviceRequest) (*GetSignatureDeviceResponse, error)
+	ListSignatureDevices(context.Context, *ListSignatureDevicesRequest) (*ListSignatureDevicesResponse, error)
+	SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error)
+	// SignTransactionBatch signs every entry of data in order and streams one
+	// response per signed entry. The stream fails at the first entry that cannot be signed.
+	SignTransactionBatch(*SignTransactionBatchRequest, SigningService_SignTransactionBatchServer) error
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// SearchTransactions returns a page of the transactions of all devices matching the filters.
+	SearchTransactions(context.Context, *SearchTransactionsRequest) (*SearchTransactionsResponse, error)
+	VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error)
+	// StartFiscalTransaction starts a fiscal transaction with the next transaction
+	// number of the device. The start, every update and the finish are signed as
+	// transactions of the device.
+	StartFiscalTransaction(context.Context, *StartFiscalTransactionRequest) (*StartFiscalTransactionResponse, error)
+	UpdateFiscalTransaction(context.Context, *UpdateFiscalTransactionRequest) (*UpdateFiscalTransactionResponse, error)
+	FinishFiscalTransaction(context.Context, *FinishFiscalTransactionRequest) (*FinishFiscalTransactionResponse, error)
+	GetFiscalTransaction(context.Context, *GetFiscalTransactionRequest) (*GetFiscalTransactionResponse, error)
+	ListFiscalTransactions(context.Context, *ListFiscalTransactionsRequest) (*ListFiscalTransactionsResponse, error)
+	// RegisterClient registers a cash register with the devices it may sign with.
+	// Every signature names a client registered to the signing device.
+	RegisterClient(context.Context, *RegisterClientRequest) (*RegisterClientResponse, error)
+	GetClient(context.Context, *GetClientRequest) (*GetClientResponse, error)
+	ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error)
+	RegisterClientDevice(context.Context, *RegisterClientDeviceRequest) (*RegisterClientDeviceResponse, error)
+	DeregisterClientDevice(context.Context, *DeregisterClientDeviceRequest) (*DeregisterClientDeviceResponse, error)
+	mustEmbedUnimplementedSigningServiceServer()
+}
+
+// UnimplementedSigningServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSigningServiceServer struct {
+}
+
+func (UnimplementedSigningServiceServer) CreateSignatureDevice(context.Context, *CreateSignatureDeviceRequest) (*CreateSignatureDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSignatureDevice not implemented")
+}
+func (UnimplementedSigningServiceServer) GetSignatureDevice(context.Context, *GetSignatureDeviceRequest) (*GetSignatureDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignatureDevice not implemented")
+}
+func (UnimplementedSigningServiceServer) ListSignatureDevices(context.Context, *ListSignatureDevicesRequest) (*ListSignatureDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSignatureDevices not implemented")
+}
+func (UnimplementedSigningServiceServer) SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTransaction not implemented")
+}
+func (UnimplementedSigningServiceServer) SignTransactionBatch(*SignTransactionBatchRequest, SigningService_SignTransactionBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method SignTransactionBatch not implemented")
+}
+func (UnimplementedSigningServiceServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedSigningServiceServer) SearchTransactions(context.Context, *SearchTransactionsRequest) (*SearchTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchTransactions not implemented")
+}
+func (UnimplementedSigningServiceServer) VerifySignature(context.Context, *VerifySignatureRequest) (*VerifySignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifySignature not implemented")
+}
+func (UnimplementedSigningServiceServer) StartFiscalTransaction(context.Context, *StartFiscalTransactionRequest) (*StartFiscalTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartFiscalTransaction not implemented")
+}
+func (UnimplementedSigningServiceServer) UpdateFiscalTransaction(context.Context, *UpdateFiscalTransactionRequest) (*UpdateFiscalTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateFiscalTransaction not implemented")
+}
+func (UnimplementedSigningServiceServer) FinishFiscalTransaction(context.Context, *FinishFiscalTransactionRequest) (*FinishFiscalTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishFiscalTransaction not implemented")
+}
+func (UnimplementedSigningServiceServer) GetFiscalTransaction(context.Context, *GetFiscalTransactionRequest) (*GetFiscalTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFiscalTransaction not implemented")
+}
+func (UnimplementedSigningServiceServer) ListFiscalTransactions(context.Context, *ListFiscalTransactionsRequest) (*ListFiscalTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiscalTransactions not implemented")
+}
+func (UnimplementedSigningServiceServer) RegisterClient(context.Context, *RegisterClientRequest) (*RegisterClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterClient not implemented")
+}
+func (UnimplementedSigningServiceServer) GetClient(context.Context, *GetClientRequest) (*GetClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClient not implemented")
+}
+func (UnimplementedSigningServiceServer) ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClients not implemented")
+}
+func (UnimplementedSigningServiceServer) RegisterClientDevice(context.Context, *RegisterClientDeviceRequest) (*RegisterClientDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterClientDevice not implemented")
+}
+func (UnimplementedSigningServiceServer) DeregisterClientDevice(context.Context, *DeregisterClientDeviceRequest) (*DeregisterClientDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeregisterClientDevice not implemented")
+}
+func (UnimplementedSigningServiceServer) mustEmbedUnimplementedSigningServiceServer() {}
+
+// UnsafeSigningServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SigningServiceServer will
+// result in compilation errors.
+type UnsafeSigningServiceServer interface {
+	mustEmbedUnimplementedSigningServiceServer()
+}
+
+func RegisterSigningServiceServer(s grpc.ServiceRegistrar, srv SigningServiceServer) {
+	s.RegisterService(&SigningService_ServiceDesc, srv)
+}
+
+func _SigningService_CreateSignatureDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSignatureDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).CreateSignatureDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_CreateSignatureDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).CreateSignatureDevice(ctx, req.(*CreateSignatureDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_GetSignatureDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignatureDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).GetSignatureDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_GetSignatureDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).GetSignatureDevice(ctx, req.(*GetSignatureDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_ListSignatureDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSignatureDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).ListSignatureDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_ListSignatureDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).ListSignatureDevices(ctx, req.(*ListSignatureDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_SignTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).SignTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_SignTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).SignTransaction(ctx, req.(*SignTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_SignTransactionBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SignTransactionBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SigningServiceServer).SignTransactionBatch(m, &signingServiceSignTransactionBatchServer{stream})
+}
+
+type SigningService_SignTransactionBatchServer interface {
+	Send(*SignTransactionBatchResponse) error
+	grpc.ServerStream
+}
+
+type signingServiceSignTransactionBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *signingServiceSignTransactionBatchServer) Send(m *SignTransactionBatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SigningService_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_SearchTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).SearchTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_SearchTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).SearchTransactions(ctx, req.(*SearchTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_VerifySignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifySignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).VerifySignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_VerifySignature_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).VerifySignature(ctx, req.(*VerifySignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_StartFiscalTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartFiscalTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).StartFiscalTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_StartFiscalTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).StartFiscalTransaction(ctx, req.(*StartFiscalTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_UpdateFiscalTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateFiscalTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).UpdateFiscalTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_UpdateFiscalTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).UpdateFiscalTransaction(ctx, req.(*UpdateFiscalTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_FinishFiscalTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishFiscalTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).FinishFiscalTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_FinishFiscalTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).FinishFiscalTransaction(ctx, req.(*FinishFiscalTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_GetFiscalTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFiscalTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).GetFiscalTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_GetFiscalTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).GetFiscalTransaction(ctx, req.(*GetFiscalTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_ListFiscalTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFiscalTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).ListFiscalTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_ListFiscalTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).ListFiscalTransactions(ctx, req.(*ListFiscalTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_RegisterClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).RegisterClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_RegisterClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).RegisterClient(ctx, req.(*RegisterClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_GetClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).GetClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_GetClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).GetClient(ctx, req.(*GetClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_ListClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).ListClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_ListClients_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).ListClients(ctx, req.(*ListClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_RegisterClientDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterClientDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).RegisterClientDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_RegisterClientDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).RegisterClientDevice(ctx, req.(*RegisterClientDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_DeregisterClientDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterClientDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).DeregisterClientDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SigningService_DeregisterClientDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).DeregisterClientDevice(ctx, req.(*DeregisterClientDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SigningService_ServiceDesc is the grpc.ServiceDesc for SigningService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SigningService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "signing.v0.SigningService",
+	HandlerType: (*SigningServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSignatureDevice",
+			Handler:    _SigningService_CreateSignatureDevice_Handler,
+		},
+		{
+			MethodName: "GetSignatureDevice",
+			Handler:    _SigningService_GetSignatureDevice_Handler,
+		},
+		{
+			MethodName: "ListSignatureDevices",
+			Handler:    _SigningService_ListSignatureDevices_Handler,
+		},
+		{
+			MethodName: "SignTransaction",
+			Handler:    _SigningService_SignTransaction_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _SigningService_ListTransactions_Handler,
+		},
+		{
+			MethodName: "SearchTransactions",
+			Handler:    _SigningService_SearchTransactions_Handler,
+		},
+		{
+			MethodName: "VerifySignature",
+			Handler:    _SigningService_VerifySignature_Handler,
+		},
+		{
+			MethodName: "StartFiscalTransaction",
+			Handler:    _SigningService_StartFiscalTransaction_Handler,
+		},
+		{
+			MethodName: "UpdateFiscalTransaction",
+			Handler:    _SigningService_UpdateFiscalTransaction_Handler,
+		},
+		{
+			MethodName: "FinishFiscalTransaction",
+			Handler:    _SigningService_FinishFiscalTransaction_Handler,
+		},
+		{
+			MethodName: "GetFiscalTransaction",
+			Handler:    _SigningService_GetFiscalTransaction_Handler,
+		},
+		{
+			MethodName: "ListFiscalTransactions",
+			Handler:    _SigningService_ListFiscalTransactions_Handler,
+		},
+		{
+			MethodName: "RegisterClient",
+			Handler:    _SigningService_RegisterClient_Handler,
+		},
+		{
+			MethodName: "GetClient",
+			Handler:    _SigningService_GetClient_Handler,
+		},
+		{
+			MethodName: "ListClients",
+			Handler:    _SigningService_ListClients_Handler,
+		},
+		{
+			MethodName: "RegisterClientDevice",
+			Handler:    _SigningService_RegisterClientDevice_Handler,
+		},
+		{
+			MethodName: "DeregisterClientDevice",
+			Handler:    _SigningService_DeregisterClientDevice_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SignTransactionBatch",
+			Handler:       _SigningService_SignTransactionBatch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "signing/v0/signing.proto",
+}
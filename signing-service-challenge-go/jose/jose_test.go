@@ -0,0 +1,104 @@
+package jose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sign_VerifiesWithJWK(t *testing.T) {
+	for _, generator := range []crypto.Generator{crypto.NewRSAGenerator(), crypto.NewECCGenerator()} {
+		keyPair, err := generator.Generate()
+		if err != nil {
+			t.Fatalf("Could not generate key pair: %v", err)
+		}
+		signer, err := crypto.NewSigner(keyPair.PrivateKey())
+		if err != nil {
+			t.Fatalf("Could not create signer: %v", err)
+		}
+		algorithm, err := Algorithm(keyPair.PublicKey())
+		if err != nil {
+			t.Fatalf("Could not select algorithm: %v", err)
+		}
+
+		jws, err := Sign(signer, Header{Algorithm: algorithm, KeyId: "device"}, []byte(`{"data":"payload"}`))
+		if err != nil {
+			t.Fatalf("Could not sign: %v", err)
+		}
+
+		// the key is taken from its JWK, as a relying party would
+		jwk, err := NewJWK("device", keyPair.PublicKey())
+		if err != nil {
+			t.Fatalf("Could not create JWK: %v", err)
+		}
+		encoded, err := json.Marshal(JWKSet{Keys: []JWK{*jwk}})
+		assert.NoError(t, err)
+		set := &JWKSet{}
+		assert.NoError(t, json.Unmarshal(encoded, set))
+		unverified, err := ParseHeader(jws)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		publicKey, err := set.Key(unverified.KeyId).PublicKey()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		header, payload, err := Verify(jws, publicKey)
+		if assert.NoError(t, err, algorithm) {
+			assert.Equal(t, algorithm, header.Algorithm)
+			assert.Equal(t, "device", header.KeyId)
+			assert.Equal(t, `{"data":"payload"}`, string(payload))
+		}
+
+		parts := strings.Split(jws, ".")
+		tampered := parts[0] + "." + encode([]byte(`{"data":"other"}`)) + "." + parts[2]
+		_, _, err = Verify(tampered, publicKey)
+		assert.ErrorIs(t, err, ErrInvalidJWS)
+		_, err = ParseHeader("not a JWS")
+		assert.ErrorIs(t, err, ErrInvalidJWS)
+	}
+}
+
+func Test_Sign_ES384UsesRawSignature(t *testing.T) {
+	keyPair, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %v", err)
+	}
+	signer, err := crypto.NewSigner(keyPair.PrivateKey())
+	if err != nil {
+		t.Fatalf("Could not create signer: %v", err)
+	}
+
+	jws, err := Sign(signer, Header{Algorithm: ES384}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("Could not sign: %v", err)
+	}
+
+	signature, err := decode(strings.Split(jws, ".")[2])
+	assert.NoError(t, err)
+	assert.Len(t, signature, 96)
+	jwk, err := NewJWK("device", keyPair.PublicKey())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "P-384", jwk.Curve)
+		assert.Len(t, jwk.X, 64)
+	}
+}
+
+func Test_Algorithm_RejectsUnsupportedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	_, err = Algorithm(&key.PublicKey)
+	assert.Error(t, err)
+	_, err = NewJWK("device", "not a key")
+	assert.Error(t, err)
+	_, _, err = Verify("not.a.jws", &key.PublicKey)
+	assert.ErrorIs(t, err, ErrInvalidJWS)
+}
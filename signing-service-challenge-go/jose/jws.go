@@ -0,0 +1,136 @@
+// Package jose encodes device signatures as JSON Web Signatures (RFC 7515) and
+// device public keys as JSON Web Keys (RFC 7517).
+package jose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// Algorithms of RFC 7518 matching the signers of the crypto package.
+const (
+	// RS256 is RSASSA-PKCS1-v1_5 over SHA-256, as signed by crypto.RSASigner.
+	RS256 = "RS256"
+	// ES384 is ECDSA on P-384 over SHA-384, as signed by crypto.ECDSASigner.
+	ES384 = "ES384"
+)
+
+// ErrInvalidJWS is returned if a compact JWS is malformed or its signature is invalid.
+var ErrInvalidJWS = errors.New("invalid JWS")
+
+// Header is the protected header of a JWS.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Algorithm returns the JWS algorithm to sign with the private key of publicKey
+// through the crypto package.
+func Algorithm(publicKey interface{}) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return RS256, nil
+	case *ecdsa.PublicKey:
+		// crypto.ECDSASigner hashes with SHA-384 on every curve, which only ES384 specifies
+		if key.Curve != elliptic.P384() {
+			return "", fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+		return ES384, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// Sign encodes the payload as a compact JWS signed by signer, which must sign
+// with the algorithm of the header.
+func Sign(signer crypto.Signer, header Header, payload []byte) (string, error) {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encode(encodedHeader) + "." + encode(payload)
+	signature, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	if header.Algorithm == ES384 {
+		// JWS carries the fixed size concatenation of r and s instead of ASN.1
+		if signature, err = crypto.ECDSARawSignature(signature, crypto.ECDSASignatureSize); err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + encode(signature), nil
+}
+
+// ParseHeader returns the header of a compact JWS without verifying its signature,
+// e.g. to select the key to verify it with by the key id.
+func ParseHeader(jws string) (*Header, error) {
+	encodedHeader, _, found := strings.Cut(jws, ".")
+	if !found {
+		return nil, ErrInvalidJWS
+	}
+	headerJSON, err := decode(encodedHeader)
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+	header := &Header{}
+	if err := json.Unmarshal(headerJSON, header); err != nil {
+		return nil, ErrInvalidJWS
+	}
+	return header, nil
+}
+
+// Verify checks the signature of a compact JWS with publicKey and returns its
+// header and payload. The algorithm of the header must match the key.
+func Verify(jws string, publicKey interface{}) (*Header, []byte, error) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrInvalidJWS
+	}
+	header, err := ParseHeader(jws)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := decode(parts[1])
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+	signature, err := decode(parts[2])
+	if err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+	algorithm, err := Algorithm(publicKey)
+	if err != nil || header.Algorithm != algorithm {
+		return nil, nil, ErrInvalidJWS
+	}
+	if algorithm == ES384 {
+		if signature, err = crypto.ECDSAASN1Signature(signature, crypto.ECDSASignatureSize); err != nil {
+			return nil, nil, ErrInvalidJWS
+		}
+	}
+	verifier, err := crypto.NewVerifier(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifier.Verify([]byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, nil, ErrInvalidJWS
+	}
+	return header, payload, nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
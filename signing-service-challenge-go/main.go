@@ -1,20 +1,87 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/grpcapi"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
 )
 
 const (
-	ListenAddress = ":8080"
+	ListenAddress     = ":8080"
+	GRPCListenAddress = ":9090"
+	// TimestampAuthorityEnv names the environment variable selecting the RFC 3161
+	// timestamp authority that countersigns every signature: "local" for the
+	// built-in authority or the URL of an external one. Signatures are not
+	// timestamped if it is unset.
+	TimestampAuthorityEnv = "SIGNING_TSA"
+	// CheckpointInterval is the period in which new signatures are anchored in
+	// a signed checkpoint of the transparency log.
+	CheckpointInterval = time.Minute
 	// TODO: add further configuration parameters here ...
 )
 
 func main() {
-	server := api.NewServer(ListenAddress)
+	deviceStore := persistence.NewInMemoryDeviceStore()
+	transactionStore := persistence.NewInMemoryTransactionStore()
+	fiscalTransactionStore := persistence.NewInMemoryFiscalTransactionStore()
+	clientStore := persistence.NewInMemoryClientStore()
+	logStore := persistence.NewInMemoryLogStore()
+
+	options, err := timestampOptions(os.Getenv(TimestampAuthorityEnv))
+	if err != nil {
+		log.Fatal("Could not create timestamp authority: ", err)
+	}
+	// the checkpoint key lives in memory only, like the stores it signs the content of
+	checkpointKey, err := crypto.NewECCGenerator().Generate()
+	if err != nil {
+		log.Fatal("Could not generate checkpoint key: ", err)
+	}
+	options = append(options, service.WithCheckpointKey(checkpointKey))
+	// device certificates of the CA can only be verified while it runs, as its key lives in memory too
+	authority, err := ca.NewAuthority("Signing Service Device CA")
+	if err != nil {
+		log.Fatal("Could not create certificate authority: ", err)
+	}
+	options = append(options, service.WithCertificateAuthority(authority))
+
+	signingService := service.NewSigningService(deviceStore, transactionStore, fiscalTransactionStore, clientStore, logStore, options...)
+	go signingService.RunCheckpoints(context.Background(), CheckpointInterval)
+
+	grpcServer := grpcapi.NewServer(GRPCListenAddress, signingService)
+	go func() {
+		if err := grpcServer.Run(); err != nil {
+			log.Fatal("Could not start gRPC server on ", GRPCListenAddress)
+		}
+	}()
+
+	server := api.NewServerWithStores(ListenAddress, deviceStore, transactionStore, fiscalTransactionStore, clientStore, logStore, options...)
 
 	if err := server.Run(); err != nil {
 		log.Fatal("Could not start server on ", ListenAddress)
 	}
 }
+
+// timestampOptions configures the timestamp authority selected by the value of TimestampAuthorityEnv.
+func timestampOptions(authority string) ([]service.Option, error) {
+	switch authority {
+	case "":
+		return nil, nil
+	case "local":
+		local, err := tsa.NewLocalAuthority()
+		if err != nil {
+			return nil, err
+		}
+		return []service.Option{service.WithTimestampAuthority(local, local.Roots())}, nil
+	default:
+		return []service.Option{service.WithTimestampAuthority(tsa.NewHTTPAuthority(authority), nil)}, nil
+	}
+}
@@ -0,0 +1,105 @@
+// Package merkle implements the Merkle tree hashing of RFC 9162, as used by
+// Certificate Transparency logs, to prove that an entry is included in a tree.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrInvalidProof is returned if an audit path does not lead to the expected root hash.
+var ErrInvalidProof = errors.New("invalid inclusion proof")
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// LeafHash returns the hash of a leaf with the given data.
+func LeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{leafPrefix}, data...))
+	return hash[:]
+}
+
+func nodeHash(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// split returns the size of the left subtree of a tree with n > 1 leaves,
+// the largest power of two smaller than n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// RootHash returns the root hash of the tree over the leaf hashes.
+func RootHash(leafHashes [][]byte) []byte {
+	switch len(leafHashes) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leafHashes[0]
+	}
+	k := split(len(leafHashes))
+	return nodeHash(RootHash(leafHashes[:k]), RootHash(leafHashes[k:]))
+}
+
+// InclusionProof returns the audit path of the leaf at index in the tree over
+// the leaf hashes, ordered from the leaf to the root.
+func InclusionProof(index int, leafHashes [][]byte) ([][]byte, error) {
+	if index < 0 || index >= len(leafHashes) {
+		return nil, errors.New("leaf index is out of range")
+	}
+	return path(index, leafHashes), nil
+}
+
+func path(index int, leafHashes [][]byte) [][]byte {
+	if len(leafHashes) <= 1 {
+		return [][]byte{}
+	}
+	k := split(len(leafHashes))
+	if index < k {
+		return append(path(index, leafHashes[:k]), RootHash(leafHashes[k:]))
+	}
+	return append(path(index-k, leafHashes[k:]), RootHash(leafHashes[:k]))
+}
+
+// VerifyInclusion checks that the audit path proves the leaf hash at index to be
+// included in the tree of the given size with the root hash, following section
+// 2.1.3.2 of RFC 9162.
+func VerifyInclusion(index int, size int, leafHash []byte, proof [][]byte, rootHash []byte) error {
+	if index < 0 || index >= size {
+		return ErrInvalidProof
+	}
+	fn, sn := index, size-1
+	hash := leafHash
+	for _, sibling := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			hash = nodeHash(sibling, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = nodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(hash, rootHash) {
+		return ErrInvalidProof
+	}
+	return nil
+}
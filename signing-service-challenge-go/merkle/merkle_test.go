@@ -0,0 +1,51 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func leaves(n int) [][]byte {
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		hashes[i] = LeafHash([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return hashes
+}
+
+func Test_RootHash_KnownValues(t *testing.T) {
+	// the empty tree and the first leaf of the test vectors of Certificate Transparency
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(RootHash(nil)))
+	assert.Equal(t, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d", hex.EncodeToString(RootHash([][]byte{LeafHash([]byte{})})))
+
+	hashes := leaves(3)
+	assert.Equal(t, nodeHash(nodeHash(hashes[0], hashes[1]), hashes[2]), RootHash(hashes))
+}
+
+func Test_InclusionProof_VerifiesForAllSizes(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		hashes := leaves(size)
+		root := RootHash(hashes)
+		for index := 0; index < size; index++ {
+			proof, err := InclusionProof(index, hashes)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			assert.NoError(t, VerifyInclusion(index, size, hashes[index], proof, root), "size %d index %d", size, index)
+			// the proof does not hold for another leaf or position
+			assert.ErrorIs(t, VerifyInclusion(index, size, LeafHash([]byte("other")), proof, root), ErrInvalidProof)
+			if size > 1 {
+				assert.ErrorIs(t, VerifyInclusion((index+1)%size, size, hashes[index], proof, root), ErrInvalidProof)
+			}
+		}
+	}
+}
+
+func Test_InclusionProof_OutOfRange(t *testing.T) {
+	_, err := InclusionProof(3, leaves(3))
+	assert.Error(t, err)
+	assert.ErrorIs(t, VerifyInclusion(3, 3, leaves(1)[0], nil, RootHash(leaves(3))), ErrInvalidProof)
+}
@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicateSerialNumber is returned if another client is registered with the same serial number.
+var ErrDuplicateSerialNumber = errors.New("serial number is already registered")
+
+// ClientStore persists clients. Clients are returned as copies, they are only
+// changed through Update.
+type ClientStore interface {
+	// Create assigns an id to a new client and saves it, unless another client
+	// has its serial number. The check and the save are atomic.
+	Create(client *domain.Client) error
+	GetById(id string) *domain.Client
+	// Update applies change to the client with the given id and saves it, unless
+	// change returns an error. Concurrent updates of a client are serialized.
+	// It returns nil if no client has the id.
+	Update(id string, change func(*domain.Client) error) (*domain.Client, error)
+	// List returns the clients in order of creation. If deviceId is not empty,
+	// only clients registered to the device are returned.
+	List(deviceId string) []*domain.Client
+}
+
+type InMemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]*domain.Client
+}
+
+func NewInMemoryClientStore() ClientStore {
+	return &InMemoryClientStore{
+		clients: map[string]*domain.Client{},
+	}
+}
+
+func (p *InMemoryClientStore) Create(client *domain.Client) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, stored := range p.clients {
+		if stored.SerialNumber == client.SerialNumber {
+			return ErrDuplicateSerialNumber
+		}
+	}
+	if client.Id == "" {
+		client.Id = uuid.New().String()
+	}
+	p.clients[client.Id] = copyClient(client)
+	return nil
+}
+
+func (p *InMemoryClientStore) GetById(id string) *domain.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	client, ok := p.clients[id]
+	if !ok {
+		return nil
+	}
+	return copyClient(client)
+}
+
+func (p *InMemoryClientStore) Update(id string, change func(*domain.Client) error) (*domain.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stored, ok := p.clients[id]
+	if !ok {
+		return nil, nil
+	}
+	client := copyClient(stored)
+	if err := change(client); err != nil {
+		return nil, err
+	}
+	p.clients[id] = client
+	return copyClient(client), nil
+}
+
+func (p *InMemoryClientStore) List(deviceId string) []*domain.Client {
+	p.mu.RLock()
+	clients := make([]*domain.Client, 0, len(p.clients))
+	for _, client := range p.clients {
+		if deviceId == "" || client.RegisteredTo(deviceId) {
+			clients = append(clients, copyClient(client))
+		}
+	}
+	p.mu.RUnlock()
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].CreatedAt.Equal(clients[j].CreatedAt) {
+			return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+		}
+		return clients[i].Id < clients[j].Id
+	})
+	return clients
+}
+
+func copyClient(client *domain.Client) *domain.Client {
+	copied := *client
+	copied.DeviceIds = append([]string{}, client.DeviceIds...)
+	return &copied
+}
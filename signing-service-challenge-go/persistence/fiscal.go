@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+type FiscalTransactionStore interface {
+	// NextNumber reserves the next fiscal transaction number of a device. Numbers
+	// are never handed out twice, even if the reserving transaction is not saved.
+	NextNumber(deviceId string) int
+	Save(transaction *domain.FiscalTransaction)
+	Get(deviceId string, number int) *domain.FiscalTransaction
+	// GetByDevice returns the fiscal transactions of a device ordered by number.
+	GetByDevice(deviceId string) []*domain.FiscalTransaction
+}
+
+type InMemoryFiscalTransactionStore struct {
+	mu           sync.RWMutex
+	transactions map[string]map[int]*domain.FiscalTransaction
+	lastNumbers  map[string]int
+}
+
+func NewInMemoryFiscalTransactionStore() FiscalTransactionStore {
+	return &InMemoryFiscalTransactionStore{
+		transactions: map[string]map[int]*domain.FiscalTransaction{},
+		lastNumbers:  map[string]int{},
+	}
+}
+
+func (p *InMemoryFiscalTransactionStore) NextNumber(deviceId string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastNumbers[deviceId]++
+	return p.lastNumbers[deviceId]
+}
+
+func (p *InMemoryFiscalTransactionStore) Save(transaction *domain.FiscalTransaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	deviceTransactions, ok := p.transactions[transaction.DeviceId]
+	if !ok {
+		deviceTransactions = map[int]*domain.FiscalTransaction{}
+		p.transactions[transaction.DeviceId] = deviceTransactions
+	}
+	deviceTransactions[transaction.Number] = transaction
+}
+
+func (p *InMemoryFiscalTransactionStore) Get(deviceId string, number int) *domain.FiscalTransaction {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.transactions[deviceId][number]
+}
+
+func (p *InMemoryFiscalTransactionStore) GetByDevice(deviceId string) []*domain.FiscalTransaction {
+	p.mu.RLock()
+	transactions := make([]*domain.FiscalTransaction, 0, len(p.transactions[deviceId]))
+	for _, transaction := range p.transactions[deviceId] {
+		transactions = append(transactions, transaction)
+	}
+	p.mu.RUnlock()
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Number < transactions[j].Number
+	})
+	return transactions
+}
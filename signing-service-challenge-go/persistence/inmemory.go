@@ -1,48 +1,73 @@
 package persistence
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/google/uuid"
 )
 
+// ErrDuplicateReference is returned if a transaction reference is already used by the device.
+var ErrDuplicateReference = errors.New("reference was already used by the device")
+
 type DeviceStore interface {
 	Save(value *domain.SignatureDevice)
 	GetById(id string) *domain.SignatureDevice
 	GetAll() []interface{}
+	// List returns a page of the devices matching the query. Stores backed by a
+	// database are expected to filter, sort and page in their queries.
+	List(query DeviceQuery) (*DevicePage, error)
 	IncrementCounter(deviceId string)
 }
 
 // in-memory persistence ...
-type InMemoryDeviceStore map[string]interface{}
+type InMemoryDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string]*domain.SignatureDevice
+}
 
 func NewInMemoryDeviceStore() DeviceStore {
-	return &InMemoryDeviceStore{}
+	return &InMemoryDeviceStore{
+		devices: map[string]*domain.SignatureDevice{},
+	}
 }
 
 func (p *InMemoryDeviceStore) Save(value *domain.SignatureDevice) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if value.Id == "" {
 		value.Id = uuid.New().String()
 	}
-	(*p)[value.Id] = value
+	p.devices[value.Id] = value
 }
 
 func (p *InMemoryDeviceStore) GetById(id string) *domain.SignatureDevice {
-	inter := (*p)[id]
-	device, ok := inter.(*domain.SignatureDevice)
-	if !ok {
-		return nil
-	}
-	return device
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.devices[id]
 }
 
 func (p *InMemoryDeviceStore) GetAll() []interface{} {
-	values := make([]interface{}, 0, len(*p))
-	for _, value := range *p {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	values := make([]interface{}, 0, len(p.devices))
+	for _, value := range p.devices {
 		values = append(values, value)
 	}
 	return values
 }
 
+func (p *InMemoryDeviceStore) List(query DeviceQuery) (*DevicePage, error) {
+	p.mu.RLock()
+	devices := make([]*domain.SignatureDevice, 0, len(p.devices))
+	for _, device := range p.devices {
+		devices = append(devices, device)
+	}
+	p.mu.RUnlock()
+	return pageDevices(devices, query)
+}
+
 func (p *InMemoryDeviceStore) IncrementCounter(deviceId string) {
 	device := p.GetById(deviceId)
 	if device != nil {
@@ -53,24 +78,53 @@ func (p *InMemoryDeviceStore) IncrementCounter(deviceId string) {
 type TransactionStore interface {
 	Save(value interface{})
 	GetByDevice(deviceId string) []*domain.Transaction
+	// SaveWithUniqueReference saves a transaction unless another transaction of
+	// the same device carries its reference, in which case ErrDuplicateReference
+	// is returned. The check and the save are atomic.
+	SaveWithUniqueReference(transaction *domain.Transaction) error
+	// Search returns a page of the transactions of all devices matching the query.
+	// Stores backed by a database are expected to filter, sort and page in their queries.
+	Search(query TransactionQuery) (*TransactionPage, error)
 }
 
-type InMemoryTransactionStore map[string]interface{}
+type InMemoryTransactionStore struct {
+	mu           sync.RWMutex
+	transactions map[string]interface{}
+}
 
 func NewInMemoryTransactionStore() TransactionStore {
-	return &InMemoryTransactionStore{}
+	return &InMemoryTransactionStore{
+		transactions: map[string]interface{}{},
+	}
 }
 
 func (p *InMemoryTransactionStore) Save(value interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	// create key as uuid string
 	id := uuid.New().String()
-	(*p)[id] = value
+	p.transactions[id] = value
+}
+
+func (p *InMemoryTransactionStore) SaveWithUniqueReference(transaction *domain.Transaction) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, value := range p.transactions {
+		stored := value.(*domain.Transaction)
+		if stored.DeviceId == transaction.DeviceId && stored.Reference == transaction.Reference {
+			return ErrDuplicateReference
+		}
+	}
+	p.transactions[uuid.New().String()] = transaction
+	return nil
 }
 
 // extract all transactions handled by a specific device
 func (p *InMemoryTransactionStore) GetByDevice(deviceId string) []*domain.Transaction {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	deviceTransactions := make([]*domain.Transaction, 0)
-	for _, value := range *p {
+	for _, value := range p.transactions {
 		transaction := value.(*domain.Transaction)
 		if transaction.DeviceId == deviceId {
 			deviceTransactions = append(deviceTransactions, transaction)
@@ -78,3 +132,16 @@ func (p *InMemoryTransactionStore) GetByDevice(deviceId string) []*domain.Transa
 	}
 	return deviceTransactions
 }
+
+func (p *InMemoryTransactionStore) Search(query TransactionQuery) (*TransactionPage, error) {
+	p.mu.RLock()
+	transactions := make([]*domain.Transaction, 0)
+	for _, value := range p.transactions {
+		transaction := value.(*domain.Transaction)
+		if query.Filter.Matches(transaction) {
+			transactions = append(transactions, transaction)
+		}
+	}
+	p.mu.RUnlock()
+	return pageTransactions(transactions, query)
+}
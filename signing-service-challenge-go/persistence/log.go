@@ -0,0 +1,159 @@
+package persistence
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ErrCheckpointConflict is returned if a checkpoint does not extend the log,
+// e.g. because another checkpoint was appended concurrently.
+var ErrCheckpointConflict = errors.New("checkpoint does not extend the log")
+
+// LogStore persists the transparency log: the entries of the anchored
+// transactions in order and the checkpoints over them. Both are append-only
+// and returned as copies.
+type LogStore interface {
+	// Append adds the entries to the log and stores the checkpoint over the
+	// extended log. Unless the entries continue the log, continue the anchored
+	// counters of their devices and the checkpoint follows the latest checkpoint,
+	// ErrCheckpointConflict is returned. The check and the append are atomic.
+	Append(entries []*domain.LogEntry, checkpoint *domain.Checkpoint) error
+	// Entries returns the first size entries of the log in order.
+	Entries(size int) []*domain.LogEntry
+	// Entry returns the entry of a transaction, or nil if it is not anchored.
+	Entry(deviceId string, counter int) *domain.LogEntry
+	// NextCounter returns the signature counter of the first transaction of the
+	// device that is not anchored yet.
+	NextCounter(deviceId string) int
+	// StartChain sets the signature counter of the first transaction of a device
+	// whose chain does not start at 0, e.g. because it was imported. It has no
+	// effect once entries of the device have been appended.
+	StartChain(deviceId string, counter int)
+	// LatestCheckpoint returns the checkpoint with the highest number, or nil if there is none.
+	LatestCheckpoint() *domain.Checkpoint
+	// GetCheckpoint returns the checkpoint with the given number, or nil if there is none.
+	GetCheckpoint(number int) *domain.Checkpoint
+	// ListCheckpoints returns all checkpoints in order.
+	ListCheckpoints() []*domain.Checkpoint
+}
+
+type logKey struct {
+	deviceId string
+	counter  int
+}
+
+type InMemoryLogStore struct {
+	mu           sync.RWMutex
+	entries      []domain.LogEntry
+	index        map[logKey]int
+	nextCounters map[string]int
+	checkpoints  []domain.Checkpoint
+}
+
+func NewInMemoryLogStore() LogStore {
+	return &InMemoryLogStore{
+		index:        map[logKey]int{},
+		nextCounters: map[string]int{},
+	}
+}
+
+func (p *InMemoryLogStore) Append(entries []*domain.LogEntry, checkpoint *domain.Checkpoint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if checkpoint.Number != len(p.checkpoints)+1 || checkpoint.TreeSize != len(p.entries)+len(entries) {
+		return ErrCheckpointConflict
+	}
+	nextCounters := map[string]int{}
+	for i, entry := range entries {
+		next, ok := nextCounters[entry.DeviceId]
+		if !ok {
+			next = p.nextCounters[entry.DeviceId]
+		}
+		if entry.Index != len(p.entries)+i || entry.Counter != next {
+			return ErrCheckpointConflict
+		}
+		nextCounters[entry.DeviceId] = next + 1
+	}
+
+	for _, entry := range entries {
+		p.index[logKey{deviceId: entry.DeviceId, counter: entry.Counter}] = entry.Index
+		p.entries = append(p.entries, *entry)
+	}
+	for deviceId, next := range nextCounters {
+		p.nextCounters[deviceId] = next
+	}
+	p.checkpoints = append(p.checkpoints, *checkpoint)
+	return nil
+}
+
+func (p *InMemoryLogStore) Entries(size int) []*domain.LogEntry {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if size > len(p.entries) {
+		size = len(p.entries)
+	}
+	entries := make([]*domain.LogEntry, size)
+	for i := range entries {
+		entry := p.entries[i]
+		entries[i] = &entry
+	}
+	return entries
+}
+
+func (p *InMemoryLogStore) Entry(deviceId string, counter int) *domain.LogEntry {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	index, ok := p.index[logKey{deviceId: deviceId, counter: counter}]
+	if !ok {
+		return nil
+	}
+	entry := p.entries[index]
+	return &entry
+}
+
+func (p *InMemoryLogStore) NextCounter(deviceId string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.nextCounters[deviceId]
+}
+
+func (p *InMemoryLogStore) StartChain(deviceId string, counter int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.nextCounters[deviceId]; !ok {
+		p.nextCounters[deviceId] = counter
+	}
+}
+
+func (p *InMemoryLogStore) LatestCheckpoint() *domain.Checkpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.checkpoints) == 0 {
+		return nil
+	}
+	checkpoint := p.checkpoints[len(p.checkpoints)-1]
+	return &checkpoint
+}
+
+func (p *InMemoryLogStore) GetCheckpoint(number int) *domain.Checkpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if number < 1 || number > len(p.checkpoints) {
+		return nil
+	}
+	checkpoint := p.checkpoints[number-1]
+	return &checkpoint
+}
+
+func (p *InMemoryLogStore) ListCheckpoints() []*domain.Checkpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	checkpoints := make([]*domain.Checkpoint, len(p.checkpoints))
+	for i := range checkpoints {
+		checkpoint := p.checkpoints[i]
+		checkpoints[i] = &checkpoint
+	}
+	return checkpoints
+}
@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InMemoryLogStore_AppendRejectsConflicts(t *testing.T) {
+	store := NewInMemoryLogStore()
+	entries := []*domain.LogEntry{
+		{Index: 0, DeviceId: "a", Counter: 0},
+		{Index: 1, DeviceId: "a", Counter: 1},
+		{Index: 2, DeviceId: "b", Counter: 0},
+	}
+	assert.NoError(t, store.Append(entries, &domain.Checkpoint{Number: 1, TreeSize: 3}))
+
+	for _, c := range []struct {
+		name       string
+		entries    []*domain.LogEntry
+		checkpoint *domain.Checkpoint
+	}{
+		{"same number", []*domain.LogEntry{{Index: 3, DeviceId: "a", Counter: 2}}, &domain.Checkpoint{Number: 1, TreeSize: 4}},
+		{"wrong size", []*domain.LogEntry{{Index: 3, DeviceId: "a", Counter: 2}}, &domain.Checkpoint{Number: 2, TreeSize: 5}},
+		{"wrong index", []*domain.LogEntry{{Index: 2, DeviceId: "a", Counter: 2}}, &domain.Checkpoint{Number: 2, TreeSize: 4}},
+		{"anchored counter", []*domain.LogEntry{{Index: 3, DeviceId: "a", Counter: 1}}, &domain.Checkpoint{Number: 2, TreeSize: 4}},
+		{"counter gap", []*domain.LogEntry{{Index: 3, DeviceId: "b", Counter: 2}}, &domain.Checkpoint{Number: 2, TreeSize: 4}},
+	} {
+		assert.ErrorIs(t, store.Append(c.entries, c.checkpoint), ErrCheckpointConflict, c.name)
+	}
+
+	assert.Len(t, store.Entries(10), 3)
+	assert.Equal(t, 2, store.NextCounter("a"))
+	assert.Equal(t, 1, store.NextCounter("b"))
+	assert.Equal(t, 2, store.Entry("b", 0).Index)
+	assert.Nil(t, store.Entry("b", 1))
+	assert.Equal(t, 1, store.LatestCheckpoint().Number)
+	assert.Nil(t, store.GetCheckpoint(2))
+}
+
+func Test_InMemoryLogStore_StartChain(t *testing.T) {
+	store := NewInMemoryLogStore()
+	store.StartChain("imported", 100)
+
+	assert.Equal(t, 100, store.NextCounter("imported"))
+	assert.ErrorIs(t, store.Append([]*domain.LogEntry{{Index: 0, DeviceId: "imported", Counter: 0}}, &domain.Checkpoint{Number: 1, TreeSize: 1}), ErrCheckpointConflict)
+	assert.NoError(t, store.Append([]*domain.LogEntry{{Index: 0, DeviceId: "imported", Counter: 100}}, &domain.Checkpoint{Number: 1, TreeSize: 1}))
+	// the chain cannot be restarted once it is anchored
+	store.StartChain("imported", 0)
+	assert.Equal(t, 101, store.NextCounter("imported"))
+}
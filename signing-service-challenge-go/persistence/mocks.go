@@ -23,6 +23,11 @@ func (m *MockDeviceStoreRepo) GetAll() []interface{} {
 	return args.Get(0).([]interface{})
 }
 
+func (m *MockDeviceStoreRepo) List(query DeviceQuery) (*DevicePage, error) {
+	args := m.Called(query)
+	return args.Get(0).(*DevicePage), args.Error(1)
+}
+
 func (m *MockDeviceStoreRepo) IncrementCounter(deviceId string) {
 	m.Called(deviceId)
 }
@@ -39,3 +44,60 @@ func (m *MockTransactionStoreRepo) GetByDevice(deviceId string) []*domain.Transa
 	args := m.Called(deviceId)
 	return args.Get(0).([]*domain.Transaction)
 }
+
+func (m *MockTransactionStoreRepo) Search(query TransactionQuery) (*TransactionPage, error) {
+	args := m.Called(query)
+	return args.Get(0).(*TransactionPage), args.Error(1)
+}
+
+func (m *MockTransactionStoreRepo) SaveWithUniqueReference(transaction *domain.Transaction) error {
+	args := m.Called(transaction)
+	return args.Error(0)
+}
+
+type MockFiscalTransactionStoreRepo struct {
+	mock.Mock
+}
+
+func (m *MockFiscalTransactionStoreRepo) NextNumber(deviceId string) int {
+	args := m.Called(deviceId)
+	return args.Int(0)
+}
+
+func (m *MockFiscalTransactionStoreRepo) Save(transaction *domain.FiscalTransaction) {
+	m.Called(transaction)
+}
+
+func (m *MockFiscalTransactionStoreRepo) Get(deviceId string, number int) *domain.FiscalTransaction {
+	args := m.Called(deviceId, number)
+	return args.Get(0).(*domain.FiscalTransaction)
+}
+
+func (m *MockFiscalTransactionStoreRepo) GetByDevice(deviceId string) []*domain.FiscalTransaction {
+	args := m.Called(deviceId)
+	return args.Get(0).([]*domain.FiscalTransaction)
+}
+
+type MockClientStoreRepo struct {
+	mock.Mock
+}
+
+func (m *MockClientStoreRepo) Create(client *domain.Client) error {
+	args := m.Called(client)
+	return args.Error(0)
+}
+
+func (m *MockClientStoreRepo) GetById(id string) *domain.Client {
+	args := m.Called(id)
+	return args.Get(0).(*domain.Client)
+}
+
+func (m *MockClientStoreRepo) Update(id string, change func(*domain.Client) error) (*domain.Client, error) {
+	args := m.Called(id, change)
+	return args.Get(0).(*domain.Client), args.Error(1)
+}
+
+func (m *MockClientStoreRepo) List(deviceId string) []*domain.Client {
+	args := m.Called(deviceId)
+	return args.Get(0).([]*domain.Client)
+}
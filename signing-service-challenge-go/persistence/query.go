@@ -0,0 +1,227 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+const (
+	// DefaultPageLimit is used if a query does not set a limit.
+	DefaultPageLimit = 50
+	// MaxPageLimit bounds the number of entries of a single page.
+	MaxPageLimit = 500
+)
+
+// ErrInvalidCursor is returned if a cursor was not issued for the same sort order.
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// DeviceSort orders a device listing. Ties are broken by device id, so the order
+// is stable across pages.
+type DeviceSort string
+
+const (
+	SortCreatedAtAsc  DeviceSort = "created_at"
+	SortCreatedAtDesc DeviceSort = "-created_at"
+	SortLabelAsc      DeviceSort = "label"
+	SortLabelDesc     DeviceSort = "-label"
+)
+
+// Valid reports whether the sort order is supported.
+func (s DeviceSort) Valid() bool {
+	switch s {
+	case SortCreatedAtAsc, SortCreatedAtDesc, SortLabelAsc, SortLabelDesc:
+		return true
+	}
+	return false
+}
+
+// DeviceFilter restricts a device listing. Zero values match every device.
+type DeviceFilter struct {
+	// Label matches devices whose label contains it, ignoring case.
+	Label     string
+	Algorithm domain.SignatureAlgorithm
+	Status    domain.DeviceStatus
+	// CreatedFrom and CreatedTo bound the creation time, both inclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Metadata matches devices carrying all of the given tags.
+	Metadata map[string]string
+}
+
+// Matches reports whether a device passes the filter.
+func (f DeviceFilter) Matches(device *domain.SignatureDevice) bool {
+	if f.Label != "" && !strings.Contains(strings.ToLower(device.Label), strings.ToLower(f.Label)) {
+		return false
+	}
+	if f.Algorithm != "" && device.SignatureAlgorithm != f.Algorithm {
+		return false
+	}
+	if f.Status == domain.DeviceActive && !device.IsActive() {
+		return false
+	}
+	if f.Status == domain.DeviceDeactivated && device.IsActive() {
+		return false
+	}
+	if f.CreatedFrom != nil && device.CreatedAt.Before(*f.CreatedFrom) {
+		return false
+	}
+	if f.CreatedTo != nil && device.CreatedAt.After(*f.CreatedTo) {
+		return false
+	}
+	for key, value := range f.Metadata {
+		if tag, ok := device.Metadata[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+// DeviceQuery selects a page of devices.
+type DeviceQuery struct {
+	Filter DeviceFilter
+	Sort   DeviceSort
+	// Cursor continues a listing after the last device of a previous page.
+	Cursor string
+	Limit  int
+}
+
+// DevicePage is a page of devices. NextCursor is empty on the last page.
+type DevicePage struct {
+	Devices    []*domain.SignatureDevice
+	NextCursor string
+}
+
+// cursor is the position of the last entry of a page. Key is the sort key of
+// the entry, Id breaks ties between equal keys.
+type cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Id   string `json:"id"`
+}
+
+// EncodeCursor returns the opaque cursor pointing behind an entry.
+func EncodeCursor(sortOrder string, key string, id string) string {
+	bytes, _ := json.Marshal(cursor{Sort: sortOrder, Key: key, Id: id})
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+// DecodeCursor returns the sort key and id of a cursor issued for the sort order.
+func DecodeCursor(sortOrder string, encoded string) (string, string, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	decoded := cursor{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil || decoded.Sort != sortOrder || decoded.Id == "" {
+		return "", "", ErrInvalidCursor
+	}
+	return decoded.Key, decoded.Id, nil
+}
+
+// NormalizeLimit applies DefaultPageLimit and MaxPageLimit.
+func NormalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
+
+// deviceSortKey returns the key a device is ordered by.
+func deviceSortKey(sortOrder DeviceSort, device *domain.SignatureDevice) string {
+	switch sortOrder {
+	case SortLabelAsc, SortLabelDesc:
+		return device.Label
+	default:
+		return timeSortKey(device.CreatedAt)
+	}
+}
+
+// timeSortKey formats a time with a fixed width, so the keys compare like the times.
+func timeSortKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
+
+// pageDevices filters, sorts and pages devices in memory.
+func pageDevices(devices []*domain.SignatureDevice, query DeviceQuery) (*DevicePage, error) {
+	sortOrder := query.Sort
+	if sortOrder == "" {
+		sortOrder = SortCreatedAtAsc
+	}
+	entries := make([]pageEntry[*domain.SignatureDevice], 0, len(devices))
+	for _, device := range devices {
+		if query.Filter.Matches(device) {
+			entries = append(entries, pageEntry[*domain.SignatureDevice]{
+				item: device,
+				key:  deviceSortKey(sortOrder, device),
+				id:   device.Id,
+			})
+		}
+	}
+	matching, nextCursor, err := pageEntries(entries, string(sortOrder), query.Cursor, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &DevicePage{Devices: matching, NextCursor: nextCursor}, nil
+}
+
+// pageEntry is an entry of a listing with its sort key and the id breaking ties.
+type pageEntry[T any] struct {
+	item T
+	key  string
+	id   string
+}
+
+// pageEntries sorts entries by key and id, descending if the sort order starts
+// with "-", and returns the page following the cursor and the cursor of the next page.
+func pageEntries[T any](entries []pageEntry[T], sortOrder string, encodedCursor string, limit int) ([]T, string, error) {
+	descending := strings.HasPrefix(sortOrder, "-")
+	less := func(keyA, idA, keyB, idB string) bool {
+		if keyA != keyB {
+			return (keyA < keyB) != descending
+		}
+		if idA != idB {
+			return (idA < idB) != descending
+		}
+		return false
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i].key, entries[i].id, entries[j].key, entries[j].id)
+	})
+
+	if encodedCursor != "" {
+		key, id, err := DecodeCursor(sortOrder, encodedCursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start := len(entries)
+		for i, entry := range entries {
+			if less(key, id, entry.key, entry.id) {
+				start = i
+				break
+			}
+		}
+		entries = entries[start:]
+	}
+
+	limit = NormalizeLimit(limit)
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		nextCursor = EncodeCursor(sortOrder, last.key, last.id)
+	}
+	items := make([]T, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, entry.item)
+	}
+	return items, nextCursor, nil
+}
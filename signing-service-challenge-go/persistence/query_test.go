@@ -0,0 +1,160 @@
+package persistence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueryTestDevice(id string, label string, createdAt time.Time) *domain.SignatureDevice {
+	return &domain.SignatureDevice{
+		Id:                 id,
+		Label:              label,
+		SignatureAlgorithm: domain.ECDSA,
+		Status:             domain.DeviceActive,
+		CreatedAt:          createdAt,
+	}
+}
+
+func collectIds(t *testing.T, store DeviceStore, query DeviceQuery) []string {
+	ids := []string{}
+	for pages := 0; pages < 10; pages++ {
+		page, err := store.List(query)
+		if err != nil {
+			t.Fatalf("Could not list devices: %v", err)
+		}
+		for _, device := range page.Devices {
+			ids = append(ids, device.Id)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+	t.Fatalf("Listing did not end")
+	return nil
+}
+
+func Test_List_StableOrderForEqualKeys(t *testing.T) {
+	store := NewInMemoryDeviceStore()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Save(newQueryTestDevice("c", "same", created))
+	store.Save(newQueryTestDevice("a", "same", created))
+	store.Save(newQueryTestDevice("b", "same", created.Add(-time.Hour)))
+	store.Save(newQueryTestDevice("d", "other", created))
+
+	assert.Equal(t, []string{"b", "a", "c", "d"}, collectIds(t, store, DeviceQuery{Limit: 1}))
+	assert.Equal(t, []string{"d", "c", "a", "b"}, collectIds(t, store, DeviceQuery{Sort: SortCreatedAtDesc, Limit: 1}))
+	assert.Equal(t, []string{"d", "a", "b", "c"}, collectIds(t, store, DeviceQuery{Sort: SortLabelAsc, Limit: 2}))
+	assert.Equal(t, []string{"c", "b", "a", "d"}, collectIds(t, store, DeviceQuery{Sort: SortLabelDesc, Limit: 3}))
+}
+
+func Test_List_CursorSurvivesInserts(t *testing.T) {
+	store := NewInMemoryDeviceStore()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Save(newQueryTestDevice("a", "a", created))
+	store.Save(newQueryTestDevice("b", "b", created.Add(time.Minute)))
+
+	page, err := store.List(DeviceQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", page.Devices[0].Id)
+
+	// devices inserted before the cursor are skipped, later ones are listed
+	store.Save(newQueryTestDevice("0", "0", created.Add(-time.Minute)))
+	store.Save(newQueryTestDevice("c", "c", created.Add(2*time.Minute)))
+	assert.Equal(t, []string{"b", "c"}, collectIds(t, store, DeviceQuery{Cursor: page.NextCursor, Limit: 1}))
+}
+
+func Test_List_Filter(t *testing.T) {
+	store := NewInMemoryDeviceStore()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Save(newQueryTestDevice("a", "Kasse 1", created))
+	tagged := newQueryTestDevice("b", "kasse 2", created.Add(time.Hour))
+	tagged.Metadata = map[string]string{"store": "berlin"}
+	store.Save(tagged)
+	deactivated := newQueryTestDevice("c", "Backoffice", created.Add(2*time.Hour))
+	deactivated.Status = domain.DeviceDeactivated
+	store.Save(deactivated)
+
+	from := created.Add(time.Hour)
+	cases := map[string]struct {
+		filter DeviceFilter
+		ids    []string
+	}{
+		"label":        {filter: DeviceFilter{Label: "KASSE"}, ids: []string{"a", "b"}},
+		"status":       {filter: DeviceFilter{Status: domain.DeviceDeactivated}, ids: []string{"c"}},
+		"created from": {filter: DeviceFilter{CreatedFrom: &from}, ids: []string{"b", "c"}},
+		"created to":   {filter: DeviceFilter{CreatedTo: &from}, ids: []string{"a", "b"}},
+		"metadata":     {filter: DeviceFilter{Metadata: map[string]string{"store": "berlin"}}, ids: []string{"b"}},
+		"algorithm":    {filter: DeviceFilter{Algorithm: domain.RSA}, ids: []string{}},
+	}
+	for name, c := range cases {
+		assert.Equal(t, c.ids, collectIds(t, store, DeviceQuery{Filter: c.filter}), name)
+	}
+}
+
+func Test_List_InvalidCursor(t *testing.T) {
+	store := NewInMemoryDeviceStore()
+
+	_, err := store.List(DeviceQuery{Cursor: "garbage"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = store.List(DeviceQuery{Sort: SortLabelAsc, Cursor: EncodeCursor(string(SortCreatedAtAsc), "a", "a")})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func Test_Search_OrdersCountersNumerically(t *testing.T) {
+	store := NewInMemoryTransactionStore()
+	signedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, counter := range []int{10, 9, 100, 2} {
+		store.Save(&domain.Transaction{DeviceId: "a", Counter: counter, SignedAt: signedAt})
+	}
+
+	counters := []int{}
+	query := TransactionQuery{Limit: 1}
+	for pages := 0; pages < 10; pages++ {
+		page, err := store.Search(query)
+		if err != nil {
+			t.Fatalf("Could not search transactions: %v", err)
+		}
+		for _, transaction := range page.Transactions {
+			counters = append(counters, transaction.Counter)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []int{2, 9, 10, 100}, counters)
+}
+
+func Test_Search_Filter(t *testing.T) {
+	store := NewInMemoryTransactionStore()
+	signedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for counter := 0; counter < 4; counter++ {
+		store.Save(&domain.Transaction{DeviceId: "a", Counter: counter, SignedAt: signedAt.Add(time.Duration(counter) * time.Hour)})
+		store.Save(&domain.Transaction{DeviceId: "b", Counter: counter, SignedAt: signedAt.Add(time.Duration(counter) * time.Hour), Reference: "b"})
+	}
+	one, two := 1, 2
+	to := signedAt.Add(time.Hour)
+
+	cases := map[string]struct {
+		filter TransactionFilter
+		count  int
+	}{
+		"devices":        {filter: TransactionFilter{DeviceIds: []string{"a"}}, count: 4},
+		"no devices":     {filter: TransactionFilter{DeviceIds: []string{}}, count: 0},
+		"counter range":  {filter: TransactionFilter{CounterFrom: &one, CounterTo: &two}, count: 4},
+		"signed to":      {filter: TransactionFilter{SignedTo: &to}, count: 4},
+		"reference":      {filter: TransactionFilter{Reference: "b"}, count: 4},
+		"all conditions": {filter: TransactionFilter{Reference: "b", CounterFrom: &two, SignedTo: &to}, count: 0},
+	}
+	for name, c := range cases {
+		page, err := store.Search(TransactionQuery{Filter: c.filter})
+		assert.NoError(t, err)
+		assert.Len(t, page.Transactions, c.count, name)
+	}
+}
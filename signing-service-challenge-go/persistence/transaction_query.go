@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// TransactionSort orders a transaction search. Ties are broken by device id and
+// signature counter, so the order is stable across pages.
+type TransactionSort string
+
+const (
+	SortSignedAtAsc  TransactionSort = "signed_at"
+	SortSignedAtDesc TransactionSort = "-signed_at"
+)
+
+// Valid reports whether the sort order is supported.
+func (s TransactionSort) Valid() bool {
+	return s == SortSignedAtAsc || s == SortSignedAtDesc
+}
+
+// TransactionFilter restricts a transaction search. Zero values match every transaction.
+type TransactionFilter struct {
+	// DeviceIds matches transactions signed by any of the devices. A non-nil,
+	// empty slice matches no transaction.
+	DeviceIds []string
+	// SignedFrom and SignedTo bound the signing time, both inclusive.
+	SignedFrom *time.Time
+	SignedTo   *time.Time
+	// CounterFrom and CounterTo bound the signature counter, both inclusive.
+	CounterFrom *int
+	CounterTo   *int
+	// Reference matches transactions with exactly this client reference.
+	Reference string
+}
+
+// Matches reports whether a transaction passes the filter.
+func (f TransactionFilter) Matches(transaction *domain.Transaction) bool {
+	if f.DeviceIds != nil && !containsString(f.DeviceIds, transaction.DeviceId) {
+		return false
+	}
+	if f.SignedFrom != nil && transaction.SignedAt.Before(*f.SignedFrom) {
+		return false
+	}
+	if f.SignedTo != nil && transaction.SignedAt.After(*f.SignedTo) {
+		return false
+	}
+	if f.CounterFrom != nil && transaction.Counter < *f.CounterFrom {
+		return false
+	}
+	if f.CounterTo != nil && transaction.Counter > *f.CounterTo {
+		return false
+	}
+	if f.Reference != "" && transaction.Reference != f.Reference {
+		return false
+	}
+	return true
+}
+
+// TransactionQuery selects a page of transactions across devices.
+type TransactionQuery struct {
+	Filter TransactionFilter
+	Sort   TransactionSort
+	// Cursor continues a search after the last transaction of a previous page.
+	Cursor string
+	Limit  int
+}
+
+// TransactionPage is a page of transactions. NextCursor is empty on the last page.
+type TransactionPage struct {
+	Transactions []*domain.Transaction
+	NextCursor   string
+}
+
+// pageTransactions filters, sorts and pages transactions in memory.
+func pageTransactions(transactions []*domain.Transaction, query TransactionQuery) (*TransactionPage, error) {
+	sortOrder := query.Sort
+	if sortOrder == "" {
+		sortOrder = SortSignedAtAsc
+	}
+	entries := make([]pageEntry[*domain.Transaction], 0, len(transactions))
+	for _, transaction := range transactions {
+		if query.Filter.Matches(transaction) {
+			entries = append(entries, pageEntry[*domain.Transaction]{
+				item: transaction,
+				key:  timeSortKey(transaction.SignedAt),
+				// counters are padded, so they compare like numbers
+				id: fmt.Sprintf("%s/%020d", transaction.DeviceId, transaction.Counter),
+			})
+		}
+	}
+	matching, nextCursor, err := pageEntries(entries, string(sortOrder), query.Cursor, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionPage{Transactions: matching, NextCursor: nextCursor}, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/cms"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var (
+	// ErrCertificateNotFound is returned if the current key of a device has not been certified.
+	ErrCertificateNotFound = errors.New("device has no certificate")
+	// ErrCertificateAuthorityDisabled is returned if a certificate of the internal CA is
+	// requested but the SigningService has no certificate authority.
+	ErrCertificateAuthorityDisabled = errors.New("certificate authority is not configured")
+)
+
+// WithCertificateAuthority lets the SigningService certify device keys with the
+// certificates of authority. Every new key is certified by it, and its certificate
+// is revoked once the key is rotated or the device is deactivated.
+func WithCertificateAuthority(authority *ca.Authority) Option {
+	return func(s *SigningService) {
+		s.certificateAuthority = authority
+	}
+}
+
+// CertificateAuthority returns the root and the intermediate certificate of the internal CA.
+func (s *SigningService) CertificateAuthority() (root *x509.Certificate, intermediate *x509.Certificate, err error) {
+	if s.certificateAuthority == nil {
+		return nil, nil, ErrCertificateAuthorityDisabled
+	}
+	return s.certificateAuthority.Certificate(), s.certificateAuthority.Intermediate(), nil
+}
+
+// CertificateRevocationList returns a DER encoded CRL of the device certificates
+// revoked by the internal CA.
+func (s *SigningService) CertificateRevocationList() ([]byte, error) {
+	if s.certificateAuthority == nil {
+		return nil, ErrCertificateAuthorityDisabled
+	}
+	return s.certificateAuthority.CRL()
+}
+
+// IssueDeviceCertificate certifies the current key of a device, either with a
+// self-signed certificate or with one of the internal CA. The certificate is
+// issued and set under the chain lock of the device, so that a concurrent
+// rotation cannot replace the key it certifies.
+func (s *SigningService) IssueDeviceCertificate(deviceId string, issuer domain.CertificateIssuer) (*domain.SignatureDevice, error) {
+	if !issuer.Valid() {
+		return nil, invalidField("issuer", "must be self or ca")
+	}
+	if issuer == domain.IssuerCA && s.certificateAuthority == nil {
+		return nil, ErrCertificateAuthorityDisabled
+	}
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	unlock := signDevice.LockChain()
+	defer unlock()
+	if !signDevice.IsActive() {
+		return nil, domain.ErrDeviceDeactivated
+	}
+
+	var chain []*x509.Certificate
+	switch issuer {
+	case domain.IssuerSelf:
+		template, err := signDevice.CertificateTemplate()
+		if err != nil {
+			return nil, err
+		}
+		if template.SerialNumber, err = ca.SerialNumber(); err != nil {
+			return nil, err
+		}
+		if template.SubjectKeyId, err = ca.SubjectKeyId(signDevice.KeyPair.PublicKey()); err != nil {
+			return nil, err
+		}
+		template.NotBefore = s.clock.Now().UTC().Truncate(time.Second)
+		template.NotAfter = template.NotBefore.Add(ca.DefaultValidity)
+		certificate, err := signDevice.SelfSignCertificate(template)
+		if err != nil {
+			return nil, err
+		}
+		chain = []*x509.Certificate{certificate}
+	case domain.IssuerCA:
+		if chain, err = s.issueCACertificate(signDevice, signDevice.KeyPair.PublicKey()); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.replaceCertificate(signDevice, chain); err != nil {
+		if issuer == domain.IssuerCA {
+			// the certificate of a key the device does not use must not stay valid
+			if revokeErr := s.certificateAuthority.Revoke(chain[0], ca.ReasonSuperseded); revokeErr != nil {
+				return nil, errors.Join(err, revokeErr)
+			}
+		}
+		return nil, err
+	}
+	s.deviceStore.Save(signDevice)
+	return signDevice, nil
+}
+
+// ImportDeviceCertificate certifies the current key of a device with a PEM encoded
+// certificate chain issued by another PKI, e.g. in response to a certificate request.
+// Like IssueDeviceCertificate, it holds the chain lock of the device.
+func (s *SigningService) ImportDeviceCertificate(deviceId string, certificate string) (*domain.SignatureDevice, error) {
+	chain, err := domain.ParseCertificates([]byte(certificate))
+	if err != nil || len(chain) == 0 {
+		return nil, invalidField("certificate", "must hold PEM encoded X.509 certificates")
+	}
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	unlock := signDevice.LockChain()
+	defer unlock()
+	if !signDevice.IsActive() {
+		return nil, domain.ErrDeviceDeactivated
+	}
+	now := s.clock.Now()
+	if now.Before(chain[0].NotBefore) || now.After(chain[0].NotAfter) {
+		return nil, invalidField("certificate", "is not valid at this time")
+	}
+	if err := s.replaceCertificate(signDevice, chain); err != nil {
+		if errors.Is(err, domain.ErrCertificateKeyMismatch) {
+			return nil, invalidField("certificate", "does not certify the public key of the device")
+		}
+		return nil, err
+	}
+	s.deviceStore.Save(signDevice)
+	return signDevice, nil
+}
+
+// DeviceCertificateRequest returns a PEM encoded PKCS #10 certificate signing request
+// for the current key of a device. The common name defaults to the device id.
+func (s *SigningService) DeviceCertificateRequest(deviceId string, subject pkix.Name) ([]byte, error) {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	if !signDevice.IsActive() {
+		return nil, domain.ErrDeviceDeactivated
+	}
+	if subject.CommonName == "" {
+		subject.CommonName = signDevice.Id
+	}
+	request, err := signDevice.CertificateRequest(subject)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request}), nil
+}
+
+// RotateDeviceKey replaces the key pair of a device. If the SigningService has a
+// certificate authority, the new key is certified before it replaces the current
+// one, so that a failure leaves the device with its previous key and certificate.
+// The certificate of the previous key is revoked as superseded, if the internal
+// CA issued it. Rotations are serialized with the transactions of the device, so
+// that every transaction is signed with the key version it records.
+func (s *SigningService) RotateDeviceKey(deviceId string) (*domain.SignatureDevice, error) {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	unlock := signDevice.LockChain()
+	defer unlock()
+	if !signDevice.IsActive() {
+		return nil, domain.ErrDeviceDeactivated
+	}
+	keyPair, err := signDevice.NewKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	var chain []*x509.Certificate
+	if s.certificateAuthority != nil {
+		if chain, err = s.issueCACertificate(signDevice, keyPair.PublicKey()); err != nil {
+			return nil, err
+		}
+	}
+	previous := signDevice.Certificate
+	if err := signDevice.RotateKeyPair(keyPair, chain); err != nil {
+		if len(chain) > 0 {
+			// the certificate of the discarded key must not stay valid
+			if revokeErr := s.certificateAuthority.Revoke(chain[0], ca.ReasonSuperseded); revokeErr != nil {
+				return nil, errors.Join(err, revokeErr)
+			}
+		}
+		return nil, err
+	}
+	if err := s.revokeCertificate(previous, ca.ReasonSuperseded); err != nil {
+		return nil, err
+	}
+	s.deviceStore.Save(signDevice)
+	return signDevice, nil
+}
+
+// DeactivateDevice permanently takes a device out of service and revokes its
+// certificate, if the internal CA issued it. The deactivation waits for the
+// transaction being signed by the device, if any, so that no transaction is
+// signed after it.
+func (s *SigningService) DeactivateDevice(deviceId string) (*domain.SignatureDevice, error) {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	unlock := signDevice.LockChain()
+	defer unlock()
+	if err := signDevice.Deactivate(); err != nil {
+		return nil, err
+	}
+	if err := s.revokeCertificate(signDevice.Certificate, ca.ReasonCessationOfOperation); err != nil {
+		return nil, err
+	}
+	s.deviceStore.Save(signDevice)
+	return signDevice, nil
+}
+
+// issueCACertificate certifies a key of a device by the internal CA.
+func (s *SigningService) issueCACertificate(signDevice *domain.SignatureDevice, publicKey interface{}) ([]*x509.Certificate, error) {
+	template, err := signDevice.CertificateTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return s.certificateAuthority.Issue(template, publicKey)
+}
+
+// replaceCertificate certifies the current key of a device with a new chain and
+// revokes the previous certificate as superseded, if the internal CA issued it.
+func (s *SigningService) replaceCertificate(signDevice *domain.SignatureDevice, chain []*x509.Certificate) error {
+	previous := signDevice.Certificate
+	if err := signDevice.SetCertificate(chain); err != nil {
+		return err
+	}
+	return s.revokeCertificate(previous, ca.ReasonSuperseded)
+}
+
+// revokeCertificate revokes the first of the PEM encoded certificates if the internal CA issued it.
+func (s *SigningService) revokeCertificate(certificate string, reason int) error {
+	if s.certificateAuthority == nil || certificate == "" {
+		return nil
+	}
+	certificates, err := domain.ParseCertificates([]byte(certificate))
+	if err != nil || len(certificates) == 0 || !s.certificateAuthority.Issued(certificates[0]) {
+		return err
+	}
+	return s.certificateAuthority.Revoke(certificates[0], reason)
+}
+
+// signCMS returns a base64 encoded detached CMS SignedData over the secured data of
+// a transaction, signed by the device key at the signing time of the transaction.
+func signCMS(transaction *domain.Transaction, securedData []byte, signDevice *domain.SignatureDevice, signer crypto.Signer) (string, error) {
+	certificates, err := signDevice.Certificates()
+	if err != nil {
+		return "", err
+	}
+	if len(certificates) == 0 {
+		return "", ErrCertificateNotFound
+	}
+	signature, err := cms.Sign(signer, certificates, securedData, transaction.SignedAt)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/merkle"
+)
+
+var (
+	// ErrCheckpointsDisabled is returned if the SigningService has no checkpoint key.
+	ErrCheckpointsDisabled = errors.New("checkpoints are not enabled")
+	// ErrCheckpointNotFound is returned if no checkpoint exists for the requested number.
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	// ErrTransactionNotFound is returned if a device has no transaction with the requested counter.
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrTransactionNotAnchored is returned if a transaction is not covered by the
+	// requested checkpoint, e.g. because it was signed after the latest one.
+	ErrTransactionNotAnchored = errors.New("transaction is not anchored in the checkpoint")
+)
+
+// WithCheckpointKey makes the SigningService sign the checkpoints of its
+// transparency log with keyPair.
+func WithCheckpointKey(keyPair crypto.KeyPair) Option {
+	return func(s *SigningService) {
+		s.checkpointKey = keyPair
+	}
+}
+
+// CheckpointPublicKey returns the PEM encoded public key checkpoints are signed with.
+func (s *SigningService) CheckpointPublicKey() ([]byte, error) {
+	if s.checkpointKey == nil {
+		return nil, ErrCheckpointsDisabled
+	}
+	return crypto.EncodePublicKey(s.checkpointKey.PublicKey())
+}
+
+// CreateCheckpoint appends the transactions signed since the latest checkpoint to
+// the transparency log and signs a checkpoint over the root hash of the log. The
+// transactions of every device are appended in counter order, stopping at the
+// first gap, and devices are taken in order of their ids. It returns nil if no
+// transaction was signed since the latest checkpoint.
+func (s *SigningService) CreateCheckpoint() (*domain.Checkpoint, error) {
+	if s.checkpointKey == nil {
+		return nil, ErrCheckpointsDisabled
+	}
+	signer, err := crypto.NewSigner(s.checkpointKey.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+
+	deviceIds := []string{}
+	for _, value := range s.deviceStore.GetAll() {
+		deviceIds = append(deviceIds, value.(*domain.SignatureDevice).Id)
+	}
+	sort.Strings(deviceIds)
+
+	latest := s.logStore.LatestCheckpoint()
+	size, number := 0, 1
+	if latest != nil {
+		size, number = latest.TreeSize, latest.Number+1
+	}
+	entries := []*domain.LogEntry{}
+	for _, deviceId := range deviceIds {
+		transactions := s.transactionStore.GetByDevice(deviceId)
+		sort.Slice(transactions, func(i, j int) bool {
+			return transactions[i].Counter < transactions[j].Counter
+		})
+		next := s.logStore.NextCounter(deviceId)
+		for _, transaction := range transactions {
+			if transaction.Counter < next {
+				continue
+			}
+			// a transaction of a concurrent request may not be stored yet
+			if transaction.Counter > next {
+				break
+			}
+			entry, err := domain.NewLogEntry(size+len(entries), transaction)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			next++
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	leafHashes, err := s.leafHashes(append(s.logStore.Entries(size), entries...))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &domain.Checkpoint{
+		Number:    number,
+		TreeSize:  len(leafHashes),
+		RootHash:  base64.StdEncoding.EncodeToString(merkle.RootHash(leafHashes)),
+		CreatedAt: s.clock.Now().UTC(),
+	}
+	signedData, err := checkpoint.SignedData()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(signedData)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(signature)
+	token, err := s.timestamp(signature)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		checkpoint.TimestampToken = base64.StdEncoding.EncodeToString(token)
+	}
+	if err := s.logStore.Append(entries, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// RunCheckpoints creates a checkpoint every interval until ctx is done.
+// Failures are logged and retried with the next checkpoint.
+func (s *SigningService) RunCheckpoints(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.CreateCheckpoint(); err != nil {
+				log.Printf("could not create checkpoint: %v", err)
+			}
+		}
+	}
+}
+
+// ListCheckpoints returns all checkpoints in order.
+func (s *SigningService) ListCheckpoints() []*domain.Checkpoint {
+	return s.logStore.ListCheckpoints()
+}
+
+// GetCheckpoint returns the checkpoint with the given number.
+func (s *SigningService) GetCheckpoint(number int) (*domain.Checkpoint, error) {
+	checkpoint := s.logStore.GetCheckpoint(number)
+	if checkpoint == nil {
+		return nil, ErrCheckpointNotFound
+	}
+	return checkpoint, nil
+}
+
+// LatestCheckpoint returns the checkpoint with the highest number.
+func (s *SigningService) LatestCheckpoint() (*domain.Checkpoint, error) {
+	checkpoint := s.logStore.LatestCheckpoint()
+	if checkpoint == nil {
+		return nil, ErrCheckpointNotFound
+	}
+	return checkpoint, nil
+}
+
+// InclusionProof proves that the transaction of a device with the given counter is
+// included in a checkpoint. A nil checkpoint number selects the latest checkpoint.
+func (s *SigningService) InclusionProof(deviceId string, counter int, checkpointNumber *int) (*domain.InclusionProof, error) {
+	if _, err := s.GetDevice(deviceId); err != nil {
+		return nil, err
+	}
+	found := false
+	for _, transaction := range s.transactionStore.GetByDevice(deviceId) {
+		if transaction.Counter == counter {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrTransactionNotFound
+	}
+	checkpoint := s.logStore.LatestCheckpoint()
+	if checkpointNumber != nil {
+		var err error
+		if checkpoint, err = s.GetCheckpoint(*checkpointNumber); err != nil {
+			return nil, err
+		}
+	}
+	entry := s.logStore.Entry(deviceId, counter)
+	if checkpoint == nil || entry == nil || entry.Index >= checkpoint.TreeSize {
+		return nil, ErrTransactionNotAnchored
+	}
+
+	leafHashes, err := s.leafHashes(s.logStore.Entries(checkpoint.TreeSize))
+	if err != nil {
+		return nil, err
+	}
+	path, err := merkle.InclusionProof(entry.Index, leafHashes)
+	if err != nil {
+		return nil, err
+	}
+	proof := &domain.InclusionProof{
+		Entry:      *entry,
+		Checkpoint: *checkpoint,
+		AuditPath:  make([]string, len(path)),
+	}
+	for i, hash := range path {
+		proof.AuditPath[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+	return proof, nil
+}
+
+func (s *SigningService) leafHashes(entries []*domain.LogEntry) ([][]byte, error) {
+	leafHashes := make([][]byte, len(entries))
+	for i, entry := range entries {
+		hash, err := base64.StdEncoding.DecodeString(entry.LeafHash)
+		if err != nil {
+			return nil, err
+		}
+		leafHashes[i] = hash
+	}
+	return leafHashes, nil
+}
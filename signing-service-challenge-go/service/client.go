@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+var (
+	// ErrClientNotFound is returned if no client exists for the requested id.
+	ErrClientNotFound = errors.New("client not found")
+	// ErrClientNotRegistered is returned if a client asks to sign with a device it is not registered to.
+	ErrClientNotRegistered = errors.New("client is not registered to the device")
+	// ErrDuplicateSerialNumber is returned if a client is registered with the serial number of another client.
+	ErrDuplicateSerialNumber = errors.New("serial number is already registered by another client")
+)
+
+// RegisterClient creates a client registered to the given devices, all of which must be active.
+func (s *SigningService) RegisterClient(serialNumber string, deviceIds []string) (*domain.Client, error) {
+	errs := FieldErrors{}
+	ValidateClient(serialNumber, deviceIds, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if serialNumber == "" {
+		return nil, invalidField("serial_number", "must not be empty")
+	}
+	if len(deviceIds) == 0 {
+		return nil, invalidField("device_ids", "must not be empty")
+	}
+	for _, deviceId := range deviceIds {
+		if err := s.registrableDevice(deviceId); err != nil {
+			return nil, err
+		}
+	}
+	client := domain.NewClient(serialNumber, deviceIds)
+	if err := s.clientStore.Create(client); err != nil {
+		if errors.Is(err, persistence.ErrDuplicateSerialNumber) {
+			return nil, ErrDuplicateSerialNumber
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// GetClient returns the client with the given id.
+func (s *SigningService) GetClient(clientId string) (*domain.Client, error) {
+	if !ValidId(clientId) {
+		return nil, invalidField("client_id", invalidIdReason)
+	}
+	client := s.clientStore.GetById(clientId)
+	if client == nil {
+		return nil, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// ListClients returns all clients in order of registration. If deviceId is not
+// empty, only the clients registered to the device are returned.
+func (s *SigningService) ListClients(deviceId string) ([]*domain.Client, error) {
+	if deviceId != "" {
+		if _, err := s.GetDevice(deviceId); err != nil {
+			return nil, err
+		}
+	}
+	return s.clientStore.List(deviceId), nil
+}
+
+// RegisterClientDevice allows a client to sign with another active device.
+func (s *SigningService) RegisterClientDevice(clientId string, deviceId string) (*domain.Client, error) {
+	if err := s.registrableDevice(deviceId); err != nil {
+		return nil, err
+	}
+	return s.updateClient(clientId, func(client *domain.Client) error {
+		client.Register(deviceId)
+		return nil
+	})
+}
+
+// DeregisterClientDevice revokes the permission of a client to sign with a device.
+func (s *SigningService) DeregisterClientDevice(clientId string, deviceId string) (*domain.Client, error) {
+	return s.updateClient(clientId, func(client *domain.Client) error {
+		if !client.RegisteredTo(deviceId) {
+			return ErrClientNotRegistered
+		}
+		client.Deregister(deviceId)
+		return nil
+	})
+}
+
+func (s *SigningService) updateClient(clientId string, change func(*domain.Client) error) (*domain.Client, error) {
+	if !ValidId(clientId) {
+		return nil, invalidField("client_id", invalidIdReason)
+	}
+	client, err := s.clientStore.Update(clientId, change)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// registrableDevice checks that clients may be registered to the device.
+func (s *SigningService) registrableDevice(deviceId string) error {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return err
+	}
+	if !signDevice.IsActive() {
+		return domain.ErrDeviceDeactivated
+	}
+	return nil
+}
+
+// authorizeClient checks that the client may sign with the device.
+func (s *SigningService) authorizeClient(clientId string, deviceId string) error {
+	client, err := s.GetClient(clientId)
+	if err != nil {
+		return err
+	}
+	if !client.RegisteredTo(deviceId) {
+		return ErrClientNotRegistered
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrClockBehind is returned if the clock reads a time before the last signature
+// of the device. Nothing is signed, so the request may be retried once the clock
+// has caught up.
+var ErrClockBehind = errors.New("clock is behind the last signature of the device")
+
+// Clock provides the time at which transactions are signed.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock reads the time of the operating system.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// Option configures a SigningService.
+type Option func(*SigningService)
+
+// WithClock makes the SigningService take signing timestamps from clock instead of SystemClock.
+func WithClock(clock Clock) Option {
+	return func(s *SigningService) {
+		s.clock = clock
+	}
+}
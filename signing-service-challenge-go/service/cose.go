@@ -0,0 +1,31 @@
+package service
+
+import (
+	"encoding/base64"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/cose"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// signCOSE encodes a signed transaction as a base64 encoded COSE_Sign1 message
+// signed by the device key. The key id is the device id, as in the JWS.
+func signCOSE(transaction *domain.Transaction, signDevice *domain.SignatureDevice, signer crypto.Signer) (string, error) {
+	algorithm, err := cose.Algorithm(signDevice.KeyPair.PublicKey())
+	if err != nil {
+		return "", err
+	}
+	claims, err := transaction.COSEPayload()
+	if err != nil {
+		return "", err
+	}
+	payload, err := cose.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	message, err := cose.Sign(signer, algorithm, []byte(transaction.DeviceId), payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(message), nil
+}
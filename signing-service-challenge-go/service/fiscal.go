@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ErrFiscalTransactionNotFound is returned if a device has no fiscal transaction with the requested number.
+var ErrFiscalTransactionNotFound = errors.New("fiscal transaction not found")
+
+// FiscalProcess holds the process of a fiscal transaction at a state change.
+// On update and finish, empty fields keep the values of the previous state.
+type FiscalProcess struct {
+	// ProcessType names the kind of process, e.g. Kassenbeleg-V1.
+	ProcessType string
+	// ProcessData holds the process, e.g. the receipt lines and payments.
+	ProcessData string
+}
+
+// StartFiscalTransaction starts a fiscal transaction on a device with the next
+// transaction number and signs the start on behalf of a client registered to the device.
+func (s *SigningService) StartFiscalTransaction(deviceId string, clientId string, process FiscalProcess) (*domain.FiscalTransactionResponse, error) {
+	errs := FieldErrors{}
+	ValidateFiscalProcess(clientId, process, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if deviceId == "" {
+		return nil, invalidField("device_id", "must not be empty")
+	}
+	if clientId == "" {
+		return nil, invalidField("client_id", "must not be empty")
+	}
+	signDevice, signer, unlock, err := s.activeSigner(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := s.authorizeClient(clientId, deviceId); err != nil {
+		return nil, err
+	}
+	fiscalTransaction := &domain.FiscalTransaction{
+		DeviceId: deviceId,
+		Number:   s.fiscalTransactionStore.NextNumber(deviceId),
+		ClientId: clientId,
+	}
+	return s.changeFiscalTransaction(fiscalTransaction, signDevice, signer, domain.FiscalStart, process)
+}
+
+// UpdateFiscalTransaction signs an update of the process of an active fiscal transaction.
+// If clientId is not empty, it must name the client that started the fiscal transaction.
+func (s *SigningService) UpdateFiscalTransaction(deviceId string, number int, clientId string, process FiscalProcess) (*domain.FiscalTransactionResponse, error) {
+	return s.continueFiscalTransaction(deviceId, number, clientId, domain.FiscalUpdate, process)
+}
+
+// FinishFiscalTransaction signs the final process of an active fiscal transaction.
+// It cannot be changed afterwards. If clientId is not empty, it must name the client
+// that started the fiscal transaction.
+func (s *SigningService) FinishFiscalTransaction(deviceId string, number int, clientId string, process FiscalProcess) (*domain.FiscalTransactionResponse, error) {
+	return s.continueFiscalTransaction(deviceId, number, clientId, domain.FiscalFinish, process)
+}
+
+func (s *SigningService) continueFiscalTransaction(deviceId string, number int, clientId string, operation domain.FiscalOperation, process FiscalProcess) (*domain.FiscalTransactionResponse, error) {
+	errs := FieldErrors{}
+	ValidateFiscalProcess(clientId, process, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	fiscalTransaction, err := s.GetFiscalTransaction(deviceId, number)
+	if err != nil {
+		return nil, err
+	}
+	if clientId != "" && clientId != fiscalTransaction.ClientId {
+		return nil, invalidField("client_id", "must name the client that started the fiscal transaction")
+	}
+	if !fiscalTransaction.Active() {
+		return nil, domain.ErrFiscalTransactionFinished
+	}
+	signDevice, signer, unlock, err := s.activeSigner(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// a concurrent request may have finished the fiscal transaction before the
+	// chain of the device was locked, so only the state read under the lock counts
+	fiscalTransaction = s.fiscalTransactionStore.Get(deviceId, number)
+	if !fiscalTransaction.Active() {
+		return nil, domain.ErrFiscalTransactionFinished
+	}
+	// the client may have been deregistered since the start
+	if err := s.authorizeClient(fiscalTransaction.ClientId, deviceId); err != nil {
+		return nil, err
+	}
+	if process.ProcessType == "" {
+		process.ProcessType = fiscalTransaction.ProcessType
+	}
+	if process.ProcessData == "" {
+		process.ProcessData = fiscalTransaction.ProcessData
+	}
+	return s.changeFiscalTransaction(fiscalTransaction, signDevice, signer, operation, process)
+}
+
+// changeFiscalTransaction signs a state change as a transaction of the device and
+// saves the fiscal transaction with the change recorded. The change is recorded on
+// a copy, so fiscal transactions read from the store are never modified.
+func (s *SigningService) changeFiscalTransaction(fiscalTransaction *domain.FiscalTransaction, signDevice *domain.SignatureDevice, signer crypto.Signer, operation domain.FiscalOperation, process FiscalProcess) (*domain.FiscalTransactionResponse, error) {
+	logData := domain.FiscalLogData{
+		TransactionNumber: fiscalTransaction.Number,
+		Operation:         operation,
+		ProcessType:       process.ProcessType,
+		ProcessData:       process.ProcessData,
+	}
+	data, err := logData.Encode()
+	if err != nil {
+		return nil, err
+	}
+	transaction := &domain.Transaction{
+		DeviceId:                fiscalTransaction.DeviceId,
+		ClientId:                fiscalTransaction.ClientId,
+		Data:                    data,
+		FiscalTransactionNumber: fiscalTransaction.Number,
+		FiscalOperation:         operation,
+	}
+	resp, err := s.signData(transaction, signDevice, signer, SignOptions{})
+	if err != nil {
+		return nil, err
+	}
+	s.deviceStore.IncrementCounter(fiscalTransaction.DeviceId)
+
+	changed := *fiscalTransaction
+	changed.Log = append([]domain.FiscalLogEntry{}, fiscalTransaction.Log...)
+	changed.Record(logData, transaction)
+	s.fiscalTransactionStore.Save(&changed)
+	return &domain.FiscalTransactionResponse{
+		FiscalTransaction: &changed,
+		Signature:         transaction,
+		SignedData:        resp.SignedData,
+	}, nil
+}
+
+// GetFiscalTransaction returns the fiscal transaction of a device with the given number.
+func (s *SigningService) GetFiscalTransaction(deviceId string, number int) (*domain.FiscalTransaction, error) {
+	if _, err := s.GetDevice(deviceId); err != nil {
+		return nil, err
+	}
+	fiscalTransaction := s.fiscalTransactionStore.Get(deviceId, number)
+	if fiscalTransaction == nil {
+		return nil, ErrFiscalTransactionNotFound
+	}
+	return fiscalTransaction, nil
+}
+
+// ListFiscalTransactions returns all fiscal transactions of a device ordered by number.
+func (s *SigningService) ListFiscalTransactions(deviceId string) ([]*domain.FiscalTransaction, error) {
+	if _, err := s.GetDevice(deviceId); err != nil {
+		return nil, err
+	}
+	return s.fiscalTransactionStore.GetByDevice(deviceId), nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// ImportOptions holds the optional attributes of an imported device.
+type ImportOptions struct {
+	// EnvelopeVersion selects the envelope of the secured data, by default domain.DefaultEnvelopeVersion.
+	EnvelopeVersion domain.EnvelopeVersion
+	// Metadata is stored with the device.
+	Metadata map[string]string
+	// Chain continues the signature chain the device had with its previous signing
+	// service. If nil, the chain starts like the one of a new device.
+	Chain *domain.ChainStart
+}
+
+// ImportDevice creates a signature device with an existing PEM encoded private key,
+// e.g. to migrate a device from another signing service, and persists it. The key
+// must match the algorithm and satisfy domain.CheckKeyPolicy. Its key is certified
+// by the internal CA, if any.
+func (s *SigningService) ImportDevice(algorithm domain.SignatureAlgorithm, label string, privateKey string, options ImportOptions) (*domain.SignatureDevice, error) {
+	if algorithm != domain.RSA && algorithm != domain.ECDSA {
+		return nil, domain.ErrInvalidAlgorithm
+	}
+	errs := FieldErrors{}
+	ValidateDevice(label, options.EnvelopeVersion, options.Metadata, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if chain := options.Chain; chain != nil {
+		if chain.Counter < 0 {
+			return nil, invalidField("signature_counter", "must not be negative")
+		}
+		if chain.LastSignature == "" {
+			return nil, invalidField("last_signature", "must not be empty if the chain is continued")
+		}
+		if len(chain.LastSignature) > MaxLastSignatureLength {
+			return nil, invalidField("last_signature", fmt.Sprintf("must not be longer than %d characters", MaxLastSignatureLength))
+		}
+		if _, err := base64.StdEncoding.DecodeString(chain.LastSignature); err != nil {
+			return nil, invalidField("last_signature", "must be base64 encoded")
+		}
+	}
+	keyPair, err := domain.DecodePrivateKey(algorithm, []byte(privateKey))
+	if errors.Is(err, crypto.ErrKeyTypeMismatch) {
+		return nil, invalidField("private_key", "does not match signature_algorithm")
+	}
+	if err != nil {
+		return nil, invalidField("private_key", "must be a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key")
+	}
+	if err := domain.CheckKeyPolicy(keyPair); err != nil {
+		return nil, invalidField("private_key", "violates the key policy: "+err.Error())
+	}
+
+	signDevice, err := domain.ImportSignatureDevice(algorithm, label, keyPair, options.Chain)
+	if err != nil {
+		return nil, err
+	}
+	if options.EnvelopeVersion != "" {
+		signDevice.EnvelopeVersion = options.EnvelopeVersion
+	}
+	if len(options.Metadata) > 0 {
+		signDevice.Metadata = options.Metadata
+	}
+	if err := s.saveNewDevice(signDevice); err != nil {
+		return nil, err
+	}
+	// the transparency log anchors the transactions of the device from the first counter on
+	s.logStore.StartChain(signDevice.Id, signDevice.ChainStart().Counter)
+	return signDevice, nil
+}
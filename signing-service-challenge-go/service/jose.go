@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jose"
+)
+
+// JWKSet returns the public keys of all devices as a JSON Web Key Set to verify
+// the JWS of transactions with. Every key a device has signed with is listed, so
+// that a JWS stays verifiable after a key rotation. Keys are identified by the
+// device id and key version, see jwkKeyId, and ordered by both.
+func (s *SigningService) JWKSet() (*jose.JWKSet, error) {
+	devices := []*domain.SignatureDevice{}
+	for _, value := range s.deviceStore.GetAll() {
+		devices = append(devices, value.(*domain.SignatureDevice))
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Id < devices[j].Id
+	})
+	set := &jose.JWKSet{Keys: []jose.JWK{}}
+	for _, signDevice := range devices {
+		for _, key := range signDevice.PublicKeys {
+			publicKey, err := crypto.DecodePublicKey([]byte(key.PublicKey))
+			if err != nil {
+				return nil, err
+			}
+			jwk, err := jose.NewJWK(jwkKeyId(signDevice.Id, key.Version), publicKey)
+			if err != nil {
+				return nil, err
+			}
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set, nil
+}
+
+// DeviceJWK returns the current public key of a device as a JSON Web Key.
+func (s *SigningService) DeviceJWK(deviceId string) (*jose.JWK, error) {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	return jose.NewJWK(jwkKeyId(signDevice.Id, signDevice.KeyVersion), signDevice.KeyPair.PublicKey())
+}
+
+// jwkKeyId returns the key id of a device key in JWS headers and JWKs,
+// <device_id>#<key_version>.
+func jwkKeyId(deviceId string, keyVersion int) string {
+	return fmt.Sprintf("%s#%d", deviceId, keyVersion)
+}
+
+// signJWS encodes a signed transaction as a compact JWS signed by the device key.
+// The key id names the device and the key version the transaction was signed with.
+func signJWS(transaction *domain.Transaction, signDevice *domain.SignatureDevice, signer crypto.Signer) (string, error) {
+	algorithm, err := jose.Algorithm(signDevice.KeyPair.PublicKey())
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(transaction.JWSPayload())
+	if err != nil {
+		return "", err
+	}
+	return jose.Sign(signer, jose.Header{Algorithm: algorithm, KeyId: jwkKeyId(transaction.DeviceId, transaction.KeyVersion), Type: "JWT"}, payload)
+}
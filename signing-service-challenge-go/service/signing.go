@@ -0,0 +1,468 @@
+package service
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ca"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+var (
+	// ErrDeviceNotFound is returned if no device exists for the requested id.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrCounterConflict is returned if a transaction with the current signature
+	// counter of the device has already been stored, e.g. by a concurrent request.
+	ErrCounterConflict = errors.New("signature counter was already used")
+	// ErrDuplicateReference is returned if a unique reference was requested and
+	// another transaction of the device already carries it.
+	ErrDuplicateReference = errors.New("reference was already used by a transaction of the device")
+)
+
+// FieldError describes why the value of a single request field is invalid.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError is returned if a request is malformed.
+// Its message and field errors are safe to be shown to clients.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalidField(field string, reason string) *ValidationError {
+	return &ValidationError{
+		Message: field + " " + reason,
+		Fields:  []FieldError{{Field: field, Reason: reason}},
+	}
+}
+
+// SigningService implements the operations on signature devices shared by all APIs.
+type SigningService struct {
+	deviceStore            persistence.DeviceStore
+	transactionStore       persistence.TransactionStore
+	fiscalTransactionStore persistence.FiscalTransactionStore
+	clientStore            persistence.ClientStore
+	logStore               persistence.LogStore
+	clock                  Clock
+	timestampAuthority     tsa.Authority
+	timestampRoots         *x509.CertPool
+	checkpointKey          crypto.KeyPair
+	certificateAuthority   *ca.Authority
+}
+
+// NewSigningService is a factory to instantiate a new SigningService.
+func NewSigningService(deviceStore persistence.DeviceStore, transactionStore persistence.TransactionStore, fiscalTransactionStore persistence.FiscalTransactionStore, clientStore persistence.ClientStore, logStore persistence.LogStore, options ...Option) *SigningService {
+	s := &SigningService{
+		deviceStore:            deviceStore,
+		transactionStore:       transactionStore,
+		fiscalTransactionStore: fiscalTransactionStore,
+		clientStore:            clientStore,
+		logStore:               logStore,
+		clock:                  SystemClock,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// CreateDevice generates a new signature device and persists it. Its key is
+// certified by the internal CA, if any.
+// An empty envelope version selects domain.DefaultEnvelopeVersion.
+func (s *SigningService) CreateDevice(algorithm domain.SignatureAlgorithm, label string, envelopeVersion domain.EnvelopeVersion, metadata map[string]string) (*domain.SignatureDevice, error) {
+	errs := FieldErrors{}
+	ValidateDevice(label, envelopeVersion, metadata, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	signDevice, err := domain.NewSignatureDevice(algorithm, label)
+	if err != nil {
+		return nil, err
+	}
+	if envelopeVersion != "" {
+		signDevice.EnvelopeVersion = envelopeVersion
+	}
+	if len(metadata) > 0 {
+		signDevice.Metadata = metadata
+	}
+	if err := s.saveNewDevice(signDevice); err != nil {
+		return nil, err
+	}
+	return signDevice, nil
+}
+
+// saveNewDevice persists a new device and certifies its key by the internal CA, if any.
+func (s *SigningService) saveNewDevice(signDevice *domain.SignatureDevice) error {
+	s.deviceStore.Save(signDevice)
+	// the certificate binds the device id, which the store assigns
+	if s.certificateAuthority != nil {
+		chain, err := s.issueCACertificate(signDevice, signDevice.KeyPair.PublicKey())
+		if err != nil {
+			return err
+		}
+		if err := signDevice.SetCertificate(chain); err != nil {
+			return err
+		}
+		s.deviceStore.Save(signDevice)
+	}
+	return nil
+}
+
+// GetDevice returns the device with the given id.
+func (s *SigningService) GetDevice(deviceId string) (*domain.SignatureDevice, error) {
+	if !ValidId(deviceId) {
+		return nil, invalidField("device_id", invalidIdReason)
+	}
+	signDevice := s.deviceStore.GetById(deviceId)
+	if signDevice == nil {
+		return nil, ErrDeviceNotFound
+	}
+	return signDevice, nil
+}
+
+// ListDevices returns a page of the devices matching the query.
+func (s *SigningService) ListDevices(query persistence.DeviceQuery) (*persistence.DevicePage, error) {
+	if query.Sort != "" && !query.Sort.Valid() {
+		return nil, invalidField("sort", "must be created_at, -created_at, label or -label")
+	}
+	if query.Limit < 0 || query.Limit > persistence.MaxPageLimit {
+		return nil, invalidField("limit", fmt.Sprintf("must be between 1 and %d", persistence.MaxPageLimit))
+	}
+	page, err := s.deviceStore.List(query)
+	if errors.Is(err, persistence.ErrInvalidCursor) {
+		return nil, invalidField("cursor", "is invalid or was issued for another sort order")
+	}
+	return page, err
+}
+
+// SignOptions holds the optional attributes of a transaction to be signed.
+type SignOptions struct {
+	// Reference is stored with the transaction to find it by search.
+	Reference string
+	// UniqueReference rejects the transaction with ErrDuplicateReference if
+	// another transaction of the device carries the same reference.
+	UniqueReference bool
+	// Metadata is stored with the transaction.
+	Metadata map[string]domain.MetadataValue
+	// Format selects the representation of the signature, by default domain.FormatJSON.
+	Format domain.SignatureFormat
+}
+
+// SignTransaction signs data with a device on behalf of a client registered to it,
+// chaining it to the last signature of the device, and increments the signature counter.
+func (s *SigningService) SignTransaction(deviceId string, clientId string, data string, options SignOptions) (*domain.SignatureResponse, error) {
+	errs := FieldErrors{}
+	ValidateTransaction(deviceId, clientId, data, options, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if deviceId == "" || data == "" {
+		validationErr := &ValidationError{Message: "device_id and data_to_be_signed must not be empty"}
+		if deviceId == "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: "device_id", Reason: "must not be empty"})
+		}
+		if data == "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: "data_to_be_signed", Reason: "must not be empty"})
+		}
+		return nil, validationErr
+	}
+	if clientId == "" {
+		return nil, invalidField("client_id", "must not be empty")
+	}
+	if options.Format != "" && !options.Format.Valid() {
+		return nil, invalidField("format", "must be json, jws, cose or cms")
+	}
+	signDevice, signer, unlock, err := s.activeSigner(deviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if options.Format == domain.FormatCMS && signDevice.Certificate == "" {
+		return nil, ErrCertificateNotFound
+	}
+	if err := s.authorizeClient(clientId, deviceId); err != nil {
+		return nil, err
+	}
+	// sign data
+	transaction := &domain.Transaction{
+		DeviceId:  deviceId,
+		ClientId:  clientId,
+		Data:      data,
+		Reference: options.Reference,
+	}
+	if len(options.Metadata) > 0 {
+		transaction.Metadata = options.Metadata
+	}
+	resp, err := s.signData(transaction, signDevice, signer, options)
+	if err != nil {
+		return nil, err
+	}
+	// increment counter
+	s.deviceStore.IncrementCounter(deviceId)
+
+	return resp, nil
+}
+
+// activeSigner returns a device that may sign and a signer backed by its private key.
+// It locks the chain of the device, see domain.SignatureDevice.LockChain, and returns
+// the function releasing the lock unless an error is returned.
+func (s *SigningService) activeSigner(deviceId string) (*domain.SignatureDevice, crypto.Signer, func(), error) {
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	unlock := signDevice.LockChain()
+	if !signDevice.IsActive() {
+		unlock()
+		return nil, nil, nil, domain.ErrDeviceDeactivated
+	}
+	// get signer from device
+	signer, err := signDevice.Signer()
+	if err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	return signDevice, signer, unlock, nil
+}
+
+// signData chains, signs and countersigns a transaction and persists it. It runs
+// under the chain lock of the device, see activeSigner, so everything it waits for,
+// including the timestamp authority, delays the other operations on the device.
+func (s *SigningService) signData(transaction *domain.Transaction, signDevice *domain.SignatureDevice, signer crypto.Signer, options SignOptions) (*domain.SignatureResponse, error) {
+	// the timestamp is part of the v2 envelope, so it is taken before signing;
+	// UTC drops the monotonic reading, which does not survive persistence
+	transaction.SignedAt = s.clock.Now().UTC()
+	// chain to the last signature on device if any
+	deviceTransactions := s.transactionStore.GetByDevice(transaction.DeviceId)
+	if len(deviceTransactions) == 0 {
+		// use encoded device id, or the last signature of an imported chain
+		transaction.LastSignature = signDevice.ChainStart().LastSignature
+	} else {
+		// Sort by Counter in descending order (latest first)
+		sort.Slice(deviceTransactions, func(i, j int) bool {
+			return deviceTransactions[i].Counter > deviceTransactions[j].Counter
+		})
+		if deviceTransactions[0].Counter >= signDevice.Counter() {
+			return nil, ErrCounterConflict
+		}
+		// a clock going backwards would let a later signature claim an earlier time
+		if transaction.SignedAt.Before(deviceTransactions[0].SignedAt) {
+			return nil, ErrClockBehind
+		}
+		transaction.LastSignature = deviceTransactions[0].Signature
+	}
+	transaction.Counter = signDevice.Counter()
+	transaction.EnvelopeVersion = signDevice.EnvelopeVersion
+	transaction.KeyVersion = signDevice.KeyVersion
+	if transaction.EnvelopeVersion == "" {
+		transaction.EnvelopeVersion = domain.EnvelopeLegacy
+	}
+	securedData, err := transaction.SecuredData().Encode(transaction.EnvelopeVersion)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(securedData)
+	if err != nil {
+		return nil, err
+	}
+	transaction.Signature = base64.StdEncoding.EncodeToString(signature)
+	token, err := s.timestamp(signature)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		transaction.TimestampToken = base64.StdEncoding.EncodeToString(token)
+	}
+	// the JWS, COSE and CMS messages are signed before persisting, so that a failure does not consume the counter
+	var jws, coseMessage, cmsSignature string
+	switch options.Format {
+	case domain.FormatJWS:
+		if jws, err = signJWS(transaction, signDevice, signer); err != nil {
+			return nil, err
+		}
+	case domain.FormatCOSE:
+		if coseMessage, err = signCOSE(transaction, signDevice, signer); err != nil {
+			return nil, err
+		}
+	case domain.FormatCMS:
+		if cmsSignature, err = signCMS(transaction, securedData, signDevice, signer); err != nil {
+			return nil, err
+		}
+	}
+	// persist transaction
+	if options.UniqueReference {
+		if err := s.transactionStore.SaveWithUniqueReference(transaction); err != nil {
+			if errors.Is(err, persistence.ErrDuplicateReference) {
+				return nil, ErrDuplicateReference
+			}
+			return nil, err
+		}
+	} else {
+		s.transactionStore.Save(transaction)
+	}
+	// response
+	resp := &domain.SignatureResponse{
+		Signature:  transaction,
+		SignedData: string(securedData),
+		JWS:        jws,
+		COSE:       coseMessage,
+		CMS:        cmsSignature,
+	}
+	return resp, nil
+}
+
+// ListTransactions returns all transactions of a device in counter order.
+func (s *SigningService) ListTransactions(deviceId string) ([]*domain.Transaction, error) {
+	if _, err := s.GetDevice(deviceId); err != nil {
+		return nil, err
+	}
+	transactions := s.transactionStore.GetByDevice(deviceId)
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Counter < transactions[j].Counter
+	})
+	return transactions, nil
+}
+
+// SearchTransactions returns a page of the transactions of all devices matching
+// the query. If devices is not nil, only transactions of the devices matching it
+// are searched, in addition to the device ids of the query.
+func (s *SigningService) SearchTransactions(query persistence.TransactionQuery, devices *persistence.DeviceFilter) (*persistence.TransactionPage, error) {
+	filter := &query.Filter
+	if query.Sort != "" && !query.Sort.Valid() {
+		return nil, invalidField("sort", "must be signed_at or -signed_at")
+	}
+	if query.Limit < 0 || query.Limit > persistence.MaxPageLimit {
+		return nil, invalidField("limit", fmt.Sprintf("must be between 1 and %d", persistence.MaxPageLimit))
+	}
+	if filter.SignedFrom != nil && filter.SignedTo != nil && filter.SignedFrom.After(*filter.SignedTo) {
+		return nil, invalidField("signed_from", "must not be after signed_to")
+	}
+	if filter.CounterFrom != nil && *filter.CounterFrom < 0 {
+		return nil, invalidField("counter_from", "must not be negative")
+	}
+	if filter.CounterFrom != nil && filter.CounterTo != nil && *filter.CounterFrom > *filter.CounterTo {
+		return nil, invalidField("counter_from", "must not be greater than counter_to")
+	}
+
+	if devices != nil {
+		deviceIds, err := s.deviceIds(*devices)
+		if err != nil {
+			return nil, err
+		}
+		if filter.DeviceIds != nil {
+			selected := []string{}
+			for _, deviceId := range filter.DeviceIds {
+				if _, ok := deviceIds[deviceId]; ok {
+					selected = append(selected, deviceId)
+				}
+			}
+			filter.DeviceIds = selected
+		} else {
+			filter.DeviceIds = make([]string, 0, len(deviceIds))
+			for deviceId := range deviceIds {
+				filter.DeviceIds = append(filter.DeviceIds, deviceId)
+			}
+		}
+	}
+
+	page, err := s.transactionStore.Search(query)
+	if errors.Is(err, persistence.ErrInvalidCursor) {
+		return nil, invalidField("cursor", "is invalid or was issued for another sort order")
+	}
+	return page, err
+}
+
+// deviceIds returns the ids of all devices matching the filter.
+func (s *SigningService) deviceIds(filter persistence.DeviceFilter) (map[string]struct{}, error) {
+	deviceIds := map[string]struct{}{}
+	query := persistence.DeviceQuery{Filter: filter, Limit: persistence.MaxPageLimit}
+	for {
+		page, err := s.deviceStore.List(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range page.Devices {
+			deviceIds[device.Id] = struct{}{}
+		}
+		if page.NextCursor == "" {
+			return deviceIds, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// VerifyResult is the outcome of a signature verification.
+type VerifyResult struct {
+	Valid      bool
+	KeyVersion int
+	// TimestampedAt is the time of the timestamp token, set if a valid token was given.
+	TimestampedAt *time.Time
+}
+
+// VerifySignature checks a base64 encoded signature over signed data with a key of
+// the device. If keyVersion is nil, the current key of the device is used. If a
+// base64 encoded timestamp token is given, the result is only valid if the token
+// covers the signature as well.
+func (s *SigningService) VerifySignature(deviceId string, signedData string, signature string, timestampToken string, keyVersion *int) (*VerifyResult, error) {
+	if signedData == "" || signature == "" {
+		validationErr := &ValidationError{Message: "signed_data and signature must not be empty"}
+		if signedData == "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: "signed_data", Reason: "must not be empty"})
+		}
+		if signature == "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: "signature", Reason: "must not be empty"})
+		}
+		return nil, validationErr
+	}
+	decodedSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, invalidField("signature", "must be base64 encoded")
+	}
+	decodedToken, err := base64.StdEncoding.DecodeString(timestampToken)
+	if err != nil {
+		return nil, invalidField("timestamp_token", "must be base64 encoded")
+	}
+	signDevice, err := s.GetDevice(deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	version := signDevice.KeyVersion
+	if keyVersion != nil {
+		version = *keyVersion
+	}
+	verifier, err := signDevice.VerifierForKey(version)
+	if err != nil {
+		return nil, &ValidationError{
+			Message: err.Error(),
+			Fields:  []FieldError{{Field: "key_version", Reason: err.Error()}},
+		}
+	}
+	result := &VerifyResult{
+		Valid:      verifier.Verify([]byte(signedData), decodedSignature) == nil,
+		KeyVersion: version,
+	}
+	if result.Valid && len(decodedToken) > 0 {
+		token, err := s.VerifyTimestamp(decodedToken, decodedSignature)
+		if err != nil {
+			result.Valid = false
+		} else {
+			result.TimestampedAt = &token.GenTime
+		}
+	}
+	return result, nil
+}
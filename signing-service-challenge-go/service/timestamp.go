@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+// ErrTimestampUnavailable is returned if a signature cannot be countersigned by the
+// timestamp authority. Nothing is signed, so the request may be retried.
+var ErrTimestampUnavailable = errors.New("timestamp authority is unavailable")
+
+// TimestampTimeout bounds a request to the timestamp authority. The request is made
+// while the chain of the device is locked, so a slow authority would otherwise hold
+// up every transaction, rotation and deactivation of the device.
+const TimestampTimeout = 5 * time.Second
+
+// WithTimestampAuthority makes the SigningService countersign every signature with
+// an RFC 3161 timestamp token of authority. Tokens are verified against roots, a nil
+// pool uses the system roots.
+func WithTimestampAuthority(authority tsa.Authority, roots *x509.CertPool) Option {
+	return func(s *SigningService) {
+		s.timestampAuthority = authority
+		s.timestampRoots = roots
+	}
+}
+
+// VerifyTimestamp checks that a DER encoded timestamp token covers the signature and
+// was issued by an authority the SigningService trusts.
+func (s *SigningService) VerifyTimestamp(token []byte, signature []byte) (*tsa.Token, error) {
+	parsed, err := tsa.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Verify(signature, s.timestampRoots); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// timestamp requests a token over the signature from the timestamp authority, if any,
+// giving up after TimestampTimeout.
+func (s *SigningService) timestamp(signature []byte) ([]byte, error) {
+	if s.timestampAuthority == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TimestampTimeout)
+	defer cancel()
+	token, err := s.timestampAuthority.Timestamp(ctx, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTimestampUnavailable, err)
+	}
+	return token, nil
+}
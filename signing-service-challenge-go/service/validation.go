@@ -0,0 +1,188 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+const (
+	// MaxDataToBeSignedBytes bounds the data of a single transaction.
+	MaxDataToBeSignedBytes = 64 << 10
+	// MaxLabelLength bounds the label of a device in characters.
+	MaxLabelLength = 64
+	// MaxMetadataEntries bounds the number of metadata tags of a device.
+	MaxMetadataEntries = 16
+	// MaxMetadataValueLength bounds the value of a metadata tag in characters.
+	MaxMetadataValueLength = 256
+	// MaxReferenceLength bounds the client reference of a transaction in characters.
+	// References are printable ASCII, so each character is a single byte.
+	MaxReferenceLength = 128
+	// MaxProcessTypeLength bounds the process type of a fiscal transaction in characters.
+	// Process types are printable ASCII, so each character is a single byte.
+	MaxProcessTypeLength = 100
+	// MaxClientDevices bounds the number of devices a client is registered to at once.
+	MaxClientDevices = 16
+	// MaxLastSignatureLength bounds the base64 encoded last signature of an imported
+	// chain, leaving room for RSA signatures of up to 4096 bits from other providers.
+	MaxLastSignatureLength = 1024
+)
+
+// invalidIdReason is reported for device and client ids not matching idPattern.
+const invalidIdReason = "must consist of 1 to 64 letters, digits, _ or -"
+
+var (
+	// labels are shown in UIs, so they are restricted to letters, digits, spaces and common punctuation
+	labelPattern = regexp.MustCompile(`^[\p{L}\p{N} _.,:;/#()+-]*$`)
+	// device and client ids are generated as UUIDs, the format leaves room for ids assigned by other stores
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	// serial numbers are printed on receipts, so they are restricted to printable ASCII without spaces
+	serialNumberPattern = regexp.MustCompile(`^[\x21-\x7E]{1,64}$`)
+	// metadata keys double as query parameter values, so they are kept simple
+	metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	// references and process types are matched exactly, printable ASCII avoids lookalike characters
+	referencePattern = regexp.MustCompile(`^[\x20-\x7E]*$`)
+)
+
+// FieldErrors collects the invalid fields of a request, so that all of them are
+// reported at once.
+type FieldErrors []FieldError
+
+// Add records that the value of field is invalid for the given reason.
+func (f *FieldErrors) Add(field string, reason string) {
+	*f = append(*f, FieldError{Field: field, Reason: reason})
+}
+
+// Err returns a *ValidationError listing the collected fields, or nil if there are none.
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(f))
+	for _, fieldErr := range f {
+		messages = append(messages, fieldErr.Field+" "+fieldErr.Reason)
+	}
+	return &ValidationError{
+		Message: strings.Join(messages, "; "),
+		Fields:  f,
+	}
+}
+
+// ValidId reports whether id is a well-formed device or client id.
+func ValidId(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// ValidateId checks the format of a device or client id, if it is given.
+// Missing ids are reported by the operation requiring them.
+func ValidateId(field string, id string, errs *FieldErrors) {
+	if id != "" && !ValidId(id) {
+		errs.Add(field, invalidIdReason)
+	}
+}
+
+// ValidateDevice checks the label, envelope version and metadata of a new device.
+func ValidateDevice(label string, envelopeVersion domain.EnvelopeVersion, metadata map[string]string, errs *FieldErrors) {
+	if utf8.RuneCountInString(label) > MaxLabelLength {
+		errs.Add("label", fmt.Sprintf("must not be longer than %d characters", MaxLabelLength))
+	} else if !labelPattern.MatchString(label) {
+		errs.Add("label", "must only contain letters, digits, spaces and _.,:;/#()+-")
+	}
+	if envelopeVersion != "" && !envelopeVersion.Valid() {
+		errs.Add("envelope_version", "must be legacy, v1 or v2")
+	}
+	ValidateMetadata(metadata, errs)
+}
+
+// ValidateMetadata checks the number of tags and the format of their keys and values.
+func ValidateMetadata(metadata map[string]string, errs *FieldErrors) {
+	if len(metadata) > MaxMetadataEntries {
+		errs.Add("metadata", fmt.Sprintf("must not have more than %d entries", MaxMetadataEntries))
+		return
+	}
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			errs.Add("metadata."+key, "key must consist of 1 to 64 letters, digits, _, . or -")
+		} else if utf8.RuneCountInString(value) > MaxMetadataValueLength {
+			errs.Add("metadata."+key, fmt.Sprintf("must not be longer than %d characters", MaxMetadataValueLength))
+		}
+	}
+}
+
+// ValidateTransaction checks the format of the device and client id, the size of
+// the data, the reference and the metadata of a transaction to be signed.
+// Missing fields are reported by SigningService.SignTransaction.
+func ValidateTransaction(deviceId string, clientId string, data string, options SignOptions, errs *FieldErrors) {
+	ValidateId("device_id", deviceId, errs)
+	if len(data) > MaxDataToBeSignedBytes {
+		errs.Add("data_to_be_signed", fmt.Sprintf("must not be larger than %d bytes", MaxDataToBeSignedBytes))
+	}
+	ValidateId("client_id", clientId, errs)
+	ValidateReference("reference", options.Reference, errs)
+	if options.UniqueReference && options.Reference == "" {
+		errs.Add("reference", "must not be empty if unique_reference is set")
+	}
+	validateTransactionMetadata(options.Metadata, errs)
+}
+
+// ValidateReference checks the length and characters of a client reference.
+func ValidateReference(field string, reference string, errs *FieldErrors) {
+	if !referencePattern.MatchString(reference) {
+		errs.Add(field, "must only contain printable ASCII characters")
+	} else if len(reference) > MaxReferenceLength {
+		errs.Add(field, fmt.Sprintf("must not be longer than %d characters", MaxReferenceLength))
+	}
+}
+
+// validateTransactionMetadata checks the number of entries, the format of their
+// keys and the type and length of their values.
+func validateTransactionMetadata(metadata map[string]domain.MetadataValue, errs *FieldErrors) {
+	if len(metadata) > MaxMetadataEntries {
+		errs.Add("metadata", fmt.Sprintf("must not have more than %d entries", MaxMetadataEntries))
+		return
+	}
+	for key, value := range metadata {
+		switch {
+		case !metadataKeyPattern.MatchString(key):
+			errs.Add("metadata."+key, "key must consist of 1 to 64 letters, digits, _, . or -")
+		case !value.Valid():
+			errs.Add("metadata."+key, "must be a string, number or boolean")
+		case utf8.RuneCountInString(value.String()) > MaxMetadataValueLength:
+			errs.Add("metadata."+key, fmt.Sprintf("must not be longer than %d characters", MaxMetadataValueLength))
+		}
+	}
+}
+
+// ValidateFiscalProcess checks the client id, the process type and the size of the
+// process data of a fiscal transaction.
+func ValidateFiscalProcess(clientId string, process FiscalProcess, errs *FieldErrors) {
+	ValidateId("client_id", clientId, errs)
+	if !referencePattern.MatchString(process.ProcessType) {
+		errs.Add("process_type", "must only contain printable ASCII characters")
+	} else if len(process.ProcessType) > MaxProcessTypeLength {
+		errs.Add("process_type", fmt.Sprintf("must not be longer than %d characters", MaxProcessTypeLength))
+	}
+	if len(process.ProcessData) > MaxDataToBeSignedBytes {
+		errs.Add("process_data", fmt.Sprintf("must not be larger than %d bytes", MaxDataToBeSignedBytes))
+	}
+}
+
+// ValidateClient checks the serial number and the device ids of a new client.
+// Missing fields are reported by SigningService.RegisterClient.
+func ValidateClient(serialNumber string, deviceIds []string, errs *FieldErrors) {
+	if serialNumber != "" && !serialNumberPattern.MatchString(serialNumber) {
+		errs.Add("serial_number", "must consist of 1 to 64 printable ASCII characters without spaces")
+	}
+	if len(deviceIds) > MaxClientDevices {
+		errs.Add("device_ids", fmt.Sprintf("must not have more than %d entries", MaxClientDevices))
+	}
+	for _, deviceId := range deviceIds {
+		if !ValidId(deviceId) {
+			errs.Add("device_ids", invalidIdReason)
+			break
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// RequestContentType is the media type of a DER encoded timestamp request.
+	RequestContentType = "application/timestamp-query"
+	// ResponseContentType is the media type of a DER encoded timestamp response.
+	ResponseContentType = "application/timestamp-reply"
+)
+
+// maxResponseSize limits the size of a response read from an authority.
+const maxResponseSize = 1 << 20
+
+// PKIStatus values of a timestamp response.
+const (
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+	statusRejection       = 2
+)
+
+// PKIFailureInfo bits of a rejected timestamp request.
+const (
+	failureBadAlg              = 0
+	failureBadRequest          = 2
+	failureBadDataFormat       = 5
+	failureUnacceptedPolicy    = 15
+	failureUnacceptedExtension = 16
+	failureSystemFailure       = 25
+)
+
+// ErrUnavailable is returned if the authority cannot be reached or fails to issue a token.
+var ErrUnavailable = errors.New("timestamp authority is unavailable")
+
+// Authority issues timestamp tokens.
+type Authority interface {
+	// Timestamp returns a DER encoded timestamp token over the SHA-256 digest of data.
+	// The request is abandoned once ctx is done.
+	Timestamp(ctx context.Context, data []byte) ([]byte, error)
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status int
+	// StatusString holds UTF8Strings, which a []string would not be encoded as
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// HTTPAuthority requests timestamp tokens from an external authority over HTTP as
+// described in section 3.4 of RFC 3161.
+type HTTPAuthority struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAuthority is a factory to instantiate an HTTPAuthority for the given URL.
+func NewHTTPAuthority(url string) *HTTPAuthority {
+	return &HTTPAuthority{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Timestamp requests a token over data, including the certificate of the authority.
+func (a *HTTPAuthority) Timestamp(ctx context.Context, data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	request, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: newMessageImprint(data),
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", RequestContentType)
+	response, err := a.client.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrUnavailable, response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	der, err := parseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token must answer this request, not a replayed one
+	token, err := ParseToken(der)
+	if err != nil {
+		return nil, err
+	}
+	if token.Nonce == nil || token.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("%w: nonce does not match the request", ErrInvalidToken)
+	}
+	if err := token.verifyImprint(data); err != nil {
+		return nil, err
+	}
+	return der, nil
+}
+
+// parseResponse returns the token of a granted timestamp response.
+func parseResponse(der []byte) ([]byte, error) {
+	resp := timeStampResp{}
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: malformed timestamp response", ErrUnavailable)
+	}
+	if resp.Status.Status != statusGranted && resp.Status.Status != statusGrantedWithMods {
+		reasons := make([]string, 0, len(resp.Status.StatusString))
+		for _, reason := range resp.Status.StatusString {
+			reasons = append(reasons, string(reason.Bytes))
+		}
+		return nil, fmt.Errorf("%w: request rejected with status %d: %s", ErrUnavailable, resp.Status.Status, strings.Join(reasons, "; "))
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("%w: response holds no token", ErrUnavailable)
+	}
+	return resp.TimeStampToken.FullBytes, nil
+}
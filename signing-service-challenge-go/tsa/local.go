@@ -0,0 +1,239 @@
+package tsa
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// DefaultPolicy is the policy under which a LocalAuthority issues its tokens. It
+// lies below the enterprise number RFC 5612 reserves for documentation.
+var DefaultPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 3161, 1}
+
+var (
+	oidExtKeyUsage          = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageTimeStamp = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+	// serialNumberLimit bounds the random serial numbers of certificates and tokens.
+	serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 127)
+)
+
+// LocalAuthority issues timestamp tokens in process, e.g. for tests and air-gapped
+// deployments. Its key and self-signed certificate live in memory only.
+type LocalAuthority struct {
+	key         *ecdsa.PrivateKey
+	certificate *x509.Certificate
+	now         func() time.Time
+}
+
+// NewLocalAuthority is a factory to instantiate a LocalAuthority with a fresh
+// P-256 key and a self-signed certificate restricted to time stamping.
+func NewLocalAuthority() (*LocalAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 3161 requires the extended key usage to be critical, which x509 does not do by itself
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimeStamp})
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().UTC().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "Signing Service Local Timestamp Authority"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		ExtraExtensions:       []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: extKeyUsage}},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalAuthority{key: key, certificate: certificate, now: time.Now}, nil
+}
+
+// Certificate returns the self-signed certificate of the authority.
+func (a *LocalAuthority) Certificate() *x509.Certificate {
+	return a.certificate
+}
+
+// Roots returns a pool holding the certificate of the authority, to verify its tokens with.
+func (a *LocalAuthority) Roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(a.certificate)
+	return roots
+}
+
+// Timestamp issues a token over data, including the certificate of the authority.
+// The token is issued in process, so ctx is not consulted.
+func (a *LocalAuthority) Timestamp(_ context.Context, data []byte) ([]byte, error) {
+	return a.issue(newMessageImprint(data), nil, true)
+}
+
+// Respond answers a DER encoded timestamp request with a DER encoded timestamp
+// response. Requests the authority cannot serve are rejected within the response.
+func (a *LocalAuthority) Respond(request []byte) ([]byte, error) {
+	req := timeStampReq{}
+	if rest, err := asn1.Unmarshal(request, &req); err != nil || len(rest) > 0 {
+		return rejection(failureBadDataFormat, "malformed request")
+	}
+	if req.Version != 1 {
+		return rejection(failureBadRequest, "unsupported version")
+	}
+	hash, ok := hashes[req.MessageImprint.HashAlgorithm.Algorithm.String()]
+	if !ok || len(req.MessageImprint.HashedMessage) != hash.Size() {
+		return rejection(failureBadAlg, "unsupported message imprint")
+	}
+	if len(req.ReqPolicy) > 0 && !req.ReqPolicy.Equal(DefaultPolicy) {
+		return rejection(failureUnacceptedPolicy, "unsupported policy")
+	}
+	if len(req.Extensions) > 0 {
+		return rejection(failureUnacceptedExtension, "extensions are not supported")
+	}
+
+	token, err := a.issue(req.MessageImprint, req.Nonce, req.CertReq)
+	if err != nil {
+		return rejection(failureSystemFailure, "cannot issue token")
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// ServeHTTP implements the HTTP transport of RFC 3161 on top of Respond.
+func (a *LocalAuthority) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		response.Header().Set("Allow", http.MethodPost)
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if request.Header.Get("Content-Type") != RequestContentType {
+		http.Error(response, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxResponseSize))
+	if err != nil {
+		http.Error(response, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	reply, err := a.Respond(body)
+	if err != nil {
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	response.Header().Set("Content-Type", ResponseContentType)
+	_, _ = response.Write(reply)
+}
+
+// issue builds a CMS signed TSTInfo over the message imprint, as defined in section 2.4.2 of RFC 3161.
+func (a *LocalAuthority) issue(imprint messageImprint, nonce *big.Int, includeCertificate bool) ([]byte, error) {
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, err
+	}
+	content, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         DefaultPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   serialNumber,
+		// GeneralizedTime is encoded with whole seconds, which the accuracy accounts for
+		GenTime:  a.now().UTC(),
+		Accuracy: accuracy{Seconds: 1},
+		Nonce:    nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentDigest := sha256.Sum256(content)
+	certificateDigest := sha256.Sum256(a.certificate.Raw)
+	attributes := []attribute{}
+	for _, attr := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidContentType, oidTSTInfo},
+		{oidMessageDigest, contentDigest[:]},
+		{oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certificateDigest[:]}}}},
+	} {
+		value, err := asn1.Marshal(attr.value)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute{Type: attr.oid, Values: []asn1.RawValue{{FullBytes: value}}})
+	}
+	signedAttrs, err := asn1.MarshalWithParams(attributes, "set")
+	if err != nil {
+		return nil, err
+	}
+	signedAttrsDigest := sha256.Sum256(signedAttrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, signedAttrsDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	signed := signedData{
+		// version 3 because the content is not of type id-data
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Algorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: content},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			Sid: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: a.certificate.RawIssuer},
+				SerialNumber: a.certificate.SerialNumber,
+			},
+			DigestAlgorithm: sha256Algorithm,
+			// the signed attributes are embedded with an implicit [0] instead of the SET OF tag
+			SignedAttrs:        asn1.RawValue{FullBytes: append([]byte{0xa0}, signedAttrs[1:]...)},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	}
+	if includeCertificate {
+		signed.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: a.certificate.Raw}
+	}
+	signedDER, err := asn1.Marshal(signed)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedDER},
+	})
+}
+
+// rejection encodes a timestamp response rejecting the request with a PKIFailureInfo bit.
+func rejection(failure int, reason string) ([]byte, error) {
+	failInfo := asn1.BitString{Bytes: make([]byte, failure/8+1), BitLength: failure + 1}
+	failInfo.Bytes[failure/8] |= 0x80 >> (failure % 8)
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status:       statusRejection,
+			StatusString: []asn1.RawValue{{Tag: asn1.TagUTF8String, Bytes: []byte(reason)}},
+			FailInfo:     failInfo,
+		},
+	})
+}
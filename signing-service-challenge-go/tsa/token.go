@@ -0,0 +1,343 @@
+// Package tsa requests, issues and verifies RFC 3161 timestamp tokens.
+package tsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	// register the hash functions a timestamp token may use
+	_ "crypto/sha512"
+)
+
+var (
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidPublicKeyRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSHA256WithRSA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECDSAWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	hashes              = map[string]crypto.Hash{oidSHA256.String(): crypto.SHA256, oidSHA384.String(): crypto.SHA384, oidSHA512.String(): crypto.SHA512}
+	signatureAlgorithms = map[string]map[crypto.Hash]x509.SignatureAlgorithm{
+		oidPublicKeyRSA.String():    {crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA},
+		oidPublicKeyECDSA.String():  {crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512},
+		oidSHA256WithRSA.String():   {crypto.SHA256: x509.SHA256WithRSA},
+		oidSHA384WithRSA.String():   {crypto.SHA384: x509.SHA384WithRSA},
+		oidSHA512WithRSA.String():   {crypto.SHA512: x509.SHA512WithRSA},
+		oidECDSAWithSHA256.String(): {crypto.SHA256: x509.ECDSAWithSHA256},
+		oidECDSAWithSHA384.String(): {crypto.SHA384: x509.ECDSAWithSHA384},
+		oidECDSAWithSHA512.String(): {crypto.SHA512: x509.ECDSAWithSHA512},
+	}
+)
+
+// ErrInvalidToken is returned if a timestamp token is malformed or does not
+// cover the data it is verified against.
+var ErrInvalidToken = errors.New("invalid timestamp token")
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	Sid                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// Token is a parsed RFC 3161 timestamp token.
+type Token struct {
+	// Raw is the DER encoded token.
+	Raw []byte
+	// GenTime is the time at which the authority issued the token.
+	GenTime time.Time
+	// Accuracy bounds the deviation of GenTime from UTC, it is zero if unknown.
+	Accuracy time.Duration
+	// SerialNumber is unique among the tokens issued by the authority.
+	SerialNumber *big.Int
+	// Policy identifies the policy under which the token was issued.
+	Policy asn1.ObjectIdentifier
+	// Nonce echoes the nonce of the request, if any.
+	Nonce *big.Int
+	// HashAlgorithm and HashedMessage form the message imprint, the digest of the timestamped data.
+	HashAlgorithm crypto.Hash
+	HashedMessage []byte
+	// Certificates holds the certificates embedded in the token, starting with the one of the signer.
+	Certificates []*x509.Certificate
+
+	content     []byte
+	contentType asn1.ObjectIdentifier
+	signer      signerInfo
+}
+
+// ParseToken decodes a DER encoded timestamp token. It does not verify it.
+func ParseToken(der []byte) (*Token, error) {
+	info := contentInfo{}
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: not a CMS content info", ErrInvalidToken)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content is not signed data", ErrInvalidToken)
+	}
+	signed := signedData{}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return nil, fmt.Errorf("%w: malformed signed data: %v", ErrInvalidToken, err)
+	}
+	if len(signed.SignerInfos) != 1 {
+		return nil, fmt.Errorf("%w: expected one signer, got %d", ErrInvalidToken, len(signed.SignerInfos))
+	}
+	if !signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("%w: content is not a TSTInfo", ErrInvalidToken)
+	}
+	tst := tstInfo{}
+	if rest, err := asn1.Unmarshal(signed.EncapContentInfo.EContent, &tst); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: malformed TSTInfo", ErrInvalidToken)
+	}
+	hash, ok := hashes[tst.MessageImprint.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported message imprint algorithm %s", ErrInvalidToken, tst.MessageImprint.HashAlgorithm.Algorithm)
+	}
+
+	token := &Token{
+		Raw:           der,
+		GenTime:       tst.GenTime,
+		Accuracy:      time.Duration(tst.Accuracy.Seconds)*time.Second + time.Duration(tst.Accuracy.Millis)*time.Millisecond + time.Duration(tst.Accuracy.Micros)*time.Microsecond,
+		SerialNumber:  tst.SerialNumber,
+		Policy:        tst.Policy,
+		Nonce:         tst.Nonce,
+		HashAlgorithm: hash,
+		HashedMessage: tst.MessageImprint.HashedMessage,
+		content:       signed.EncapContentInfo.EContent,
+		contentType:   signed.EncapContentInfo.EContentType,
+		signer:        signed.SignerInfos[0],
+	}
+	if len(signed.Certificates.Bytes) > 0 {
+		certificates, err := x509.ParseCertificates(signed.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed certificate: %v", ErrInvalidToken, err)
+		}
+		// the signer goes first, the order of the others is kept
+		for i, certificate := range certificates {
+			if token.signedBy(certificate) {
+				certificates[0], certificates[i] = certificates[i], certificates[0]
+				break
+			}
+		}
+		token.Certificates = certificates
+	}
+	return token, nil
+}
+
+// Verify checks that the token covers data, that it is signed by the certificate
+// embedded first in the token and that this certificate is valid for time stamping
+// and chains up to roots at the time the token was issued. A nil roots pool
+// uses the system roots.
+func (t *Token) Verify(data []byte, roots *x509.CertPool) error {
+	if err := t.verifyImprint(data); err != nil {
+		return err
+	}
+	if len(t.Certificates) == 0 || !t.signedBy(t.Certificates[0]) {
+		return fmt.Errorf("%w: certificate of the signer is missing", ErrInvalidToken)
+	}
+	certificate := t.Certificates[0]
+	if err := t.verifySignature(certificate); err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range t.Certificates[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+func (t *Token) verifyImprint(data []byte) error {
+	if !t.HashAlgorithm.Available() {
+		return fmt.Errorf("%w: message imprint algorithm is not available", ErrInvalidToken)
+	}
+	digest := t.HashAlgorithm.New()
+	digest.Write(data)
+	if !bytes.Equal(digest.Sum(nil), t.HashedMessage) {
+		return fmt.Errorf("%w: message imprint does not match the data", ErrInvalidToken)
+	}
+	return nil
+}
+
+func (t *Token) signedBy(certificate *x509.Certificate) bool {
+	return bytes.Equal(certificate.RawIssuer, t.signer.Sid.Issuer.FullBytes) &&
+		certificate.SerialNumber.Cmp(t.signer.Sid.SerialNumber) == 0
+}
+
+// verifySignature checks the signed attributes of the signer against the content
+// and the certificate, and their signature with the key of the certificate.
+func (t *Token) verifySignature(certificate *x509.Certificate) error {
+	if len(t.signer.SignedAttrs.Bytes) == 0 {
+		return fmt.Errorf("%w: signed attributes are missing", ErrInvalidToken)
+	}
+	hash, ok := hashes[t.signer.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("%w: unsupported digest algorithm %s", ErrInvalidToken, t.signer.DigestAlgorithm.Algorithm)
+	}
+	algorithm, ok := signatureAlgorithms[t.signer.SignatureAlgorithm.Algorithm.String()][hash]
+	if !ok {
+		return fmt.Errorf("%w: unsupported signature algorithm %s", ErrInvalidToken, t.signer.SignatureAlgorithm.Algorithm)
+	}
+
+	// the signature covers the DER encoding of the attributes as an explicit SET OF
+	signedAttrs := append([]byte{0x31}, t.signer.SignedAttrs.FullBytes[1:]...)
+	attributes := []attribute{}
+	if _, err := asn1.UnmarshalWithParams(signedAttrs, &attributes, "set"); err != nil {
+		return fmt.Errorf("%w: malformed signed attributes", ErrInvalidToken)
+	}
+	var contentType asn1.ObjectIdentifier
+	var messageDigest []byte
+	var signingCertificate *signingCertificateV2
+	for _, attribute := range attributes {
+		if len(attribute.Values) != 1 {
+			return fmt.Errorf("%w: attribute %s must have a single value", ErrInvalidToken, attribute.Type)
+		}
+		value := attribute.Values[0].FullBytes
+		var err error
+		switch {
+		case attribute.Type.Equal(oidContentType):
+			_, err = asn1.Unmarshal(value, &contentType)
+		case attribute.Type.Equal(oidMessageDigest):
+			_, err = asn1.Unmarshal(value, &messageDigest)
+		case attribute.Type.Equal(oidSigningCertificateV2):
+			signingCertificate = &signingCertificateV2{}
+			_, err = asn1.Unmarshal(value, signingCertificate)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: malformed attribute %s", ErrInvalidToken, attribute.Type)
+		}
+	}
+	if !contentType.Equal(t.contentType) {
+		return fmt.Errorf("%w: content type attribute does not match the content", ErrInvalidToken)
+	}
+	digest := hash.New()
+	digest.Write(t.content)
+	if !bytes.Equal(digest.Sum(nil), messageDigest) {
+		return fmt.Errorf("%w: message digest attribute does not match the content", ErrInvalidToken)
+	}
+	if signingCertificate != nil {
+		if len(signingCertificate.Certs) == 0 {
+			return fmt.Errorf("%w: signing certificate attribute is empty", ErrInvalidToken)
+		}
+		// the first entry names the signing certificate, its hash defaults to SHA-256
+		certID := signingCertificate.Certs[0]
+		certHash := crypto.SHA256
+		if len(certID.HashAlgorithm.Algorithm) > 0 {
+			if certHash, ok = hashes[certID.HashAlgorithm.Algorithm.String()]; !ok {
+				return fmt.Errorf("%w: unsupported certificate hash algorithm %s", ErrInvalidToken, certID.HashAlgorithm.Algorithm)
+			}
+		}
+		digest := certHash.New()
+		digest.Write(certificate.Raw)
+		if !bytes.Equal(digest.Sum(nil), certID.CertHash) {
+			return fmt.Errorf("%w: signing certificate attribute does not match the certificate", ErrInvalidToken)
+		}
+	}
+
+	if err := certificate.CheckSignature(algorithm, signedAttrs, t.signer.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// newMessageImprint returns the SHA-256 message imprint of data.
+func newMessageImprint(data []byte) messageImprint {
+	digest := sha256.Sum256(data)
+	return messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		HashedMessage: digest[:],
+	}
+}
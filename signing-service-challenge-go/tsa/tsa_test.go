@@ -0,0 +1,168 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"encoding/asn1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuthority(t *testing.T) *LocalAuthority {
+	authority, err := NewLocalAuthority()
+	if err != nil {
+		t.Fatalf("Could not create authority: %v", err)
+	}
+	return authority
+}
+
+func Test_LocalAuthority_TimestampVerifies(t *testing.T) {
+	authority := newTestAuthority(t)
+	before := time.Now().Truncate(time.Second)
+
+	der, err := authority.Timestamp(context.Background(), []byte("signature"))
+	if err != nil {
+		t.Fatalf("Could not timestamp: %v", err)
+	}
+	token, err := ParseToken(der)
+	if err != nil {
+		t.Fatalf("Could not parse token: %v", err)
+	}
+
+	assert.NoError(t, token.Verify([]byte("signature"), authority.Roots()))
+	assert.False(t, token.GenTime.Before(before))
+	assert.Equal(t, time.Second, token.Accuracy)
+	assert.True(t, token.Policy.Equal(DefaultPolicy))
+	assert.Equal(t, authority.Certificate().Raw, token.Certificates[0].Raw)
+	// the critical extended key usage is understood by x509
+	assert.Empty(t, authority.Certificate().UnhandledCriticalExtensions)
+}
+
+func Test_Token_VerifyRejects(t *testing.T) {
+	authority := newTestAuthority(t)
+	der, err := authority.Timestamp(context.Background(), []byte("signature"))
+	if err != nil {
+		t.Fatalf("Could not timestamp: %v", err)
+	}
+	token, err := ParseToken(der)
+	if err != nil {
+		t.Fatalf("Could not parse token: %v", err)
+	}
+
+	// other data
+	assert.ErrorIs(t, token.Verify([]byte("other"), authority.Roots()), ErrInvalidToken)
+	// another authority
+	assert.ErrorIs(t, token.Verify([]byte("signature"), newTestAuthority(t).Roots()), ErrInvalidToken)
+	// system roots do not know the local authority
+	assert.ErrorIs(t, token.Verify([]byte("signature"), nil), ErrInvalidToken)
+
+	// tampered content
+	tampered := bytes.Replace(der, token.HashedMessage, bytes.Repeat([]byte{0}, len(token.HashedMessage)), 1)
+	tamperedToken, err := ParseToken(tampered)
+	if assert.NoError(t, err) {
+		assert.ErrorIs(t, tamperedToken.Verify([]byte("signature"), authority.Roots()), ErrInvalidToken)
+	}
+
+	_, err = ParseToken([]byte("not a token"))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func Test_HTTPAuthority_LocalAuthority(t *testing.T) {
+	authority := newTestAuthority(t)
+	server := httptest.NewServer(authority)
+	defer server.Close()
+
+	der, err := NewHTTPAuthority(server.URL).Timestamp(context.Background(), []byte("signature"))
+	if err != nil {
+		t.Fatalf("Could not timestamp: %v", err)
+	}
+	token, err := ParseToken(der)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, token.Nonce)
+		assert.NoError(t, token.Verify([]byte("signature"), authority.Roots()))
+	}
+
+	// without a certificate in the token, the signer cannot be verified
+	request, err := asn1.Marshal(timeStampReq{Version: 1, MessageImprint: newMessageImprint([]byte("signature"))})
+	if err != nil {
+		t.Fatalf("Could not marshal request: %v", err)
+	}
+	reply, err := authority.Respond(request)
+	assert.NoError(t, err)
+	der, err = parseResponse(reply)
+	if assert.NoError(t, err) {
+		token, err = ParseToken(der)
+		if assert.NoError(t, err) {
+			assert.Empty(t, token.Certificates)
+			assert.ErrorIs(t, token.Verify([]byte("signature"), authority.Roots()), ErrInvalidToken)
+		}
+	}
+}
+
+func Test_LocalAuthority_RejectsRequests(t *testing.T) {
+	authority := newTestAuthority(t)
+	server := httptest.NewServer(authority)
+	defer server.Close()
+
+	response, err := http.Post(server.URL, RequestContentType, bytes.NewBufferString("garbage"))
+	if err != nil {
+		t.Fatalf("Could not post request: %v", err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, ResponseContentType, response.Header.Get("Content-Type"))
+
+	for _, req := range []timeStampReq{
+		{Version: 2, MessageImprint: newMessageImprint([]byte("data"))},
+		{Version: 1, MessageImprint: messageImprint{HashAlgorithm: newMessageImprint(nil).HashAlgorithm, HashedMessage: []byte("short")}},
+		{Version: 1, MessageImprint: newMessageImprint([]byte("data")), ReqPolicy: asn1.ObjectIdentifier{1, 2, 3}},
+	} {
+		request, err := asn1.Marshal(req)
+		if err != nil {
+			t.Fatalf("Could not marshal request: %v", err)
+		}
+		reply, err := authority.Respond(request)
+		assert.NoError(t, err)
+		_, err = parseResponse(reply)
+		assert.ErrorIs(t, err, ErrUnavailable)
+	}
+
+	response, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Could not get: %v", err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func Test_HTTPAuthority_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPAuthority(server.URL).Timestamp(context.Background(), []byte("signature"))
+
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func Test_HTTPAuthority_Deadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewHTTPAuthority(server.URL).Timestamp(ctx, []byte("signature"))
+
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Less(t, time.Since(start), time.Second)
+}